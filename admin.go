@@ -0,0 +1,42 @@
+package vertigo
+
+import "fmt"
+
+// Returns the PID of the server process of this connection, as reported when the
+// connection was opened. It is zero if the connection is not open.
+func (c *Connection) BackendPID() uint32 {
+	return c.backendPid
+}
+
+// Returns the secret key of the server process of this connection, which is needed
+// to cancel its statements with CancelBackend. It is zero if the connection is not open.
+func (c *Connection) BackendKey() uint32 {
+	return c.backendKey
+}
+
+// Asks the server at address to cancel the statement running in the server process
+// identified by pid and key. See Connection.BackendPID and Connection.BackendKey.
+func CancelBackend(address string, pid, key uint32) error {
+	return sendCancelRequest(nil, address, pid, key)
+}
+
+// Returns the ID Vertica uses for the session of this connection, as found in the
+// v_monitor.sessions system table.
+func (c *Connection) SessionID() (string, error) {
+	return QueryValue[string](c, "SELECT session_id FROM v_monitor.current_session")
+}
+
+// Closes the session with the given ID with CLOSE_SESSION, rolling back its open
+// transaction. The statement is run on a second connection opened with the same
+// configuration, so this can be used while this connection is busy; the user needs
+// superuser privileges to close the sessions of other users.
+func (c *Connection) KillSession(sessionID string) error {
+	admin, err := Connect(c.config)
+	if err != nil {
+		return fmt.Errorf("Cannot open a connection to close session %s: %w", sessionID, err)
+	}
+	defer admin.Close()
+
+	_, err = admin.Exec("SELECT CLOSE_SESSION(?)", sessionID)
+	return err
+}
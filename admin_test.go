@@ -0,0 +1,43 @@
+package vertigo
+
+import (
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestKillSession(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT session_id FROM v_monitor.current_session").
+		Columns(vertigotest.Column{Name: "session_id"}).
+		Row("v_vmart_node0001-4017:0x1f")
+	server.Expect("SELECT CLOSE_SESSION('v_vmart_node0001-4017:0x1f')").
+		Columns(vertigotest.Column{Name: "CLOSE_SESSION"}).
+		Row("Session close command sent")
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if connection.BackendPID() == 0 || connection.BackendKey() == 0 {
+		t.Fatalf("Expected the backend PID and key to be set, but found %d and %d", connection.BackendPID(), connection.BackendKey())
+	}
+
+	sessionID, err := connection.SessionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := connection.KillSession(sessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CancelBackend(server.Addr(), connection.BackendPID(), connection.BackendKey()); err != nil {
+		t.Fatal(err)
+	}
+}
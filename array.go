@@ -0,0 +1,116 @@
+package vertigo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Offsets of the OIDs of the ARRAY and SET types from the OIDs of their element types.
+const (
+	arrayTypeOffset = 1500
+	setTypeOffset   = 2700
+)
+
+// Returns the data type OID of the elements of an ARRAY or SET type, or false if
+// the OID isn't one of a one-dimensional ARRAY or SET of a built-in type.
+func collectionElementType(oid uint32) (uint32, bool) {
+	for _, offset := range []uint32{arrayTypeOffset, setTypeOffset} {
+		if oid > offset && oid < offset+200 {
+			return oid - offset, true
+		}
+	}
+	return 0, false
+}
+
+// Returns the field describing the elements of an ARRAY or SET field, or nil if
+// the element type isn't known. The type modifier of the collection applies to
+// its elements.
+func elementField(field *Field) *Field {
+	if field == nil || field.DataTypeOID == DataTypeArray {
+		return nil
+	}
+
+	element := *field
+	if oid, ok := collectionElementType(field.DataTypeOID); ok {
+		element.DataTypeOID = oid
+	}
+	return &element
+}
+
+// Splits an ARRAY or SET value in text format, like [1,null,3] or ["a","b"],
+// into the text representation of its elements. NULL elements are nil, and
+// nested arrays and ROW values are returned in their JSON text representation.
+func splitCollection(src []byte) ([][]byte, error) {
+	var raw []json.RawMessage
+	err := json.Unmarshal(src, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid array %q", src)
+	}
+
+	elements := make([][]byte, len(raw))
+	for i, element := range raw {
+		if elements[i], err = jsonMemberText(element); err != nil {
+			return nil, fmt.Errorf("Invalid array %q", src)
+		}
+	}
+	return elements, nil
+}
+
+// Decodes an ARRAY or SET value into a slice with the decoded values of its elements.
+func decodeCollection(field Field, src []byte) ([]interface{}, error) {
+	elements, err := splitCollection(src)
+	if err != nil {
+		return nil, err
+	}
+
+	element := elementField(&field)
+	values := make([]interface{}, len(elements))
+	for i, src := range elements {
+		if values[i], err = decodeValue(*element, src); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Converts an ARRAY or SET value into the slice pointed at by value, converting
+// every element into the element type of the slice.
+func convertCollection(field *Field, src []byte, value reflect.Value) error {
+	elements, err := splitCollection(src)
+	if err != nil {
+		return err
+	}
+
+	element := elementField(field)
+	slice := reflect.MakeSlice(value.Type(), len(elements), len(elements))
+	for i, src := range elements {
+		if err := convertValue(element, src, slice.Index(i).Addr().Interface()); err != nil {
+			return fmt.Errorf("Cannot convert array element %d: %w", i, err)
+		}
+	}
+	value.Set(slice)
+	return nil
+}
+
+// Returns an ARRAY literal for a slice or array, or false if v is neither.
+func quoteArray(v interface{}) (string, bool, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return "", false, nil
+	}
+	if value.Kind() == reflect.Slice && value.IsNil() {
+		return "NULL", true, nil
+	}
+
+	literals := make([]string, value.Len())
+	for i := range literals {
+		literal, err := QuoteLiteral(value.Index(i).Interface())
+		if err != nil {
+			return "", true, err
+		}
+		literals[i] = literal
+	}
+	return "ARRAY[" + strings.Join(literals, ",") + "]", true, nil
+}
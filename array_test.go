@@ -0,0 +1,88 @@
+package vertigo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeArray(t *testing.T) {
+	value, err := decodeValue(Field{DataTypeOID: arrayTypeOffset + DataTypeInteger}, []byte("[1,null,3]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []interface{}{int64(1), nil, int64(3)}
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("Expected %#v, but found %#v", expected, value)
+	}
+
+	value, err = decodeValue(Field{DataTypeOID: setTypeOffset + DataTypeVarchar}, []byte(`["a","b\"c"]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []interface{}{"a", `b"c`}
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("Expected %#v, but found %#v", expected, value)
+	}
+
+	if _, err := decodeValue(Field{DataTypeOID: arrayTypeOffset + DataTypeInteger}, []byte("[[1]]")); err == nil {
+		t.Fatal("Expected an error for a nested array")
+	}
+}
+
+func TestRowScanArray(t *testing.T) {
+	fields := []Field{
+		{DataTypeOID: arrayTypeOffset + DataTypeInteger},
+		{DataTypeOID: arrayTypeOffset + DataTypeVarchar},
+		{DataTypeOID: setTypeOffset + DataTypeDate},
+	}
+	row := Row{Values: [][]byte{[]byte("[1,2]"), []byte(`["a",null]`), []byte(`["2020-01-02"]`)}, fields: fields}
+
+	var (
+		ints    []int32
+		strings []*string
+		dates   []Nullable[string]
+	)
+	if err := row.Scan(&ints, &strings, &dates); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(ints, []int32{1, 2}) {
+		t.Errorf("Unexpected integers %v", ints)
+	}
+	if len(strings) != 2 || *strings[0] != "a" || strings[1] != nil {
+		t.Errorf("Unexpected strings %v", strings)
+	}
+	if len(dates) != 1 || dates[0].Value != "2020-01-02" {
+		t.Errorf("Unexpected dates %v", dates)
+	}
+
+	var notArray []int
+	if err := (Row{Values: [][]byte{[]byte("1")}, fields: []Field{{DataTypeOID: DataTypeInteger}}}).Scan(&notArray); err == nil {
+		t.Error("Expected an error when scanning an integer into a slice")
+	}
+}
+
+func TestQuoteArray(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{[]int{1, -2}, "ARRAY[1,(-2)]"},
+		{[]string{"a", "b'c"}, "ARRAY['a','b''c']"},
+		{[2]bool{true, false}, "ARRAY[TRUE,FALSE]"},
+		{[]interface{}{nil, 1.5}, "ARRAY[NULL,1.5]"},
+		{[]int(nil), "NULL"},
+	}
+
+	for _, test := range tests {
+		literal, err := QuoteLiteral(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if literal != test.expected {
+			t.Errorf("Expected %s, but found %s", test.expected, literal)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package vertigo
+
+import (
+	"context"
+)
+
+// The outcome of a query started with QueryAsync.
+type AsyncResult struct {
+	Resultset *Resultset
+	Err       error
+}
+
+// A query that runs in the background, started with QueryAsync.
+type AsyncQuery struct {
+	result chan AsyncResult
+	cancel context.CancelFunc
+}
+
+// Starts a SQL query like QueryContext does, but returns right away, so the caller can
+// do other work while the query runs and collect its result later. The connection is
+// busy until the query completes; other statements on it wait for it.
+//
+// The result is sent on the channel returned by AsyncQuery.Result once the query
+// completes. Like the resultsets returned by Query, it should be closed if it might
+// have spilled rows to disk.
+func (c *Connection) QueryAsync(ctx context.Context, sql string, args ...interface{}) *AsyncQuery {
+	ctx, cancel := context.WithCancel(ctx)
+	q := &AsyncQuery{result: make(chan AsyncResult, 1), cancel: cancel}
+
+	go func() {
+		defer cancel()
+		resultset, err := c.QueryContext(ctx, sql, args...)
+		q.result <- AsyncResult{Resultset: resultset, Err: err}
+	}()
+	return q
+}
+
+// Returns the channel the result of the query is sent on. A single result is sent,
+// and the channel is buffered, so the query doesn't wait for it to be received.
+func (q *AsyncQuery) Result() <-chan AsyncResult {
+	return q.result
+}
+
+// Asks the server to cancel the query, if it is still running. The query then
+// completes with context.Canceled, like QueryContext does when its context is
+// cancelled. It doesn't wait for the query to complete.
+func (q *AsyncQuery) Cancel() {
+	q.cancel()
+}
@@ -0,0 +1,51 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestQueryAsync(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "a", Type: DataTypeInteger}).Row(1).Delay(100 * time.Millisecond)
+	server.Expect("SELECT SLEEP(10)").Delay(300 * time.Millisecond)
+
+	connection, err := Connect(&ConnectionInfo{Address: server.Addr(), User: "dbadmin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	query := connection.QueryAsync(context.Background(), "SELECT 1")
+	select {
+	case <-query.Result():
+		t.Fatalf("Expected QueryAsync to return before the query completes")
+	default:
+	}
+	if result := <-query.Result(); result.Err != nil || len(result.Resultset.Rows) != 1 {
+		t.Fatalf("Expected a single row, but found %v, %v", result.Resultset, result.Err)
+	}
+
+	query = connection.QueryAsync(context.Background(), "SELECT SLEEP(10)")
+	for len(server.Statements()) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	query.Cancel()
+	if result := <-query.Result(); !errors.Is(result.Err, context.Canceled) {
+		t.Fatalf("Expected the query to be cancelled, but found %v", result.Err)
+	}
+	if server.CancelRequests() != 1 {
+		t.Fatalf("Expected a cancel request, but found %d", server.CancelRequests())
+	}
+	if !connection.IsAlive() {
+		t.Fatalf("Expected the connection to survive the cancelled query")
+	}
+}
@@ -0,0 +1,149 @@
+package vertigo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var labelHint = regexp.MustCompile(`(?i)/\*\+\s*label\s*\(\s*'?([^)'\s]*)'?\s*\)`)
+
+// A single line of the audit log. Every statement that is run on a connection
+// with an AuditLog writer results in one of these records, encoded as JSON.
+type auditRecord struct {
+	Time        time.Time `json:"time"`
+	Fingerprint string    `json:"fingerprint"`
+	Label       string    `json:"label,omitempty"`
+	Duration    float64   `json:"duration_ms"`
+	Rows        int       `json:"rows"`
+	ErrorCode   string    `json:"error_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Address     string    `json:"address"`
+	User        string    `json:"user"`
+	Database    string    `json:"database,omitempty"`
+	BackendPid  uint32    `json:"backend_pid"`
+}
+
+// Writes an audit record for a statement to the connection's AuditLog.
+func (c *Connection) writeAuditRecord(sql string, start time.Time, rows int, err error) error {
+	record := auditRecord{
+		Time:        start.UTC(),
+		Fingerprint: fingerprint(sql),
+		Label:       queryLabel(sql),
+		Duration:    float64(time.Since(start)) / float64(time.Millisecond),
+		Rows:        rows,
+		Address:     c.address,
+		User:        c.config.User,
+		Database:    c.config.Database,
+		BackendPid:  c.backendPid,
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+		record.ErrorCode = sqlState(err)
+	}
+
+	return json.NewEncoder(c.config.AuditLog).Encode(record)
+}
+
+// Returns the label of a query, as set with the /*+LABEL(...)*/ hint.
+func queryLabel(sql string) string {
+	if match := labelHint.FindStringSubmatch(sql); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// Returns a fingerprint that is the same for all queries that only differ in
+// their literal values, comments, whitespace or casing.
+func fingerprint(sql string) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(normalizeQuery(sql)))
+	return fmt.Sprintf("%016x", hash.Sum64())
+}
+
+// Normalizes a query by replacing literals with ?, removing comments, and
+// collapsing whitespace.
+func normalizeQuery(sql string) string {
+	var buffer bytes.Buffer
+
+	space := false
+	for i := 0; i < len(sql); {
+		var (
+			end         int
+			replacement string
+		)
+
+		switch ch := sql[i]; {
+		case ch == '\'':
+			escapes := i > 0 && (sql[i-1] == 'e' || sql[i-1] == 'E')
+			if escapes {
+				// Drop the E prefix, so escape strings are normalized like any other string.
+				buffer.Truncate(buffer.Len() - 1)
+			}
+			end, replacement = skipQuoted(sql, i, escapes), "?"
+
+		case ch == '"':
+			end = skipQuoted(sql, i, false)
+			replacement = sql[i:end]
+
+		case strings.HasPrefix(sql[i:], "--"):
+			if end = strings.IndexByte(sql[i:], '\n'); end < 0 {
+				end = len(sql)
+			} else {
+				end += i + 1
+			}
+			replacement = " "
+
+		case strings.HasPrefix(sql[i:], "/*"):
+			if end = strings.Index(sql[i+2:], "*/"); end < 0 {
+				end = len(sql)
+			} else {
+				end += i + 4
+			}
+			replacement = " "
+
+		case isDigit(ch) && (space || !endsWithIdentifier(buffer.Bytes())):
+			for end = i + 1; end < len(sql) && (isDigit(sql[end]) || sql[end] == '.'); end++ {
+			}
+			replacement = "?"
+
+		case unicode.IsSpace(rune(ch)):
+			end, replacement = i+1, " "
+
+		default:
+			end, replacement = i+1, strings.ToLower(sql[i:i+1])
+		}
+
+		if replacement == " " {
+			space = buffer.Len() > 0
+		} else {
+			if space {
+				buffer.WriteByte(' ')
+				space = false
+			}
+			buffer.WriteString(replacement)
+		}
+		i = end
+	}
+	return buffer.String()
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// Checks whether the normalized query so far ends with an identifier character,
+// in which case a digit is part of that identifier rather than a number literal.
+func endsWithIdentifier(normalized []byte) bool {
+	if len(normalized) == 0 {
+		return false
+	}
+	ch := normalized[len(normalized)-1]
+	return ch == '_' || ch == '$' || isDigit(ch) || (ch >= 'a' && ch <= 'z')
+}
@@ -0,0 +1,55 @@
+package vertigo
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	same := []string{
+		"SELECT * FROM t WHERE id = 1 AND name = 'foo'",
+		"select *  from t\nwhere id = 42 and name = 'it''s' -- comment",
+		"/*+LABEL(test)*/ SELECT * FROM t WHERE id = 3.5 AND name = E'\\''",
+	}
+
+	for _, sql := range same[1:] {
+		if fingerprint(sql) != fingerprint(same[0]) {
+			t.Errorf("Expected %q to have the same fingerprint as %q: %q vs %q", sql, same[0], normalizeQuery(sql), normalizeQuery(same[0]))
+		}
+	}
+
+	if fingerprint("SELECT * FROM t1") == fingerprint("SELECT * FROM t2") {
+		t.Error("Expected different tables to result in different fingerprints")
+	}
+}
+
+func TestQueryLabel(t *testing.T) {
+	if label := queryLabel("SELECT /*+ LABEL(nightly_etl) */ 1"); label != "nightly_etl" {
+		t.Errorf("Expected label nightly_etl, but found %q", label)
+	}
+
+	if label := queryLabel("SELECT 1"); label != "" {
+		t.Errorf("Expected no label, but found %q", label)
+	}
+}
+
+func TestWriteAuditRecord(t *testing.T) {
+	var buffer bytes.Buffer
+	connection := &Connection{config: &ConnectionInfo{Address: "localhost:5433", User: "dbadmin", AuditLog: &buffer}, backendPid: 42}
+
+	err := ErrorResponseMessage{Fields: map[byte]string{'S': "ERROR", 'C': "42601", 'M': "Syntax error"}}
+	if err := connection.writeAuditRecord("SELECT /ERROR", time.Now(), 0, err); err != nil {
+		t.Fatal(err)
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record["error_code"] != "42601" || record["backend_pid"] != float64(42) || record["user"] != "dbadmin" {
+		t.Fatalf("Unexpected audit record: %s", buffer.String())
+	}
+}
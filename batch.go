@@ -0,0 +1,115 @@
+package vertigo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The default number of rows per statement of a BatchInsert.
+const defaultBatchRows = 1000
+
+// Builds multi-row INSERT statements for loading rows when COPY isn't available.
+//
+// Vertica doesn't accept multiple rows in a VALUES clause, so the rows are inserted
+// with INSERT ... SELECT, with one SELECT of literals per row joined by UNION ALL.
+// The values are quoted the same way as query arguments.
+type BatchInsert struct {
+	Table            string   // The table to insert into, optionally qualified with its schema as schema.table
+	Columns          []string // The columns to insert into. Empty inserts into all columns, in table order.
+	RowsPerStatement int      // The maximum number of rows per statement. Defaults to 1000.
+}
+
+// Returns the INSERT statements for the rows. Every row must have a value for each of
+// the Columns.
+func (b BatchInsert) Statements(rows [][]interface{}) ([]string, error) {
+	if b.Table == "" {
+		return nil, errors.New("BatchInsert needs a table")
+	}
+
+	size := b.RowsPerStatement
+	if size <= 0 {
+		size = defaultBatchRows
+	}
+
+	var header bytes.Buffer
+	header.WriteString("INSERT INTO ")
+	header.WriteString(quoteQualifiedName(b.Table))
+	if len(b.Columns) > 0 {
+		header.WriteString(" (")
+		for i, column := range b.Columns {
+			if i > 0 {
+				header.WriteString(", ")
+			}
+			header.WriteString(QuoteIdentifier(column))
+		}
+		header.WriteString(")")
+	}
+
+	var statements []string
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		var buffer bytes.Buffer
+		buffer.Write(header.Bytes())
+		for i, row := range rows[start:end] {
+			if len(b.Columns) > 0 && len(row) != len(b.Columns) {
+				return nil, fmt.Errorf("Row %d has %d values, but there are %d columns", start+i, len(row), len(b.Columns))
+			}
+			if len(row) == 0 {
+				return nil, fmt.Errorf("Row %d has no values", start+i)
+			}
+
+			if i > 0 {
+				buffer.WriteString(" UNION ALL")
+			}
+			buffer.WriteString(" SELECT ")
+			for j, value := range row {
+				literal, err := QuoteLiteral(value)
+				if err != nil {
+					return nil, fmt.Errorf("Row %d, column %d: %w", start+i, j, err)
+				}
+				if j > 0 {
+					buffer.WriteString(", ")
+				}
+				buffer.WriteString(literal)
+			}
+		}
+		statements = append(statements, buffer.String())
+	}
+	return statements, nil
+}
+
+// Inserts the rows, and returns the number of rows inserted. The statements are run
+// one after the other, so when one fails, the rows of the statements before it are
+// already inserted; run it in a transaction to insert all rows or none.
+func (b BatchInsert) Exec(c *Connection, rows [][]interface{}) (int64, error) {
+	statements, err := b.Statements(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	var inserted int64
+	for _, sql := range statements {
+		result, err := c.Exec(sql)
+		if err != nil {
+			return inserted, err
+		}
+		inserted += result.RowsAffected()
+	}
+	return inserted, nil
+}
+
+// Returns a quoted, optionally schema qualified name. Every part separated by a dot
+// is quoted separately.
+func quoteQualifiedName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,59 @@
+package vertigo
+
+import (
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestBatchInsertStatements(t *testing.T) {
+	batch := BatchInsert{Table: "public.events", Columns: []string{"id", "name"}, RowsPerStatement: 2}
+	statements, err := batch.Statements([][]interface{}{{1, "it's"}, {-2, nil}, {3, "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`INSERT INTO "public"."events" ("id", "name") SELECT 1, 'it''s' UNION ALL SELECT (-2), NULL`,
+		`INSERT INTO "public"."events" ("id", "name") SELECT 3, 'c'`,
+	}
+	if len(statements) != len(expected) {
+		t.Fatalf("Expected %d statements, but found %q", len(expected), statements)
+	}
+	for i := range expected {
+		if statements[i] != expected[i] {
+			t.Fatalf("Expected statement %q, but found %q", expected[i], statements[i])
+		}
+	}
+
+	if _, err := batch.Statements([][]interface{}{{1}}); err == nil {
+		t.Fatalf("Expected a row with a missing value to fail")
+	}
+	if _, err := batch.Statements([][]interface{}{{1, struct{}{}}}); err == nil {
+		t.Fatalf("Expected a value that can't be quoted to fail")
+	}
+	if statements, err := batch.Statements(nil); err != nil || len(statements) != 0 {
+		t.Fatalf("Expected no statements for no rows, but found %q, %v", statements, err)
+	}
+}
+
+func TestBatchInsertExec(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect(`INSERT INTO "t" SELECT 1 UNION ALL SELECT 2`).Tag("INSERT 0 2")
+	server.Expect(`INSERT INTO "t" SELECT 3`).Tag("INSERT 0 1")
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	inserted, err := BatchInsert{Table: "t", RowsPerStatement: 2}.Exec(connection, [][]interface{}{{1}, {2}, {3}})
+	if err != nil || inserted != 3 {
+		t.Fatalf("Expected 3 rows to be inserted, but found %d, %v", inserted, err)
+	}
+}
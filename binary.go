@@ -0,0 +1,68 @@
+package vertigo
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// Checks whether the data type OID is one of the binary string types.
+func isBinaryType(oid uint32) bool {
+	return oid == DataTypeVarbinary || oid == DataTypeLongVarbinary || oid == DataTypeBinary
+}
+
+// Decodes a VARBINARY, LONG VARBINARY or BINARY value. In binary format the value
+// is used as is. In text format, bytes that are not printable ASCII characters are
+// escaped as a backslash followed by three octal digits, and backslashes are doubled.
+func decodeBinary(field *Field, src []byte) ([]byte, error) {
+	if field.FormatCode == 1 {
+		return append([]byte(nil), src...), nil
+	}
+
+	result := make([]byte, 0, len(src))
+	for i := 0; i < len(src); i++ {
+		if src[i] != '\\' {
+			result = append(result, src[i])
+			continue
+		}
+
+		switch {
+		case i+1 < len(src) && src[i+1] == '\\':
+			result = append(result, '\\')
+			i++
+
+		case i+3 < len(src) && isOctal(src[i+1]) && isOctal(src[i+2]) && isOctal(src[i+3]):
+			result = append(result, (src[i+1]-'0')<<6|(src[i+2]-'0')<<3|(src[i+3]-'0'))
+			i += 3
+
+		default:
+			return nil, fmt.Errorf("Invalid escape sequence in binary value at offset %d", i)
+		}
+	}
+	return result, nil
+}
+
+// Escapes a binary value into the text format of VARBINARY values. This is the
+// inverse of decodeBinary.
+func escapeBinary(b []byte) []byte {
+	result := make([]byte, 0, len(b))
+	for _, ch := range b {
+		switch {
+		case ch == '\\':
+			result = append(result, '\\', '\\')
+		case ch < 0x20 || ch > 0x7e:
+			result = append(result, '\\', '0'+ch>>6, '0'+ch>>3&7, '0'+ch&7)
+		default:
+			result = append(result, ch)
+		}
+	}
+	return result
+}
+
+func isOctal(ch byte) bool {
+	return ch >= '0' && ch <= '7'
+}
+
+// Returns a hexadecimal VARBINARY literal for b.
+func quoteBinary(b []byte) string {
+	return "X'" + hex.EncodeToString(b) + "'"
+}
@@ -0,0 +1,48 @@
+package vertigo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeBinary(t *testing.T) {
+	field := &Field{DataTypeOID: DataTypeVarbinary}
+
+	decoded, err := decodeBinary(field, []byte(`ab\000\377\\c`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []byte("ab\x00\xff\\c"); !bytes.Equal(decoded, expected) {
+		t.Fatalf("Expected %q, but found %q", expected, decoded)
+	}
+
+	if _, err := decodeBinary(field, []byte(`\12`)); err == nil {
+		t.Fatal("Expected an error for a truncated escape sequence")
+	}
+
+	field.FormatCode = 1
+	if decoded, err := decodeBinary(field, []byte(`\000`)); err != nil || string(decoded) != `\000` {
+		t.Fatalf("Expected binary format values to be used as is, but found %q", decoded)
+	}
+}
+
+func TestRowScanBinary(t *testing.T) {
+	fields := []Field{{DataTypeOID: DataTypeVarbinary}, {DataTypeOID: DataTypeVarchar}}
+	row := Row{Values: [][]byte{[]byte(`\001\002`), []byte(`\001`)}, fields: fields}
+
+	var binary, text []byte
+	if err := row.Scan(&binary, &text); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(binary, []byte{1, 2}) || string(text) != `\001` {
+		t.Fatalf("Unexpected values %q and %q", binary, text)
+	}
+}
+
+func TestQuoteBinary(t *testing.T) {
+	if literal, err := QuoteLiteral([]byte{0, 0xff, 'a'}); err != nil || literal != "X'00ff61'" {
+		t.Fatalf("Unexpected literal %s (%v)", literal, err)
+	}
+}
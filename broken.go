@@ -0,0 +1,98 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrConnectionBroken = errors.New("Connection is broken")
+
+// Closes the connection after a failure that may have left the protocol stream out
+// of sync, and marks it as broken. A broken connection refuses all statements until
+// it is reopened with Reconnect, so a lost session (and any transaction, temporary
+// tables and session parameters with it) is never replaced silently.
+func (c *Connection) markBroken(cause error) {
+	c.sessionEnded(cause)
+	c.resetConnection()
+	c.broken = cause
+}
+
+// Returns the error for statements on a broken connection, or nil if the connection
+// isn't broken.
+func (c *Connection) brokenError() error {
+	if c.broken == nil {
+		return nil
+	}
+	return fmt.Errorf("%w by an earlier error: %s", ErrConnectionBroken, c.broken)
+}
+
+// Returns whether the connection is open and usable. It returns false after the
+// connection was closed, or when it broke because of a network or protocol error.
+// Pools should discard connections that are no longer alive.
+func (c *Connection) IsAlive() bool {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.socket != nil && c.broken == nil
+}
+
+// Checks that the server still answers, with a Sync round trip that doesn't run a
+// statement. When the server doesn't answer in time, the connection is broken and
+// the error is returned.
+func (c *Connection) Ping() (err error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+			c.markBroken(err)
+		}
+	}()
+
+	if c.portal != nil {
+		return ErrPortalOpen
+	}
+	if err := c.brokenError(); err != nil {
+		return err
+	}
+	if c.socket == nil {
+		return errors.New("Socket is not open")
+	}
+
+	c.syncRoundTrip()
+	return nil
+}
+
+// Sends a Sync message, which doesn't run a statement, and waits up to the validation
+// timeout for the server to answer it. This function will panic if it doesn't.
+func (c *Connection) syncRoundTrip() {
+	socket := c.socket
+	socket.SetDeadline(time.Now().Add(validationTimeout))
+	defer socket.SetDeadline(time.Time{})
+
+	c.sendMessage(SyncMessage{})
+	for msg := c.receiveMessage(); !c.isReadyForQuery(msg); msg = c.receiveMessage() {
+		c.handleStatelessMessage(msg)
+	}
+}
+
+// Opens a new session on the connection, replacing the current one. This is the
+// way to recover a broken connection.
+func (c *Connection) Reconnect() (err error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.resetConnection()
+			err = r.(error)
+		}
+	}()
+
+	c.sessionEnded(nil)
+	c.resetConnection()
+	c.broken = nil
+	c.openConnection()
+	return nil
+}
@@ -0,0 +1,43 @@
+package vertigo
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestBrokenConnection(t *testing.T) {
+	c := &Connection{config: &ConnectionInfo{}}
+	if c.IsAlive() {
+		t.Fatalf("Expected a connection without a socket not to be alive")
+	}
+
+	c.markBroken(io.ErrUnexpectedEOF)
+	if c.IsAlive() {
+		t.Fatalf("Expected a broken connection not to be alive")
+	}
+
+	_, err := c.Query("SELECT 1")
+	if !errors.Is(err, ErrConnectionBroken) {
+		t.Fatalf("Expected ErrConnectionBroken, but found %v", err)
+	}
+	if !IsConnectionError(err) {
+		t.Fatalf("Expected %v to be a connection error", err)
+	}
+
+	if _, err := c.Prepare("SELECT 1"); !errors.Is(err, ErrConnectionBroken) {
+		t.Fatalf("Expected ErrConnectionBroken, but found %v", err)
+	}
+}
+
+func TestCloseBrokenConnection(t *testing.T) {
+	c := &Connection{config: &ConnectionInfo{}}
+	if err := c.Close(); err == nil {
+		t.Fatalf("Expected closing a connection that isn't open to fail")
+	}
+
+	c.markBroken(io.ErrUnexpectedEOF)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Expected closing a broken connection to succeed, but found %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package vertigo
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Returns whether the error means the connection to the server failed or was lost,
+// like network faults and nodes shutting down. The statement may or may not have
+// been run by the server.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrConnectionBroken) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) {
+		return true
+	}
+
+	switch state := sqlState(err); {
+	case strings.HasPrefix(state, "08"):
+		return true
+	case state == ErrCodeAdminShutdown, state == ErrCodeCrashShutdown, state == ErrCodeCannotConnectNow:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns whether the server aborted the statement because of a conflict with a
+// concurrent transaction. The transaction can be retried as a whole.
+func IsSerializationFailure(err error) bool {
+	state := sqlState(err)
+	return state == ErrCodeSerializationFailure || state == ErrCodeDeadlockDetected
+}
+
+// Returns whether the error is transient, so the statement is likely to succeed when
+// it is retried, possibly on a new connection. Besides connection errors and
+// serialization failures, this includes lock timeouts and statements rejected by a
+// resource pool because of a lack of resources.
+//
+// Note that a statement that failed with a connection error may have been run by
+// the server, so only statements that can safely be run twice should be retried.
+func IsRetryable(err error) bool {
+	if IsConnectionError(err) || IsSerializationFailure(err) {
+		return true
+	}
+
+	switch sqlState(err) {
+	case ErrCodeLockNotAvailable, ErrCodeInsufficientResources, ErrCodeOutOfMemory, ErrCodeTooManyConnections:
+		return true
+	default:
+		return false
+	}
+}
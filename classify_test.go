@@ -0,0 +1,50 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestErrorClassification(t *testing.T) {
+	verticaError := func(code string) error {
+		return ErrorResponseMessage{Fields: map[byte]string{'S': "ERROR", 'C': code}}.VerticaError()
+	}
+
+	tests := []struct {
+		err           error
+		connection    bool
+		serialization bool
+		retryable     bool
+	}{
+		{nil, false, false, false},
+		{errors.New("other"), false, false, false},
+		{io.EOF, true, false, true},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, true, false, true},
+		{fmt.Errorf("write: %w", syscall.EPIPE), true, false, true},
+		{verticaError(ErrCodeAdminShutdown), true, false, true},
+		{verticaError(ErrCodeConnectionFailure), true, false, true},
+		{verticaError(ErrCodeSerializationFailure), false, true, true},
+		{verticaError(ErrCodeDeadlockDetected), false, true, true},
+		{verticaError(ErrCodeInsufficientResources), false, false, true},
+		{verticaError(ErrCodeLockNotAvailable), false, false, true},
+		{verticaError(ErrCodeSyntaxError), false, false, false},
+		{verticaError(ErrCodeUniqueViolation), false, false, false},
+		{&ClientTimeoutError{}, false, false, false},
+	}
+
+	for _, test := range tests {
+		if IsConnectionError(test.err) != test.connection {
+			t.Errorf("Expected IsConnectionError to be %v for %v", test.connection, test.err)
+		}
+		if IsSerializationFailure(test.err) != test.serialization {
+			t.Errorf("Expected IsSerializationFailure to be %v for %v", test.serialization, test.err)
+		}
+		if IsRetryable(test.err) != test.retryable {
+			t.Errorf("Expected IsRetryable to be %v for %v", test.retryable, test.err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package vertigo
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// The default of ConnectionInfo.CloseTimeout.
+const defaultCloseTimeout = 5 * time.Second
+
+// The parts of the session that Close needs to interrupt a statement running on
+// another goroutine, which can't be read from the Connection without the lock.
+type sharedSession struct {
+	socket  net.Conn
+	address string
+	pid     uint32
+	key     uint32
+}
+
+// Publishes the current socket and backend of the connection for Close.
+func (c *Connection) shareSession() {
+	c.shared.Store(&sharedSession{socket: c.socket, address: c.address, pid: c.backendPid, key: c.backendKey})
+}
+
+// Closes the connection to the server.
+//
+// It will try to gracefully terminate the connection by sending the server
+// a terminate message. Regardless of whether this succeeds, the socket will be
+// closed and the status of the connection will be reset.
+//
+// When a statement is running on another goroutine, Close asks the server to cancel
+// it, and waits up to CloseTimeout for it to end. After that, the socket is closed,
+// which makes the statement fail. Sending the terminate message is limited by the
+// CloseTimeout as well, so Close doesn't hang on an unresponsive server.
+func (c *Connection) Close() (err error) {
+	if !c.l.TryLock() {
+		c.interruptStatement()
+	}
+	defer c.l.Unlock()
+
+	c.stopHeartbeat()
+	c.sessionEnded(nil)
+	defer c.resetConnection()
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+
+	if c.socket != nil {
+		c.socket.SetWriteDeadline(time.Now().Add(c.closeTimeout()))
+		c.sendMessage(TerminateMessage{})
+	} else if c.broken == nil {
+		panic(errors.New("Socket is not open"))
+	}
+
+	return nil
+}
+
+// Cancels the statement that holds the lock of the connection, and takes the lock
+// once the statement has ended. If it doesn't end within the CloseTimeout, its
+// socket is closed.
+func (c *Connection) interruptStatement() {
+	locked := make(chan struct{})
+	go func() {
+		c.l.Lock()
+		close(locked)
+	}()
+
+	if shared := c.shared.Load(); shared != nil && shared.pid != 0 {
+		sendCancelRequest(c.dialFunc(), shared.address, shared.pid, shared.key)
+	}
+
+	select {
+	case <-locked:
+		return
+	case <-time.After(c.closeTimeout()):
+	}
+
+	if shared := c.shared.Load(); shared != nil {
+		c.log(LogLevelWarn, "Closing the socket of a statement that didn't end", "address", shared.address, "timeout", c.closeTimeout())
+		shared.socket.Close()
+	}
+	<-locked
+}
+
+func (c *Connection) closeTimeout() time.Duration {
+	if c.config.CloseTimeout > 0 {
+		return c.config.CloseTimeout
+	}
+	return defaultCloseTimeout
+}
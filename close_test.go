@@ -0,0 +1,48 @@
+package vertigo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestCloseRunningStatement(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT SLEEP(10)").Delay(time.Second)
+
+	connection, err := Connect(&ConnectionInfo{Address: server.Addr(), User: "dbadmin", CloseTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := connection.Query("SELECT SLEEP(10)")
+		done <- err
+	}()
+
+	// Wait for the statement to be sent.
+	for len(server.Statements()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	connection.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected Close to give up after the CloseTimeout, but it took %s", elapsed)
+	}
+	if err := <-done; err == nil {
+		t.Fatalf("Expected the interrupted statement to fail")
+	}
+	if server.CancelRequests() != 1 {
+		t.Fatalf("Expected the statement to be cancelled, but found %d cancel requests", server.CancelRequests())
+	}
+	if connection.IsAlive() {
+		t.Fatalf("Expected the connection to be closed")
+	}
+}
@@ -0,0 +1,75 @@
+package vertigo
+
+import (
+	"sync"
+)
+
+// Codec converts values of a data type that isn't built into the driver, like a
+// user-defined type, between their text representation and Go values.
+type Codec interface {
+	// Decodes a non-NULL value in text format.
+	Decode(field Field, src []byte) (interface{}, error)
+
+	// Returns the SQL literal for v, or false if the codec doesn't handle values
+	// of the type of v.
+	Encode(v interface{}) (literal string, ok bool, err error)
+}
+
+var codecs struct {
+	sync.RWMutex
+	byOID map[uint32]Codec
+	order []uint32
+}
+
+// Registers the codec for the data type with the given OID. It replaces the built-in
+// decoding of the data type, if any. The codec is also used to encode query arguments
+// of types the driver doesn't know about; codecs are tried in the order they were
+// registered. Registering a nil codec removes the registration for the OID.
+func RegisterType(oid uint32, codec Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	if _, exists := codecs.byOID[oid]; exists {
+		for i, registered := range codecs.order {
+			if registered == oid {
+				codecs.order = append(codecs.order[:i:i], codecs.order[i+1:]...)
+				break
+			}
+		}
+		delete(codecs.byOID, oid)
+	}
+
+	if codec == nil {
+		return
+	}
+
+	if codecs.byOID == nil {
+		codecs.byOID = make(map[uint32]Codec)
+	}
+	codecs.byOID[oid] = codec
+	codecs.order = append(codecs.order, oid)
+}
+
+// Returns the codec registered for the data type, or nil.
+func lookupCodec(oid uint32) Codec {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	return codecs.byOID[oid]
+}
+
+// Encodes v with the first registered codec that handles its type.
+func encodeWithCodec(v interface{}) (string, bool, error) {
+	codecs.RLock()
+	registered := make([]Codec, len(codecs.order))
+	for i, oid := range codecs.order {
+		registered[i] = codecs.byOID[oid]
+	}
+	codecs.RUnlock()
+
+	for _, codec := range registered {
+		if literal, ok, err := codec.Encode(v); ok || err != nil {
+			return literal, ok, err
+		}
+	}
+	return "", false, nil
+}
@@ -0,0 +1,78 @@
+package vertigo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testPointOID = 1000001
+
+type testPoint struct {
+	X, Y int
+}
+
+type testPointCodec struct{}
+
+func (testPointCodec) Decode(field Field, src []byte) (interface{}, error) {
+	var p testPoint
+	if _, err := fmt.Sscanf(string(src), "POINT(%d %d)", &p.X, &p.Y); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (testPointCodec) Encode(v interface{}) (string, bool, error) {
+	p, ok := v.(testPoint)
+	if !ok {
+		return "", false, nil
+	}
+	return fmt.Sprintf("ST_GeomFromText('POINT(%d %d)')", p.X, p.Y), true, nil
+}
+
+func TestRegisterType(t *testing.T) {
+	RegisterType(testPointOID, testPointCodec{})
+	defer RegisterType(testPointOID, nil)
+
+	fields := []Field{{Name: "p", DataTypeOID: testPointOID}}
+	row := Row{Values: [][]byte{[]byte("POINT(1 2)")}, fields: fields}
+
+	var p testPoint
+	var value interface{}
+	var text string
+	if err := row.Scan(&p); err != nil {
+		t.Fatal(err)
+	}
+	if err := row.Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	if err := row.Scan(&text); err != nil {
+		t.Fatal(err)
+	}
+
+	if p != (testPoint{1, 2}) || value != (testPoint{1, 2}) || text != "POINT(1 2)" {
+		t.Fatalf("Unexpected values %v, %v and %q", p, value, text)
+	}
+
+	sql, err := interpolate("SELECT ?", []interface{}{testPoint{3, 4}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT ST_GeomFromText('POINT(3 4)')"; sql != expected {
+		t.Fatalf("Expected %q, but found %q", expected, sql)
+	}
+}
+
+func TestRegisterTypeRemoval(t *testing.T) {
+	RegisterType(testPointOID, testPointCodec{})
+	RegisterType(testPointOID, nil)
+
+	value, err := decodeValue(Field{DataTypeOID: testPointOID}, []byte("POINT(1 2)"))
+	if err != nil || value != "POINT(1 2)" {
+		t.Fatalf("Expected the value to be decoded as a string, but found %#v (%v)", value, err)
+	}
+
+	if _, err := QuoteLiteral(testPoint{}); err == nil || !strings.Contains(err.Error(), "Cannot use value") {
+		t.Fatalf("Expected an error for an unregistered argument type, but found %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package vertigo
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Describes a lossy or surprising conversion of a value while decoding it.
+type CoercionWarning struct {
+	Value  string // The value in text format, as received from the server.
+	Type   string // The Go type the value was converted into.
+	Reason string // Why the conversion was lossy.
+}
+
+func (w *CoercionWarning) Error() string {
+	return fmt.Sprintf("Lossy conversion of %q into %s: %s", w.Value, w.Type, w.Reason)
+}
+
+// How the lossy conversions of the values of a connection are reported, see
+// ConnectionInfo.CoercionWarningHandler and ConnectionInfo.StrictCoercions. A nil
+// policy ignores them.
+type coercionPolicy struct {
+	strict  bool
+	handler func(warning *CoercionWarning)
+}
+
+// Returns the coercion policy of the connection, or nil when lossy conversions don't
+// need to be detected at all.
+func (c *Connection) coercionPolicy() *coercionPolicy {
+	if !c.config.StrictCoercions && c.config.CoercionWarningHandler == nil {
+		return nil
+	}
+	return &coercionPolicy{strict: c.config.StrictCoercions, handler: c.config.CoercionWarningHandler}
+}
+
+// Reports a lossy conversion. It returns the warning as an error in strict mode.
+func (p *coercionPolicy) report(warning *CoercionWarning) error {
+	if p == nil {
+		return nil
+	}
+	if p.strict {
+		return warning
+	}
+
+	if p.handler != nil {
+		p.handler(warning)
+	}
+	return nil
+}
+
+// Checks whether the float f exactly represents the number in text format.
+func (p *coercionPolicy) checkFloat(src string, f float64, bitSize int, typeName string) error {
+	original, ok := new(big.Rat).SetString(src)
+	if !ok {
+		return nil
+	}
+
+	converted, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, bitSize))
+	if !ok || original.Cmp(converted) != 0 {
+		return p.report(&CoercionWarning{Value: src, Type: typeName, Reason: "precision lost"})
+	}
+	return nil
+}
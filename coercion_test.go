@@ -0,0 +1,53 @@
+package vertigo
+
+import (
+	"testing"
+)
+
+// Returns fields that report lossy conversions like the connection configured by config.
+func coercionFields(config *ConnectionInfo, n int) []Field {
+	fields := make([]Field, n)
+	(&Connection{config: config}).prepareFields(fields)
+	return fields
+}
+
+func TestCoercionWarnings(t *testing.T) {
+	var warnings []*CoercionWarning
+	fields := coercionFields(&ConnectionInfo{CoercionWarningHandler: func(warning *CoercionWarning) { warnings = append(warnings, warning) }}, 2)
+
+	var (
+		f64 float64
+		f32 float32
+	)
+
+	row := Row{Values: [][]byte{[]byte("1.50"), []byte("0.1")}, fields: fields}
+	if err := row.Scan(&f64, &f32); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(warnings) != 0 {
+		t.Fatalf("Expected no warnings for exact conversions, but found %#+v", warnings)
+	}
+
+	row = Row{Values: [][]byte{[]byte("123.4567890123456789"), []byte("16777217")}, fields: fields}
+	if err := row.Scan(&f64, &f32); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(warnings) != 2 || warnings[0].Type != "float64" || warnings[1].Type != "float32" {
+		t.Fatalf("Expected warnings for both lossy conversions, but found %#+v", warnings)
+	}
+}
+
+func TestStrictCoercions(t *testing.T) {
+	var f float64
+	values := [][]byte{[]byte("123.4567890123456789")}
+	if err := (Row{Values: values, fields: coercionFields(&ConnectionInfo{StrictCoercions: true}, 1)}).Scan(&f); err == nil {
+		t.Fatal("Expected an error for a lossy conversion in strict mode")
+	}
+
+	// The setting only applies to the connection it is configured on.
+	if err := (Row{Values: values, fields: coercionFields(&ConnectionInfo{}, 1)}).Scan(&f); err != nil {
+		t.Fatalf("Expected no error without strict mode, but found %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package vertigo
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type traceparentKey struct{}
+
+// Returns a context that tags the statements run with it with the W3C trace context
+// of the caller, like "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", for
+// TraceComment.
+func WithTraceparent(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, traceparentKey{}, traceparent)
+}
+
+// Returns a ConnectionInfo.QueryComment function that tags every statement with the
+// tags, like {"app": "billing"}, and with the trace context set by WithTraceparent.
+func TraceComment(tags map[string]string) func(ctx context.Context) map[string]string {
+	return func(ctx context.Context) map[string]string {
+		traceparent, _ := ctx.Value(traceparentKey{}).(string)
+		if traceparent == "" {
+			return tags
+		}
+
+		withTrace := make(map[string]string, len(tags)+1)
+		for key, value := range tags {
+			withTrace[key] = value
+		}
+		withTrace["traceparent"] = traceparent
+		return withTrace
+	}
+}
+
+// Appends the comment for the tags of the QueryComment of the connection to a statement.
+func (c *Connection) commentQuery(ctx context.Context, sql string) string {
+	if c.config.QueryComment == nil {
+		return sql
+	}
+	return appendQueryComment(sql, c.config.QueryComment(ctx))
+}
+
+// Appends a comment with the tags to a statement in the sqlcommenter format: the keys
+// and values are URL encoded, the values are quoted, and the tags are sorted by key,
+// as in /*app='billing',traceparent='00-...-01'*/. The comment goes before a trailing
+// semicolon. Statements that already end with a comment are left alone.
+func appendQueryComment(sql string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return sql
+	}
+	body := strings.TrimRight(sql, " \t\r\n")
+	semicolon := strings.HasSuffix(body, ";")
+	body = strings.TrimSuffix(body, ";")
+	if strings.HasSuffix(body, "*/") {
+		return sql
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(body)
+	b.WriteString(" /*")
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(commentEscape(key))
+		b.WriteString("='")
+		b.WriteString(commentEscape(tags[key]))
+		b.WriteByte('\'')
+	}
+	b.WriteString("*/")
+	if semicolon {
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// URL encodes a key or value of a comment, which also keeps it from ending the comment.
+func commentEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,59 @@
+package vertigo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestAppendQueryComment(t *testing.T) {
+	tags := map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "app": "billing api", "route": "*/x'"}
+	tests := []struct {
+		sql, expected string
+	}{
+		{"SELECT 1", "SELECT 1 /*app='billing%20api',route='%2A%2Fx%27',traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'*/"},
+		{"SELECT 1;\n", "SELECT 1 /*app='billing%20api',route='%2A%2Fx%27',traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'*/;"},
+		{"SELECT 1 /* mine */", "SELECT 1 /* mine */"},
+	}
+	for _, test := range tests {
+		if sql := appendQueryComment(test.sql, tags); sql != test.expected {
+			t.Errorf("Expected %q, but found %q", test.expected, sql)
+		}
+	}
+	if sql := appendQueryComment("SELECT 1", nil); sql != "SELECT 1" {
+		t.Errorf("Expected no comment without tags, but found %q", sql)
+	}
+}
+
+func TestQueryComment(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.ExpectMatch("^SELECT 1")
+
+	connection, err := Open(server.Addr(), WithQueryComment(map[string]string{"app": "billing"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	ctx := WithTraceparent(context.Background(), "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	if _, err := connection.QueryContext(ctx, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connection.Query("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	statements := server.Statements()
+	expected := []string{
+		"SELECT 1 /*app='billing',traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'*/",
+		"SELECT 1 /*app='billing'*/",
+	}
+	if len(statements) != 2 || statements[0] != expected[0] || statements[1] != expected[1] {
+		t.Fatalf("Expected statements %q, but found %q", expected, statements)
+	}
+}
@@ -0,0 +1,113 @@
+package vertigo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Data type OIDs of Vertica's complex types. The text representation of their
+// values is JSON.
+const (
+	DataTypeRow   = 300
+	DataTypeArray = 301 // Arrays of complex types, and multi-dimensional arrays
+	DataTypeMap   = 302
+)
+
+func isComplexType(oid uint32) bool {
+	return oid == DataTypeRow || oid == DataTypeArray || oid == DataTypeMap
+}
+
+// Decodes a complex value. ROW values are decoded as map[string]interface{}, arrays
+// as []interface{}. Members are decoded into the Go type matching their JSON type,
+// with integral numbers as int64 and other numbers as float64.
+func decodeComplexValue(src []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(src))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("Invalid complex value %q", src)
+	}
+	return convertJSONNumbers(value)
+}
+
+func convertJSONNumbers(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n, nil
+		}
+		return value.Float64()
+
+	case []interface{}:
+		for i, element := range value {
+			converted, err := convertJSONNumbers(element)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = converted
+		}
+
+	case map[string]interface{}:
+		for key, member := range value {
+			converted, err := convertJSONNumbers(member)
+			if err != nil {
+				return nil, err
+			}
+			value[key] = converted
+		}
+	}
+	return value, nil
+}
+
+// Returns the text representation of a member of a complex value. NULL is nil,
+// strings are unquoted, and other members are used as is.
+func jsonMemberText(member json.RawMessage) ([]byte, error) {
+	switch {
+	case bytes.Equal(member, []byte("null")):
+		return nil, nil
+
+	case len(member) > 0 && member[0] == '"':
+		var s string
+		if err := json.Unmarshal(member, &s); err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+
+	default:
+		return member, nil
+	}
+}
+
+// Converts a ROW value into the struct value. The members of the ROW are mapped
+// onto the fields of the struct like columns in Row.ScanStruct.
+func convertRowValue(src []byte, value reflect.Value) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(src, &members); err != nil {
+		return fmt.Errorf("Invalid ROW value %q", src)
+	}
+
+	names := make([]Field, 0, len(members))
+	for name := range members {
+		names = append(names, Field{Name: name})
+	}
+
+	indexes, err := structFieldIndexes(value.Type(), names)
+	if err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		text, err := jsonMemberText(members[name.Name])
+		if err != nil {
+			return err
+		}
+		if err := convertValue(nil, text, value.FieldByIndex(indexes[i]).Addr().Interface()); err != nil {
+			return fmt.Errorf("Cannot convert member %s: %w", strconv.Quote(name.Name), err)
+		}
+	}
+	return nil
+}
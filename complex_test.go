@@ -0,0 +1,69 @@
+package vertigo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeRow(t *testing.T) {
+	value, err := decodeValue(Field{DataTypeOID: DataTypeRow}, []byte(`{"id":1,"name":"a","score":1.5,"tags":["x",null],"address":{"city":"b"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"id":      int64(1),
+		"name":    "a",
+		"score":   1.5,
+		"tags":    []interface{}{"x", nil},
+		"address": map[string]interface{}{"city": "b"},
+	}
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("Expected %#v, but found %#v", expected, value)
+	}
+}
+
+func TestRowScanRow(t *testing.T) {
+	type address struct {
+		City string
+	}
+	type person struct {
+		ID        int64 `db:"id"`
+		Name      *string
+		Addresses []address `db:"addresses"`
+	}
+
+	fields := []Field{{DataTypeOID: DataTypeRow}, {DataTypeOID: DataTypeRow}, {DataTypeOID: DataTypeArray}}
+	row := Row{
+		Values: [][]byte{
+			[]byte(`{"id":1,"name":null,"addresses":[{"city":"a"},{"city":"b"}]}`),
+			[]byte(`{"x":1}`),
+			[]byte(`[{"id":2,"name":"c","addresses":[]}]`),
+		},
+		fields: fields,
+	}
+
+	var (
+		p      person
+		m      map[string]interface{}
+		people []person
+	)
+	if err := row.Scan(&p, &m, &people); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.ID != 1 || p.Name != nil || !reflect.DeepEqual(p.Addresses, []address{{"a"}, {"b"}}) {
+		t.Errorf("Unexpected struct %+v", p)
+	}
+	if !reflect.DeepEqual(m, map[string]interface{}{"x": int64(1)}) {
+		t.Errorf("Unexpected map %v", m)
+	}
+	if len(people) != 1 || people[0].ID != 2 || *people[0].Name != "c" || len(people[0].Addresses) != 0 {
+		t.Errorf("Unexpected structs %+v", people)
+	}
+
+	var unmapped struct{ Other int }
+	if err := (Row{Values: [][]byte{[]byte(`{"x":1}`)}, fields: fields[:1]}).Scan(&unmapped); err == nil {
+		t.Error("Expected an error for a member that can't be mapped onto the struct")
+	}
+}
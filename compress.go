@@ -0,0 +1,89 @@
+package vertigo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// Compresses the data of a COPY FROM STDIN statement on the client, so less of it
+// is sent over the network. The server decompresses it when the statement has the
+// matching compression option, like GZIP. Vertica supports GZIP, BZIP, LZO and ZSTD;
+// GzipCompressor is built in, and compressors for the other formats can be written
+// on top of their Go implementations.
+type Compressor interface {
+	// Returns the compression option of COPY for the format, e.g. "GZIP".
+	CopyOption() string
+
+	// Returns a writer that compresses everything written to it into w. Closing it
+	// flushes the compressed data, but doesn't close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// Compresses COPY data with gzip.
+type GzipCompressor struct {
+	Level int // The compression level, see compress/gzip. Zero uses the default level.
+}
+
+func (c GzipCompressor) CopyOption() string {
+	return "GZIP"
+}
+
+func (c GzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// Returns a reader that compresses the data read from data with the compressor, to
+// pass to CopyIn. The COPY statement needs the compression option of the compressor:
+//
+//	connection.CopyIn("COPY t FROM STDIN GZIP", vertigo.Compress(file, vertigo.GzipCompressor{}))
+//
+// The data is compressed while it is read, so it is never held in memory as a whole.
+func Compress(data io.Reader, compressor Compressor) io.Reader {
+	return &compressingReader{data: data, compressor: compressor}
+}
+
+type compressingReader struct {
+	data       io.Reader
+	compressor Compressor
+	writer     io.WriteCloser // Compresses into buffer, once the first data was read
+	buffer     bytes.Buffer   // The compressed data that hasn't been read yet
+	chunk      []byte
+	err        error
+}
+
+func (r *compressingReader) Read(p []byte) (int, error) {
+	if r.writer == nil && r.err == nil {
+		r.chunk = make([]byte, copyChunkSize)
+		r.writer, r.err = r.compressor.NewWriter(&r.buffer)
+	}
+
+	// Compressors buffer their output, so a read may take more than one chunk.
+	for r.buffer.Len() == 0 && r.err == nil {
+		n, err := r.data.Read(r.chunk)
+		if n > 0 {
+			if _, err := r.writer.Write(r.chunk[:n]); err != nil {
+				r.err = err
+				break
+			}
+		}
+
+		switch {
+		case err == io.EOF:
+			if r.err = r.writer.Close(); r.err == nil {
+				r.err = io.EOF
+			}
+		case err != nil:
+			r.err = err
+		}
+	}
+
+	if r.buffer.Len() > 0 {
+		return r.buffer.Read(p)
+	}
+	return 0, r.err
+}
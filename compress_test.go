@@ -0,0 +1,51 @@
+package vertigo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func gunzip(t *testing.T, data []byte) string {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(text)
+}
+
+func TestCompress(t *testing.T) {
+	data := strings.Repeat("1|some text\n", 20000)
+	compressed, err := io.ReadAll(Compress(strings.NewReader(data), GzipCompressor{Level: gzip.BestSpeed}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(data) {
+		t.Fatalf("Expected the data to be compressed, but found %d bytes for %d", len(compressed), len(data))
+	}
+	if text := gunzip(t, compressed); text != data {
+		t.Fatalf("Expected the compressed data to decompress to the data")
+	}
+
+	if _, err := io.ReadAll(Compress(io.MultiReader(strings.NewReader(data), failingReader{}), GzipCompressor{})); err == nil || err.Error() != "Disk on fire" {
+		t.Fatalf("Expected the read error, but found %v", err)
+	}
+}
+
+func TestCopyLoaderCompressor(t *testing.T) {
+	server, connection := newCopyServer(t, 0)
+	server.Expect(`COPY "t" ("id") FROM STDIN GZIP DELIMITER '|' NULL E'\\N'`).CopyIn()
+
+	if _, err := (CopyLoader{Table: "t", Compressor: GzipCompressor{}}).Load(connection, []copyBase{{ID: 1}, {ID: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if copies := server.CopyData(); len(copies) != 1 || gunzip(t, copies[0]) != "1\n2\n" {
+		t.Fatalf("Expected the rows to be compressed, but found %q", copies)
+	}
+}
@@ -2,39 +2,267 @@ package vertigo
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/url"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+const validationTimeout = 5 * time.Second // How long the server gets to answer a connection validation
+
 var (
 	SslNotSupported                  = errors.New("SSL not available on this server")
 	AuthenticationMethodNotSupported = errors.New("Authentication method not supported")
+	ErrNoRows                        = errors.New("Query did not return any rows")
+	ErrTooManyRows                   = errors.New("Query returned more than one row")
 )
 
 // Struct to hold all the information necessary to connect to the Vertics server.
 type ConnectionInfo struct {
 	Address   string      // The address of the Vertica server. Should include a port number.
 	User      string      // The user to connect with.
-	Password  string      // The password for this user.
+	Password  string      // The password for this user. Ignored if CredentialProvider is set.
 	Database  string      // The database to connect to. This can be left empty.
 	SslConfig *tls.Config // The tls.Config struct to use for SSL connections.
+
+	// Session parameters that are set right after the connection is opened, and again
+	// whenever it is reopened, like "search_path", "timezone", "locale" or "resource_pool".
+	// Parameters without a dedicated SET statement are set with ALTER SESSION SET. The
+	// values are quoted, or, for "datestyle", "intervalstyle" and "autocommit", may only
+	// hold letters, digits, underscores, commas and spaces.
+	SessionParams map[string]string
+
+	// When set, connections try the addresses this resolver returns, in order, instead of
+	// the Address. The Address is still used when resolving fails, and can be left empty.
+	// See SRVResolver for resolving the nodes from DNS SRV records.
+	HostResolver HostResolver
+
+	// When set, the nodes of the cluster are discovered after connecting, and used to fail
+	// over to when the node at Address is unreachable, or to spread connections over.
+	Topology *Topology
+
+	// The Eon mode subcluster to connect to, so workloads can be pinned to a subcluster.
+	// Connections only go to the UP nodes of the subcluster, which are discovered with the
+	// Topology. The first connection may go to the Address to discover them, and is then
+	// reopened to a node of the subcluster.
+	Subcluster string
+
+	// The maximum number of prepared statements that are cached by Connection.Prepare,
+	// and reused when the same SQL is prepared again. Zero disables the cache.
+	StatementCacheSize int
+
+	// The number of bytes of rows to fetch per batch from the portals executed with
+	// AdaptiveFetchSize. Zero uses a default of 1 MiB.
+	FetchBytes int
+
+	// The workload the session is tagged with, which the routing rules configured on the
+	// server with CREATE ROUTING RULE use to route it to a subcluster. It is sent when
+	// the connection is opened, and again whenever it is reopened.
+	Workload string
+
+	// The label of the session and its statements, like "nightly-etl-step-3", so workload
+	// management rules and monitoring can key off it. It is sent as the client label of the
+	// session, and added as a /*+LABEL(...)*/ hint to the statements that accept one and
+	// don't have a label yet, unless their context has another one, see WithQueryLabel.
+	Label string
+
+	// The resource pool to run the session in, to isolate workloads from each other. The
+	// session is moved into the pool right after the connection is opened, and connecting
+	// fails if the pool doesn't exist or the user isn't allowed to use it.
+	ResourcePool string
+
+	// When set, the session is made read-only right after the connection is opened, so the
+	// server rejects statements that modify data. Statements that obviously do, like INSERT,
+	// COPY or DROP, are also rejected by the client with an error matching ErrReadOnly
+	// before they are sent, unless SkipReadOnlyCheck leaves all checking to the server.
+	ReadOnly          bool
+	SkipReadOnlyCheck bool
+
+	// When set, the password is fetched from this provider every time the connection is
+	// opened, instead of using the static Password. This allows secrets to be rotated.
+	CredentialProvider CredentialProvider
+
+	// The maximum time to wait for the connection to be established and authenticated.
+	// Zero means no timeout.
+	ConnectTimeout time.Duration
+
+	// When set, network connections to the nodes are opened with this function instead
+	// of directly, e.g. to reach a cluster through an SSH tunnel. This includes the
+	// connections that cancel running statements.
+	Dial DialFunc
+
+	// When the host of an address resolves to both IPv6 and IPv4 addresses, connecting
+	// over IPv4 starts after this delay if IPv6 hasn't connected yet, and the first to
+	// connect wins ("Happy Eyeballs", RFC 8305). This keeps broken IPv6 routes from stalling
+	// connecting. Zero uses a default of 300ms, and a negative value disables the fallback.
+	// It doesn't apply when Dial is set.
+	FallbackDelay time.Duration
+
+	// When set, network connections are opened through the proxy this function returns
+	// for the address of the node, like with http.Transport.Proxy. SOCKS5 (socks5 and
+	// socks5h) and HTTP CONNECT (http and https) proxies are supported. No proxy is used
+	// if it returns nil. See ProxyFromEnvironment and ProxyURL.
+	Proxy func(address string) (*url.URL, error)
+
+	// How connecting is retried when the cluster can't be reached, like while it is
+	// starting up. The zero value makes a single attempt. Connections that are reopened
+	// after they broke are retried as well.
+	ConnectRetry RetryPolicy
+
+	// The maximum time Close waits for a statement running on another goroutine to
+	// end after cancelling it, and for the server to accept the terminate message.
+	// Zero uses a default of five seconds.
+	CloseTimeout time.Duration
+
+	// Client-side statement timeout. When a statement runs longer, the client asks the
+	// server to cancel it and Query returns a *ClientTimeoutError. Zero disables the timeout.
+	ClientTimeout time.Duration
+
+	// When a connection has been idle for longer than this duration, it is validated with a
+	// Sync round trip before running the next query, and reopened if it turns out to be broken.
+	// This catches connections silently dropped by load balancers. Zero disables validation.
+	ValidateAfterIdle time.Duration
+
+	// When set, a JSON line describing every statement that is run on the connection is written
+	// to this writer. The writer should be safe for concurrent use if it is shared by connections.
+	AuditLog io.Writer
+
+	// When set, a Sync message is sent on the connection whenever it has been idle for this
+	// duration, which detects dead peers early and keeps NAT mappings and load balancers from
+	// dropping the connection. The connection is broken when the server doesn't answer. The
+	// connection has to be closed to stop the heartbeats. Zero disables heartbeats. Only the
+	// connections opened by Open, Pool and the database/sql driver send heartbeats, as those
+	// returned by Connect are copied by the caller.
+	HeartbeatInterval time.Duration
+
+	// When set, the lifecycle events of the connection, like connecting, disconnecting and
+	// failing over to another node, are reported to this listener.
+	Events ConnectionEvents
+
+	// Decode TIMESTAMPTZ values in UTC, instead of in the session time zone reported by the server.
+	ForceUTC bool
+
+	// Called whenever a value is converted into a Go type that can't represent it exactly,
+	// e.g. when a NUMERIC value with more significant digits than a float64 can hold is
+	// scanned into a float64. It should be safe for concurrent use if it is shared by
+	// connections.
+	CoercionWarningHandler func(warning *CoercionWarning)
+
+	// When set, lossy conversions fail with a *CoercionWarning error instead of only
+	// being reported to the CoercionWarningHandler.
+	StrictCoercions bool
+
+	// When set, the connection logs its statements and protocol traffic to this logger.
+	// See NewSlogLogger for logging to an slog.Logger.
+	Logger Logger
+
+	// Statements that take longer than this duration are logged with a warning to the Logger,
+	// including their query text, duration, row count and the PID of the backend process.
+	// Zero disables slow query logging.
+	SlowQueryThreshold time.Duration
+
+	// When set, every protocol message sent and received is written to this writer as a raw
+	// frame dump: the direction, the type byte, the length and a hex dump of the body. This is
+	// meant for diagnosing protocol errors, and is separate from the Logger.
+	WireDump io.Writer
+
+	// Passwords in statements like CREATE USER are redacted from the SQL text in all logs and
+	// dumps. When set, the Redactor is applied as well, to scrub application-specific data.
+	Redactor Redactor
+
+	// When set, the connection reports the events statistics are derived from to this collector.
+	// The statistics of a single connection are also available through Connection.Stats.
+	StatsCollector StatsCollector
+
+	// When set, a comment with the tags this function returns for the context of a statement
+	// is appended to it in the sqlcommenter format, like /*app='billing',traceparent='...'*/,
+	// so the statements in v_monitor.query_requests can be correlated with traces. See
+	// TraceComment.
+	QueryComment func(ctx context.Context) map[string]string
+
+	// Wrap the statements run on the connection, the first one outermost, e.g. to log, retry
+	// or rewrite them. See QueryInterceptor.
+	Interceptors []QueryInterceptor
+
+	// Limits on the resultsets buffered in memory by Query, to protect against running out of
+	// memory on an accidental SELECT * of a huge table. Zero disables a limit. When a limit is
+	// exceeded, the query is cancelled and Query returns an error matching ErrResultTooLarge.
+	MaxRows       int   // The maximum number of rows.
+	MaxResultSize int64 // The maximum total size of the values, in bytes.
+
+	// When set, Query doesn't fail when a resultset exceeds its limits, but spills the remaining
+	// rows to a temporary file in this directory. See Resultset.EachRow and Resultset.Close.
+	SpillDir string
+
+	// The maximum size of a message from the server that is read into memory, in bytes. Larger
+	// messages are rejected with a *ProtocolError, which breaks the connection. This protects
+	// against corrupted length fields. Zero disables the limit. Rows read by QueryStream
+	// aren't read into memory, and aren't limited.
+	MaxMessageSize int
+
+	// The size of the buffer responses are read through, in bytes. Scans of wide rows read
+	// far fewer times with a buffer of 256 KiB or more, while the default of 4 KiB keeps
+	// the memory of many connections with small results low.
+	ReadBufferSize int
+
+	// When set, the progress of every statement is reported to this function while it
+	// runs, every ProgressInterval, and once more when it completes. This is meant for
+	// progress bars and heartbeats of long COPY loads and exports. The function is called
+	// while the statement is running, so it must not use the connection.
+	Progress         func(Progress)
+	ProgressInterval time.Duration // Defaults to one second.
+
+	// When set, these hooks are called before and after every statement that is run on the
+	// connection, e.g. to trace them. The context OnQueryStart returns is passed to OnQueryEnd,
+	// so it can carry a span; nil keeps the context of the statement. The hooks are called
+	// while the connection is locked, so they must not use it.
+	OnQueryStart func(ctx context.Context, event QueryEvent) context.Context
+	OnQueryEnd   func(ctx context.Context, event QueryEvent)
 }
 
 // The main connection object.
 type Connection struct {
 	l sync.Mutex // Connection lock to make sure only one command runs at a time
 
-	config            *ConnectionInfo   // Holds the connection parameters
-	socket            net.Conn          // The network socket of this connection
-	parameters        map[string]string // Server parameters the client gets told about when connecting
-	backendPid        uint32            // The PID of the server's process.
-	backendKey        uint32            // The secret key of the server's backend process.
-	transactionStatus byte              // The current transaction status of the connection
-	bufioReader       io.Reader         // Read all data from socket via buffered reader. Minimize syscalls
+	config            *ConnectionInfo    // Holds the connection parameters
+	address           string             // The address of the node the connection was last opened to
+	socket            net.Conn           // The network socket of this connection
+	parameters        map[string]string  // Server parameters the client gets told about when connecting
+	backendPid        uint32             // The PID of the server's process.
+	backendKey        uint32             // The secret key of the server's backend process.
+	transactionStatus TransactionStatus  // The current transaction status of the connection
+	bufioReader       *bufio.Reader      // Read all data from socket via buffered reader. Minimize syscalls
+	header            [5]byte            // The header of the last message received, read here to save allocations
+	rowBuffer         rowBuffer          // Where the rows received are read into
+	idleSince         time.Time          // The time the server last reported it was ready for a query
+	location          *time.Location     // The session time zone, as reported by the server
+	statements        int                // The number of statements prepared, used to name them
+	session           int                // Changes whenever the connection is reset, so statements know when to re-prepare
+	portal            *Portal            // The portal that is being fetched from, if any
+	stmtCache         *statementCache    // The cached prepared statements of the session, if enabled
+	prepared          map[*Stmt]struct{} // The other open statements prepared in the session
+	stats             connectionStats    // The counters behind Stats
+	broken            error              // The error that broke the connection, if any
+	progress          *progressTracker   // Tracks the progress of the running statement, if it is reported
+	heartbeat         *heartbeat         // Sends heartbeats while the connection is idle, if enabled
+	connectedTo       string             // The address the last session was opened to, for ConnectionEvents
+	established       bool               // Whether the session was opened, for ConnectionEvents
+
+	parameterChange func(name, old, new string)   // Called when the server reports a changed parameter
+	privateTopology *Topology                     // The topology used for a Subcluster without a configured Topology
+	shared          atomic.Pointer[sharedSession] // The session as seen by Close from other goroutines
+
+	// Whether the server reported standard_conforming_strings off. It is read to quote
+	// arguments before the connection lock is taken, so it is kept apart from parameters.
+	nonstandardStrings atomic.Bool
 }
 
 // Opens a connection to the server using the information in the config parameter.
@@ -58,31 +286,53 @@ func Connect(config *ConnectionInfo) (connection Connection, connectionError err
 	return connection, nil
 }
 
-// Returns the current transaction status of the connection.
-func (c *Connection) TransactionStatus() byte {
+// Returns the address of the node the connection was opened to. This differs from
+// the configured address when the connection failed over to another node.
+func (c *Connection) Address() string {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.address
+}
+
+// Returns the transaction status of the connection, as of the end of the last
+// statement. Pools can use it to detect connections that were left in a transaction,
+// or in a failed transaction that has to be rolled back.
+func (c *Connection) TransactionStatus() TransactionStatus {
+	c.l.Lock()
+	defer c.l.Unlock()
 	return c.transactionStatus
 }
 
-// Closes the connection to the server.
-//
-// It will try to gracefully terminate the connection by sending the server
-// a terminate message. Regardless of whether this succeeds, the socket will be
-// closed and the status of the connection will be reset.
-func (c *Connection) Close() (err error) {
-	defer c.resetConnection()
-	defer func() {
-		if r := recover(); r != nil {
-			err = r.(error)
-		}
-	}()
+// Returns the value of a parameter the server reported, like "timezone" or
+// "standard_conforming_strings". The second return value is false if the server
+// didn't report the parameter.
+func (c *Connection) ServerParameter(name string) (string, bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	value, ok := c.parameters[name]
+	return value, ok
+}
 
-	if c.socket != nil {
-		c.sendMessage(TerminateMessage{})
-	} else {
-		panic("Socket is not open")
-	}
+// Returns the version of the server, e.g. "v12.0.4-0".
+func (c *Connection) ServerVersion() string {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.parameters["server_version"]
+}
 
-	return nil
+// Returns whether backslashes are ordinary characters in string literals that
+// aren't escape strings, as reported by the server. They are unless the server
+// reports otherwise.
+func (c *Connection) standardConformingStrings() bool {
+	return !c.nonstandardStrings.Load()
+}
+
+// Registers a function that is called when the server reports a new value for a
+// parameter during the session, e.g. after SET TIME ZONE. The values reported when
+// the connection is opened don't trigger it. The function is called while a
+// statement is running, so it must not use the connection.
+func (c *Connection) OnParameterChange(fn func(name, old, new string)) {
+	c.parameterChange = fn
 }
 
 // Runs a SQL connection on the server.
@@ -91,71 +341,374 @@ func (c *Connection) Close() (err error) {
 // When the server returns an error response, this will be returned as the second
 // return value.
 //
-// If a connection occurs, the state of the connection is undeterministic, so
+// If a connection error occurs, the state of the connection is undeterministic, so
 // the connection will be closed, and the connection error will be returned as
-// the second return value. The connection will automatically try to reconnect
-// if you try to use it for a query again.
-func (c *Connection) Query(sql string) (resultset *Resultset, queryError error) {
+// the second return value. The connection is then marked as broken, and further
+// queries fail with ErrConnectionBroken until Reconnect is called.
+//
+// If ClientTimeout is set and the query takes longer, the query is cancelled and
+// a *ClientTimeoutError is returned as the second return value.
+//
+// Any ? placeholders in the SQL string are replaced by the quoted literals of args,
+// in the order they are given. When the only argument is a map[string]interface{},
+// :name and @name placeholders are replaced by the values of the map instead.
+//
+// The size of the resultset is bounded by the MaxRows and MaxResultSize settings
+// of the connection.
+//
+// If the SQL string contains multiple statements, only the resultset of the last
+// statement is returned. Use QueryMulti to get all of them.
+func (c *Connection) Query(sql string, args ...interface{}) (*Resultset, error) {
+	return c.QueryContext(context.Background(), sql, args...)
+}
+
+// Runs a SQL query like Query, until the context is done. Then the server is asked
+// to cancel the query, and the rest of its response is read and discarded, so the
+// connection can still be used. The error of the context is returned. When the server
+// doesn't honor the cancel request in time, the connection is broken instead.
+func (c *Connection) QueryContext(ctx context.Context, sql string, args ...interface{}) (*Resultset, error) {
+	result, err := c.intercept(ctx, sql, args, func(ctx context.Context, query Query) (QueryResult, error) {
+		handler := c.newResultsetHandler()
+		result, err := c.execute(ctx, query.SQL, query.Args, handler)
+		if handler.err != nil {
+			err = handler.err
+		}
+		if err != nil {
+			if handler.resultset != nil {
+				handler.resultset.Close()
+			}
+			return result, err
+		}
+		result.Resultset = handler.resultset
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Resultset, nil
+}
+
+// Runs a SQL string with multiple statements separated by semicolons, and returns
+// the resultsets of all statements in order. Statements that don't return rows,
+// like DDL statements, get a resultset without fields and rows.
+//
+// When a statement fails, the server skips the remaining statements. The error is
+// returned together with the resultsets of the statements that completed before it.
+// Otherwise errors are handled the same way as they are by Query.
+func (c *Connection) QueryMulti(sql string, args ...interface{}) ([]*Resultset, error) {
+	handler := &multiResultsetHandler{newHandler: c.newResultsetHandler}
+	err := c.run(sql, args, handler)
+	if handler.current != nil && handler.current.err != nil {
+		err = handler.current.err
+	}
+	if err != nil && handler.current != nil && handler.current.resultset != nil {
+		handler.current.resultset.Close()
+	}
+	return handler.resultsets, err
+}
+
+// Returns a handler that collects a resultset within the limits of the connection.
+func (c *Connection) newResultsetHandler() *resultsetHandler {
+	return &resultsetHandler{
+		maxRows:  c.config.MaxRows,
+		maxSize:  c.config.MaxResultSize,
+		spillDir: c.config.SpillDir,
+		cancel:   c.cancelRunningQuery,
+	}
+}
+
+// Runs a SQL statement on the server, discarding any rows it returns.
+//
+// This is meant for DDL and DML statements, for which only the command tag
+// is of interest. Errors are handled the same way as they are by Query.
+func (c *Connection) Exec(sql string, args ...interface{}) (Result, error) {
+	return c.ExecContext(context.Background(), sql, args...)
+}
+
+// Runs a SQL statement like Exec, until the context is done. Cancellation is handled
+// the same way as it is by QueryContext.
+func (c *Connection) ExecContext(ctx context.Context, sql string, args ...interface{}) (Result, error) {
+	handler := &discardHandler{}
+	if err := c.runContext(ctx, sql, args, handler); err != nil {
+		return Result{}, err
+	}
+	return Result{CommandTag: handler.tag}, nil
+}
+
+// Runs a SQL query on the server, and passes the resultset to the handler as
+// it is received.
+func (c *Connection) run(sql string, args []interface{}, handler resultHandler) error {
+	return c.runContext(context.Background(), sql, args, handler)
+}
+
+// Runs a SQL query like run, passing it through the interceptors of the connection.
+func (c *Connection) runContext(ctx context.Context, sql string, args []interface{}, handler resultHandler) error {
+	_, err := c.intercept(ctx, sql, args, func(ctx context.Context, query Query) (QueryResult, error) {
+		return c.execute(ctx, query.SQL, query.Args, handler)
+	})
+	return err
+}
+
+// Runs a SQL query on the server, and cancels it when the context is done. The response
+// is drained up to the ReadyForQuery message either way, so the protocol stays in sync.
+func (c *Connection) execute(ctx context.Context, sql string, args []interface{}, handler resultHandler) (result QueryResult, queryError error) {
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if len(args) > 0 {
+		if sql, queryError = interpolate(sql, args, c.standardConformingStrings()); queryError != nil {
+			return result, queryError
+		}
+	}
+	if sql, queryError = c.labelQuery(ctx, sql); queryError != nil {
+		return result, queryError
+	}
+	sql = c.commentQuery(ctx, sql)
+	if queryError = c.checkReadOnly(sql); queryError != nil {
+		return result, queryError
+	}
+
 	c.l.Lock()
 	defer c.l.Unlock()
 
+	if c.portal != nil {
+		return result, ErrPortalOpen
+	}
+	if err := c.brokenError(); err != nil {
+		return result, err
+	}
+
+	var (
+		canceller    *watchdog // Cancels the statement when the context is done
+		watchdog     *watchdog
+		rowsReceived int
+		backendPid   uint32
+		start        = time.Now()
+	)
+	event := QueryEvent{SQL: c.redact(sql), Start: start, BackendPid: c.backendPid}
+	hookCtx := c.queryStarted(ctx, &event)
+	c.progress = c.startProgress(sql)
 	defer func() {
 		if r := recover(); r != nil {
-			c.resetConnection()
 			queryError = r.(error)
+			c.markBroken(queryError)
+			c.log(LogLevelWarn, "Connection reset", "address", c.address, "error", queryError)
+		}
+
+		c.progress.finish(rowsReceived)
+		c.progress = nil
+		result.Rows = rowsReceived
+
+		if watchdog != nil && watchdog.stop() {
+			queryError = &ClientTimeoutError{Timeout: c.config.ClientTimeout, RowsReceived: rowsReceived}
+		}
+		if canceller != nil && canceller.stop() {
+			queryError = ctx.Err()
+		}
+
+		if c.config.AuditLog != nil {
+			c.writeAuditRecord(sql, start, rowsReceived, queryError)
+		}
+
+		duration := time.Since(start)
+		c.recordQuery(duration, rowsReceived, queryError)
+		event.BackendPid = backendPid
+		c.queryEnded(hookCtx, &event, rowsReceived, queryError)
+
+		if c.config.SlowQueryThreshold > 0 && duration > c.config.SlowQueryThreshold {
+			c.log(LogLevelWarn, "Slow query", "query", c.redact(sql), "duration", duration, "rows", rowsReceived, "pid", backendPid)
+		}
+
+		if queryError != nil {
+			c.log(LogLevelError, "Query failed", "query", c.redact(sql), "duration", duration, "rows", rowsReceived, "error", queryError)
+		} else {
+			c.log(LogLevelInfo, "Query completed", "query", c.redact(sql), "duration", duration, "rows", rowsReceived)
 		}
 	}()
 
+	if c.socket != nil && c.config.ValidateAfterIdle > 0 && time.Since(c.idleSince) > c.config.ValidateAfterIdle {
+		c.validateConnection()
+	}
+
 	if c.socket == nil {
 		c.openConnection()
 	}
 
+	backendPid = c.backendPid
+	capStatement, capped := runtimeCapStatement(ctx)
+	var previousCap string
+	if capped {
+		if previousCap, queryError = c.sessionRuntimeCap(); queryError != nil {
+			return result, queryError
+		}
+		if queryError = c.execInternal(capStatement, &discardHandler{}); queryError != nil {
+			return result, queryError
+		}
+	}
+
+	if c.config.ClientTimeout > 0 {
+		watchdog = c.startWatchdog(c.config.ClientTimeout)
+	}
+	if ctx.Done() != nil {
+		canceller = c.watchContext(ctx)
+	}
+
+	streamer, _ := handler.(rowStreamer)
+	_, c.rowBuffer.transient = handler.(transientRowHandler)
+	defer func() { c.rowBuffer.transient = false }()
 	c.sendMessage(QueryMessage{SQL: sql})
-	for msg := c.receiveMessage(); !c.isReadyForQuery(msg); msg = c.receiveMessage() {
+	for msg := c.receiveStreamedMessage(streamer); !c.isReadyForQuery(msg); msg = c.receiveStreamedMessage(streamer) {
+		c.progress.update(rowsReceived)
+
 		switch msg := msg.(type) {
-		case EmptyQueryMessage, ErrorResponseMessage:
-			resultset = nil
-			queryError = msg.(error)
+		case EmptyQueryMessage:
+			queryError = msg
+
+		case ErrorResponseMessage:
+			queryError = msg.VerticaError()
 
 		case RowDescriptionMessage:
-			resultset = &Resultset{Fields: msg.Fields}
+			c.prepareFields(msg.Fields)
+			handler.handleFields(msg.Fields)
 
 		case DataRowMessage:
-			resultset.Rows = append(resultset.Rows, Row{Values: msg.Values})
+			handler.handleRow(msg.Values)
+			rowsReceived++
+
+		case streamedDataRowMessage:
+			rowsReceived++
 
 		case CommandCompleteMessage:
-			resultset.Result = msg.Result
+			handler.handleComplete(msg.Result)
+			result.CommandTag = CommandTag(msg.Result)
+
+		case CopyInResponseMessage:
+			c.sendCopyData(handler)
+
+		case NoticeResponseMessage:
+			if receiver, ok := handler.(noticeReceiver); ok {
+				receiver.handleNotice(msg)
+			}
+			c.handleStatelessMessage(msg)
 
 		default:
 			c.handleStatelessMessage(msg)
 		}
 	}
+
+	if capped {
+		if err := c.execInternal(restoreRuntimeCapStatement(previousCap), &discardHandler{}); err != nil && queryError == nil {
+			queryError = err
+		}
+	}
 	return
 }
 
+// Runs a SQL query that is expected to return exactly one row, and returns that row.
+//
+// ErrNoRows is returned when the query doesn't return any rows, and ErrTooManyRows
+// when it returns more than one row.
+func (c *Connection) QueryRow(sql string, args ...interface{}) (Row, error) {
+	resultset, err := c.Query(sql, args...)
+	if err != nil {
+		return Row{}, err
+	}
+
+	if resultset == nil {
+		return Row{}, ErrNoRows
+	}
+	defer resultset.Close()
+
+	switch {
+	case resultset.RowCount() == 0:
+		return Row{}, ErrNoRows
+	case resultset.RowCount() > 1:
+		return Row{}, ErrTooManyRows
+	default:
+		return resultset.Rows[0], nil
+	}
+}
+
+// Runs a SQL query that is expected to return a single value, e.g. "SELECT COUNT(*) FROM t",
+// and converts that value into a T.
+func QueryValue[T any](c *Connection, sql string, args ...interface{}) (value T, err error) {
+	row, err := c.QueryRow(sql, args...)
+	if err != nil {
+		return value, err
+	}
+
+	err = row.Scan(&value)
+	return value, err
+}
+
+// Attaches the session state needed to decode values to the fields of a resultset.
+func (c *Connection) prepareFields(fields []Field) {
+	location := c.location
+	if c.config.ForceUTC {
+		location = time.UTC
+	}
+
+	coercion := c.coercionPolicy()
+	for i := range fields {
+		fields[i].location = location
+		fields[i].coercion = coercion
+	}
+}
+
 // Handles any message from the server that falls outside the stateful parts of
 // the protocol.
 func (c *Connection) handleStatelessMessage(msg IncomingMessage) {
 	switch msg := msg.(type) {
 	case ParameterStatusMessage:
+		old, reported := c.parameters[msg.Name]
 		c.parameters[msg.Name] = msg.Value
+		if reported && old != msg.Value && c.parameterChange != nil {
+			c.parameterChange(msg.Name, old, msg.Value)
+		}
+		if strings.EqualFold(msg.Name, "timezone") {
+			c.location, _ = time.LoadLocation(msg.Value)
+		}
+		if msg.Name == "standard_conforming_strings" {
+			c.nonstandardStrings.Store(msg.Value == "off")
+		}
 
 	case BackendKeyDataMessage:
 		c.backendPid = msg.Pid
 		c.backendKey = msg.Key
 
+	case NoticeResponseMessage:
+		c.log(LogLevelInfo, "Notice", "severity", msg.Fields['S'], "message", msg.Fields['M'], "hint", msg.Fields['H'])
+
 	default:
 		panic(fmt.Errorf("Unexpected message: %#+v", msg))
 	}
 }
 
 // Opens the TCP socket, and optionally initializes the TLS encryption on it.
-// This function will panic if something goes wrong when connecting.
-func (c *Connection) openConnection() {
-	if socket, dialError := net.Dial("tcp", c.config.Address); dialError != nil {
+// This is a single attempt; see openConnection for the retries. This function will
+// panic if something goes wrong when connecting.
+func (c *Connection) openSession() {
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			c.recordConnectAttempt(err)
+			panic(r)
+		}
+		c.recordConnectAttempt(nil)
+	}()
+
+	if socket, dialError := c.dial(); dialError != nil {
 		panic(dialError)
 	} else {
 		c.socket = socket
+		c.shareSession()
+	}
+
+	if c.config.ConnectTimeout > 0 {
+		c.socket.SetDeadline(time.Now().Add(c.config.ConnectTimeout))
 	}
 
 	if c.config.SslConfig != nil {
@@ -174,15 +727,31 @@ func (c *Connection) openConnection() {
 		}
 	}
 
-	c.bufioReader = bufio.NewReader(c.socket)
+	c.bufioReader = bufio.NewReaderSize(c.socket, c.readBufferSize())
 
 	c.authenticateConnection()
+	c.shareSession()
+	c.refreshTopology()
+	if !c.checkSubcluster() {
+		c.log(LogLevelInfo, "Reconnecting to subcluster", "address", c.address, "subcluster", c.config.Subcluster)
+		c.resetConnection()
+		c.openSession()
+		return
+	}
+	c.applySessionParams()
+	c.applyResourcePool()
+	c.applyReadOnly()
+	if c.config.ConnectTimeout > 0 {
+		c.socket.SetDeadline(time.Time{})
+	}
+	c.log(LogLevelInfo, "Connected", "address", c.address, "pid", c.backendPid)
+	c.sessionOpened()
 }
 
 // Initializes the connection by doing the initial authenentication message
 // This function will panic when skmething goes wrong while connecting.
 func (c *Connection) authenticateConnection() {
-	c.sendMessage(StartupMessage{User: c.config.User, Database: c.config.Database})
+	c.sendMessage(StartupMessage{User: c.config.User, Database: c.config.Database, Workload: c.config.Workload, Label: c.config.Label})
 
 	for msg := c.receiveMessage(); !c.isReadyForQuery(msg); msg = c.receiveMessage() {
 		switch msg := msg.(type) {
@@ -191,13 +760,20 @@ func (c *Connection) authenticateConnection() {
 			case AuthenticationOK:
 				continue
 			case AuthenticationCleartextPassword:
-				c.sendMessage(PasswordMessage{Password: c.config.Password, AuthenticationMethod: msg.AuthCode})
+				password, err := c.password()
+				if err != nil {
+					panic(err)
+				}
+				c.sendMessage(PasswordMessage{Password: password, AuthenticationMethod: msg.AuthCode})
 			default:
+				c.authenticationFailed(AuthenticationMethodNotSupported)
 				panic(AuthenticationMethodNotSupported)
 			}
 
 		case ErrorResponseMessage:
-			panic(msg)
+			err := msg.VerticaError()
+			c.authenticationFailed(err)
+			panic(err)
 
 		default:
 			c.handleStatelessMessage(msg)
@@ -206,12 +782,27 @@ func (c *Connection) authenticateConnection() {
 	return
 }
 
+// Validates the connection by sending a Sync message and waiting for the server
+// to report it is ready for a query. If this fails, the connection is reset so
+// it will be reopened before it is used again.
+func (c *Connection) validateConnection() {
+	defer func() {
+		if r := recover(); r != nil {
+			c.sessionEnded(r.(error))
+			c.resetConnection()
+		}
+	}()
+
+	c.syncRoundTrip()
+}
+
 // Checks whether the message from the server is a ReadyForQuery (Z)
 // message. If so, the transaction status for the connection is set.
 func (c *Connection) isReadyForQuery(msg IncomingMessage) bool {
 	typeMsg, ok := msg.(ReadyForQueryMessage)
 	if ok {
-		c.transactionStatus = typeMsg.TransactionStatus
+		c.transactionStatus = TransactionStatus(typeMsg.TransactionStatus)
+		c.idleSince = time.Now()
 	}
 	return ok
 }
@@ -220,45 +811,139 @@ func (c *Connection) isReadyForQuery(msg IncomingMessage) bool {
 // if it still exists, and will reset all the connection status variables
 // to their default vaules.
 func (c *Connection) resetConnection() {
+	c.shared.Store(nil)
 	if c.socket != nil {
 		c.socket.Close()
 		c.socket = nil
 	}
 
 	c.parameters = make(map[string]string)
+	c.nonstandardStrings.Store(false)
+	c.location = nil
+	c.portal = nil
+	c.stmtCache = nil
+	c.prepared = nil
 	c.backendPid = 0
 	c.backendKey = 0
 	c.transactionStatus = 0
+	c.session++
+	c.established = false
 }
 
 // Send a message to the server.
 //
-// This method will log the message to the TrafficLogger if the
-// Traffic logger is set to a logger instance.
+// The message is logged to the Logger of the connection, and to the
+// TrafficLogger if it is set.
 func (c *Connection) sendMessage(msg OutgoingMessage) {
-	err := sendMessage(c.socket, msg)
+	c.writeMessageTo(c.socket, msg)
+}
+
+// Writes a message for the server to w, and logs it like sendMessage does. The message
+// is framed in a pooled buffer, and written with a single call.
+func (c *Connection) writeMessageTo(w io.Writer, msg OutgoingMessage) {
+	buffer := getWriteBuffer()
+	defer putWriteBuffer(buffer)
+
+	c.appendMessage(buffer, msg)
+	if _, err := w.Write(buffer.Bytes()); err != nil {
+		panic(err)
+	}
+}
+
+// Appends the frame of a message for the server to buffer, and logs it.
+func (c *Connection) appendMessage(buffer *bytes.Buffer, msg OutgoingMessage) {
+	messageType, body, err := appendMessage(buffer, msg)
 	if err != nil {
 		panic(err)
 	}
 
+	if c.config.WireDump != nil {
+		c.dumpMessage("=>", messageType, c.redactMessageBody(messageType, body))
+	}
+	c.recordMessageSent(messageType, len(body)+4)
+	if c.config.Logger != nil {
+		c.log(LogLevelDebug, "Sent message", "type", messageTypeName(messageType), "bytes", len(body)+4)
+	}
 	if TrafficLogger != nil {
-		TrafficLogger.Printf("=> %#+v\n", msg)
+		TrafficLogger.Printf("=> %#+v\n", c.redactMessage(msg))
 	}
 }
 
+// The size of the read buffer when ReadBufferSize isn't set, the default of bufio.
+const defaultReadBufferSize = 4096
+
+// Returns the size of the buffer to read responses through.
+func (c *Connection) readBufferSize() int {
+	if c.config.ReadBufferSize > 0 {
+		return c.config.ReadBufferSize
+	}
+	return defaultReadBufferSize
+}
+
 // Receive a message from the server.
 //
-// This method will log the message to the TrafficLogger if the
-// Traffic logger is set to a logger instance.
+// The message is logged to the Logger of the connection, and to the
+// TrafficLogger if it is set.
 func (c *Connection) receiveMessage() IncomingMessage {
-	msg, err := receiveMessage(c.bufioReader)
+	messageType, bodySize, err := receiveMessageHeader(c.bufioReader, c.header[:])
 	if err != nil {
 		panic(err)
 	}
 
+	body := c.receiveMessageBody(messageType, bodySize)
+	var msg IncomingMessage
+	if messageType == 'D' {
+		if msg, err = parseDataRow(body, c.rowBuffer.allocValues); err != nil {
+			err = &ProtocolError{MessageType: messageType, Err: err}
+		}
+	} else {
+		msg, err = parseMessage(messageType, body)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	c.observeReceivedMessage(messageType, bodySize, msg)
+	return msg
+}
+
+// Reads the body of a message into memory, enforcing MaxMessageSize. The bodies of
+// DataRow messages are read into the rowBuffer.
+func (c *Connection) receiveMessageBody(messageType byte, bodySize int) []byte {
+	if c.config.MaxMessageSize > 0 && bodySize > c.config.MaxMessageSize {
+		panic(&ProtocolError{MessageType: messageType, Err: fmt.Errorf("Message of %d bytes exceeds the maximum message size of %d bytes", bodySize, c.config.MaxMessageSize)})
+	}
+
+	var body []byte
+	if messageType == 'D' {
+		body = c.rowBuffer.allocBody(bodySize)
+	} else {
+		body = make([]byte, bodySize)
+	}
+	if _, err := io.ReadFull(c.bufioReader, body); err != nil {
+		panic(err)
+	}
+	c.dumpMessage("<=", messageType, body)
+	return body
+}
+
+// Counts and logs a message received from the server.
+func (c *Connection) observeReceivedMessage(messageType byte, bodySize int, msg IncomingMessage) {
+	c.recordMessageReceived(messageType, bodySize+5)
+	if c.config.Logger != nil {
+		// Checked here, as the arguments would be allocated for every row otherwise.
+		c.log(LogLevelDebug, "Received message", "type", messageTypeName(messageType), "bytes", bodySize+5)
+	}
 	if TrafficLogger != nil {
 		TrafficLogger.Printf("<= %#+v", msg)
 	}
+}
 
-	return msg
+// Returns the type of a message as a string. Messages without a type byte, like
+// the startup message, are named after their first byte being zero.
+func messageTypeName(messageType byte) string {
+	if messageType == 0 {
+		return "startup"
+	}
+	return string(rune(messageType))
 }
@@ -2,7 +2,10 @@ package vertigo
 
 import (
 	"crypto/tls"
+	"sync"
 	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
 )
 
 func defaultConnectionInfo() *ConnectionInfo {
@@ -43,3 +46,115 @@ func TestSSLConnecting(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestServerParameters(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.SetParameter("server_version", "v12.0.4-0")
+	server.Expect("SET TIME ZONE TO 'Europe/Amsterdam'").Parameter("timezone", "Europe/Amsterdam").Tag("SET")
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if version := connection.ServerVersion(); version != "v12.0.4-0" {
+		t.Fatalf("Expected server version v12.0.4-0, but found %q", version)
+	}
+	if _, ok := connection.ServerParameter("no_such_parameter"); ok {
+		t.Fatalf("Expected an unknown parameter not to be found")
+	}
+
+	var changes []string
+	connection.OnParameterChange(func(name, old, new string) {
+		changes = append(changes, name+": "+old+" => "+new)
+	})
+
+	if _, err := connection.Exec("SET TIME ZONE TO 'Europe/Amsterdam'"); err != nil {
+		t.Fatal(err)
+	}
+	if timezone, _ := connection.ServerParameter("timezone"); timezone != "Europe/Amsterdam" {
+		t.Fatalf("Expected time zone Europe/Amsterdam, but found %q", timezone)
+	}
+	if len(changes) != 1 || changes[0] != "timezone: UTC => Europe/Amsterdam" {
+		t.Fatalf("Expected a single time zone change, but found %q", changes)
+	}
+}
+
+func TestConcurrentParameterStatus(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SET STANDARD_CONFORMING_STRINGS TO ON").Parameter("standard_conforming_strings", "on").Tag("SET")
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "one", Type: DataTypeInteger}).Row(1)
+
+	connection, err := Open(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	// Arguments are quoted before the statement takes the lock, while the other one
+	// receives parameters.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if _, err := connection.Exec("SET STANDARD_CONFORMING_STRINGS TO ON"); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if _, err := connection.Query("SELECT ?", 1); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+func TestTransactionStatus(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("BEGIN").TransactionStatus('T')
+	server.Expect("SELECT 1/0").Error(ErrCodeDivisionByZero, "Division by zero").TransactionStatus('E')
+	server.Expect("ROLLBACK").TransactionStatus('I')
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		sql    string
+		status TransactionStatus
+	}{
+		{"BEGIN", TransactionStatusInTransaction},
+		{"SELECT 1/0", TransactionStatusFailed},
+		{"ROLLBACK", TransactionStatusIdle},
+	}
+	for _, e := range expected {
+		connection.Exec(e.sql)
+		if status := connection.TransactionStatus(); status != e.status {
+			t.Fatalf("Expected transaction status %s after %s, but found %s", e.status, e.sql, status)
+		}
+	}
+
+	connection.Close()
+	if status := connection.TransactionStatus(); status != TransactionStatusUnknown || status.String() != "unknown" {
+		t.Fatalf("Expected an unknown transaction status after Close, but found %s", status)
+	}
+}
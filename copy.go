@@ -0,0 +1,131 @@
+package vertigo
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// The size of the chunks of data sent by CopyIn.
+const copyChunkSize = 64 * 1024
+
+// The outcome of a COPY statement.
+type CopyResult struct {
+	Result
+
+	Loaded   int64 // The number of rows that were loaded
+	Rejected int64 // The number of rows that were rejected, because they couldn't be parsed or converted
+}
+
+// A row that a COPY statement rejected, as it is stored in the table given with the
+// REJECTED DATA AS TABLE option of the statement.
+type CopyRejection struct {
+	NodeName      string `db:"node_name"`
+	FileName      string `db:"file_name"` // STDIN for rows loaded with CopyIn
+	SessionID     string `db:"session_id"`
+	TransactionID int64  `db:"transaction_id"`
+	StatementID   int64  `db:"statement_id"`
+	BatchNumber   int64  `db:"batch_number"`
+	RowNumber     int64  `db:"row_number"` // The number of the row in the input
+	Data          string `db:"rejected_data"`
+	Reason        string `db:"rejected_reason"` // The exception that rejected the row
+}
+
+// Collects the outcome of a COPY FROM STDIN statement, and holds the data to send
+// when the server asks for it.
+type copyHandler struct {
+	discardHandler
+
+	data   io.Reader
+	err    error  // The error reading the data, if any
+	loaded *int64 // The number of rows loaded, if the server returned it as a resultset
+}
+
+// Vertica returns the number of loaded rows as a single row resultset.
+func (h *copyHandler) handleRow(values [][]byte) {
+	if h.loaded != nil || len(values) == 0 {
+		return
+	}
+	if loaded, err := strconv.ParseInt(string(values[0]), 10, 64); err == nil {
+		h.loaded = &loaded
+	}
+}
+
+// Runs a COPY ... FROM STDIN statement, and sends the data read from data until
+// io.EOF as its input. The data has to be in the format the statement expects,
+// e.g. delimited text for the default parser.
+//
+// The number of rejected rows is looked up with GET_NUM_REJECTED_ROWS after the COPY
+// completed. To find out which rows were rejected and why, use the REJECTED DATA AS
+// TABLE option of COPY, and read the table with CopyRejections.
+//
+// When reading the data fails, the COPY is aborted and the read error is returned.
+// Errors are otherwise handled the same way as they are by Query.
+func (c *Connection) CopyIn(sql string, data io.Reader, args ...interface{}) (CopyResult, error) {
+	handler := &copyHandler{data: data}
+	err := c.run(sql, args, handler)
+	if handler.err != nil {
+		err = handler.err
+	}
+	if err != nil {
+		return CopyResult{}, err
+	}
+
+	result := CopyResult{Result: Result{CommandTag: handler.tag}, Loaded: handler.tag.RowsAffected()}
+	if handler.loaded != nil {
+		result.Loaded = *handler.loaded
+	}
+
+	if result.Rejected, err = QueryValue[int64](c, "SELECT GET_NUM_REJECTED_ROWS()"); err != nil {
+		return result, fmt.Errorf("Cannot count the rejected rows: %w", err)
+	}
+	return result, nil
+}
+
+// Returns the rows rejected by the COPY statements of this session that stored their
+// rejected rows in the table, with the REJECTED DATA AS TABLE option. The rows are
+// ordered by transaction and statement, so the rejections of the last COPY come last.
+func (c *Connection) CopyRejections(table string) ([]CopyRejection, error) {
+	rs, err := c.Query("SELECT node_name, file_name, session_id, transaction_id, statement_id, batch_number, row_number, rejected_data, rejected_reason FROM " +
+		quoteQualifiedName(table) + " WHERE session_id = CURRENT_SESSION() ORDER BY transaction_id, statement_id, batch_number, row_number")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	var rejections []CopyRejection
+	if err := rs.ScanStruct(&rejections); err != nil {
+		return nil, err
+	}
+	return rejections, nil
+}
+
+// Sends the input of a COPY FROM STDIN statement, after the server sent a
+// CopyInResponse. Statements that aren't run by CopyIn have no input to send,
+// so they are aborted.
+func (c *Connection) sendCopyData(handler resultHandler) {
+	copier, ok := handler.(*copyHandler)
+	if !ok {
+		c.sendMessage(CopyFailMessage{Message: "COPY FROM STDIN is only supported by CopyIn"})
+		return
+	}
+
+	buffer := make([]byte, copyChunkSize)
+	for {
+		n, err := copier.data.Read(buffer)
+		if n > 0 {
+			c.sendMessage(CopyDataMessage{Data: buffer[:n]})
+			c.progress.update(0)
+		}
+
+		switch {
+		case err == io.EOF:
+			c.sendMessage(CopyDoneMessage{})
+			return
+		case err != nil:
+			copier.err = err
+			c.sendMessage(CopyFailMessage{Message: err.Error()})
+			return
+		}
+	}
+}
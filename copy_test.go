@@ -0,0 +1,162 @@
+package vertigo
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+// Starts a server for COPY statements, which reports the number of rejected rows.
+func newCopyServer(t *testing.T, rejected int) (*vertigotest.Server, *Connection) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	server.Expect("SELECT GET_NUM_REJECTED_ROWS()").Columns(vertigotest.Column{Name: "GET_NUM_REJECTED_ROWS", Type: DataTypeInteger}).Row(rejected)
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { connection.Close() })
+	return server, connection
+}
+
+func TestCopyIn(t *testing.T) {
+	server, connection := newCopyServer(t, 0)
+	server.Expect("COPY t FROM STDIN").CopyIn().Tag("COPY 2")
+
+	data := strings.Repeat("x", copyChunkSize) + "\n1|a\n"
+	result, err := connection.CopyIn("COPY t FROM STDIN", strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Loaded != 2 || result.Rejected != 0 {
+		t.Fatalf("Expected 2 rows to be loaded, but found %+v", result)
+	}
+	if copies := server.CopyData(); len(copies) != 1 || string(copies[0]) != data {
+		t.Fatalf("Expected the server to receive the data, but found %d copies", len(copies))
+	}
+}
+
+func TestCopyRejections(t *testing.T) {
+	server, connection := newCopyServer(t, 1)
+	server.Expect(`COPY t FROM STDIN REJECTED DATA AS TABLE "t_rejected"`).CopyIn().
+		Columns(vertigotest.Column{Name: "Rows Loaded", Type: DataTypeInteger}).Row(2).Tag("COPY")
+	server.ExpectMatch(`FROM "t_rejected" WHERE session_id = CURRENT_SESSION\(\)`).
+		Columns(
+			vertigotest.Column{Name: "node_name"},
+			vertigotest.Column{Name: "file_name"},
+			vertigotest.Column{Name: "session_id"},
+			vertigotest.Column{Name: "transaction_id", Type: DataTypeInteger},
+			vertigotest.Column{Name: "statement_id", Type: DataTypeInteger},
+			vertigotest.Column{Name: "batch_number", Type: DataTypeInteger},
+			vertigotest.Column{Name: "row_number", Type: DataTypeInteger},
+			vertigotest.Column{Name: "rejected_data"},
+			vertigotest.Column{Name: "rejected_reason"},
+		).
+		Row("v_db_node0001", "STDIN", "session-1", 45035996273705000, 1, 0, 2, "x", "Invalid integer format 'x' for column 1 (a)")
+
+	result, err := connection.CopyIn(`COPY t FROM STDIN REJECTED DATA AS TABLE "t_rejected"`, strings.NewReader("1\nx\n3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Loaded != 2 || result.Rejected != 1 {
+		t.Fatalf("Expected 2 loaded and 1 rejected row, but found %+v", result)
+	}
+
+	rejections, err := connection.CopyRejections("t_rejected")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejections) != 1 || rejections[0].RowNumber != 2 || rejections[0].Data != "x" || !strings.Contains(rejections[0].Reason, "Invalid integer") {
+		t.Fatalf("Expected the rejected row, but found %+v", rejections)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("Disk on fire")
+}
+
+func TestCopyInReadError(t *testing.T) {
+	server, connection := newCopyServer(t, 0)
+	server.Expect("COPY t FROM STDIN").CopyIn()
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "one"}).Row(1)
+
+	if _, err := connection.CopyIn("COPY t FROM STDIN", io.MultiReader(strings.NewReader("1\n"), failingReader{})); err == nil || err.Error() != "Disk on fire" {
+		t.Fatalf("Expected the read error, but found %v", err)
+	}
+	if _, err := connection.Query("COPY t FROM STDIN"); err == nil {
+		t.Fatalf("Expected COPY FROM STDIN to fail when not run by CopyIn")
+	}
+	if _, err := connection.Query("SELECT 1"); err != nil {
+		t.Fatalf("Expected the connection to be usable after an aborted COPY, but found %v", err)
+	}
+}
+
+type copyBase struct {
+	ID int64 `db:"id"`
+}
+
+type copyRow struct {
+	copyBase
+	Name    string
+	Note    *string
+	Created time.Time `db:"created_at"`
+	Skipped string    `db:"-"`
+	hidden  string
+}
+
+func TestCopyLoader(t *testing.T) {
+	server, connection := newCopyServer(t, 0)
+	server.Expect(`COPY "public"."events" ("id", "Name", "Note", "created_at") FROM STDIN DELIMITER '|' NULL E'\\N' REJECTED DATA AS TABLE "events_rejected" DIRECT`).CopyIn().Tag("COPY 2")
+
+	note := "multi\nline"
+	created := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := []copyRow{
+		{copyBase: copyBase{ID: 1}, Name: `a|b\c`, Note: &note, Created: created, Skipped: "x", hidden: "y"},
+		{copyBase: copyBase{ID: 2}, Name: "", Created: created},
+	}
+
+	loader := CopyLoader{Table: "public.events", RejectionsTable: "events_rejected", Options: "DIRECT"}
+	if _, err := loader.Load(connection, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "1|a\\|b\\\\c|multi\\\nline|2015-01-02 03:04:05+00:00\n" +
+		"2||\\N|2015-01-02 03:04:05+00:00\n"
+	if copies := server.CopyData(); len(copies) != 1 || string(copies[0]) != expected {
+		t.Fatalf("Expected the data %q, but found %q", expected, copies)
+	}
+}
+
+func TestCopyLoaderChannel(t *testing.T) {
+	server, connection := newCopyServer(t, 0)
+	server.ExpectMatch(`^COPY "t"`).CopyIn()
+
+	rows := make(chan *copyBase, 2)
+	rows <- &copyBase{ID: 1}
+	rows <- &copyBase{ID: 2}
+	close(rows)
+
+	if _, err := (CopyLoader{Table: "t", Delimiter: ',', Null: "NULL", TimeFormat: "2006-01-02"}).Load(connection, rows); err != nil {
+		t.Fatal(err)
+	}
+	if statements := server.Statements(); statements[len(statements)-2] != `COPY "t" ("id") FROM STDIN DELIMITER ',' NULL 'NULL'` {
+		t.Fatalf("Expected the COPY statement to use the options, but found %q", statements)
+	}
+	if copies := server.CopyData(); len(copies) != 1 || string(copies[0]) != "1\n2\n" {
+		t.Fatalf("Expected the data of the channel, but found %q", copies)
+	}
+
+	if _, err := (CopyLoader{Table: "t"}).Load(connection, []int{1}); err == nil {
+		t.Fatalf("Expected rows that aren't structs to fail")
+	}
+}
@@ -0,0 +1,290 @@
+package vertigo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// The defaults of a CopyLoader.
+const (
+	defaultCopyDelimiter = '|'
+	defaultCopyNull      = `\N`
+)
+
+// Loads Go structs into a table with COPY FROM STDIN, which is a lot faster than
+// inserting them.
+//
+// The fields of the structs are the columns of the table that are loaded, in order.
+// Columns are named after the `db:"name"` tag of a field, or else after the field
+// itself. Fields tagged with `db:"-"` and unexported fields are skipped, and the
+// fields of embedded structs are included.
+//
+// The values are encoded as delimited text for the default COPY parser, the same
+// way as statement parameters. Delimiters, newlines and backslashes in the values
+// are escaped with a backslash, and nil values are written as the NULL marker.
+//
+// When Native is set, the values are encoded in Vertica's native binary format
+// instead, see NativeWriter. The data types of the columns follow from the types of
+// the fields: bool is BOOLEAN, integers are INTEGER, floats are FLOAT, strings are
+// VARCHAR, []byte is VARBINARY, time.Time is TIMESTAMPTZ and time.Duration is
+// INTERVAL, and pointers to them are NULL when they are nil. Use a NativeWriter with
+// CopyIn to load into columns of other types.
+type CopyLoader struct {
+	Table      string // The table to load into, optionally qualified with its schema as schema.table
+	Delimiter  byte   // The column delimiter. Defaults to '|'.
+	Null       string // The NULL marker. Defaults to \N, so empty strings aren't loaded as NULL.
+	TimeFormat string // The layout time.Time values are formatted with. Defaults to a timestamp with a time zone.
+	Native     bool   // Whether to use the native binary format instead of delimited text.
+
+	// When set, the data is compressed with the compressor before it is sent, and the
+	// COPY statement has its compression option. See Compress.
+	Compressor Compressor
+
+	// The table to store rejected rows in, which can be read with CopyRejections.
+	// It is created by the COPY if it doesn't exist.
+	RejectionsTable string
+
+	// Additional options for the COPY statement, like "DIRECT" or "REJECTMAX 10".
+	Options string
+}
+
+// Loads the rows, which are either a slice or array of structs or pointers to
+// structs, or a channel of them. A channel is read until it is closed.
+func (l CopyLoader) Load(c *Connection, rows interface{}) (CopyResult, error) {
+	encoder, err := l.newEncoder(rows)
+	if err != nil {
+		return CopyResult{}, err
+	}
+	if l.Compressor != nil {
+		return c.CopyIn(l.statement(encoder.columns), Compress(encoder, l.Compressor))
+	}
+	return c.CopyIn(l.statement(encoder.columns), encoder)
+}
+
+// Returns the COPY statement for the columns.
+func (l CopyLoader) statement(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = QuoteIdentifier(column)
+	}
+
+	sql := fmt.Sprintf("COPY %s (%s) FROM STDIN", quoteQualifiedName(l.Table), strings.Join(quoted, ", "))
+	if l.Compressor != nil {
+		sql += " " + l.Compressor.CopyOption()
+	}
+	if l.Native {
+		sql += " NATIVE"
+	} else {
+		sql += fmt.Sprintf(" DELIMITER %s NULL %s", quoteString(string(l.delimiter())), quoteString(l.null()))
+	}
+	if l.RejectionsTable != "" {
+		sql += " REJECTED DATA AS TABLE " + quoteQualifiedName(l.RejectionsTable)
+	}
+	if l.Options != "" {
+		sql += " " + l.Options
+	}
+	return sql
+}
+
+func (l CopyLoader) delimiter() byte {
+	if l.Delimiter == 0 {
+		return defaultCopyDelimiter
+	}
+	return l.Delimiter
+}
+
+func (l CopyLoader) null() string {
+	if l.Null == "" {
+		return defaultCopyNull
+	}
+	return l.Null
+}
+
+// Encodes structs as the delimited text input of a COPY statement. It reads the
+// next struct whenever all text encoded so far was read.
+type copyEncoder struct {
+	CopyLoader
+
+	columns []string
+	indexes [][]int
+	next    func() (reflect.Value, bool) // Returns the next struct, or false after the last one
+	buffer  bytes.Buffer
+	err     error
+
+	native       []NativeColumn // The columns in the native binary format, if Native is set
+	nativeWriter *NativeWriter  // Writes the native binary format to the buffer, once the header was written
+}
+
+func (l CopyLoader) newEncoder(rows interface{}) (*copyEncoder, error) {
+	value := reflect.ValueOf(rows)
+
+	var next func() (reflect.Value, bool)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		i := 0
+		next = func() (reflect.Value, bool) {
+			if i == value.Len() {
+				return reflect.Value{}, false
+			}
+			i++
+			return value.Index(i - 1), true
+		}
+	case reflect.Chan:
+		next = value.Recv
+	default:
+		return nil, fmt.Errorf("Rows should be a slice or a channel of structs, got %T", rows)
+	}
+
+	structType := value.Type().Elem()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Rows should be a slice or a channel of structs, got %T", rows)
+	}
+
+	columns, indexes := structColumns(structType, nil)
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%s has no fields to load", structType)
+	}
+	encoder := &copyEncoder{CopyLoader: l, columns: columns, indexes: indexes, next: next}
+	if l.Native {
+		for i, index := range indexes {
+			field := structType.FieldByIndex(index)
+			dataType, ok := nativeType(field.Type)
+			if !ok {
+				return nil, fmt.Errorf("Cannot load field %s of type %s in the native binary format", field.Name, field.Type)
+			}
+			encoder.native = append(encoder.native, NativeColumn{Name: columns[i], Type: dataType})
+		}
+	}
+	return encoder, nil
+}
+
+func (e *copyEncoder) Read(p []byte) (int, error) {
+	if e.Native && e.nativeWriter == nil && e.err == nil {
+		// The header is written even if there are no rows.
+		e.nativeWriter, e.err = NewNativeWriter(&e.buffer, e.native)
+	}
+
+	for e.buffer.Len() == 0 && e.err == nil {
+		row, ok := e.next()
+		if !ok {
+			e.err = io.EOF
+			break
+		}
+		if row.Kind() == reflect.Ptr {
+			if row.IsNil() {
+				e.err = fmt.Errorf("Cannot load a nil %s", row.Type())
+				break
+			}
+			row = row.Elem()
+		}
+		e.err = e.encodeRow(row)
+	}
+
+	if e.buffer.Len() > 0 {
+		return e.buffer.Read(p)
+	}
+	return 0, e.err
+}
+
+// Appends a line with the fields of the struct to the buffer, or a row in the native
+// binary format.
+func (e *copyEncoder) encodeRow(row reflect.Value) error {
+	if e.Native {
+		return e.encodeNativeRow(row)
+	}
+
+	for i, index := range e.indexes {
+		if i > 0 {
+			e.buffer.WriteByte(e.delimiter())
+		}
+
+		text, err := e.encodeValue(row.FieldByIndex(index).Interface())
+		if err != nil {
+			return fmt.Errorf("Cannot load column %s: %w", e.columns[i], err)
+		}
+		if text == nil {
+			e.buffer.WriteString(e.null())
+			continue
+		}
+
+		for _, ch := range text {
+			if ch == '\\' || ch == e.delimiter() || ch == '\n' || ch == '\r' {
+				e.buffer.WriteByte('\\')
+			}
+			e.buffer.WriteByte(ch)
+		}
+	}
+	e.buffer.WriteByte('\n')
+	return nil
+}
+
+func (e *copyEncoder) encodeNativeRow(row reflect.Value) error {
+	values := make([]interface{}, len(e.indexes))
+	for i, index := range e.indexes {
+		values[i] = row.FieldByIndex(index).Interface()
+	}
+	return e.nativeWriter.WriteRow(values...)
+}
+
+// Returns the text of a value, or nil for NULL. Pointers are followed.
+func (e *copyEncoder) encodeValue(v interface{}) ([]byte, error) {
+	v, err := resolveEncoder(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, nil
+		}
+		return e.encodeValue(value.Elem().Interface())
+	}
+
+	if t, ok := v.(time.Time); ok && e.TimeFormat != "" {
+		return []byte(t.Format(e.TimeFormat)), nil
+	}
+	return encodeParameter(v)
+}
+
+// Returns the names of the columns the exported fields of a struct type are loaded
+// into, and the indexes of the fields, in the order of the fields. See CopyLoader.
+func structColumns(structType reflect.Type, parent []int) ([]string, [][]int) {
+	var (
+		columns []string
+		indexes [][]int
+	)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		index := append(append([]int(nil), parent...), i)
+
+		tag := field.Tag.Get("db")
+		switch {
+		case tag == "-":
+			continue
+
+		case tag == "" && field.Anonymous && field.Type.Kind() == reflect.Struct:
+			embeddedColumns, embeddedIndexes := structColumns(field.Type, index)
+			columns = append(columns, embeddedColumns...)
+			indexes = append(indexes, embeddedIndexes...)
+
+		case field.PkgPath != "":
+			continue
+
+		case tag != "":
+			columns = append(columns, tag)
+			indexes = append(indexes, index)
+
+		default:
+			columns = append(columns, field.Name)
+			indexes = append(indexes, index)
+		}
+	}
+	return columns, indexes
+}
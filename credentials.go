@@ -0,0 +1,33 @@
+package vertigo
+
+import (
+	"fmt"
+)
+
+// CredentialProvider supplies the secret used to authenticate a connection, like a
+// password or a token that is sent in its place. It is asked for the secret on every
+// attempt to open a connection, so it can be fetched from a secret store like Vault
+// or a KMS, and rotated without restarting the process.
+type CredentialProvider interface {
+	Password() (string, error)
+}
+
+// An adapter to use an ordinary function as a CredentialProvider.
+type CredentialProviderFunc func() (string, error)
+
+func (f CredentialProviderFunc) Password() (string, error) {
+	return f()
+}
+
+// Returns the password to authenticate with.
+func (c *Connection) password() (string, error) {
+	if c.config.CredentialProvider == nil {
+		return c.config.Password, nil
+	}
+
+	password, err := c.config.CredentialProvider.Password()
+	if err != nil {
+		return "", fmt.Errorf("Cannot get credentials: %w", err)
+	}
+	return password, nil
+}
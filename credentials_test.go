@@ -0,0 +1,29 @@
+package vertigo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCredentialProvider(t *testing.T) {
+	c := &Connection{config: &ConnectionInfo{Password: "static"}}
+	if password, err := c.password(); err != nil || password != "static" {
+		t.Fatalf("Expected the static password, but found %q (%v)", password, err)
+	}
+
+	attempts := 0
+	c.config.CredentialProvider = CredentialProviderFunc(func() (string, error) {
+		attempts++
+		if attempts > 1 {
+			return "", errors.New("vault sealed")
+		}
+		return "rotated", nil
+	})
+
+	if password, err := c.password(); err != nil || password != "rotated" {
+		t.Fatalf("Expected the provided password, but found %q (%v)", password, err)
+	}
+	if _, err := c.password(); err == nil || err.Error() != "Cannot get credentials: vault sealed" {
+		t.Fatalf("Expected the provider error, but found %v", err)
+	}
+}
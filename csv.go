@@ -0,0 +1,93 @@
+package vertigo
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// Options for writing rows as CSV.
+type CSVOptions struct {
+	Delimiter rune   // The field delimiter. Defaults to a comma.
+	Header    bool   // Whether to write a header row with the column names.
+	Null      string // The representation of NULL values. Defaults to an empty field.
+}
+
+// Writes the resultset to w as CSV. Values are written in the text format they
+// were received in from the server.
+func (rs *Resultset) WriteCSV(w io.Writer, options CSVOptions) error {
+	writer := newCSVWriter(w, options)
+	writer.handleFields(rs.Fields)
+	err := rs.EachRow(func(row Row) error {
+		writer.handleRow(row.Values)
+		return writer.err
+	})
+	if err != nil {
+		return err
+	}
+	return writer.close()
+}
+
+// Runs a SQL query, and writes its rows to w as CSV while they are received from
+// the server, without buffering a Resultset. See Resultset.WriteCSV.
+func (c *Connection) QueryCSV(w io.Writer, options CSVOptions, sql string, args ...interface{}) error {
+	writer := newCSVWriter(w, options)
+	if err := c.run(sql, args, writer); err != nil {
+		return err
+	}
+	return writer.close()
+}
+
+// A resultHandler that writes rows as CSV.
+type csvWriter struct {
+	w       *csv.Writer
+	options CSVOptions
+	record  []string
+	err     error
+}
+
+func newCSVWriter(w io.Writer, options CSVOptions) *csvWriter {
+	writer := &csvWriter{w: csv.NewWriter(w), options: options}
+	if options.Delimiter != 0 {
+		writer.w.Comma = options.Delimiter
+	}
+	return writer
+}
+
+func (cw *csvWriter) handleFields(fields []Field) {
+	cw.record = make([]string, len(fields))
+	if !cw.options.Header || cw.err != nil {
+		return
+	}
+
+	for i, field := range fields {
+		cw.record[i] = field.Name
+	}
+	cw.err = cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) handleRow(values [][]byte) {
+	if cw.err != nil {
+		return
+	}
+
+	for i, value := range values {
+		if value == nil {
+			cw.record[i] = cw.options.Null
+		} else {
+			cw.record[i] = string(value)
+		}
+	}
+	cw.err = cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) handleComplete(result string) {}
+
+// Flushes the output.
+func (cw *csvWriter) close() error {
+	if cw.err != nil {
+		return cw.err
+	}
+
+	cw.w.Flush()
+	return cw.w.Error()
+}
@@ -0,0 +1,26 @@
+package vertigo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestResultsetWriteCSV(t *testing.T) {
+	resultset := &Resultset{
+		Fields: []Field{{Name: "id"}, {Name: "name"}},
+		Rows: []Row{
+			{Values: [][]byte{[]byte("1"), []byte("foo;bar")}},
+			{Values: [][]byte{[]byte("2"), nil}},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := resultset.WriteCSV(&buffer, CSVOptions{Delimiter: ';', Header: true, Null: `\N`}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "id;name\n1;\"foo;bar\"\n2;\\N\n"
+	if buffer.String() != expected {
+		t.Fatalf("Expected %q, but found %q", expected, buffer.String())
+	}
+}
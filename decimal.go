@@ -0,0 +1,103 @@
+package vertigo
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Decimal is an exact decimal number, as used for the values of NUMERIC columns.
+// Its value is unscaled * 10^-scale.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+// Returns the decimal with value unscaled * 10^-scale.
+func NewDecimal(unscaled *big.Int, scale int) Decimal {
+	return Decimal{unscaled: new(big.Int).Set(unscaled), scale: scale}
+}
+
+// Parses a decimal number like "-123.4500". The scale of the decimal is the
+// number of digits after the decimal point.
+func ParseDecimal(s string) (Decimal, error) {
+	digits := s
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+
+	scale := 0
+	if dot := strings.IndexByte(digits, '.'); dot >= 0 {
+		scale = len(digits) - dot - 1
+		digits = digits[:dot] + digits[dot+1:]
+	}
+
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("Invalid decimal %q", s)
+	}
+
+	unscaled, _ := new(big.Int).SetString(digits, 10)
+	if strings.HasPrefix(s, "-") {
+		unscaled.Neg(unscaled)
+	}
+	return Decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+// Returns the unscaled value of the decimal.
+func (d Decimal) Unscaled() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(d.unscaled)
+}
+
+// Returns the number of digits after the decimal point.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Returns the value of the decimal as a rational number.
+func (d Decimal) Rat() *big.Rat {
+	denominator := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil)
+	return new(big.Rat).SetFrac(d.Unscaled(), denominator)
+}
+
+// Returns the nearest float64 value of the decimal, and whether it is exact.
+func (d Decimal) Float64() (float64, bool) {
+	return d.Rat().Float64()
+}
+
+// Returns the decimal in plain notation, keeping all digits of its scale.
+func (d Decimal) String() string {
+	unscaled := d.Unscaled()
+	digits := new(big.Int).Abs(unscaled).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+
+	if unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Encodes the decimal as a JSON number, without losing precision.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) scanText(field *Field, src []byte) error {
+	if src == nil {
+		return fmt.Errorf("%w, cannot convert it into vertigo.Decimal; use a pointer or Nullable instead", ErrUnexpectedNull)
+	}
+
+	decimal, err := ParseDecimal(string(src))
+	if err != nil {
+		return err
+	}
+	*d = decimal
+	return nil
+}
@@ -0,0 +1,74 @@
+package vertigo
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		src      string
+		unscaled string
+		scale    int
+		str      string
+	}{
+		{"123.4500", "1234500", 4, "123.4500"},
+		{"-0.05", "-5", 2, "-0.05"},
+		{"42", "42", 0, "42"},
+		{"12345678901234567890.123456789012345678", "12345678901234567890123456789012345678", 18, "12345678901234567890.123456789012345678"},
+	}
+
+	for _, test := range tests {
+		d, err := ParseDecimal(test.src)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %s", test.src, err)
+			continue
+		}
+
+		if d.Unscaled().String() != test.unscaled || d.Scale() != test.scale || d.String() != test.str {
+			t.Errorf("Unexpected decimal for %q: %s, %d, %q", test.src, d.Unscaled(), d.Scale(), d.String())
+		}
+	}
+
+	for _, src := range []string{"", "-", "1.2.3", "1e5", "abc"} {
+		if _, err := ParseDecimal(src); err == nil {
+			t.Errorf("Expected an error for %q", src)
+		}
+	}
+}
+
+func TestDecimalConversions(t *testing.T) {
+	d, _ := ParseDecimal("0.1")
+	if d.Rat().Cmp(big.NewRat(1, 10)) != 0 {
+		t.Errorf("Expected 1/10, but found %s", d.Rat())
+	}
+
+	if f, exact := d.Float64(); f != 0.1 || exact {
+		t.Errorf("Expected inexact 0.1, but found %f (%t)", f, exact)
+	}
+
+	data, err := json.Marshal(map[string]Decimal{"d": d})
+	if err != nil || string(data) != `{"d":0.1}` {
+		t.Errorf("Unexpected JSON %s (%v)", data, err)
+	}
+}
+
+func TestRowScanNumeric(t *testing.T) {
+	fields := []Field{{DataTypeOID: DataTypeNumeric}, {DataTypeOID: DataTypeNumeric}, {DataTypeOID: DataTypeNumeric}, {DataTypeOID: DataTypeNumeric}}
+	row := Row{Values: [][]byte{[]byte("1.50"), []byte("2.25"), []byte("12345678901234567890"), []byte("3.75")}, fields: fields}
+
+	var (
+		d Decimal
+		r big.Rat
+		i big.Int
+		v interface{}
+	)
+	if err := row.Scan(&d, &r, &i, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.String() != "1.50" || r.RatString() != "9/4" || i.String() != "12345678901234567890" || v.(Decimal).String() != "3.75" {
+		t.Fatalf("Unexpected values %s, %s, %s, %#+v", d, r.String(), i.String(), v)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"io"
+	"strconv"
 	"testing"
 )
 
@@ -74,3 +75,58 @@ func BenchmarkDecodeUint32(b *testing.B) {
 		}
 	}
 }
+
+// Returns the messages of a resultset of n rows with an integer and a short string,
+// as the server sends them.
+func dataRowStream(n int) []byte {
+	var stream []byte
+	for i := 0; i < n; i++ {
+		value := strconv.Itoa(i)
+		body := binary.BigEndian.AppendUint16(nil, 2)
+		body = binary.BigEndian.AppendUint32(body, uint32(len(value)))
+		body = append(body, value...)
+		body = binary.BigEndian.AppendUint32(body, 5)
+		body = append(body, "hello"...)
+		stream = append(stream, 'D')
+		stream = binary.BigEndian.AppendUint32(stream, uint32(len(body)+4))
+		stream = append(stream, body...)
+	}
+	tag := "SELECT " + strconv.Itoa(n) + "\x00"
+	stream = binary.BigEndian.AppendUint32(append(stream, 'C'), uint32(len(tag)+4))
+	stream = append(stream, tag...)
+	return append(stream, 'Z', 0, 0, 0, 5, 'I')
+}
+
+// Reads a resultset of a million rows, as Query does, and as Exec does, which doesn't
+// keep the rows.
+func BenchmarkReceiveDataRows(b *testing.B) {
+	const rows = 1000000
+	stream := dataRowStream(rows)
+
+	for _, bm := range []struct {
+		name       string
+		newHandler func() resultHandler
+	}{
+		{"Resultset", func() resultHandler { return &resultsetHandler{} }},
+		{"Discard", func() resultHandler { return &discardHandler{} }},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(stream)))
+			for n := 0; n < b.N; n++ {
+				c := &Connection{config: &ConnectionInfo{}, bufioReader: bufio.NewReader(bytes.NewReader(stream))}
+				handler := bm.newHandler()
+				_, c.rowBuffer.transient = handler.(transientRowHandler)
+				handler.handleFields([]Field{{Name: "i", DataTypeOID: DataTypeInteger}, {Name: "s", DataTypeOID: DataTypeVarchar}})
+				for msg := c.receiveMessage(); !c.isReadyForQuery(msg); msg = c.receiveMessage() {
+					switch msg := msg.(type) {
+					case DataRowMessage:
+						handler.handleRow(msg.Values)
+					case CommandCompleteMessage:
+						handler.handleComplete(msg.Result)
+					}
+				}
+			}
+		})
+	}
+}
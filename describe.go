@@ -0,0 +1,39 @@
+package vertigo
+
+// The shape of a statement, as described by the server without running it.
+type StatementDescription struct {
+	ParameterTypes []uint32 // The data type OIDs of the ? parameters of the statement
+	Fields         []Field  // The fields of the rows the statement returns, if any
+}
+
+// Asks the server for the data types of the parameters of the statement and the fields
+// of its rows, without running it. This is meant for tools that introspect queries, like
+// code generators. The statement is parsed as an unnamed statement, so nothing is left
+// open on the server.
+func (c *Connection) DescribeStatement(sql string) (description *StatementDescription, err error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			description, err = nil, r.(error)
+			c.markBroken(err)
+		}
+	}()
+
+	if c.portal != nil {
+		return nil, ErrPortalOpen
+	}
+	if err := c.brokenError(); err != nil {
+		return nil, err
+	}
+	if c.socket == nil {
+		c.openConnection()
+	}
+
+	parameterTypes, fields, err := c.parseStatement("", sql)
+	if err != nil {
+		return nil, err
+	}
+	return &StatementDescription{ParameterTypes: parameterTypes, Fields: fields}, nil
+}
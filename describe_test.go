@@ -0,0 +1,39 @@
+package vertigo
+
+import (
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestDescribeStatement(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT a, b FROM t WHERE c = ?").Columns(
+		vertigotest.Column{Name: "a", Type: DataTypeInteger},
+		vertigotest.Column{Name: "b", Type: DataTypeVarchar},
+	)
+
+	connection, err := Connect(&ConnectionInfo{Address: server.Addr(), User: "dbadmin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	description, err := connection.DescribeStatement("SELECT a, b FROM t WHERE c = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(description.ParameterTypes) != 1 || description.ParameterTypes[0] != DataTypeVarchar {
+		t.Fatalf("Expected a single VARCHAR parameter, but found %v", description.ParameterTypes)
+	}
+	if len(description.Fields) != 2 || description.Fields[0].Name != "a" || description.Fields[1].DataTypeOID != DataTypeVarchar {
+		t.Fatalf("Expected fields a and b, but found %+v", description.Fields)
+	}
+	if statements := server.Statements(); len(statements) != 0 {
+		t.Fatalf("Expected the statement not to be run, but found %q", statements)
+	}
+}
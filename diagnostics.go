@@ -0,0 +1,136 @@
+package vertigo
+
+import (
+	"strconv"
+	"time"
+)
+
+// Diagnostics tells what a connection is doing on the server, from the v_monitor system
+// tables, so applications can expose it without writing catalog queries. Its queries run
+// on the connection itself, so they wait for any statement that is running on it; use a
+// second connection to look at a busy one, with its SessionID.
+type Diagnostics struct {
+	c *Connection
+}
+
+// Returns the diagnostics of the connection.
+func (c *Connection) Diagnostics() Diagnostics {
+	return Diagnostics{c: c}
+}
+
+// A session on the cluster, as found in v_monitor.sessions.
+type SessionInfo struct {
+	NodeName         string    `db:"node_name"`
+	UserName         string    `db:"user_name"`
+	ClientHostname   string    `db:"client_hostname"`
+	LoginTimestamp   time.Time `db:"login_timestamp"`
+	SessionID        string    `db:"session_id"`
+	TransactionID    int64     `db:"transaction_id"`
+	StatementID      int64     `db:"statement_id"`
+	CurrentStatement string    `db:"current_statement"` // Empty if the session is idle
+	LastStatement    string    `db:"last_statement"`
+}
+
+const sessionColumns = "node_name, user_name, client_hostname, login_timestamp, session_id, " +
+	"COALESCE(transaction_id, 0) AS transaction_id, COALESCE(statement_id, 0) AS statement_id, " +
+	"COALESCE(current_statement, '') AS current_statement, COALESCE(last_statement, '') AS last_statement"
+
+// A statement run by a session, as found in v_monitor.query_requests.
+type QueryRequest struct {
+	TransactionID  int64      `db:"transaction_id"`
+	StatementID    int64      `db:"statement_id"`
+	RequestType    string     `db:"request_type"` // Like "QUERY", "DDL" or "LOAD"
+	Request        string     `db:"request"`
+	StartTimestamp time.Time  `db:"start_timestamp"`
+	EndTimestamp   *time.Time `db:"end_timestamp"` // Nil while the statement is running
+	Duration       int64      `db:"request_duration_ms"`
+	Executing      bool       `db:"is_executing"`
+	Success        bool       `db:"success"`
+}
+
+// Resources acquired from a resource pool for a statement, as found in
+// v_monitor.resource_acquisitions.
+type ResourceAcquisition struct {
+	NodeName             string    `db:"node_name"`
+	TransactionID        int64     `db:"transaction_id"`
+	StatementID          int64     `db:"statement_id"`
+	PoolName             string    `db:"pool_name"`
+	ThreadCount          int64     `db:"thread_count"`
+	OpenFileHandleCount  int64     `db:"open_file_handle_count"`
+	MemoryInUseKB        int64     `db:"memory_inuse_kb"`
+	QueueEntryTimestamp  time.Time `db:"queue_entry_timestamp"`
+	AcquisitionTimestamp time.Time `db:"acquisition_timestamp"`
+}
+
+// Returns the session of the connection.
+func (d Diagnostics) Session() (*SessionInfo, error) {
+	sessions, err := d.sessions("v_monitor.current_session")
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNoRows
+	}
+	return &sessions[0], nil
+}
+
+// Returns the sessions on the cluster the user can see: all sessions for superusers,
+// otherwise those of the user.
+func (d Diagnostics) Sessions() ([]SessionInfo, error) {
+	return d.sessions("v_monitor.sessions")
+}
+
+func (d Diagnostics) sessions(table string) ([]SessionInfo, error) {
+	rs, err := d.c.Query("SELECT " + sessionColumns + " FROM " + table + " ORDER BY login_timestamp")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	var sessions []SessionInfo
+	if err := rs.ScanStruct(&sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Returns the most recent statements of the session of the connection, the most recent
+// first. At most limit statements are returned, unless it is zero.
+func (d Diagnostics) QueryRequests(limit int) ([]QueryRequest, error) {
+	sql := "SELECT transaction_id, statement_id, request_type, request, start_timestamp, end_timestamp, " +
+		"COALESCE(request_duration_ms, 0) AS request_duration_ms, is_executing, COALESCE(success, FALSE) AS success " +
+		"FROM v_monitor.query_requests WHERE session_id = CURRENT_SESSION() ORDER BY start_timestamp DESC"
+	if limit > 0 {
+		sql += " LIMIT " + strconv.Itoa(limit)
+	}
+
+	rs, err := d.c.Query(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	var requests []QueryRequest
+	if err := rs.ScanStruct(&requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Returns the resources acquired for the statements of the current transaction of the
+// connection, on all nodes.
+func (d Diagnostics) ResourceAcquisitions() ([]ResourceAcquisition, error) {
+	rs, err := d.c.Query("SELECT node_name, transaction_id, statement_id, pool_name, thread_count, open_file_handle_count, " +
+		"memory_inuse_kb, queue_entry_timestamp, acquisition_timestamp FROM v_monitor.resource_acquisitions " +
+		"WHERE transaction_id = CURRENT_TRANS_ID() ORDER BY statement_id, node_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	var acquisitions []ResourceAcquisition
+	if err := rs.ScanStruct(&acquisitions); err != nil {
+		return nil, err
+	}
+	return acquisitions, nil
+}
@@ -0,0 +1,101 @@
+package vertigo
+
+import (
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestDiagnostics(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	sessionColumns := []vertigotest.Column{
+		{Name: "node_name"},
+		{Name: "user_name"},
+		{Name: "client_hostname"},
+		{Name: "login_timestamp", Type: DataTypeTimestampTZ},
+		{Name: "session_id"},
+		{Name: "transaction_id", Type: DataTypeInteger},
+		{Name: "statement_id", Type: DataTypeInteger},
+		{Name: "current_statement"},
+		{Name: "last_statement"},
+	}
+	server.ExpectMatch(`FROM v_monitor\.current_session ORDER BY`).Columns(sessionColumns...).
+		Row("v_db_node0001", "dbadmin", "10.0.0.1:51234", "2026-10-16 10:00:00+00", "node01-1234:0x5", 45035996273705000, 3, "SELECT 1", "SELECT 2")
+	server.ExpectMatch(`FROM v_monitor\.sessions ORDER BY`).Columns(sessionColumns...).
+		Row("v_db_node0001", "dbadmin", "10.0.0.1:51234", "2026-10-16 10:00:00+00", "node01-1234:0x5", 45035996273705000, 3, "SELECT 1", "SELECT 2").
+		Row("v_db_node0002", "dbadmin", "10.0.0.2:40000", "2026-10-16 11:00:00+00", "node02-1234:0x9", 0, 0, "", "SELECT 3")
+	server.ExpectMatch(`FROM v_monitor\.query_requests WHERE session_id = CURRENT_SESSION\(\) ORDER BY start_timestamp DESC LIMIT 2$`).
+		Columns(
+			vertigotest.Column{Name: "transaction_id", Type: DataTypeInteger},
+			vertigotest.Column{Name: "statement_id", Type: DataTypeInteger},
+			vertigotest.Column{Name: "request_type"},
+			vertigotest.Column{Name: "request"},
+			vertigotest.Column{Name: "start_timestamp", Type: DataTypeTimestampTZ},
+			vertigotest.Column{Name: "end_timestamp", Type: DataTypeTimestampTZ},
+			vertigotest.Column{Name: "request_duration_ms", Type: DataTypeInteger},
+			vertigotest.Column{Name: "is_executing", Type: DataTypeBoolean},
+			vertigotest.Column{Name: "success", Type: DataTypeBoolean},
+		).
+		Row(45035996273705000, 3, "QUERY", "SELECT 1", "2026-10-16 10:00:02+00", nil, 0, "t", "f").
+		Row(45035996273705000, 2, "QUERY", "SELECT 2", "2026-10-16 10:00:01+00", "2026-10-16 10:00:01.5+00", 500, "f", "t")
+	server.ExpectMatch(`FROM v_monitor\.resource_acquisitions WHERE transaction_id = CURRENT_TRANS_ID\(\)`).
+		Columns(
+			vertigotest.Column{Name: "node_name"},
+			vertigotest.Column{Name: "transaction_id", Type: DataTypeInteger},
+			vertigotest.Column{Name: "statement_id", Type: DataTypeInteger},
+			vertigotest.Column{Name: "pool_name"},
+			vertigotest.Column{Name: "thread_count", Type: DataTypeInteger},
+			vertigotest.Column{Name: "open_file_handle_count", Type: DataTypeInteger},
+			vertigotest.Column{Name: "memory_inuse_kb", Type: DataTypeInteger},
+			vertigotest.Column{Name: "queue_entry_timestamp", Type: DataTypeTimestampTZ},
+			vertigotest.Column{Name: "acquisition_timestamp", Type: DataTypeTimestampTZ},
+		).
+		Row("v_db_node0001", 45035996273705000, 3, "general", 4, 2, 102400, "2026-10-16 10:00:02+00", "2026-10-16 10:00:02+00")
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+	diagnostics := connection.Diagnostics()
+
+	session, err := diagnostics.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.SessionID != "node01-1234:0x5" || session.StatementID != 3 || session.CurrentStatement != "SELECT 1" || session.LoginTimestamp.Hour() != 10 {
+		t.Fatalf("Expected the current session, but found %+v", session)
+	}
+
+	sessions, err := diagnostics.Sessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[1].NodeName != "v_db_node0002" || sessions[1].CurrentStatement != "" {
+		t.Fatalf("Expected 2 sessions, but found %+v", sessions)
+	}
+
+	requests, err := diagnostics.QueryRequests(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || !requests[0].Executing || requests[0].EndTimestamp != nil {
+		t.Fatalf("Expected the running statement first, but found %+v", requests)
+	}
+	if requests[1].EndTimestamp == nil || requests[1].Duration != 500 || !requests[1].Success {
+		t.Fatalf("Expected the completed statement second, but found %+v", requests[1])
+	}
+
+	acquisitions, err := diagnostics.ResourceAcquisitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acquisitions) != 1 || acquisitions[0].PoolName != "general" || acquisitions[0].MemoryInUseKB != 102400 {
+		t.Fatalf("Expected the acquisition of the general pool, but found %+v", acquisitions)
+	}
+}
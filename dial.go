@@ -0,0 +1,52 @@
+package vertigo
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// DialFunc opens the network connection to a Vertica node, like net.Dialer.DialContext
+// does. It can route connections through a tunnel or a proxy. The network is always "tcp".
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Opens a connection to address with dial, or directly if dial is nil. Zero means no
+// timeout.
+func dialTimeout(dial DialFunc, address string, timeout time.Duration) (net.Conn, error) {
+	if dial == nil {
+		return net.DialTimeout("tcp", address, timeout)
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return dial(ctx, "tcp", address)
+}
+
+// Returns the function that opens the network connections of this connection: the Dial
+// function or a net.Dialer, through the Proxy if one is configured.
+func (c *Connection) dialFunc() DialFunc {
+	dial := c.config.Dial
+	if dial == nil {
+		dialer := &net.Dialer{FallbackDelay: c.config.FallbackDelay}
+		dial = dialer.DialContext
+	}
+	if c.config.Proxy == nil {
+		return dial
+	}
+
+	proxy := c.config.Proxy
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		u, err := proxy(address)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			return dial(ctx, network, address)
+		}
+		return dialProxy(ctx, dial, u, address)
+	}
+}
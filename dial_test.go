@@ -0,0 +1,57 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestDial(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT SLEEP(10)").Delay(300 * time.Millisecond)
+
+	var (
+		mu      sync.Mutex
+		dialed  []string
+		dialer  net.Dialer
+		tunnel  = "tunnel:5433"
+		timeout = 50 * time.Millisecond
+	)
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, address)
+		mu.Unlock()
+		if address != tunnel {
+			return nil, errors.New("unexpected address")
+		}
+		return dialer.DialContext(ctx, network, server.Addr())
+	}
+
+	connection, err := Connect(&ConnectionInfo{Address: tunnel, User: "dbadmin", Dial: dial, ClientTimeout: timeout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if _, err := connection.Query("SELECT SLEEP(10)"); !errors.Is(err, ErrClientTimeout) {
+		t.Fatalf("Expected the statement to time out, but found %v", err)
+	}
+	if server.CancelRequests() != 1 {
+		t.Fatalf("Expected the statement to be cancelled, but found %d cancel requests", server.CancelRequests())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dialed) != 2 {
+		t.Fatalf("Expected the connection and the cancel request to be dialed, but found %q", dialed)
+	}
+}
@@ -0,0 +1,54 @@
+package vertigo
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+)
+
+// Encoder is implemented by types that control how they are encoded as query
+// arguments and statement parameters, like UUIDs, decimals or enums of other
+// packages. VerticaValue returns a value of a type the driver can encode, e.g. a
+// string or an int64, that is encoded in place of the value itself.
+//
+// Types that implement driver.Valuer are encoded with their Value method the same
+// way, unless they implement Encoder as well.
+type Encoder interface {
+	VerticaValue() (interface{}, error)
+}
+
+// How many times a value can encode to another Encoder or driver.Valuer.
+const maxEncoderDepth = 8
+
+// Returns the value v is encoded as. Values whose types implement Encoder or
+// driver.Valuer are replaced by the values they return, and nil pointers to such
+// types become nil. Other values are returned as they are.
+func resolveEncoder(v interface{}) (interface{}, error) {
+	for depth := 0; depth <= maxEncoderDepth; depth++ {
+		var err error
+		switch e := v.(type) {
+		case Encoder:
+			if isNilPointer(v) {
+				return nil, nil
+			}
+			v, err = e.VerticaValue()
+		case driver.Valuer:
+			if isNilPointer(v) {
+				return nil, nil
+			}
+			v, err = e.Value()
+		default:
+			return v, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("Cannot encode value of type %T: it keeps encoding to other encoders", v)
+}
+
+func isNilPointer(v interface{}) bool {
+	value := reflect.ValueOf(v)
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
@@ -0,0 +1,73 @@
+package vertigo
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+type testEnum int
+
+func (e testEnum) VerticaValue() (interface{}, error) {
+	switch e {
+	case 1:
+		return "red", nil
+	case 2:
+		return "green", nil
+	}
+	return nil, errors.New("Unknown color")
+}
+
+type testValuer struct {
+	value string
+	valid bool
+}
+
+func (v *testValuer) Value() (driver.Value, error) {
+	if !v.valid {
+		return nil, nil
+	}
+	return v.value, nil
+}
+
+type testLoop struct{}
+
+func (testLoop) VerticaValue() (interface{}, error) {
+	return testLoop{}, nil
+}
+
+func TestEncoder(t *testing.T) {
+	if literal, err := QuoteLiteral(testEnum(1)); err != nil || literal != "'red'" {
+		t.Fatalf("Expected 'red', but found %s, %v", literal, err)
+	}
+	if value, err := encodeParameter(testEnum(2)); err != nil || string(value) != "green" {
+		t.Fatalf("Expected green, but found %s, %v", value, err)
+	}
+	if _, err := QuoteLiteral(testEnum(3)); err == nil || err.Error() != "Unknown color" {
+		t.Fatalf("Expected the error of the encoder, but found %v", err)
+	}
+	if _, err := encodeParameter(testLoop{}); err == nil {
+		t.Fatalf("Expected an encoder that never returns a plain value to fail")
+	}
+}
+
+func TestValuer(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{&testValuer{value: "it's", valid: true}, "'it''s'"},
+		{&testValuer{}, "NULL"},
+		{(*testValuer)(nil), "NULL"},
+	}
+
+	for _, test := range tests {
+		if literal, err := QuoteLiteral(test.value); err != nil || literal != test.expected {
+			t.Errorf("Expected %s, but found %s, %v", test.expected, literal, err)
+		}
+	}
+
+	if value, err := encodeParameter(&testValuer{}); err != nil || value != nil {
+		t.Fatalf("Expected NULL to be encoded as nil, but found %v, %v", value, err)
+	}
+}
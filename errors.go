@@ -0,0 +1,136 @@
+package vertigo
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// VerticaError is an error reported by the server. It matches another *VerticaError
+// with the same Code when used with errors.Is, so errors can be checked against the
+// SQLSTATE constants like this:
+//
+//	errors.Is(err, &VerticaError{Code: ErrCodeUniqueViolation})
+type VerticaError struct {
+	Severity string // ERROR, FATAL or PANIC, or a localized translation.
+	Code     string // The SQLSTATE code, see the ErrCode constants.
+	Message  string // The primary error message.
+	Detail   string // An optional secondary message with more detail.
+	Hint     string // An optional suggestion what to do about the problem.
+	Position int    // The position in the query the error refers to, counting characters from 1, or 0.
+	Routine  string // The name of the source code routine reporting the error.
+
+	Fields map[byte]string // All fields of the error response, keyed by their field type.
+}
+
+// Converts the error response into a *VerticaError.
+func (msg ErrorResponseMessage) VerticaError() *VerticaError {
+	position, _ := strconv.Atoi(msg.Fields['P'])
+	return &VerticaError{
+		Severity: msg.Fields['S'],
+		Code:     msg.Fields['C'],
+		Message:  msg.Fields['M'],
+		Detail:   msg.Fields['D'],
+		Hint:     msg.Fields['H'],
+		Position: position,
+		Routine:  msg.Fields['R'],
+		Fields:   msg.Fields,
+	}
+}
+
+func (e *VerticaError) Error() string {
+	return fmt.Sprintf("Vertica %s %s: %s", e.Severity, e.Code, e.Message)
+}
+
+func (e *VerticaError) Is(target error) bool {
+	other, ok := target.(*VerticaError)
+	return ok && other.Code == e.Code
+}
+
+// Returns the error response the error was converted from, so errors.As still finds
+// the ErrorResponse and ErrorResponseMessage that earlier versions returned.
+func (e *VerticaError) Unwrap() error {
+	return ErrorResponseMessage{Fields: e.Fields}
+}
+
+// SQLSTATE codes of the errors reported by Vertica. Codes that are specific to
+// PostgreSQL use a P in their third position, Vertica uses a V instead.
+const (
+	ErrCodeSuccessfulCompletion = "00000"
+	ErrCodeWarning              = "01000"
+
+	ErrCodeConnectionException                           = "08000"
+	ErrCodeSQLClientUnableToEstablishSQLConnection       = "08001"
+	ErrCodeConnectionDoesNotExist                        = "08003"
+	ErrCodeSQLServerRejectedEstablishmentOfSQLConnection = "08004"
+	ErrCodeConnectionFailure                             = "08006"
+	ErrCodeProtocolViolation                             = "08V01"
+
+	ErrCodeFeatureNotSupported = "0A000"
+
+	ErrCodeDataException                = "22000"
+	ErrCodeStringDataRightTruncation    = "22001"
+	ErrCodeNumericValueOutOfRange       = "22003"
+	ErrCodeNullValueNotAllowed          = "22004"
+	ErrCodeInvalidDatetimeFormat        = "22007"
+	ErrCodeDatetimeFieldOverflow        = "22008"
+	ErrCodeDivisionByZero               = "22012"
+	ErrCodeInvalidParameterValue        = "22023"
+	ErrCodeCharacterNotInRepertoire     = "22021"
+	ErrCodeInvalidTextRepresentation    = "22V02"
+	ErrCodeInvalidBinaryRepresentation  = "22V03"
+	ErrCodeInvalidEscapeSequence        = "22025"
+	ErrCodeUntranslatableCharacter      = "22V04"
+	ErrCodeIntegrityConstraintViolation = "23000"
+	ErrCodeRestrictViolation            = "23001"
+	ErrCodeNotNullViolation             = "23502"
+	ErrCodeForeignKeyViolation          = "23503"
+	ErrCodeUniqueViolation              = "23505"
+	ErrCodeCheckViolation               = "23514"
+
+	ErrCodeInvalidTransactionState      = "25000"
+	ErrCodeActiveSQLTransaction         = "25001"
+	ErrCodeReadOnlySQLTransaction       = "25006"
+	ErrCodeNoActiveSQLTransaction       = "25V01"
+	ErrCodeInFailedSQLTransaction       = "25V02"
+	ErrCodeInvalidAuthorizationSpec     = "28000"
+	ErrCodeInvalidPassword              = "28V01"
+	ErrCodeTransactionRollback          = "40000"
+	ErrCodeSerializationFailure         = "40001"
+	ErrCodeStatementCompletionUnknown   = "40003"
+	ErrCodeDeadlockDetected             = "40V01"
+	ErrCodeSyntaxErrorOrAccessViolation = "42000"
+	ErrCodeInsufficientPrivilege        = "42501"
+	ErrCodeSyntaxError                  = "42601"
+	ErrCodeDuplicateColumn              = "42701"
+	ErrCodeAmbiguousColumn              = "42702"
+	ErrCodeUndefinedColumn              = "42703"
+	ErrCodeUndefinedObject              = "42704"
+	ErrCodeDuplicateObject              = "42710"
+	ErrCodeDatatypeMismatch             = "42804"
+	ErrCodeUndefinedFunction            = "42883"
+	ErrCodeUndefinedTable               = "42V01"
+	ErrCodeUndefinedSchema              = "3F000"
+	ErrCodeDuplicateSchema              = "42V06"
+	ErrCodeDuplicateTable               = "42V07"
+	ErrCodeInsufficientResources        = "53000"
+	ErrCodeDiskFull                     = "53100"
+	ErrCodeOutOfMemory                  = "53200"
+	ErrCodeTooManyConnections           = "53300"
+	ErrCodeProgramLimitExceeded         = "54000"
+	ErrCodeObjectNotInPrerequisiteState = "55000"
+	ErrCodeObjectInUse                  = "55006"
+	ErrCodeLockNotAvailable             = "55V03"
+	ErrCodeOperatorIntervention         = "57000"
+	ErrCodeQueryCanceled                = "57014"
+	ErrCodeAdminShutdown                = "57V01"
+	ErrCodeCrashShutdown                = "57V02"
+	ErrCodeCannotConnectNow             = "57V03"
+	ErrCodeSystemError                  = "58000"
+	ErrCodeIOError                      = "58030"
+	ErrCodeUndefinedFile                = "58V01"
+	ErrCodeDuplicateFile                = "58V02"
+	ErrCodeConfigFileError              = "F0000"
+	ErrCodeInternalError                = "XX000"
+	ErrCodeDataCorrupted                = "XX001"
+	ErrCodeIndexCorrupted               = "XX002"
+)
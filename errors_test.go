@@ -0,0 +1,48 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestVerticaError(t *testing.T) {
+	msg := ErrorResponseMessage{Fields: map[byte]string{
+		'S': "ERROR", 'C': "23505", 'M': "Duplicate key values", 'D': "key (id)=(1)", 'H': "Use MERGE", 'P': "15", 'R': "check_unique",
+	}}
+
+	err := msg.VerticaError()
+	if err.Code != ErrCodeUniqueViolation || err.Severity != "ERROR" || err.Detail != "key (id)=(1)" || err.Hint != "Use MERGE" || err.Position != 15 || err.Routine != "check_unique" {
+		t.Fatalf("Unexpected error %#+v", err)
+	}
+
+	if err.Error() != "Vertica ERROR 23505: Duplicate key values" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+
+	wrapped := fmt.Errorf("Insert failed: %w", err)
+	if !errors.Is(wrapped, &VerticaError{Code: ErrCodeUniqueViolation}) {
+		t.Error("Expected the error to match its SQLSTATE")
+	}
+	if errors.Is(wrapped, &VerticaError{Code: ErrCodeSyntaxError}) {
+		t.Error("Expected the error not to match another SQLSTATE")
+	}
+
+	var verticaError *VerticaError
+	if !errors.As(wrapped, &verticaError) || verticaError != err {
+		t.Error("Expected errors.As to find the *VerticaError")
+	}
+
+	var response ErrorResponse
+	if !errors.As(wrapped, &response) || response.Code() != ErrCodeUniqueViolation {
+		t.Error("Expected errors.As to find the ErrorResponse")
+	}
+	var responseMessage ErrorResponseMessage
+	if !errors.As(wrapped, &responseMessage) || responseMessage.Severity() != "ERROR" {
+		t.Error("Expected errors.As to find the ErrorResponseMessage")
+	}
+
+	if state := sqlState(wrapped); state != ErrCodeUniqueViolation {
+		t.Errorf("Expected SQLSTATE %s, but found %q", ErrCodeUniqueViolation, state)
+	}
+}
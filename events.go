@@ -0,0 +1,69 @@
+package vertigo
+
+import (
+	"strings"
+)
+
+// ConnectionEvents receives the lifecycle events of a connection, e.g. to log them or
+// to derive metrics from them. The methods are called synchronously while the
+// connection is locked, so they should be fast and must not use the connection. They
+// should be safe for concurrent use if the listener is shared by connections.
+type ConnectionEvents interface {
+	// Called when the first session of the connection was opened.
+	Connected(address string)
+
+	// Called when the server rejected the credentials, or the authentication method.
+	AuthFailed(address string, err error)
+
+	// Called when a session ended, with the error that broke it, or nil when it was
+	// closed or replaced on purpose.
+	Disconnected(address string, err error)
+
+	// Called when a session was opened to replace an earlier one, like after Reconnect.
+	Reconnected(address string)
+
+	// Called when a session was opened to another node than the previous one, like
+	// when the connection failed over to another node of the Topology.
+	NodeSwitched(from, to string)
+}
+
+// Reports that a session was opened to the address of the connection.
+func (c *Connection) sessionOpened() {
+	previous := c.connectedTo
+	c.connectedTo = c.address
+	c.established = true
+	if c.config.Events == nil {
+		return
+	}
+
+	if previous == "" {
+		c.config.Events.Connected(c.address)
+		return
+	}
+	c.config.Events.Reconnected(c.address)
+	if previous != c.address {
+		c.config.Events.NodeSwitched(previous, c.address)
+	}
+}
+
+// Reports that the session of the connection ended, if it was open. This has to be
+// called before the connection is reset.
+func (c *Connection) sessionEnded(err error) {
+	if !c.established {
+		return
+	}
+	c.established = false
+	if c.config.Events != nil {
+		c.config.Events.Disconnected(c.address, err)
+	}
+}
+
+// Reports that authenticating failed, when the error is an authentication error.
+func (c *Connection) authenticationFailed(err error) {
+	if c.config.Events == nil {
+		return
+	}
+	if err == AuthenticationMethodNotSupported || strings.HasPrefix(sqlState(err), "28") {
+		c.config.Events.AuthFailed(c.address, err)
+	}
+}
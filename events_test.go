@@ -0,0 +1,82 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+type recordingEvents struct {
+	events []string
+}
+
+func (e *recordingEvents) Connected(address string) {
+	e.events = append(e.events, "connected "+address)
+}
+
+func (e *recordingEvents) AuthFailed(address string, err error) {
+	e.events = append(e.events, "auth failed "+address)
+}
+
+func (e *recordingEvents) Disconnected(address string, err error) {
+	e.events = append(e.events, fmt.Sprintf("disconnected %s %v", address, err))
+}
+
+func (e *recordingEvents) Reconnected(address string) {
+	e.events = append(e.events, "reconnected "+address)
+}
+
+func (e *recordingEvents) NodeSwitched(from, to string) {
+	e.events = append(e.events, "switched "+from+" "+to)
+}
+
+func TestConnectionEvents(t *testing.T) {
+	first, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.RequirePassword("secret")
+
+	nodes := []string{first.Addr()}
+	resolver := HostResolverFunc(func(ctx context.Context) ([]string, error) { return nodes, nil })
+	events := &recordingEvents{}
+	connection, err := Open("", WithPassword("secret"), WithEvents(events), func(config *ConnectionInfo) { config.HostResolver = resolver })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes = []string{second.Addr()}
+	if err := connection.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	connection.markBroken(errors.New("connection reset"))
+	if err := connection.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(second.Addr(), WithPassword("wrong"), WithEvents(events)); err == nil {
+		t.Fatalf("Expected the wrong password to be rejected")
+	}
+
+	expected := []string{
+		"connected " + first.Addr(),
+		"disconnected " + first.Addr() + " <nil>",
+		"reconnected " + second.Addr(),
+		"switched " + first.Addr() + " " + second.Addr(),
+		"disconnected " + second.Addr() + " connection reset",
+		"auth failed " + second.Addr(),
+	}
+	if strings.Join(events.events, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected events %q, but found %q", expected, events.events)
+	}
+}
@@ -0,0 +1,250 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A query plan, as shown by EXPLAIN.
+type Plan struct {
+	Text string    // The full output of EXPLAIN
+	Root *PlanNode // The root of the access path, or nil if it couldn't be parsed
+}
+
+// A step of the access path of a query plan. Estimates the optimizer abbreviates,
+// like "2K", are expanded, so they are approximate.
+type PlanNode struct {
+	Operator string   // The operator with its arguments, like "STORAGE ACCESS for t" or "SORT [TOPK]"
+	Input    string   // "Outer" or "Inner" for the inputs of joins, otherwise empty
+	Cost     float64  // The estimated cost
+	Rows     float64  // The estimated number of rows
+	PathID   int      // The PATH ID, which identifies the step in the profiling tables
+	Details  []string // The lines below the operator, like "Projection: public.t_super"
+	Children []*PlanNode
+}
+
+// Returns all nodes of the plan, in depth-first order, starting with the root.
+func (p *Plan) Nodes() []*PlanNode {
+	var nodes []*PlanNode
+	var walk func(node *PlanNode)
+	walk = func(node *PlanNode) {
+		nodes = append(nodes, node)
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	if p.Root != nil {
+		walk(p.Root)
+	}
+	return nodes
+}
+
+// Runs EXPLAIN for the query, and parses the access path of the plan into a tree.
+// The query isn't run.
+func (c *Connection) Explain(sql string, args ...interface{}) (*Plan, error) {
+	resultset, err := c.Query("EXPLAIN "+sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer resultset.Close()
+
+	var lines []string
+	err = resultset.EachRow(func(row Row) error {
+		for _, value := range row.Values {
+			lines = append(lines, strings.Split(string(value), "\n")...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{Text: strings.Join(lines, "\n"), Root: parsePlan(lines)}, nil
+}
+
+var (
+	planEstimates = regexp.MustCompile(`\[Cost: ([0-9.]+[KMBT]?), Rows: ([0-9.]+[KMBT]?)[^\]]*\]`)
+	planPathID    = regexp.MustCompile(`\(PATH ID: (\d+)\)`)
+)
+
+// Parses the access path of the output of EXPLAIN. The operators start with +- markers,
+// and are nested by the column of their marker:
+//
+//	+-SELECT  LIMIT 10 [Cost: 20K, Rows: 10] (PATH ID: 0)
+//	|  Output Only: 10 tuples
+//	| +---> JOIN HASH [Cost: 3K, Rows: 5K] (PATH ID: 1)
+//	| |      Join Cond: (a.id = b.id)
+//	| | +-- Outer -> STORAGE ACCESS for a [Cost: 1K, Rows: 5M] (PATH ID: 2)
+//
+// Everything after the access path, like the GraphViz version of the plan, is ignored.
+func parsePlan(lines []string) *PlanNode {
+	type level struct {
+		column int
+		node   *PlanNode
+	}
+	var (
+		root  *PlanNode
+		stack []level
+	)
+	for _, line := range lines {
+		column := strings.Index(line, "+-")
+		if column < 0 || !planEstimates.MatchString(line) {
+			if len(stack) > 0 {
+				detail := strings.TrimSpace(strings.TrimLeft(line, "| "))
+				if strings.HasPrefix(detail, "----") || strings.HasPrefix(detail, "PLAN:") {
+					break
+				}
+				if detail != "" {
+					top := stack[len(stack)-1].node
+					top.Details = append(top.Details, detail)
+				}
+			}
+			continue
+		}
+
+		node := parsePlanNode(line[column:])
+		for len(stack) > 0 && stack[len(stack)-1].column >= column {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			if root != nil {
+				break
+			}
+			root = node
+		} else {
+			parent := stack[len(stack)-1].node
+			parent.Children = append(parent.Children, node)
+		}
+		stack = append(stack, level{column: column, node: node})
+	}
+	return root
+}
+
+// Parses an operator line of a plan, starting at its +- marker.
+func parsePlanNode(line string) *PlanNode {
+	node := &PlanNode{}
+	line = strings.TrimLeft(line, "+->")
+	line = strings.TrimSpace(line)
+	for _, input := range []string{"Outer", "Inner"} {
+		if strings.HasPrefix(line, input+" ->") {
+			node.Input = input
+			line = strings.TrimSpace(strings.TrimPrefix(line, input+" ->"))
+		}
+	}
+
+	estimates := planEstimates.FindStringSubmatchIndex(line)
+	node.Operator = strings.Join(strings.Fields(line[:estimates[0]]), " ")
+	node.Cost = parsePlanEstimate(line[estimates[2]:estimates[3]])
+	node.Rows = parsePlanEstimate(line[estimates[4]:estimates[5]])
+	if match := planPathID.FindStringSubmatch(line); match != nil {
+		node.PathID, _ = strconv.Atoi(match[1])
+	}
+	return node
+}
+
+// Parses an estimate like "639", "2K" or "1.5M".
+func parsePlanEstimate(s string) float64 {
+	multiplier := 1.0
+	switch s[len(s)-1] {
+	case 'K':
+		multiplier = 1e3
+	case 'M':
+		multiplier = 1e6
+	case 'B':
+		multiplier = 1e9
+	case 'T':
+		multiplier = 1e12
+	}
+	f, _ := strconv.ParseFloat(strings.TrimRight(s, "KMBT"), 64)
+	return f * multiplier
+}
+
+// The execution of a statement that was run with PROFILE.
+type Profile struct {
+	TransactionID int64
+	StatementID   int64
+	Resultset     *Resultset       // The rows the statement returned
+	Counters      []ProfileCounter // The counters of the execution engine, summed over all nodes
+}
+
+// A counter of an operator of the execution engine, like "execution time (us)" of
+// the "Scan" operator.
+type ProfileCounter struct {
+	Operator string
+	Counter  string
+	Value    int64
+}
+
+// Returns the sum of a counter over all operators, like "rows produced".
+func (p *Profile) Total(counter string) int64 {
+	var total int64
+	for _, c := range p.Counters {
+		if c.Counter == counter {
+			total += c.Value
+		}
+	}
+	return total
+}
+
+const profileCountersQuery = "SELECT operator_name, counter_name, SUM(counter_value) FROM v_monitor.execution_engine_profiles " +
+	"WHERE transaction_id = ? AND statement_id = ? GROUP BY operator_name, counter_name ORDER BY operator_name, counter_name"
+
+var profileIDs = regexp.MustCompile(`transaction_id=(\d+) and statement_id=(\d+)`)
+
+// Collects the resultset of a PROFILE statement, and the notice that identifies its
+// profiling data.
+type profileHandler struct {
+	*resultsetHandler
+	ids []string
+}
+
+func (h *profileHandler) handleNotice(msg NoticeResponseMessage) {
+	if match := profileIDs.FindStringSubmatch(msg.Fields['H']); match != nil {
+		h.ids = match[1:]
+	}
+}
+
+// Runs the query with PROFILE, and returns its rows together with the counters of the
+// execution engine from v_monitor.execution_engine_profiles. Unlike Explain, the query
+// is run.
+func (c *Connection) Profile(sql string, args ...interface{}) (*Profile, error) {
+	handler := &profileHandler{resultsetHandler: c.newResultsetHandler()}
+	err := c.run("PROFILE "+sql, args, handler)
+	if handler.err != nil {
+		err = handler.err
+	}
+	if err == nil && handler.ids == nil {
+		err = errors.New("Cannot find the profiling data of the statement")
+	}
+	if err != nil {
+		handler.resultset.Close()
+		return nil, err
+	}
+
+	profile := &Profile{Resultset: handler.resultset}
+	profile.TransactionID, _ = strconv.ParseInt(handler.ids[0], 10, 64)
+	profile.StatementID, _ = strconv.ParseInt(handler.ids[1], 10, 64)
+
+	counters, err := c.Query(profileCountersQuery, profile.TransactionID, profile.StatementID)
+	if err != nil {
+		profile.Resultset.Close()
+		return nil, fmt.Errorf("Cannot read the profiling data of the statement: %w", err)
+	}
+	defer counters.Close()
+
+	err = counters.EachRow(func(row Row) error {
+		var counter ProfileCounter
+		if err := row.Scan(&counter.Operator, &counter.Counter, &counter.Value); err != nil {
+			return err
+		}
+		profile.Counters = append(profile.Counters, counter)
+		return nil
+	})
+	if err != nil {
+		profile.Resultset.Close()
+		return nil, err
+	}
+	return profile, nil
+}
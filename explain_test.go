@@ -0,0 +1,109 @@
+package vertigo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+const testPlan = ` ------------------------------
+ QUERY PLAN DESCRIPTION:
+ ------------------------------
+
+ EXPLAIN SELECT * FROM a JOIN b ON a.id = b.id ORDER BY a.name LIMIT 10;
+
+ Access Path:
+ +-SELECT  LIMIT 10 [Cost: 20K, Rows: 10] (PATH ID: 0)
+ |  Output Only: 10 tuples
+ | +---> SORT [TOPK] [Cost: 20K, Rows: 5K] (PATH ID: 1)
+ | |      Order: a.name ASC
+ | | +---> JOIN HASH [Cost: 3K, Rows: 5K (NO STATISTICS)] (PATH ID: 2)
+ | | |      Join Cond: (a.id = b.id)
+ | | | +-- Outer -> STORAGE ACCESS for a [Cost: 1.5M, Rows: 5M] (PATH ID: 3)
+ | | | |      Projection: public.a_super
+ | | | +-- Inner -> STORAGE ACCESS for b [Cost: 300, Rows: 50K] (PATH ID: 4)
+ | | | |      Projection: public.b_super
+
+ ------------------------------
+ -----------------------------------------------
+ PLAN: BASE QUERY PLAN (GraphViz Format)
+ -----------------------------------------------
+ digraph G {
+ 0[label = "+-SELECT [Cost: 1, Rows: 1]"];
+ }`
+
+func TestParsePlan(t *testing.T) {
+	root := parsePlan(strings.Split(testPlan, "\n"))
+	if root == nil {
+		t.Fatalf("Expected the plan to be parsed")
+	}
+	plan := &Plan{Root: root}
+
+	var operators []string
+	for _, node := range plan.Nodes() {
+		operators = append(operators, node.Operator)
+	}
+	expected := []string{"SELECT LIMIT 10", "SORT [TOPK]", "JOIN HASH", "STORAGE ACCESS for a", "STORAGE ACCESS for b"}
+	if !reflect.DeepEqual(operators, expected) {
+		t.Fatalf("Expected operators %q, but found %q", expected, operators)
+	}
+
+	join := root.Children[0].Children[0]
+	if join.PathID != 2 || join.Cost != 3000 || join.Rows != 5000 || len(join.Children) != 2 {
+		t.Fatalf("Expected the join to be parsed, but found %+v", join)
+	}
+	if outer := join.Children[0]; outer.Input != "Outer" || outer.Cost != 1.5e6 || !reflect.DeepEqual(outer.Details, []string{"Projection: public.a_super"}) {
+		t.Fatalf("Expected the outer input of the join, but found %+v", outer)
+	}
+	if inner := join.Children[1]; inner.Input != "Inner" || inner.Rows != 50000 {
+		t.Fatalf("Expected the inner input of the join, but found %+v", inner)
+	}
+	if !reflect.DeepEqual(root.Details, []string{"Output Only: 10 tuples"}) {
+		t.Fatalf("Expected the details of the root, but found %q", root.Details)
+	}
+}
+
+func TestExplainAndProfile(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	explain := server.Expect("EXPLAIN SELECT * FROM t").Columns(vertigotest.Column{Name: "QUERY PLAN"})
+	for _, line := range []string{" Access Path:", " +-STORAGE ACCESS for t [Cost: 6, Rows: 3] (PATH ID: 1)", " |  Projection: public.t_super"} {
+		explain.Row(line)
+	}
+	server.Expect("PROFILE SELECT * FROM t").Columns(vertigotest.Column{Name: "a", Type: DataTypeInteger}).Row(1).Row(2).
+		Notice("Statement is being profiled", "Select * from v_monitor.execution_engine_profiles where transaction_id=45035996273705915 and statement_id=7;")
+	server.Expect("SELECT operator_name, counter_name, SUM(counter_value) FROM v_monitor.execution_engine_profiles WHERE transaction_id = 45035996273705915 AND statement_id = 7 GROUP BY operator_name, counter_name ORDER BY operator_name, counter_name").
+		Columns(vertigotest.Column{Name: "operator_name"}, vertigotest.Column{Name: "counter_name"}, vertigotest.Column{Name: "sum", Type: DataTypeInteger}).
+		Row("NewEENode", "rows produced", 2).Row("Scan", "rows produced", 3).Row("Scan", "execution time (us)", 120)
+
+	connection, err := Connect(&ConnectionInfo{Address: server.Addr(), User: "dbadmin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	plan, err := connection.Explain("SELECT * FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Root == nil || plan.Root.Operator != "STORAGE ACCESS for t" || plan.Root.Rows != 3 || len(plan.Root.Details) != 1 {
+		t.Fatalf("Expected a single storage access, but found %+v", plan.Root)
+	}
+
+	profile, err := connection.Profile("SELECT * FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.TransactionID != 45035996273705915 || profile.StatementID != 7 || len(profile.Resultset.Rows) != 2 {
+		t.Fatalf("Expected the profiled statement and its rows, but found %+v", profile)
+	}
+	if len(profile.Counters) != 3 || profile.Total("rows produced") != 5 {
+		t.Fatalf("Expected the counters of the statement, but found %+v", profile.Counters)
+	}
+}
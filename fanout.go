@@ -0,0 +1,188 @@
+package vertigo
+
+import (
+	"sync"
+)
+
+// FanOut streams the rows of a query to a number of worker goroutines, and
+// passes the results of the workers to a single collector function.
+//
+// The rows are sent to the workers over bounded channels, so reading from the
+// server slows down when the workers can't keep up. The first error returned by
+// a worker or the collector stops the processing of the remaining rows, which
+// are still read from the server but discarded.
+type FanOut struct {
+	Workers int  // The number of worker goroutines. Defaults to 1.
+	Buffer  int  // The capacity of the channels between the reader, the workers and the collector. Defaults to Workers.
+	Ordered bool // Whether results are passed to Collect in the order of the rows they were produced from.
+
+	// Processes a single row. It is called concurrently from all worker goroutines.
+	Work func(fields []Field, row Row) (interface{}, error)
+
+	// Receives the results of Work. It is called from a single goroutine, and can be nil.
+	Collect func(result interface{}) error
+}
+
+type fanOutJob struct {
+	seq    int
+	fields []Field
+	row    Row
+}
+
+type fanOutResult struct {
+	seq   int
+	value interface{}
+	err   error
+}
+
+// The state of a single run of a FanOut. It is the resultHandler of the query.
+type fanOutRun struct {
+	FanOut
+
+	fields  []Field
+	seq     int
+	jobs    chan fanOutJob
+	results chan fanOutResult
+	done    chan struct{}
+
+	workers   sync.WaitGroup
+	collector sync.WaitGroup
+	errOnce   sync.Once
+	err       error
+}
+
+// Runs the query, and processes its rows with the workers.
+//
+// It returns the first error returned by a worker or the collector, or else the
+// error returned by the query.
+func (f FanOut) Run(c *Connection, sql string, args ...interface{}) error {
+	run := f.start()
+	return run.wait(c.run(sql, args, run))
+}
+
+// Starts the worker and collector goroutines.
+func (f FanOut) start() *fanOutRun {
+	if f.Workers <= 0 {
+		f.Workers = 1
+	}
+	if f.Buffer <= 0 {
+		f.Buffer = f.Workers
+	}
+
+	run := &fanOutRun{
+		FanOut:  f,
+		jobs:    make(chan fanOutJob, f.Buffer),
+		results: make(chan fanOutResult, f.Buffer),
+		done:    make(chan struct{}),
+	}
+
+	run.workers.Add(f.Workers)
+	for i := 0; i < f.Workers; i++ {
+		go run.work()
+	}
+
+	run.collector.Add(1)
+	go run.collect()
+
+	return run
+}
+
+// Waits for all rows to be processed, and returns the consolidated error.
+func (run *fanOutRun) wait(queryError error) error {
+	close(run.jobs)
+	run.workers.Wait()
+	close(run.results)
+	run.collector.Wait()
+
+	if run.err != nil {
+		return run.err
+	}
+	return queryError
+}
+
+// Records the first error, and makes the reader, workers and collector skip
+// all remaining rows.
+func (run *fanOutRun) fail(err error) {
+	run.errOnce.Do(func() {
+		run.err = err
+		close(run.done)
+	})
+}
+
+func (run *fanOutRun) failed() bool {
+	select {
+	case <-run.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (run *fanOutRun) work() {
+	defer run.workers.Done()
+
+	for job := range run.jobs {
+		if run.failed() {
+			continue
+		}
+
+		value, err := run.Work(job.fields, job.row)
+		run.results <- fanOutResult{seq: job.seq, value: value, err: err}
+	}
+}
+
+func (run *fanOutRun) collect() {
+	defer run.collector.Done()
+
+	var (
+		next    int
+		pending = make(map[int]fanOutResult)
+	)
+
+	for result := range run.results {
+		if run.failed() {
+			continue
+		}
+
+		if !run.Ordered {
+			run.handleResult(result)
+			continue
+		}
+
+		pending[result.seq] = result
+		for result, ok := pending[next]; ok && !run.failed(); result, ok = pending[next] {
+			delete(pending, next)
+			run.handleResult(result)
+			next++
+		}
+	}
+}
+
+func (run *fanOutRun) handleResult(result fanOutResult) {
+	if result.err != nil {
+		run.fail(result.err)
+		return
+	}
+
+	if run.Collect != nil {
+		if err := run.Collect(result.value); err != nil {
+			run.fail(err)
+		}
+	}
+}
+
+func (run *fanOutRun) handleFields(fields []Field) {
+	run.fields = fields
+}
+
+func (run *fanOutRun) handleRow(values [][]byte) {
+	job := fanOutJob{seq: run.seq, fields: run.fields, row: Row{Values: values, fields: run.fields}}
+	run.seq++
+
+	select {
+	case run.jobs <- job:
+	case <-run.done:
+	}
+}
+
+func (run *fanOutRun) handleComplete(result string) {}
@@ -0,0 +1,65 @@
+package vertigo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func runFanOut(f FanOut, rows int) error {
+	run := f.start()
+	run.handleFields([]Field{{Name: "n", DataTypeOID: DataTypeInteger}})
+	for i := 0; i < rows; i++ {
+		run.handleRow([][]byte{[]byte(strconv.Itoa(i))})
+	}
+	run.handleComplete("SELECT")
+	return run.wait(nil)
+}
+
+func TestFanOutOrdered(t *testing.T) {
+	var collected []int64
+	fanOut := FanOut{
+		Workers: 4,
+		Ordered: true,
+		Work: func(fields []Field, row Row) (interface{}, error) {
+			var n int64
+			err := row.Scan(&n)
+			return n, err
+		},
+		Collect: func(result interface{}) error {
+			collected = append(collected, result.(int64))
+			return nil
+		},
+	}
+
+	if err := runFanOut(fanOut, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(collected) != 1000 {
+		t.Fatalf("Expected 1000 results, but found %d", len(collected))
+	}
+
+	for i, n := range collected {
+		if n != int64(i) {
+			t.Fatalf("Expected result %d to be %d, but found %d", i, i, n)
+		}
+	}
+}
+
+func TestFanOutError(t *testing.T) {
+	workError := errors.New("work failed")
+	fanOut := FanOut{
+		Workers: 4,
+		Work: func(fields []Field, row Row) (interface{}, error) {
+			if string(row.Values[0]) == "10" {
+				return nil, workError
+			}
+			return nil, nil
+		},
+	}
+
+	if err := runFanOut(fanOut, 1000); err != workError {
+		t.Fatalf("Expected the work error, but found %#+v", err)
+	}
+}
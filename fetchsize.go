@@ -0,0 +1,48 @@
+package vertigo
+
+import (
+	"time"
+)
+
+// Makes ExecutePortal tune the number of rows fetched per batch to the rows of the
+// statement, see Stmt.ExecutePortal.
+const AdaptiveFetchSize = -1
+
+const (
+	defaultFetchBytes        = 1 << 20
+	initialAdaptiveFetchSize = 100
+	maxAdaptiveFetchSize     = 1 << 20
+
+	// Batches aren't grown beyond what the server sends within this duration, so slow
+	// statements still return their rows steadily.
+	maxAdaptiveFetchLatency = time.Second
+)
+
+// Picks the number of rows to fetch in the next batch of a portal executed with
+// AdaptiveFetchSize, after a batch of rows was fetched in the duration. The size aims
+// at the FetchBytes of the connection based on the average width of the rows so far,
+// and grows at most twofold per batch, so a mistaken estimate from a few rows doesn't
+// fetch huge batches.
+func (p *Portal) adaptFetchSize(rows []Row, latency time.Duration) {
+	for _, row := range rows {
+		for _, value := range row.Values {
+			// Every value is preceded by its length.
+			p.fetchedBytes += int64(len(value)) + 4
+		}
+	}
+	p.fetchedRows += int64(len(rows))
+	if p.fetchedRows == 0 {
+		return
+	}
+
+	target := int64(p.stmt.c.config.FetchBytes)
+	if target <= 0 {
+		target = defaultFetchBytes
+	}
+	width := max(p.fetchedBytes/p.fetchedRows, 1)
+	size := min(target/width, 2*int64(p.fetchSize), maxAdaptiveFetchSize)
+	if latency > maxAdaptiveFetchLatency {
+		size = min(size, int64(len(rows))*int64(maxAdaptiveFetchLatency)/int64(latency))
+	}
+	p.fetchSize = int(max(size, 1))
+}
@@ -0,0 +1,76 @@
+package vertigo
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestAdaptFetchSize(t *testing.T) {
+	portal := &Portal{stmt: &Stmt{c: &Connection{config: &ConnectionInfo{FetchBytes: 4000}}}, fetchSize: 10}
+	rows := make([]Row, 10)
+	for i := range rows {
+		// 2 values of 16 bytes, plus their lengths.
+		rows[i] = Row{Values: [][]byte{make([]byte, 16), make([]byte, 16)}}
+	}
+
+	portal.adaptFetchSize(rows, time.Millisecond)
+	if portal.fetchSize != 20 {
+		t.Fatalf("Expected the fetch size to double, but found %d", portal.fetchSize)
+	}
+	for i := 0; i < 3; i++ {
+		portal.adaptFetchSize(rows, time.Millisecond)
+	}
+	if portal.fetchSize != 100 {
+		t.Fatalf("Expected the fetch size to reach 4000 bytes of rows, but found %d", portal.fetchSize)
+	}
+
+	portal.adaptFetchSize(rows, 2*time.Second)
+	if portal.fetchSize != 5 {
+		t.Fatalf("Expected the fetch size to shrink for a slow batch, but found %d", portal.fetchSize)
+	}
+}
+
+func TestExecutePortalAdaptive(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	response := server.Expect("SELECT i FROM t").Columns(vertigotest.Column{Name: "i", Type: DataTypeInteger})
+	for i := 0; i < 250; i++ {
+		response.Row(i)
+	}
+
+	connection, err := Open(server.Addr(), WithFetchBytes(1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	stmt, err := connection.Prepare("SELECT i FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	portal, err := stmt.ExecutePortal(AdaptiveFetchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batches []int
+	for {
+		rows, err := portal.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		batches = append(batches, len(rows))
+	}
+	if len(batches) != 2 || batches[0] != 100 || batches[1] != 150 {
+		t.Fatalf("Expected batches of 100 rows and then of the remaining rows, but found %v", batches)
+	}
+}
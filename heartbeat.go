@@ -0,0 +1,70 @@
+package vertigo
+
+import (
+	"time"
+)
+
+// Stops the heartbeat goroutine of a connection when it is closed.
+type heartbeat struct {
+	stop chan struct{}
+}
+
+// Starts sending heartbeats on the connection if HeartbeatInterval is set and they
+// aren't sent yet. The connection lock must be held.
+//
+// Connections returned by Connect are copied by the caller, so the heartbeat can only
+// start once the connection has reached its final address, which is only known in Open,
+// Pool and Connector. Connections returned by Connect don't send heartbeats.
+func (c *Connection) startHeartbeat() {
+	if c.config.HeartbeatInterval <= 0 || c.heartbeat != nil {
+		return
+	}
+
+	c.heartbeat = &heartbeat{stop: make(chan struct{})}
+	go c.sendHeartbeats(c.config.HeartbeatInterval, c.heartbeat.stop)
+}
+
+// Stops sending heartbeats. The connection lock must be held.
+func (c *Connection) stopHeartbeat() {
+	if c.heartbeat != nil {
+		close(c.heartbeat.stop)
+		c.heartbeat = nil
+	}
+}
+
+func (c *Connection) sendHeartbeats(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.sendHeartbeat(interval)
+		}
+	}
+}
+
+// Sends a Sync message if the connection has been idle for the interval, and breaks
+// the connection when the server doesn't answer it. Connections that are in use are
+// skipped, rather than waited for.
+func (c *Connection) sendHeartbeat(interval time.Duration) {
+	if !c.l.TryLock() {
+		return
+	}
+	defer c.l.Unlock()
+
+	if c.socket == nil || c.broken != nil || c.portal != nil || time.Since(c.idleSince) < interval {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err := r.(error)
+			c.markBroken(err)
+			c.log(LogLevelWarn, "Heartbeat failed", "address", c.address, "error", err)
+		}
+	}()
+	c.syncRoundTrip()
+}
@@ -0,0 +1,72 @@
+package vertigo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestHeartbeat(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	connection, err := Open(server.Addr(), WithHeartbeat(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	for deadline := time.Now().Add(time.Second); connection.Stats().MessagesSent["S"] < 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected heartbeats to be sent, but found %v", connection.Stats().MessagesSent)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	server.Close()
+	for deadline := time.Now().Add(time.Second); connection.IsAlive(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a heartbeat to break the connection after the server went away")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHeartbeatPool(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "one", Type: DataTypeInteger}).Row(1)
+
+	// Run with -race: the heartbeats share the connection with the borrowers.
+	pool := &Pool{Config: &ConnectionInfo{Address: server.Addr(), HeartbeatInterval: time.Millisecond}, MaxOpen: 1}
+	defer pool.Close()
+
+	for i := 0; i < 100; i++ {
+		c, err := pool.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%10 == 0 {
+			if _, err := c.Query("SELECT 1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if c.Address() != server.Addr() || c.TransactionStatus() != TransactionStatusIdle {
+			t.Fatalf("Expected an idle connection to %s, but found %s in state %s", server.Addr(), c.Address(), c.TransactionStatus())
+		}
+		time.Sleep(time.Millisecond)
+		pool.Put(c)
+	}
+
+	if stats := pool.Stats(); stats.OpenConnections != 1 {
+		t.Fatalf("Expected the heartbeats to keep the connection alive, but found %+v", stats)
+	}
+}
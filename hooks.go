@@ -0,0 +1,40 @@
+package vertigo
+
+import (
+	"context"
+	"time"
+)
+
+// Describes a statement to the OnQueryStart and OnQueryEnd hooks of a connection.
+type QueryEvent struct {
+	SQL        string    // The SQL text as it is sent, with the arguments interpolated, and redacted like in logs.
+	Start      time.Time // When the statement started.
+	BackendPid uint32    // The PID of the backend process, or zero if the connection isn't open yet.
+
+	// Only set for OnQueryEnd.
+	Duration time.Duration // How long the statement took.
+	Rows     int           // The number of rows received.
+	Err      error         // The error the statement failed with, if any.
+}
+
+// Calls the OnQueryStart hook, and returns the context for the OnQueryEnd hook.
+func (c *Connection) queryStarted(ctx context.Context, event *QueryEvent) context.Context {
+	if c.config.OnQueryStart == nil {
+		return ctx
+	}
+	if hookCtx := c.config.OnQueryStart(ctx, *event); hookCtx != nil {
+		return hookCtx
+	}
+	return ctx
+}
+
+// Calls the OnQueryEnd hook.
+func (c *Connection) queryEnded(ctx context.Context, event *QueryEvent, rows int, err error) {
+	if c.config.OnQueryEnd == nil {
+		return
+	}
+	event.Duration = time.Since(event.Start)
+	event.Rows = rows
+	event.Err = err
+	c.config.OnQueryEnd(ctx, *event)
+}
@@ -0,0 +1,63 @@
+package vertigo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+type hookKey struct{}
+
+func TestQueryHooks(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT id FROM t WHERE id > 1").Columns(vertigotest.Column{Name: "id", Type: DataTypeInteger}).Row(2).Row(3)
+	server.Expect("SELECT broken").Error("42601", "Syntax error")
+	server.Expect("ALTER USER u IDENTIFIED BY 'secret'").Tag("ALTER USER")
+
+	var started, ended []QueryEvent
+	start := func(ctx context.Context, event QueryEvent) context.Context {
+		started = append(started, event)
+		return context.WithValue(ctx, hookKey{}, len(started))
+	}
+	end := func(ctx context.Context, event QueryEvent) {
+		if ctx.Value(hookKey{}) != len(started) {
+			t.Errorf("Expected the context of OnQueryStart, but found %v", ctx.Value(hookKey{}))
+		}
+		ended = append(ended, event)
+	}
+
+	connection, err := Open(server.Addr(), WithQueryHooks(start, end))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if _, err := connection.Query("SELECT id FROM t WHERE id > ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connection.Query("SELECT broken"); err == nil {
+		t.Fatalf("Expected the statement to fail")
+	}
+
+	if len(started) != 2 || started[0].SQL != "SELECT id FROM t WHERE id > 1" || started[0].Start.IsZero() {
+		t.Fatalf("Expected two started statements, but found %+v", started)
+	}
+	if len(ended) != 2 || ended[0].Rows != 2 || ended[0].Err != nil || ended[0].Duration <= 0 || ended[0].BackendPid == 0 {
+		t.Fatalf("Expected the first statement to end with 2 rows, but found %+v", ended)
+	}
+	if sqlState(ended[1].Err) != "42601" || ended[1].Start != started[1].Start {
+		t.Fatalf("Expected the second statement to end with a syntax error, but found %+v", ended[1])
+	}
+
+	if _, err := connection.Exec("ALTER USER u IDENTIFIED BY 'secret'"); err != nil {
+		t.Fatal(err)
+	}
+	if sql := started[2].SQL; sql != "ALTER USER u IDENTIFIED BY '***'" {
+		t.Fatalf("Expected the password to be redacted, but found %q", sql)
+	}
+}
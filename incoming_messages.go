@@ -8,6 +8,8 @@ import (
 	"io"
 )
 
+// The errors reported by the server are returned as *VerticaError, which wraps an
+// ErrorResponse. Use errors.As to get it.
 type ErrorResponse interface {
 	Error() string
 	Code() string
@@ -57,6 +59,17 @@ func (msg ErrorResponseMessage) Severity() string {
 	return msg.Fields['S']
 }
 
+// A warning or informational message of the server, with the same fields as an
+// ErrorResponseMessage. It doesn't affect the statement that is running.
+type NoticeResponseMessage struct {
+	Fields map[byte]string
+}
+
+func parseNoticeResponseMessage(body []byte) (IncomingMessage, error) {
+	msg, err := parseErrorResponseMessage(body)
+	return NoticeResponseMessage{Fields: msg.(ErrorResponseMessage).Fields}, err
+}
+
 type EmptyQueryMessage struct{}
 
 func parseEmptyQueryMessage(body []byte) (IncomingMessage, error) {
@@ -164,6 +177,11 @@ func parseRowDescriptionMessage(body []byte) (IncomingMessage, error) {
 
 	offset := 2
 
+	// Every field takes at least 19 bytes, so don't allocate more fields than fit in the body.
+	if int(numFields)*19 > len(body)-offset {
+		return msg, errors.New("parseRowDescriptionMessage: truncated message")
+	}
+
 	msg.Fields = make([]Field, numFields)
 	for i := range msg.Fields {
 		field := &msg.Fields[i]
@@ -213,7 +231,12 @@ type DataRowMessage struct {
 }
 
 func parseDataRowMessage(body []byte) (IncomingMessage, error) {
-	msg := DataRowMessage{}
+	return parseDataRow(body, func(n int) [][]byte { return make([][]byte, n) })
+}
+
+// Parses a DataRow message into the value slice makeValues returns for the number of
+// values of the row. The values refer to the body.
+func parseDataRow(body []byte, makeValues func(n int) [][]byte) (msg DataRowMessage, err error) {
 	var numValues uint16
 	if err := decodeUint16(body, &numValues); err != nil {
 		return msg, err
@@ -222,7 +245,11 @@ func parseDataRowMessage(body []byte) (IncomingMessage, error) {
 	offset := 2
 	bodyLen := len(body)
 
-	msg.Values = make([][]byte, numValues)
+	if int(numValues)*4 > bodyLen-offset {
+		return msg, errors.New("parseDataRowMessage: truncated message")
+	}
+
+	msg.Values = makeValues(int(numValues))
 	for i := range msg.Values {
 		var size uint32
 		if err := decodeUint32(body[offset:], &size); err != nil {
@@ -236,7 +263,7 @@ func parseDataRowMessage(body []byte) (IncomingMessage, error) {
 			if offset+int(size) > bodyLen {
 				return msg, errors.New("parseDataRowMessage: truncated message")
 			}
-			msg.Values[i] = body[offset : offset+int(size)]
+			msg.Values[i] = body[offset : offset+int(size) : offset+int(size)]
 			offset += int(size)
 		}
 	}
@@ -244,52 +271,157 @@ func parseDataRowMessage(body []byte) (IncomingMessage, error) {
 	return msg, nil
 }
 
+type ParseCompleteMessage struct{}
+
+func parseParseCompleteMessage(body []byte) (IncomingMessage, error) {
+	return ParseCompleteMessage{}, nil
+}
+
+type BindCompleteMessage struct{}
+
+func parseBindCompleteMessage(body []byte) (IncomingMessage, error) {
+	return BindCompleteMessage{}, nil
+}
+
+type CloseCompleteMessage struct{}
+
+func parseCloseCompleteMessage(body []byte) (IncomingMessage, error) {
+	return CloseCompleteMessage{}, nil
+}
+
+type NoDataMessage struct{}
+
+func parseNoDataMessage(body []byte) (IncomingMessage, error) {
+	return NoDataMessage{}, nil
+}
+
+type PortalSuspendedMessage struct{}
+
+func parsePortalSuspendedMessage(body []byte) (IncomingMessage, error) {
+	return PortalSuspendedMessage{}, nil
+}
+
+type ParameterDescriptionMessage struct {
+	DataTypeOIDs []uint32
+}
+
+func parseParameterDescriptionMessage(body []byte) (IncomingMessage, error) {
+	msg := ParameterDescriptionMessage{}
+	var numParameters uint16
+	if err := decodeUint16(body, &numParameters); err != nil {
+		return msg, err
+	}
+
+	if int(numParameters)*4 > len(body)-2 {
+		return msg, errors.New("parseParameterDescriptionMessage: truncated message")
+	}
+
+	msg.DataTypeOIDs = make([]uint32, numParameters)
+	for i := range msg.DataTypeOIDs {
+		if err := decodeUint32(body[2+4*i:], &msg.DataTypeOIDs[i]); err != nil {
+			return msg, err
+		}
+	}
+	return msg, nil
+}
+
+// Sent when a COPY FROM STDIN statement is ready to receive data.
+type CopyInResponseMessage struct {
+	Format        byte     // 0 for text, 1 for binary
+	ColumnFormats []uint16 // The format of each column
+}
+
+func parseCopyInResponseMessage(body []byte) (IncomingMessage, error) {
+	msg := CopyInResponseMessage{}
+	if err := decodeUint8(body, &msg.Format); err != nil {
+		return msg, err
+	}
+
+	var numColumns uint16
+	if err := decodeUint16(body[1:], &numColumns); err != nil {
+		return msg, err
+	}
+	if int(numColumns)*2 > len(body)-3 {
+		return msg, errors.New("parseCopyInResponseMessage: truncated message")
+	}
+
+	msg.ColumnFormats = make([]uint16, numColumns)
+	for i := range msg.ColumnFormats {
+		msg.ColumnFormats[i] = unpackUint16(body[3+2*i:])
+	}
+	return msg, nil
+}
+
 type messageFactoryMethod func(raw []byte) (IncomingMessage, error)
 
 var messageFactoryMethods = map[byte]messageFactoryMethod{
 	'R': parseAuthenticationRequestMessage,
 	'Z': parseReadyForQueryMessage,
 	'E': parseErrorResponseMessage,
+	'N': parseNoticeResponseMessage,
 	'I': parseEmptyQueryMessage,
 	'S': parseParameterStatusMessage,
 	'K': parseBackendKeyDataMessage,
 	'T': parseRowDescriptionMessage,
 	'C': parseCommandCompleteMessage,
 	'D': parseDataRowMessage,
+	'1': parseParseCompleteMessage,
+	'2': parseBindCompleteMessage,
+	'3': parseCloseCompleteMessage,
+	'n': parseNoDataMessage,
+	's': parsePortalSuspendedMessage,
+	't': parseParameterDescriptionMessage,
+	'G': parseCopyInResponseMessage,
+}
+
+// Returned when the server sends a message that cannot be parsed. The connection
+// is out of sync with the server after this, and is closed.
+type ProtocolError struct {
+	MessageType byte
+	Err         error
 }
 
-func receiveMessage(r io.Reader) (message IncomingMessage, err error) {
-	var (
-		messageType byte
-		messageSize uint32
-	)
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("Malformed message of type %q: %s", e.MessageType, e.Err)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
 
-	header := make([]byte, 5)
-	if _, err = io.ReadAtLeast(r, header, 5); err != nil {
+// Reads the header of a message, and returns the message type and the size of its body.
+// Unknown message types are rejected before the body is read, so a peer that doesn't
+// speak the protocol doesn't make us read an arbitrary amount of data. The header is
+// read into header, which must hold 5 bytes, so it can be reused for every message.
+func receiveMessageHeader(r io.Reader, header []byte) (messageType byte, bodySize int, err error) {
+	if _, err = io.ReadFull(r, header[:5]); err != nil {
 		return
 	}
 
 	messageType = header[0]
-	messageSize = unpackUint32(header[1:5])
-
-	var messageContent []byte
-	if messageSize >= 4 {
-		messageContent = make([]byte, messageSize-4)
-		if messageSize > 4 {
-			if _, err = io.ReadFull(r, messageContent); err != nil {
-				return
-			}
-		}
-	} else {
-		err = errors.New("A message should be at least 4 bytes long")
+	messageSize := unpackUint32(header[1:5])
+	if messageFactoryMethods[messageType] == nil {
+		err = &ProtocolError{MessageType: messageType, Err: errors.New("Unknown message type")}
+		return
+	}
+	if messageSize < 4 {
+		err = &ProtocolError{MessageType: messageType, Err: errors.New("A message should be at least 4 bytes long")}
 		return
 	}
+	return messageType, int(messageSize - 4), nil
+}
 
+func parseMessage(messageType byte, body []byte) (IncomingMessage, error) {
 	factoryMethod := messageFactoryMethods[messageType]
 	if factoryMethod == nil {
-		panic(fmt.Sprintf("Unknown message type: %c", messageType))
+		return nil, &ProtocolError{MessageType: messageType, Err: errors.New("Unknown message type")}
+	}
+
+	msg, err := factoryMethod(body)
+	if err != nil {
+		return nil, &ProtocolError{MessageType: messageType, Err: err}
 	}
-	return factoryMethod(messageContent)
+	return msg, nil
 }
 
 func decodeNumeric(reader *bufio.Reader, data interface{}) error {
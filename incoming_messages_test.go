@@ -0,0 +1,167 @@
+package vertigo
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+// Seeds the fuzzer with the valid body and all of its truncations, and checks that
+// the parser never panics.
+func fuzzParser(f *testing.F, parse messageFactoryMethod, valid ...[]byte) {
+	for _, body := range valid {
+		for i := 0; i <= len(body); i++ {
+			f.Add(body[:i])
+		}
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		parse(body)
+	})
+}
+
+func FuzzParseErrorResponseMessage(f *testing.F) {
+	fuzzParser(f, parseErrorResponseMessage, []byte("SERROR\x00C42601\x00MSyntax error\x00P3\x00\x00"))
+}
+
+func FuzzParseEmptyQueryMessage(f *testing.F) {
+	fuzzParser(f, parseEmptyQueryMessage, nil)
+}
+
+func FuzzParseAuthenticationRequestMessage(f *testing.F) {
+	fuzzParser(f, parseAuthenticationRequestMessage, []byte{0, 0, 0, 3})
+}
+
+func FuzzParseReadyForQueryMessage(f *testing.F) {
+	fuzzParser(f, parseReadyForQueryMessage, []byte{'I'})
+}
+
+func FuzzParseParameterStatusMessage(f *testing.F) {
+	fuzzParser(f, parseParameterStatusMessage, []byte("timezone\x00UTC\x00"))
+}
+
+func FuzzParseBackendKeyDataMessage(f *testing.F) {
+	fuzzParser(f, parseBackendKeyDataMessage, []byte{0, 0, 0, 42, 0, 0, 0, 7})
+}
+
+func FuzzParseCommandCompleteMessage(f *testing.F) {
+	fuzzParser(f, parseCommandCompleteMessage, []byte("SELECT 1\x00"))
+}
+
+func FuzzParseRowDescriptionMessage(f *testing.F) {
+	fuzzParser(f, parseRowDescriptionMessage, []byte("\x00\x02a\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x06\x00\x08\xff\xff\xff\xff\x00\x00b\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x09\xff\xff\x00\x00\x00\x00\x00\x00"))
+}
+
+func FuzzParseDataRowMessage(f *testing.F) {
+	fuzzParser(f, parseDataRowMessage, []byte("\x00\x02\x00\x00\x00\x011\xff\xff\xff\xff"))
+}
+
+func FuzzParseParseCompleteMessage(f *testing.F) {
+	fuzzParser(f, parseParseCompleteMessage, nil)
+}
+
+func FuzzParseBindCompleteMessage(f *testing.F) {
+	fuzzParser(f, parseBindCompleteMessage, nil)
+}
+
+func FuzzParseCloseCompleteMessage(f *testing.F) {
+	fuzzParser(f, parseCloseCompleteMessage, nil)
+}
+
+func FuzzParseNoDataMessage(f *testing.F) {
+	fuzzParser(f, parseNoDataMessage, nil)
+}
+
+func FuzzParsePortalSuspendedMessage(f *testing.F) {
+	fuzzParser(f, parsePortalSuspendedMessage, nil)
+}
+
+func FuzzParseParameterDescriptionMessage(f *testing.F) {
+	fuzzParser(f, parseParameterDescriptionMessage, []byte{0, 2, 0, 0, 0, 6, 0, 0, 0, 9})
+}
+
+func FuzzParseCopyInResponseMessage(f *testing.F) {
+	fuzzParser(f, parseCopyInResponseMessage, []byte{0, 0, 2, 0, 0, 0, 0})
+}
+
+func FuzzParseMessage(f *testing.F) {
+	f.Add(byte('D'), []byte("\x00\x01\x00\x00\x00\x011"))
+	f.Add(byte('x'), []byte{})
+	f.Fuzz(func(t *testing.T, messageType byte, body []byte) {
+		if _, err := parseMessage(messageType, body); err != nil {
+			var protocolError *ProtocolError
+			if !errors.As(err, &protocolError) {
+				t.Fatalf("Expected a *ProtocolError, but found %T", err)
+			}
+		}
+	})
+}
+
+func FuzzReceiveMessageHeader(f *testing.F) {
+	f.Add([]byte("Z\x00\x00\x00\x05I"))
+	f.Add([]byte("HTTP/1.1 400 Bad Request\r\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		messageType, bodySize, err := receiveMessageHeader(bytes.NewReader(data), make([]byte, 5))
+		if err == nil && (messageFactoryMethods[messageType] == nil || bodySize < 0) {
+			t.Fatalf("Expected message %q with body size %d to be rejected", messageType, bodySize)
+		}
+	})
+}
+
+func TestParseTruncatedMessages(t *testing.T) {
+	tests := []struct {
+		messageType byte
+		body        []byte
+	}{
+		{'K', []byte{0, 0, 0, 42, 0, 0}},
+		{'T', []byte{0xff, 0xff, 'a', 0}},
+		{'D', []byte{0xff, 0xff, 0, 0, 0, 0}},
+		{'D', []byte{0, 1, 0x7f, 0xff, 0xff, 0xff}},
+		{'t', []byte{0, 2, 0, 0, 0, 6}},
+		{'G', []byte{0, 0, 2, 0, 0}},
+		{'E', []byte("SERROR")},
+		{'S', []byte("timezone\x00UTC")},
+	}
+
+	for _, test := range tests {
+		_, err := parseMessage(test.messageType, test.body)
+		var protocolError *ProtocolError
+		if !errors.As(err, &protocolError) || protocolError.MessageType != test.messageType {
+			t.Fatalf("Expected a *ProtocolError for truncated message %q, but found %v", test.messageType, err)
+		}
+	}
+}
+
+func TestReceiveUnknownMessage(t *testing.T) {
+	_, _, err := receiveMessageHeader(bytes.NewReader([]byte("HTTP/1.1 400 Bad Request\r\n")), make([]byte, 5))
+	var protocolError *ProtocolError
+	if !errors.As(err, &protocolError) || protocolError.MessageType != 'H' {
+		t.Fatalf("Expected a *ProtocolError for an unknown message type, but found %v", err)
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "a"}).Row(strings.Repeat("a", 100))
+
+	connection, err := Connect(&ConnectionInfo{Address: server.Addr(), User: "dbadmin", MaxMessageSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	_, err = connection.Query("SELECT 1")
+	var protocolError *ProtocolError
+	if !errors.As(err, &protocolError) || protocolError.MessageType != 'D' {
+		t.Fatalf("Expected a *ProtocolError for a message exceeding MaxMessageSize, but found %v", err)
+	}
+	if connection.IsAlive() {
+		t.Fatalf("Expected the connection to be broken")
+	}
+}
@@ -0,0 +1,50 @@
+package vertigo
+
+import (
+	"context"
+)
+
+// A statement that is about to be run, as seen by a QueryInterceptor. Unlike in logs
+// and the QueryEvent of hooks, passwords aren't redacted from the SQL, as interceptors
+// may rewrite it, so interceptors that log statements have to take care of that.
+type Query struct {
+	SQL  string        // The SQL text, with placeholders if there are arguments.
+	Args []interface{} // The arguments for the placeholders.
+}
+
+// The outcome of a statement, as seen by a QueryInterceptor.
+type QueryResult struct {
+	CommandTag CommandTag // The command tag of the last statement.
+	Rows       int        // The number of rows received.
+
+	// The resultset that Query and QueryContext return, which is nil for the methods
+	// that don't buffer one. An interceptor can return a resultset without calling
+	// next, e.g. to serve it from a cache.
+	Resultset *Resultset
+}
+
+// Runs a statement, see QueryInterceptor.
+type QueryFunc func(ctx context.Context, query Query) (QueryResult, error)
+
+// Wraps the statements run on a connection, like HTTP middleware. It can inspect or
+// rewrite the query before passing it on to next, retry next, or skip it altogether,
+// and it sees the result or error that the method running the statement returns.
+//
+// Interceptors apply to the statements run by Query, Exec, QueryMulti, QueryInto,
+// QueryStream and the methods built on them, but not to the statements the connection
+// runs internally, like those that set up the session. next must be called at most
+// once at a time, from the goroutine the interceptor is called on.
+type QueryInterceptor func(ctx context.Context, query Query, next QueryFunc) (QueryResult, error)
+
+// Passes a statement through the interceptors of the connection, in the order they
+// are configured, before running it with run.
+func (c *Connection) intercept(ctx context.Context, sql string, args []interface{}, run QueryFunc) (QueryResult, error) {
+	next := run
+	for i := len(c.config.Interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := c.config.Interceptors[i], next
+		next = func(ctx context.Context, query Query) (QueryResult, error) {
+			return interceptor(ctx, query, inner)
+		}
+	}
+	return next(ctx, Query{SQL: sql, Args: args})
+}
@@ -0,0 +1,65 @@
+package vertigo
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestInterceptors(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT id FROM t /* app */").Columns(vertigotest.Column{Name: "id", Type: DataTypeInteger}).Row(1).Row(2)
+	server.Expect("DELETE FROM t /* app */").Tag("DELETE 0 5")
+
+	var calls []string
+	logging := func(ctx context.Context, query Query, next QueryFunc) (QueryResult, error) {
+		result, err := next(ctx, query)
+		calls = append(calls, query.SQL+" -> "+string(result.CommandTag))
+		return result, err
+	}
+	rewriting := func(ctx context.Context, query Query, next QueryFunc) (QueryResult, error) {
+		query.SQL += " /* app */"
+		return next(ctx, query)
+	}
+	cached := &Resultset{Result: "SELECT 0"}
+	caching := func(ctx context.Context, query Query, next QueryFunc) (QueryResult, error) {
+		if strings.HasPrefix(query.SQL, "SELECT 'cached'") {
+			return QueryResult{Resultset: cached}, nil
+		}
+		return next(ctx, query)
+	}
+
+	connection, err := Open(server.Addr(), WithInterceptors(logging, caching), WithInterceptors(rewriting))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	resultset, err := connection.Query("SELECT id FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resultset.RowCount() != 2 {
+		t.Fatalf("Expected 2 rows, but found %d", resultset.RowCount())
+	}
+	if _, err := connection.Exec("DELETE FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	if resultset, err := connection.Query("SELECT 'cached'"); err != nil || resultset != cached {
+		t.Fatalf("Expected the cached resultset, but found %v, %v", resultset, err)
+	}
+
+	expected := []string{"SELECT id FROM t -> SELECT 2", "DELETE FROM t -> DELETE 0 5", "SELECT 'cached' -> "}
+	if strings.Join(calls, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected calls %q, but found %q", expected, calls)
+	}
+	if statements := server.Statements(); len(statements) != 2 || statements[1] != "DELETE FROM t /* app */" {
+		t.Fatalf("Expected the rewritten statements to be sent, but found %q", statements)
+	}
+}
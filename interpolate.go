@@ -0,0 +1,198 @@
+package vertigo
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// The format of timestamps in query arguments.
+const timestampFormat = "2006-01-02 15:04:05.999999-07:00"
+
+// Replaces the ? placeholders in sql with the quoted literals of args, in order.
+// Placeholders inside string literals, quoted identifiers and comments are left alone.
+// Unless standardStrings is set, backslashes are escapes in all string literals, as
+// they are when the server has standard_conforming_strings turned off.
+//
+// When the only argument is a map[string]interface{}, the :name and @name
+// placeholders are replaced by the quoted literals of the values instead.
+func interpolate(sql string, args []interface{}, standardStrings bool) (string, error) {
+	if len(args) == 1 {
+		if named, ok := args[0].(map[string]interface{}); ok {
+			return interpolateNamed(sql, named, standardStrings)
+		}
+	}
+
+	var (
+		buffer bytes.Buffer
+		arg    int
+	)
+
+	for i := 0; i < len(sql); {
+		if end := skipNonCode(sql, i, standardStrings); end > i {
+			buffer.WriteString(sql[i:end])
+			i = end
+			continue
+		}
+
+		if sql[i] != '?' {
+			buffer.WriteByte(sql[i])
+			i++
+			continue
+		}
+
+		if arg >= len(args) {
+			return "", fmt.Errorf("Not enough arguments for the placeholders in the query, got %d", len(args))
+		}
+
+		literal, err := QuoteLiteral(args[arg])
+		if err != nil {
+			return "", err
+		}
+
+		buffer.WriteString(literal)
+		arg++
+		i++
+	}
+
+	if arg != len(args) {
+		return "", fmt.Errorf("Query has %d placeholders, but got %d arguments", arg, len(args))
+	}
+	return buffer.String(), nil
+}
+
+// Returns the offset just after the string literal, quoted identifier or comment
+// that starts at offset i, or i if there is none.
+func skipNonCode(sql string, i int, standardStrings bool) int {
+	switch {
+	case sql[i] == '\'':
+		return skipQuoted(sql, i, !standardStrings || i > 0 && (sql[i-1] == 'e' || sql[i-1] == 'E'))
+
+	case sql[i] == '"':
+		return skipQuoted(sql, i, false)
+
+	case strings.HasPrefix(sql[i:], "--"):
+		if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+			return i + end + 1
+		}
+		return len(sql)
+
+	case strings.HasPrefix(sql[i:], "/*"):
+		if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+			return i + end + 4
+		}
+		return len(sql)
+	}
+	return i
+}
+
+// Returns the offset just after the quoted string or identifier that starts at
+// offset start. A doubled quote character is part of the string, and so is any
+// character following a backslash if escapes are enabled.
+func skipQuoted(sql string, start int, escapes bool) int {
+	quote := sql[start]
+	for i := start + 1; i < len(sql); i++ {
+		switch {
+		case escapes && sql[i] == '\\':
+			i++
+		case sql[i] == quote && i+1 < len(sql) && sql[i+1] == quote:
+			i++
+		case sql[i] == quote:
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// Returns the SQL literal for value v, as it is used for query arguments. Strings
+// containing backslashes are written as E'...' escape strings, so they mean the same
+// whether or not the server has standard_conforming_strings turned on.
+//
+// Values of types that implement Encoder or driver.Valuer are quoted as the value
+// they return.
+//
+// Use it when composing dynamic SQL that can't use ? placeholders; values that
+// can be passed as query arguments should be.
+func QuoteLiteral(v interface{}) (string, error) {
+	v, err := resolveEncoder(v)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return quoteString(v), nil
+	case []byte:
+		return quoteBinary(v), nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int, int8, int16, int32, int64:
+		return quoteNumber(fmt.Sprintf("%d", v)), nil
+	case uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), nil
+	case float32:
+		return quoteFloat(float64(v), 32), nil
+	case float64:
+		return quoteFloat(v, 64), nil
+	case Decimal:
+		return quoteNumber(v.String()), nil
+	case *big.Int:
+		return quoteNumber(v.String()), nil
+	case time.Time:
+		return quoteString(v.Format(timestampFormat)), nil
+	}
+
+	if uuid, ok := uuidString(v); ok {
+		return quoteString(uuid), nil
+	}
+	if literal, ok, err := encodeWithCodec(v); ok || err != nil {
+		return literal, err
+	}
+	if literal, ok, err := quoteArray(v); ok || err != nil {
+		return literal, err
+	}
+	return "", fmt.Errorf("Cannot use value of type %T as a query argument", v)
+}
+
+// Returns a quoted identifier, like the name of a table or column. Any double
+// quotes are doubled. Unlike in PostgreSQL, quoted identifiers are case insensitive in
+// Vertica, so quoting only preserves special characters and reserved words.
+func QuoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// Returns a string literal for s. Any quote characters are doubled, and strings
+// with backslashes become escape strings with the backslashes doubled.
+func quoteString(s string) string {
+	s = strings.Replace(s, "'", "''", -1)
+	if strings.Contains(s, `\`) {
+		return "E'" + strings.Replace(s, `\`, `\\`, -1) + "'"
+	}
+	return "'" + s + "'"
+}
+
+// Returns a literal for a float, using Vertica's string representation
+// for the special values.
+func quoteFloat(f float64, bitSize int) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return quoteString(formatFloat(f, bitSize)) + "::FLOAT"
+	}
+	return quoteNumber(formatFloat(f, bitSize))
+}
+
+// Wraps negative numbers in parentheses, so a minus sign in front of the
+// placeholder won't turn the literal into a comment.
+func quoteNumber(n string) string {
+	if strings.HasPrefix(n, "-") {
+		return "(" + n + ")"
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+package vertigo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		sql      string
+		args     []interface{}
+		expected string
+	}{
+		{"SELECT ?", []interface{}{1}, "SELECT 1"},
+		{"SELECT 1 - ?", []interface{}{-1}, "SELECT 1 - (-1)"},
+		{"SELECT ?, ?", []interface{}{"it's", nil}, "SELECT 'it''s', NULL"},
+		{"SELECT ?::float", []interface{}{1.5}, "SELECT 1.5::float"},
+		{"SELECT ?", []interface{}{true}, "SELECT TRUE"},
+		{"SELECT '?', \"?\", ?", []interface{}{"a"}, "SELECT '?', \"?\", 'a'"},
+		{"SELECT 'it''s ?', ?", []interface{}{"a"}, "SELECT 'it''s ?', 'a'"},
+		{"SELECT E'\\' ?', ?", []interface{}{"a"}, "SELECT E'\\' ?', 'a'"},
+		{"SELECT ? -- ?\n, ?", []interface{}{1, 2}, "SELECT 1 -- ?\n, 2"},
+		{"SELECT /* ? */ ?", []interface{}{1}, "SELECT /* ? */ 1"},
+		{"SELECT ?", []interface{}{time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)}, "SELECT '2015-01-02 03:04:05+00:00'"},
+	}
+
+	for _, test := range tests {
+		if sql, err := interpolate(test.sql, test.args, true); err != nil {
+			t.Errorf("Unexpected error for %q: %s", test.sql, err)
+		} else if sql != test.expected {
+			t.Errorf("Expected %q to become %q, but found %q", test.sql, test.expected, sql)
+		}
+	}
+}
+
+func TestInterpolateArgumentMismatch(t *testing.T) {
+	if _, err := interpolate("SELECT ?, ?", []interface{}{1}, true); err == nil {
+		t.Error("Expected an error for too few arguments")
+	}
+
+	if _, err := interpolate("SELECT ?", []interface{}{1, 2}, true); err == nil {
+		t.Error("Expected an error for too many arguments")
+	}
+
+	if _, err := interpolate("SELECT ?", []interface{}{struct{}{}}, true); err == nil {
+		t.Error("Expected an error for an unsupported argument type")
+	}
+}
+
+func TestInterpolateWithoutStandardStrings(t *testing.T) {
+	sql, err := interpolate(`SELECT 'a\' ?', ?`, []interface{}{`b\`}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `SELECT 'a\' ?', E'b\\'`; sql != expected {
+		t.Fatalf("Expected %q, but found %q", expected, sql)
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{"it's", `'it''s'`},
+		{`C:\temp`, `E'C:\\temp'`},
+		{`it's \n`, `E'it''s \\n'`},
+		{-1, "(-1)"},
+		{nil, "NULL"},
+	}
+
+	for _, test := range tests {
+		if literal, err := QuoteLiteral(test.value); err != nil || literal != test.expected {
+			t.Errorf("Expected %v to be quoted as %s, but found %s, %v", test.value, test.expected, literal, err)
+		}
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	if identifier := QuoteIdentifier(`my "table"`); identifier != `"my ""table"""` {
+		t.Fatalf(`Expected "my ""table""", but found %s`, identifier)
+	}
+}
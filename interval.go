@@ -0,0 +1,208 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Masks for the range of an interval type, stored in the upper 16 bits of the type modifier.
+const (
+	intervalMonth  = 1 << 1
+	intervalYear   = 1 << 2
+	intervalDay    = 1 << 3
+	intervalHour   = 1 << 10
+	intervalMinute = 1 << 11
+	intervalSecond = 1 << 12
+)
+
+var ErrIntervalNotExact = errors.New("Interval with months can't be converted into a time.Duration exactly")
+
+// Interval is the decoded value of an INTERVAL column. Year-month intervals only
+// use Months, day-time intervals use Days and Microseconds.
+type Interval struct {
+	Months       int64
+	Days         int64
+	Microseconds int64
+}
+
+// Converts the interval into a time.Duration, counting a day as 24 hours. This
+// fails if the interval has months, as their length isn't fixed, or if the
+// interval doesn't fit into a time.Duration.
+func (i Interval) Duration() (time.Duration, error) {
+	if i.Months != 0 {
+		return 0, ErrIntervalNotExact
+	}
+
+	const microsecondsPerDay = 24 * 60 * 60 * 1000 * 1000
+	if i.Days > math.MaxInt64/microsecondsPerDay || i.Days < math.MinInt64/microsecondsPerDay {
+		return 0, fmt.Errorf("Interval %s overflows a time.Duration", i)
+	}
+
+	micros := i.Days*microsecondsPerDay + i.Microseconds
+	if micros > math.MaxInt64/1000 || micros < math.MinInt64/1000 {
+		return 0, fmt.Errorf("Interval %s overflows a time.Duration", i)
+	}
+	return time.Duration(micros) * time.Microsecond, nil
+}
+
+// Returns the interval in ISO 8601 duration format, e.g. P1Y2M3DT4H5M6.5S.
+func (i Interval) String() string {
+	var buffer strings.Builder
+	buffer.WriteByte('P')
+	if years := i.Months / 12; years != 0 {
+		fmt.Fprintf(&buffer, "%dY", years)
+	}
+	if months := i.Months % 12; months != 0 {
+		fmt.Fprintf(&buffer, "%dM", months)
+	}
+	if i.Days != 0 {
+		fmt.Fprintf(&buffer, "%dD", i.Days)
+	}
+
+	if i.Microseconds != 0 {
+		buffer.WriteByte('T')
+		micros := i.Microseconds
+		if hours := micros / 3600000000; hours != 0 {
+			fmt.Fprintf(&buffer, "%dH", hours)
+		}
+		if minutes := micros / 60000000 % 60; minutes != 0 {
+			fmt.Fprintf(&buffer, "%dM", minutes)
+		}
+		if seconds := micros % 60000000; seconds != 0 {
+			buffer.WriteString(strconv.FormatFloat(float64(seconds)/1e6, 'f', -1, 64))
+			buffer.WriteByte('S')
+		}
+	}
+
+	if buffer.Len() == 1 {
+		return "PT0S"
+	}
+	return buffer.String()
+}
+
+func (i *Interval) scanText(field *Field, src []byte) error {
+	if src == nil {
+		return fmt.Errorf("%w, cannot convert it into vertigo.Interval; use a pointer or Nullable instead", ErrUnexpectedNull)
+	}
+
+	var (
+		oid      uint32 = DataTypeInterval
+		modifier uint32
+	)
+	if field != nil {
+		oid, modifier = field.DataTypeOID, field.TypeModifier
+	}
+
+	interval, err := parseInterval(oid, modifier, string(src))
+	if err != nil {
+		return err
+	}
+	*i = interval
+	return nil
+}
+
+// Parses an INTERVAL value in text format. Year-month intervals look like "1-2",
+// day-time intervals like "3 04:05:06.5". Values with a single number, like "14",
+// are interpreted in the largest unit of the range of the interval type.
+func parseInterval(oid uint32, modifier uint32, src string) (Interval, error) {
+	s := strings.TrimSpace(src)
+	negative := strings.HasPrefix(s, "-")
+	if negative {
+		s = s[1:]
+	}
+
+	interval, err := parseUnsignedInterval(oid, modifier>>16, s)
+	if err != nil {
+		return Interval{}, fmt.Errorf("Invalid interval %q", src)
+	}
+
+	if negative {
+		interval = Interval{Months: -interval.Months, Days: -interval.Days, Microseconds: -interval.Microseconds}
+	}
+	return interval, nil
+}
+
+func parseUnsignedInterval(oid uint32, intervalRange uint32, s string) (Interval, error) {
+	if oid == DataTypeIntervalYM {
+		if dash := strings.IndexByte(s, '-'); dash >= 0 {
+			years, err := strconv.ParseInt(s[:dash], 10, 64)
+			if err != nil {
+				return Interval{}, err
+			}
+			months, err := strconv.ParseInt(s[dash+1:], 10, 64)
+			return Interval{Months: years*12 + months}, err
+		}
+
+		n, err := strconv.ParseInt(s, 10, 64)
+		if intervalRange&intervalYear == 0 && intervalRange&intervalMonth != 0 {
+			return Interval{Months: n}, err
+		}
+		return Interval{Months: n * 12}, err
+	}
+
+	var interval Interval
+	if space := strings.IndexByte(s, ' '); space >= 0 {
+		days, err := strconv.ParseInt(s[:space], 10, 64)
+		if err != nil {
+			return Interval{}, err
+		}
+		interval.Days, s = days, s[space+1:]
+	} else if !strings.ContainsAny(s, ":.") {
+		n, err := strconv.ParseInt(s, 10, 64)
+		switch {
+		case intervalRange == 0 || intervalRange&intervalDay != 0:
+			return Interval{Days: n}, err
+		case intervalRange&intervalHour != 0:
+			return Interval{Microseconds: n * 3600000000}, err
+		case intervalRange&intervalMinute != 0:
+			return Interval{Microseconds: n * 60000000}, err
+		default:
+			return Interval{Microseconds: n * 1000000}, err
+		}
+	}
+
+	// The clock part has up to three components, of which the last one can have a fraction.
+	units := []int64{3600000000, 60000000, 1000000}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return Interval{}, fmt.Errorf("Too many components in %q", s)
+	}
+	if len(parts) == 2 && intervalRange&(intervalDay|intervalHour) == 0 && intervalRange&intervalMinute != 0 {
+		units = units[1:]
+	}
+	if len(parts) == 1 {
+		units = units[2:]
+	}
+
+	for i, part := range parts {
+		whole, fraction := part, ""
+		if dot := strings.IndexByte(part, '.'); dot >= 0 {
+			if i < len(parts)-1 || units[i] != 1000000 {
+				return Interval{}, fmt.Errorf("Unexpected fraction in %q", s)
+			}
+			whole, fraction = part[:dot], part[dot+1:]
+		}
+
+		n, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil {
+			return Interval{}, err
+		}
+		interval.Microseconds += n * units[i]
+
+		if fraction != "" {
+			if len(fraction) > 6 {
+				fraction = fraction[:6]
+			}
+			micros, err := strconv.ParseInt(fraction+strings.Repeat("0", 6-len(fraction)), 10, 64)
+			if err != nil {
+				return Interval{}, err
+			}
+			interval.Microseconds += micros
+		}
+	}
+	return interval, nil
+}
@@ -0,0 +1,80 @@
+package vertigo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		oid      uint32
+		modifier uint32
+		src      string
+		expected Interval
+	}{
+		{DataTypeInterval, 0, "1 02:03:04.5", Interval{Days: 1, Microseconds: 7384500000}},
+		{DataTypeInterval, 0, "-1 02:03", Interval{Days: -1, Microseconds: -7380000000}},
+		{DataTypeInterval, 0, "02:03:04.000001", Interval{Microseconds: 7384000001}},
+		{DataTypeInterval, 0, "3", Interval{Days: 3}},
+		{DataTypeInterval, intervalHour << 16, "25", Interval{Microseconds: 25 * 3600000000}},
+		{DataTypeInterval, (intervalMinute | intervalSecond) << 16, "2:03.5", Interval{Microseconds: 123500000}},
+		{DataTypeIntervalYM, 0, "1-2", Interval{Months: 14}},
+		{DataTypeIntervalYM, 0, "-1-2", Interval{Months: -14}},
+		{DataTypeIntervalYM, intervalMonth << 16, "14", Interval{Months: 14}},
+		{DataTypeIntervalYM, intervalYear << 16, "2", Interval{Months: 24}},
+	}
+
+	for _, test := range tests {
+		interval, err := parseInterval(test.oid, test.modifier, test.src)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %s", test.src, err)
+		} else if interval != test.expected {
+			t.Errorf("Expected %q to be parsed as %#+v, but found %#+v", test.src, test.expected, interval)
+		}
+	}
+
+	if _, err := parseInterval(DataTypeInterval, 0, "1 02:0x"); err == nil {
+		t.Error("Expected an error for an invalid interval")
+	}
+}
+
+func TestIntervalDuration(t *testing.T) {
+	if d, err := (Interval{Days: 1, Microseconds: 1500000}).Duration(); err != nil || d != 24*time.Hour+1500*time.Millisecond {
+		t.Errorf("Unexpected duration %s (%v)", d, err)
+	}
+
+	if _, err := (Interval{Months: 1}).Duration(); err != ErrIntervalNotExact {
+		t.Errorf("Expected ErrIntervalNotExact, but found %#+v", err)
+	}
+
+	if _, err := (Interval{Days: 1 << 40}).Duration(); err == nil {
+		t.Error("Expected an error for an interval that overflows a duration")
+	}
+}
+
+func TestIntervalString(t *testing.T) {
+	if s := (Interval{Months: 14, Days: 3, Microseconds: 3723500000}).String(); s != "P1Y2M3DT1H2M3.5S" {
+		t.Errorf("Unexpected string %q", s)
+	}
+
+	if s := (Interval{}).String(); s != "PT0S" {
+		t.Errorf("Unexpected string %q", s)
+	}
+}
+
+func TestRowScanInterval(t *testing.T) {
+	fields := []Field{{Name: "i", DataTypeOID: DataTypeInterval}, {Name: "d", DataTypeOID: DataTypeInterval}}
+	row := Row{Values: [][]byte{[]byte("1 00:00"), []byte("00:01:30")}, fields: fields}
+
+	var (
+		interval Interval
+		duration time.Duration
+	)
+	if err := row.Scan(&interval, &duration); err != nil {
+		t.Fatal(err)
+	}
+
+	if interval.Days != 1 || duration != 90*time.Second {
+		t.Fatalf("Unexpected values %#+v and %s", interval, duration)
+	}
+}
@@ -0,0 +1,137 @@
+package vertigo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Encodes the resultset as a JSON array, with an object for every row. The
+// values are encoded using their Go type as returned by Row.Map, and NULLs
+// are encoded as null.
+func (rs *Resultset) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := rs.WriteJSON(&buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Writes the resultset to w as a JSON array. See MarshalJSON.
+func (rs *Resultset) WriteJSON(w io.Writer) error {
+	writer := newJSONWriter(w)
+	writer.handleFields(rs.Fields)
+	err := rs.EachRow(func(row Row) error {
+		writer.handleRow(row.Values)
+		return writer.err
+	})
+	if err != nil {
+		return err
+	}
+	return writer.close()
+}
+
+// Runs a SQL query, and writes its rows to w as a JSON array while they are
+// received from the server, without buffering a Resultset. See MarshalJSON for
+// how rows are encoded.
+func (c *Connection) QueryJSON(w io.Writer, sql string, args ...interface{}) error {
+	writer := newJSONWriter(w)
+	if err := c.run(sql, args, writer); err != nil {
+		return err
+	}
+	return writer.close()
+}
+
+// A resultHandler that writes rows as a JSON array.
+type jsonWriter struct {
+	w      *bufio.Writer
+	fields []Field
+	keys   [][]byte
+	rows   int
+	err    error
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{w: bufio.NewWriter(w)}
+}
+
+func (jw *jsonWriter) handleFields(fields []Field) {
+	jw.fields = fields
+	jw.keys = make([][]byte, len(fields))
+	for i, field := range fields {
+		jw.keys[i], _ = json.Marshal(field.Name)
+	}
+}
+
+func (jw *jsonWriter) handleRow(values [][]byte) {
+	if jw.err != nil {
+		return
+	}
+
+	if jw.rows == 0 {
+		jw.w.WriteByte('[')
+	} else {
+		jw.w.WriteByte(',')
+	}
+	jw.rows++
+
+	jw.w.WriteByte('{')
+	for i, field := range jw.fields {
+		if i > 0 {
+			jw.w.WriteByte(',')
+		}
+		jw.w.Write(jw.keys[i])
+		jw.w.WriteByte(':')
+
+		value, err := decodeValue(field, values[i])
+		if err != nil {
+			jw.err = fmt.Errorf("Cannot decode column %q: %s", field.Name, err)
+			return
+		}
+
+		encoded, err := marshalJSONValue(value)
+		if err != nil {
+			jw.err = err
+			return
+		}
+		jw.w.Write(encoded)
+	}
+
+	if err := jw.w.WriteByte('}'); err != nil {
+		jw.err = err
+	}
+}
+
+func (jw *jsonWriter) handleComplete(result string) {}
+
+// Terminates the JSON array, and flushes the output.
+func (jw *jsonWriter) close() error {
+	if jw.err != nil {
+		return jw.err
+	}
+
+	if jw.rows == 0 {
+		jw.w.WriteByte('[')
+	}
+	jw.w.WriteByte(']')
+	return jw.w.Flush()
+}
+
+// Encodes a decoded value as JSON. Floats that can't be represented in JSON
+// are encoded as the strings "NaN", "Infinity" and "-Infinity".
+func marshalJSONValue(value interface{}) ([]byte, error) {
+	if f, ok := value.(float64); ok {
+		switch {
+		case math.IsNaN(f):
+			return []byte(`"NaN"`), nil
+		case math.IsInf(f, 1):
+			return []byte(`"Infinity"`), nil
+		case math.IsInf(f, -1):
+			return []byte(`"-Infinity"`), nil
+		}
+	}
+	return json.Marshal(value)
+}
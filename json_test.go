@@ -0,0 +1,37 @@
+package vertigo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestResultsetMarshalJSON(t *testing.T) {
+	resultset := &Resultset{
+		Fields: []Field{{Name: "id", DataTypeOID: DataTypeInteger}, {Name: "name", DataTypeOID: DataTypeVarchar}, {Name: "score", DataTypeOID: DataTypeFloat}},
+		Rows: []Row{
+			{Values: [][]byte{[]byte("1"), []byte(`"foo"`), []byte("1.5")}},
+			{Values: [][]byte{[]byte("2"), nil, []byte("NaN")}},
+		},
+	}
+
+	data, err := json.Marshal(resultset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[{"id":1,"name":"\"foo\"","score":1.5},{"id":2,"name":null,"score":"NaN"}]`
+	if string(data) != expected {
+		t.Fatalf("Expected %s, but found %s", expected, data)
+	}
+}
+
+func TestEmptyResultsetMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(&Resultset{Fields: []Field{{Name: "id"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "[]" {
+		t.Fatalf("Expected an empty array, but found %s", data)
+	}
+}
@@ -0,0 +1,49 @@
+package vertigo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The statements the LABEL hint is accepted by, which it goes right after.
+var labelledStatement = regexp.MustCompile(`(?i)^\s*(SELECT|INSERT|UPDATE|DELETE|MERGE|COPY|EXPORT)\b`)
+
+type queryLabelKey struct{}
+
+// Returns a context that labels the statements run with it, like with QueryContext,
+// with the label instead of the Label of the connection. See ConnectionInfo.Label.
+func WithQueryLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, queryLabelKey{}, label)
+}
+
+// Adds the label of the statement or the connection to a statement, if any.
+func (c *Connection) labelQuery(ctx context.Context, sql string) (string, error) {
+	label, _ := ctx.Value(queryLabelKey{}).(string)
+	if label == "" {
+		label = c.config.Label
+	}
+	if label == "" {
+		return sql, nil
+	}
+	return addLabelHint(sql, label)
+}
+
+// Adds a /*+LABEL(...)*/ hint with the label after the first keyword of a statement.
+// Statements that already have a label, and statements that don't accept the hint,
+// like DDL statements, are left alone.
+func addLabelHint(sql, label string) (string, error) {
+	if strings.ContainsAny(label, "'\x00") || strings.Contains(label, "*/") {
+		return "", fmt.Errorf("Invalid query label %q", label)
+	}
+	if labelHint.MatchString(sql) {
+		return sql, nil
+	}
+
+	keyword := labelledStatement.FindStringIndex(sql)
+	if keyword == nil {
+		return sql, nil
+	}
+	return sql[:keyword[1]] + " /*+LABEL('" + label + "')*/" + sql[keyword[1]:], nil
+}
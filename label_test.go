@@ -0,0 +1,61 @@
+package vertigo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestAddLabelHint(t *testing.T) {
+	tests := []struct {
+		sql, expected string
+	}{
+		{"SELECT * FROM t", "SELECT /*+LABEL('etl-3')*/ * FROM t"},
+		{"\n insert INTO t VALUES (1)", "\n insert /*+LABEL('etl-3')*/ INTO t VALUES (1)"},
+		{"SELECT /*+LABEL(mine)*/ * FROM t", "SELECT /*+LABEL(mine)*/ * FROM t"},
+		{"CREATE TABLE t (a INT)", "CREATE TABLE t (a INT)"},
+		{"SELECTED", "SELECTED"},
+	}
+	for _, test := range tests {
+		sql, err := addLabelHint(test.sql, "etl-3")
+		if err != nil || sql != test.expected {
+			t.Errorf("Expected %q, but found %q, %v", test.expected, sql, err)
+		}
+	}
+
+	if _, err := addLabelHint("SELECT 1", "x')*/ DROP"); err == nil {
+		t.Errorf("Expected a label with a quote to be rejected")
+	}
+}
+
+func TestLabel(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.ExpectMatch("^SELECT")
+
+	connection, err := Open(server.Addr(), WithLabel("nightly-etl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if _, err := connection.Query("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connection.QueryContext(WithQueryLabel(context.Background(), "step-3"), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if startups := server.Startups(); len(startups) != 1 || startups[0]["client_label"] != "nightly-etl" {
+		t.Fatalf("Expected the label to be sent as the client label, but found %v", startups)
+	}
+	statements := server.Statements()
+	expected := []string{"SELECT /*+LABEL('nightly-etl')*/ 1", "SELECT /*+LABEL('step-3')*/ 1"}
+	if len(statements) != 2 || statements[0] != expected[0] || statements[1] != expected[1] {
+		t.Fatalf("Expected statements %q, but found %q", expected, statements)
+	}
+}
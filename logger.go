@@ -0,0 +1,64 @@
+package vertigo
+
+import (
+	"context"
+	"log/slog"
+)
+
+// The severity of a log event.
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota // Protocol traffic
+	LogLevelInfo                  // Connections and statements
+	LogLevelWarn                  // Connection failures
+	LogLevelError                 // Failed statements
+)
+
+// Logger receives the log events of a connection. The fields of an event are given
+// as alternating keys and values, like with slog. Events use these keys:
+//
+//	query     The SQL of a statement.
+//	duration  How long a statement took, as a time.Duration.
+//	rows      The number of rows a statement returned.
+//	error     The error a statement or connection failed with.
+//	type      The type of a protocol message, as a string like "Q".
+//	bytes     The size of a protocol message.
+//	address   The address of the server.
+//	pid       The PID of the server's backend process.
+//
+// A Logger should be safe for concurrent use if it is shared by connections.
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// Returns a Logger that writes events to an slog.Logger.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+func (l slogLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	var slogLevel slog.Level
+	switch level {
+	case LogLevelDebug:
+		slogLevel = slog.LevelDebug
+	case LogLevelInfo:
+		slogLevel = slog.LevelInfo
+	case LogLevelWarn:
+		slogLevel = slog.LevelWarn
+	default:
+		slogLevel = slog.LevelError
+	}
+	l.logger.Log(context.Background(), slogLevel, msg, keyvals...)
+}
+
+// Logs an event to the logger of the connection, if it has one.
+func (c *Connection) log(level LogLevel, msg string, keyvals ...interface{}) {
+	if c.config.Logger != nil {
+		c.config.Logger.Log(level, msg, keyvals...)
+	}
+}
@@ -0,0 +1,59 @@
+package vertigo
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+type recordingLogger struct {
+	levels   []LogLevel
+	messages []string
+	keyvals  [][]interface{}
+}
+
+func (l *recordingLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	l.levels = append(l.levels, level)
+	l.messages = append(l.messages, msg)
+	l.keyvals = append(l.keyvals, keyvals)
+}
+
+func TestConnectionLog(t *testing.T) {
+	logger := &recordingLogger{}
+	c := &Connection{config: &ConnectionInfo{}}
+	c.log(LogLevelInfo, "Ignored without a logger")
+
+	c.config.Logger = logger
+	c.log(LogLevelWarn, "Connection reset", "address", "localhost:5433")
+
+	if len(logger.messages) != 1 || logger.levels[0] != LogLevelWarn || logger.keyvals[0][1] != "localhost:5433" {
+		t.Fatalf("Unexpected log events %+v", logger)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	logger.Log(LogLevelDebug, "Sent message", "type", "Q", "bytes", 20)
+	logger.Log(LogLevelError, "Query failed", "query", "SELECT 1", "rows", 0)
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &event); err != nil {
+		t.Fatalf("Expected a single JSON event, but found %q", buffer.String())
+	}
+
+	if event["level"] != "ERROR" || event["msg"] != "Query failed" || event["query"] != "SELECT 1" {
+		t.Fatalf("Unexpected event %v", event)
+	}
+}
+
+func TestMessageTypeName(t *testing.T) {
+	if name := messageTypeName('Q'); name != "Q" {
+		t.Errorf("Expected Q, but found %q", name)
+	}
+	if name := messageTypeName(0); name != "startup" {
+		t.Errorf("Expected startup, but found %q", name)
+	}
+}
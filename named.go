@@ -0,0 +1,107 @@
+package vertigo
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Replaces the :name and @name placeholders in sql with the quoted literals of the
+// values in args. See interpolate.
+func interpolateNamed(sql string, args map[string]interface{}, standardStrings bool) (string, error) {
+	var buffer bytes.Buffer
+	err := scanNamed(sql, args, standardStrings, func(text, name string) error {
+		if name == "" {
+			buffer.WriteString(text)
+			return nil
+		}
+
+		literal, err := QuoteLiteral(args[name])
+		if err != nil {
+			return fmt.Errorf("Cannot use named parameter %s: %w", name, err)
+		}
+		buffer.WriteString(literal)
+		return nil
+	})
+	return buffer.String(), err
+}
+
+// Rewrites the :name and @name placeholders in sql to ? placeholders, and returns
+// the values of args in the order of the placeholders. A name that is used more than
+// once gets a placeholder and value each time.
+//
+// This makes named parameters usable with Connection.Prepare and Stmt.ExecutePortal.
+// Query, QueryRow and Exec take a map[string]interface{} as their only argument
+// for named parameters directly.
+func BindNamed(sql string, args map[string]interface{}) (string, []interface{}, error) {
+	var (
+		buffer bytes.Buffer
+		values []interface{}
+	)
+	err := scanNamed(sql, args, true, func(text, name string) error {
+		if name == "" {
+			buffer.WriteString(text)
+			return nil
+		}
+
+		buffer.WriteByte('?')
+		values = append(values, args[name])
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return buffer.String(), values, nil
+}
+
+// Splits sql into text and named placeholders, and passes them to emit in order.
+// For text, the name is empty. Placeholders inside string literals, quoted
+// identifiers and comments are text, and so are :: casts. Every placeholder must
+// have a value in args.
+func scanNamed(sql string, args map[string]interface{}, standardStrings bool, emit func(text, name string) error) error {
+	for i := 0; i < len(sql); {
+		if end := skipNonCode(sql, i, standardStrings); end > i {
+			if err := emit(sql[i:end], ""); err != nil {
+				return err
+			}
+			i = end
+			continue
+		}
+
+		end := i + 1
+		if (sql[i] == ':' || sql[i] == '@') && (i == 0 || sql[i-1] != ':') {
+			for end < len(sql) && isNameChar(sql[end], end == i+1) {
+				end++
+			}
+		}
+
+		if end == i+1 {
+			if err := emit(sql[i:end], ""); err != nil {
+				return err
+			}
+			i = end
+			continue
+		}
+
+		name := sql[i+1 : end]
+		if _, ok := args[name]; !ok {
+			return fmt.Errorf("Missing value for named parameter %s", sql[i:end])
+		}
+		if err := emit(sql[i:end], name); err != nil {
+			return err
+		}
+		i = end
+	}
+	return nil
+}
+
+// Returns whether ch can be part of a parameter name. Names start with a letter or
+// an underscore, and continue with letters, digits and underscores.
+func isNameChar(ch byte, first bool) bool {
+	switch {
+	case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch == '_':
+		return true
+	case ch >= '0' && ch <= '9':
+		return !first
+	}
+	return false
+}
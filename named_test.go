@@ -0,0 +1,44 @@
+package vertigo
+
+import (
+	"testing"
+)
+
+func TestInterpolateNamed(t *testing.T) {
+	args := map[string]interface{}{"id": 1, "name": "it's", "from_date": "2015-01-02"}
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{"SELECT :id, @name", "SELECT 1, 'it''s'"},
+		{"SELECT :id + :id", "SELECT 1 + 1"},
+		{"SELECT :from_date::date", "SELECT '2015-01-02'::date"},
+		{"SELECT ':id', \":id\", @id -- :name", "SELECT ':id', \":id\", 1 -- :name"},
+		{"SELECT 1::int, ?", "SELECT 1::int, ?"},
+	}
+
+	for _, test := range tests {
+		if sql, err := interpolate(test.sql, []interface{}{args}, true); err != nil {
+			t.Errorf("Unexpected error for %q: %s", test.sql, err)
+		} else if sql != test.expected {
+			t.Errorf("Expected %q to become %q, but found %q", test.sql, test.expected, sql)
+		}
+	}
+
+	if _, err := interpolate("SELECT :missing", []interface{}{args}, true); err == nil {
+		t.Error("Expected an error for a missing named parameter")
+	}
+}
+
+func TestBindNamed(t *testing.T) {
+	sql, values, err := BindNamed("SELECT * FROM t WHERE a = :a AND b IN (@b, :a)", map[string]interface{}{"a": 1, "b": "x", "c": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM t WHERE a = ? AND b IN (?, ?)"; sql != expected {
+		t.Fatalf("Expected %q, but found %q", expected, sql)
+	}
+	if len(values) != 3 || values[0] != 1 || values[1] != "x" || values[2] != 1 {
+		t.Fatalf("Expected values [1 x 1], but found %v", values)
+	}
+}
@@ -0,0 +1,84 @@
+package vertigo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestNativeWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := NewNativeWriter(&buffer, []NativeColumn{
+		{Name: "id", Type: DataTypeInteger},
+		{Name: "small", Type: DataTypeInteger, Width: 2},
+		{Name: "name", Type: DataTypeVarchar},
+		{Name: "code", Type: DataTypeChar, Width: 3},
+		{Name: "day", Type: DataTypeDate},
+		{Name: "at", Type: DataTypeTimestampTZ},
+		{Name: "ok", Type: DataTypeBoolean},
+		{Name: "score", Type: DataTypeFloat},
+		{Name: "note", Type: DataTypeVarchar},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := "4e41544956450aff0d0a00" + "29000000" + "0100" + "00" + "0900" +
+		"08000000" + "02000000" + "ffffffff" + "03000000" + "08000000" + "08000000" + "01000000" + "08000000" + "ffffffff"
+	if got := hex.EncodeToString(buffer.Bytes()); got != header {
+		t.Fatalf("Expected header %s, but found %s", header, got)
+	}
+
+	buffer.Reset()
+	at := time.Date(2000, 1, 2, 1, 0, 0, 0, time.FixedZone("CET", 3600))
+	if err := writer.WriteRow(int64(-1), 2, "ab", "x", at, at, true, 1.5, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	row := "2c000000" + "0080" + // The length of the data, and the NULL bits: the ninth column is NULL
+		"ffffffffffffffff" + "0200" + "02000000" + "6162" + "782020" +
+		"0100000000000000" + // 2000-01-02 is day 1
+		"0060d71d14000000" + // 2000-01-02 01:00 CET is 2000-01-02 00:00 UTC, a day of microseconds
+		"01" + "000000000000f83f"
+	if got := hex.EncodeToString(buffer.Bytes()); got != row {
+		t.Fatalf("Expected row %s, but found %s", row, got)
+	}
+
+	if err := writer.WriteRow(1); err == nil {
+		t.Fatalf("Expected a row with missing values to fail")
+	}
+	if err := writer.WriteRow(1, 1<<20, nil, nil, nil, nil, nil, nil, nil); err == nil {
+		t.Fatalf("Expected a value that doesn't fit the width to fail")
+	}
+	if _, err := NewNativeWriter(&buffer, []NativeColumn{{Name: "c", Type: DataTypeChar}}); err == nil {
+		t.Fatalf("Expected a CHAR column without a width to fail")
+	}
+}
+
+type nativeRow struct {
+	ID      int64 `db:"id"`
+	Name    *string
+	Created time.Time
+}
+
+func TestCopyLoaderNative(t *testing.T) {
+	server, connection := newCopyServer(t, 0)
+	server.Expect(`COPY "t" ("id", "Name", "Created") FROM STDIN NATIVE`).CopyIn()
+
+	created := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := (CopyLoader{Table: "t", Native: true}).Load(connection, []nativeRow{{ID: 1, Created: created}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var expected bytes.Buffer
+	writer, _ := NewNativeWriter(&expected, []NativeColumn{{Name: "id", Type: DataTypeInteger}, {Name: "Name", Type: DataTypeVarchar}, {Name: "Created", Type: DataTypeTimestampTZ}})
+	writer.WriteRow(1, nil, created)
+	if copies := server.CopyData(); len(copies) != 1 || !bytes.Equal(copies[0], expected.Bytes()) {
+		t.Fatalf("Expected the rows in the native binary format, but found %x", copies)
+	}
+
+	if _, err := (CopyLoader{Table: "t", Native: true}).Load(connection, []struct{ C complex128 }{{1}}); err == nil {
+		t.Fatalf("Expected a field without a native data type to fail")
+	}
+}
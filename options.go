@@ -0,0 +1,192 @@
+package vertigo
+
+import (
+	"context"
+	"crypto/tls"
+	"net/url"
+	"time"
+)
+
+// Configures a connection opened with Open. Any function that modifies the
+// ConnectionInfo can be used as an option, for settings without a With function.
+type Option func(config *ConnectionInfo)
+
+// Opens a connection to the server at address, configured by the options:
+//
+//	connection, err := vertigo.Open("vertica:5433",
+//		vertigo.WithUser("dbadmin"),
+//		vertigo.WithDatabase("analytics"),
+//		vertigo.WithTimeout(5*time.Second))
+func Open(address string, options ...Option) (*Connection, error) {
+	connection, err := Connect(newConnectionInfo(address, options))
+	if err != nil {
+		return nil, err
+	}
+	connection.l.Lock()
+	connection.startHeartbeat()
+	connection.l.Unlock()
+	return &connection, nil
+}
+
+func newConnectionInfo(address string, options []Option) *ConnectionInfo {
+	config := &ConnectionInfo{Address: address}
+	for _, option := range options {
+		option(config)
+	}
+	return config
+}
+
+// Sets the user to connect with.
+func WithUser(user string) Option {
+	return func(config *ConnectionInfo) { config.User = user }
+}
+
+// Sets the password of the user.
+func WithPassword(password string) Option {
+	return func(config *ConnectionInfo) { config.Password = password }
+}
+
+// Fetches the password from the provider every time the connection is opened.
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return func(config *ConnectionInfo) { config.CredentialProvider = provider }
+}
+
+// Sets the database to connect to.
+func WithDatabase(database string) Option {
+	return func(config *ConnectionInfo) { config.Database = database }
+}
+
+// Connects using SSL with the TLS configuration.
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(config *ConnectionInfo) { config.SslConfig = tlsConfig }
+}
+
+// Sets the maximum time to wait for the connection to be established and authenticated.
+func WithTimeout(timeout time.Duration) Option {
+	return func(config *ConnectionInfo) { config.ConnectTimeout = timeout }
+}
+
+// Opens the network connections with dial. See ConnectionInfo.Dial.
+func WithDial(dial DialFunc) Option {
+	return func(config *ConnectionInfo) { config.Dial = dial }
+}
+
+// Opens the network connections through the proxy the function returns, like
+// ProxyFromEnvironment. See ConnectionInfo.Proxy.
+func WithProxy(proxy func(address string) (*url.URL, error)) Option {
+	return func(config *ConnectionInfo) { config.Proxy = proxy }
+}
+
+// Retries connecting according to the policy. See ConnectionInfo.ConnectRetry.
+func WithConnectRetry(policy RetryPolicy) Option {
+	return func(config *ConnectionInfo) { config.ConnectRetry = policy }
+}
+
+// Sets the client-side statement timeout. See ConnectionInfo.ClientTimeout.
+func WithClientTimeout(timeout time.Duration) Option {
+	return func(config *ConnectionInfo) { config.ClientTimeout = timeout }
+}
+
+// Logs the statements and protocol traffic of the connection to the logger.
+func WithLogger(logger Logger) Option {
+	return func(config *ConnectionInfo) { config.Logger = logger }
+}
+
+// Reports the statistics of the connection to the collector.
+func WithStatsCollector(collector StatsCollector) Option {
+	return func(config *ConnectionInfo) { config.StatsCollector = collector }
+}
+
+// Calls the hooks before and after every statement. Either may be nil. See
+// ConnectionInfo.OnQueryStart.
+func WithQueryHooks(start func(ctx context.Context, event QueryEvent) context.Context, end func(ctx context.Context, event QueryEvent)) Option {
+	return func(config *ConnectionInfo) {
+		config.OnQueryStart = start
+		config.OnQueryEnd = end
+	}
+}
+
+// Appends a comment with the tags of the statement and its trace context to every
+// statement. See TraceComment.
+func WithQueryComment(tags map[string]string) Option {
+	return func(config *ConnectionInfo) { config.QueryComment = TraceComment(tags) }
+}
+
+// Reports the lifecycle events of the connection to the listener. See ConnectionEvents.
+func WithEvents(events ConnectionEvents) Option {
+	return func(config *ConnectionInfo) { config.Events = events }
+}
+
+// Adds interceptors that wrap the statements run on the connection, after those that
+// were added before. See QueryInterceptor.
+func WithInterceptors(interceptors ...QueryInterceptor) Option {
+	return func(config *ConnectionInfo) { config.Interceptors = append(config.Interceptors, interceptors...) }
+}
+
+// Sets a session parameter right after connecting. See ConnectionInfo.SessionParams.
+func WithSessionParam(name, value string) Option {
+	return func(config *ConnectionInfo) {
+		if config.SessionParams == nil {
+			config.SessionParams = make(map[string]string)
+		}
+		config.SessionParams[name] = value
+	}
+}
+
+// Runs the session in the resource pool. See ConnectionInfo.ResourcePool.
+func WithResourcePool(pool string) Option {
+	return func(config *ConnectionInfo) { config.ResourcePool = pool }
+}
+
+// Makes the session read-only. Unless checkStatements is set, mutating statements are
+// only rejected by the server. See ConnectionInfo.ReadOnly.
+func WithReadOnly(checkStatements bool) Option {
+	return func(config *ConnectionInfo) {
+		config.ReadOnly = true
+		config.SkipReadOnlyCheck = !checkStatements
+	}
+}
+
+// Only connects to the nodes of the Eon mode subcluster. See ConnectionInfo.Subcluster.
+func WithSubcluster(subcluster string) Option {
+	return func(config *ConnectionInfo) { config.Subcluster = subcluster }
+}
+
+// Tags the session with a workload for routing. See ConnectionInfo.Workload.
+func WithWorkload(workload string) Option {
+	return func(config *ConnectionInfo) { config.Workload = workload }
+}
+
+// Labels the session and its statements. See ConnectionInfo.Label.
+func WithLabel(label string) Option {
+	return func(config *ConnectionInfo) { config.Label = label }
+}
+
+// Sends heartbeats on the connection while it is idle. See ConnectionInfo.HeartbeatInterval.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(config *ConnectionInfo) { config.HeartbeatInterval = interval }
+}
+
+// Caches up to size prepared statements. See ConnectionInfo.StatementCacheSize.
+func WithStatementCache(size int) Option {
+	return func(config *ConnectionInfo) { config.StatementCacheSize = size }
+}
+
+// Sets the number of bytes to fetch per batch with AdaptiveFetchSize. See
+// ConnectionInfo.FetchBytes.
+func WithFetchBytes(n int) Option {
+	return func(config *ConnectionInfo) { config.FetchBytes = n }
+}
+
+// Sets the size of the buffer responses are read through. See ConnectionInfo.ReadBufferSize.
+func WithReadBufferSize(size int) Option {
+	return func(config *ConnectionInfo) { config.ReadBufferSize = size }
+}
+
+// Reports the progress of statements every interval. See ConnectionInfo.Progress.
+func WithProgress(interval time.Duration, fn func(Progress)) Option {
+	return func(config *ConnectionInfo) {
+		config.ProgressInterval = interval
+		config.Progress = fn
+	}
+}
@@ -0,0 +1,54 @@
+package vertigo
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestOptions(t *testing.T) {
+	tlsConfig := &tls.Config{}
+	config := newConnectionInfo("localhost:5433", []Option{
+		WithUser("dbadmin"),
+		WithPassword("secret"),
+		WithDatabase("analytics"),
+		WithTLS(tlsConfig),
+		WithTimeout(5 * time.Second),
+		func(config *ConnectionInfo) { config.SpillDir = "/tmp" },
+	})
+
+	if config.Address != "localhost:5433" || config.User != "dbadmin" || config.Password != "secret" || config.Database != "analytics" {
+		t.Fatalf("Expected the options to be applied, but found %+v", config)
+	}
+	if config.SslConfig != tlsConfig || config.ConnectTimeout != 5*time.Second || config.SpillDir != "/tmp" {
+		t.Fatalf("Expected the options to be applied, but found %+v", config)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.RequirePassword("secret")
+
+	if _, err := Open(server.Addr(), WithUser("dbadmin"), WithPassword("wrong")); err == nil {
+		t.Fatalf("Expected a wrong password to be rejected")
+	}
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"), WithPassword("secret"), WithTimeout(time.Second), WithReadBufferSize(256*1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if !connection.IsAlive() {
+		t.Fatalf("Expected the connection to be alive")
+	}
+	if size := connection.bufioReader.Size(); size != 256*1024 {
+		t.Fatalf("Expected a read buffer of 256 KiB, but found %d bytes", size)
+	}
+}
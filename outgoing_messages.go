@@ -3,12 +3,15 @@ package vertigo
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"sync"
 )
 
 const (
-	protocolVersion = uint32(3 << 16)
-	sslMagicNumber  = uint32(80877103)
+	protocolVersion   = uint32(3 << 16)
+	sslMagicNumber    = uint32(80877103)
+	cancelRequestCode = uint32(80877102)
 )
 
 type OutgoingMessage interface {
@@ -21,9 +24,26 @@ func (m SSLRequestMessage) Encode(buffer *bytes.Buffer) (byte, error) {
 	return 0, encodeNumeric(buffer, sslMagicNumber)
 }
 
+type CancelRequestMessage struct {
+	Pid uint32
+	Key uint32
+}
+
+func (m CancelRequestMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	if err := encodeNumeric(buffer, cancelRequestCode); err != nil {
+		return 0, err
+	}
+	if err := encodeNumeric(buffer, m.Pid); err != nil {
+		return 0, err
+	}
+	return 0, encodeNumeric(buffer, m.Key)
+}
+
 type StartupMessage struct {
 	User     string
 	Database string
+	Workload string
+	Label    string
 }
 
 func (m StartupMessage) Encode(buffer *bytes.Buffer) (byte, error) {
@@ -36,6 +56,14 @@ func (m StartupMessage) Encode(buffer *bytes.Buffer) (byte, error) {
 		encodeString(buffer, "database")
 		encodeString(buffer, m.Database)
 	}
+	if m.Workload != "" {
+		encodeString(buffer, "workload")
+		encodeString(buffer, m.Workload)
+	}
+	if m.Label != "" {
+		encodeString(buffer, "client_label")
+		encodeString(buffer, m.Label)
+	}
 
 	return 0, encodeNull(buffer)
 }
@@ -45,6 +73,15 @@ type PasswordMessage struct {
 	Password             string
 }
 
+// Keeps the password out of formatted output, like the TrafficLogger's.
+func (m PasswordMessage) GoString() string {
+	return fmt.Sprintf("vertigo.PasswordMessage{AuthenticationMethod:0x%x, Password:\"***\"}", m.AuthenticationMethod)
+}
+
+func (m PasswordMessage) String() string {
+	return m.GoString()
+}
+
 func (m PasswordMessage) Encode(buffer *bytes.Buffer) (byte, error) {
 	switch m.AuthenticationMethod {
 	case AuthenticationCleartextPassword:
@@ -60,6 +97,12 @@ func (m TerminateMessage) Encode(buffer *bytes.Buffer) (byte, error) {
 	return 'X', nil
 }
 
+type SyncMessage struct{}
+
+func (m SyncMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	return 'S', nil
+}
+
 type QueryMessage struct {
 	SQL string
 }
@@ -69,33 +112,191 @@ func (m QueryMessage) Encode(buffer *bytes.Buffer) (byte, error) {
 	return 'Q', err
 }
 
-func sendMessage(w io.Writer, m OutgoingMessage) error {
-	buffer := new(bytes.Buffer)
-	messageType, encodeErr := m.Encode(buffer)
-	if encodeErr != nil {
-		return encodeErr
+type ParseMessage struct {
+	Name string
+	SQL  string
+}
+
+func (m ParseMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	encodeString(buffer, m.Name)
+	encodeString(buffer, m.SQL)
+	return 'P', encodeNumeric(buffer, uint16(0))
+}
+
+// Binds text format parameter values to a prepared statement. Nil values are NULL.
+type BindMessage struct {
+	Portal    string
+	Statement string
+	Values    [][]byte
+}
+
+func (m BindMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	encodeString(buffer, m.Portal)
+	encodeString(buffer, m.Statement)
+	encodeNumeric(buffer, uint16(0))
+	encodeNumeric(buffer, uint16(len(m.Values)))
+	for _, value := range m.Values {
+		if value == nil {
+			encodeNumeric(buffer, int32(-1))
+			continue
+		}
+		encodeNumeric(buffer, int32(len(value)))
+		buffer.Write(value)
 	}
+	return 'B', encodeNumeric(buffer, uint16(0))
+}
+
+// Asks for the description of a prepared statement ('S') or a portal ('P').
+type DescribeMessage struct {
+	Kind byte
+	Name string
+}
+
+func (m DescribeMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	encodeNumeric(buffer, m.Kind)
+	return 'D', encodeString(buffer, m.Name)
+}
+
+// Executes a portal. When MaxRows is not zero, the portal is suspended after that many rows.
+type ExecuteMessage struct {
+	Portal  string
+	MaxRows uint32
+}
+
+func (m ExecuteMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	encodeString(buffer, m.Portal)
+	return 'E', encodeNumeric(buffer, m.MaxRows)
+}
+
+// Closes a prepared statement ('S') or a portal ('P').
+type CloseMessage struct {
+	Kind byte
+	Name string
+}
 
-	if messageType != 0 {
-		binary.Write(w, binary.BigEndian, messageType)
+func (m CloseMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	encodeNumeric(buffer, m.Kind)
+	return 'C', encodeString(buffer, m.Name)
+}
+
+// Sends data for a COPY FROM STDIN statement.
+type CopyDataMessage struct {
+	Data []byte
+}
+
+func (m CopyDataMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	_, err := buffer.Write(m.Data)
+	return 'd', err
+}
+
+// Tells the server all data for a COPY FROM STDIN statement was sent.
+type CopyDoneMessage struct{}
+
+func (m CopyDoneMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	return 'c', nil
+}
+
+// Aborts a COPY FROM STDIN statement, which then fails with the message.
+type CopyFailMessage struct {
+	Message string
+}
+
+func (m CopyFailMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	return 'f', encodeString(buffer, m.Message)
+}
+
+type FlushMessage struct{}
+
+func (m FlushMessage) Encode(buffer *bytes.Buffer) (byte, error) {
+	return 'H', nil
+}
+
+// The largest write buffer that is put back into the pool. Larger ones, like those of
+// big Bind messages, are left to the garbage collector rather than being kept around.
+const maxPooledWriteBuffer = 256 * 1024
+
+// The buffers messages are encoded into before they are written, shared by all
+// connections.
+var writeBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getWriteBuffer() *bytes.Buffer {
+	buffer := writeBuffers.Get().(*bytes.Buffer)
+	buffer.Reset()
+	return buffer
+}
+
+func putWriteBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() <= maxPooledWriteBuffer {
+		writeBuffers.Put(buffer)
 	}
-	binary.Write(w, binary.BigEndian, uint32(buffer.Len()+4))
-	_, writeErr := w.Write(buffer.Bytes())
-	return writeErr
+}
 
+// Writes the message to w, and returns its type and size.
+func sendMessage(w io.Writer, m OutgoingMessage) (byte, int, error) {
+	buffer := getWriteBuffer()
+	defer putWriteBuffer(buffer)
+
+	messageType, body, err := appendMessage(buffer, m)
+	if err != nil {
+		return messageType, 0, err
+	}
+	_, err = w.Write(buffer.Bytes())
+	return messageType, len(body) + 4, err
 }
 
+// Appends the frame of the message to buffer, and returns its type and body, which
+// refers to the buffer. The message is encoded in place after room for its type and
+// length, which are filled in afterwards. Messages without a type, like the startup
+// message, only have a length before their body.
+func appendMessage(buffer *bytes.Buffer, m OutgoingMessage) (byte, []byte, error) {
+	start := buffer.Len()
+	buffer.Write([]byte{0, 0, 0, 0, 0})
+	messageType, err := m.Encode(buffer)
+	if err != nil {
+		buffer.Truncate(start)
+		return messageType, nil, err
+	}
+
+	frame := buffer.Bytes()[start:]
+	if messageType == 0 {
+		copy(frame, frame[1:])
+		buffer.Truncate(buffer.Len() - 1)
+		frame = frame[:len(frame)-1]
+		binary.BigEndian.PutUint32(frame, uint32(len(frame)))
+		return 0, frame[4:], nil
+	}
+	frame[0] = messageType
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(frame)-1))
+	return messageType, frame[5:], nil
+}
+
+// Encodes a number in network byte order. The common types are written without
+// going through reflection.
 func encodeNumeric(buffer *bytes.Buffer, data interface{}) error {
-	return binary.Write(buffer, binary.BigEndian, data)
+	switch data := data.(type) {
+	case byte:
+		return buffer.WriteByte(data)
+	case uint16:
+		buffer.Write(binary.BigEndian.AppendUint16(buffer.AvailableBuffer(), data))
+	case uint32:
+		buffer.Write(binary.BigEndian.AppendUint32(buffer.AvailableBuffer(), data))
+	case int32:
+		buffer.Write(binary.BigEndian.AppendUint32(buffer.AvailableBuffer(), uint32(data)))
+	default:
+		return binary.Write(buffer, binary.BigEndian, data)
+	}
+	return nil
 }
 
 func encodeString(buffer *bytes.Buffer, s string) error {
-	if _, err := buffer.Write([]byte(s)); err != nil {
+	if _, err := buffer.WriteString(s); err != nil {
 		return err
 	}
 	return encodeNull(buffer)
 }
 
 func encodeNull(buffer *bytes.Buffer) error {
-	return encodeNumeric(buffer, byte(0))
+	return buffer.WriteByte(0)
 }
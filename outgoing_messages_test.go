@@ -0,0 +1,42 @@
+package vertigo
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestAppendMessage(t *testing.T) {
+	var buffer bytes.Buffer
+	if _, _, err := appendMessage(&buffer, QueryMessage{SQL: "SELECT 1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := appendMessage(&buffer, CancelRequestMessage{Pid: 1, Key: 2}); err != nil {
+		t.Fatal(err)
+	}
+	messageType, body, err := appendMessage(&buffer, SyncMessage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Q\x00\x00\x00\x0dSELECT 1\x00" +
+		"\x00\x00\x00\x10\x04\xd2\x16\x2e\x00\x00\x00\x01\x00\x00\x00\x02" +
+		"S\x00\x00\x00\x04"
+	if buffer.String() != expected {
+		t.Fatalf("Expected frames %q, but found %q", expected, buffer.String())
+	}
+	if messageType != 'S' || len(body) != 0 {
+		t.Fatalf("Expected an empty Sync message, but found %q with body %q", messageType, body)
+	}
+}
+
+func BenchmarkWriteCopyData(b *testing.B) {
+	c := &Connection{config: &ConnectionInfo{}}
+	msg := CopyDataMessage{Data: make([]byte, copyChunkSize)}
+
+	b.ReportAllocs()
+	b.SetBytes(copyChunkSize)
+	for n := 0; n < b.N; n++ {
+		c.writeMessageTo(io.Discard, msg)
+	}
+}
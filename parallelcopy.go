@@ -0,0 +1,184 @@
+package vertigo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// The default size of the chunks of a ParallelCopy.
+const defaultParallelCopyChunkSize = 16 * 1024 * 1024
+
+// Loads data with concurrent COPY FROM STDIN statements on sessions spread over
+// the nodes of the cluster, which is how Vertica ingests data fastest.
+//
+// The input is split into chunks of whole lines, so it has to be in a line based
+// format like the delimited text of the default parser; the native binary format
+// can't be split. Every chunk is loaded with its own COPY statement, on one of
+// Sessions connections. The connections are spread over the UP nodes of the
+// cluster with the Topology of the configuration, or with a new Topology that
+// distributes connections if it has none.
+//
+// Every COPY commits on its own, so when a chunk fails to load, the chunks loaded
+// before it stay loaded.
+type ParallelCopy struct {
+	Config    *ConnectionInfo // The configuration the sessions are opened with
+	SQL       string          // The COPY ... FROM STDIN statement that loads a chunk
+	Sessions  int             // The number of concurrent sessions. Defaults to the number of UP nodes.
+	ChunkSize int             // The size a chunk grows to before it is cut at the next line. Defaults to 16 MiB.
+}
+
+// An error loading one of the chunks of a ParallelCopy.
+type ChunkError struct {
+	Chunk  int   // The number of the chunk in the input, from 0
+	Offset int64 // The offset of the chunk in the input
+	Err    error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("Cannot load chunk %d at offset %d: %s", e.Chunk, e.Offset, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+type parallelCopyChunk struct {
+	number int
+	offset int64
+	data   []byte
+}
+
+// Loads the data, and returns the total number of loaded and rejected rows. When
+// chunks fail to load, the input is no longer read, and the errors of all chunks
+// that failed are returned, joined with errors.Join, as *ChunkError values.
+func (p ParallelCopy) Load(data io.Reader) (CopyResult, error) {
+	config := *p.Config
+	if config.Topology == nil {
+		config.Topology = &Topology{Distribute: true}
+	}
+
+	sessions := p.Sessions
+	if sessions <= 0 {
+		sessions = p.upNodes(&config)
+	}
+
+	var (
+		total   CopyResult
+		errs    []error
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		chunks  = make(chan parallelCopyChunk, sessions)
+		aborted = make(chan struct{})
+		once    sync.Once
+	)
+	fail := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+		once.Do(func() { close(aborted) })
+	}
+
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			connection, err := Connect(&config)
+			if err != nil {
+				fail(fmt.Errorf("Cannot open a session for COPY: %w", err))
+				return
+			}
+			defer connection.Close()
+
+			for chunk := range chunks {
+				select {
+				case <-aborted:
+					continue
+				default:
+				}
+
+				result, err := connection.CopyIn(p.SQL, bytes.NewReader(chunk.data))
+				if err != nil {
+					fail(&ChunkError{Chunk: chunk.number, Offset: chunk.offset, Err: err})
+					continue
+				}
+
+				mu.Lock()
+				total.Loaded += result.Loaded
+				total.Rejected += result.Rejected
+				mu.Unlock()
+			}
+		}()
+	}
+
+	readErr := p.split(data, chunks, aborted)
+	close(chunks)
+	wg.Wait()
+
+	if readErr != nil {
+		errs = append(errs, readErr)
+	}
+	return total, errors.Join(errs...)
+}
+
+// Returns the number of UP nodes of the cluster, or 1 if it isn't known.
+func (p ParallelCopy) upNodes(config *ConnectionInfo) int {
+	if len(config.Topology.Nodes()) == 0 {
+		// The nodes are discovered when the first connection is opened.
+		if connection, err := Connect(config); err == nil {
+			connection.Close()
+		}
+	}
+
+	up := 0
+	for _, node := range config.Topology.Nodes() {
+		if node.State == "UP" {
+			up++
+		}
+	}
+	if up == 0 {
+		return 1
+	}
+	return up
+}
+
+// Splits the input into chunks of whole lines, and sends them to the channel until
+// the input ends or the load is aborted.
+func (p ParallelCopy) split(data io.Reader, chunks chan<- parallelCopyChunk, aborted <-chan struct{}) error {
+	size := p.ChunkSize
+	if size <= 0 {
+		size = defaultParallelCopyChunkSize
+	}
+
+	reader := bufio.NewReader(data)
+	var offset int64
+	for number := 0; ; number++ {
+		chunk := make([]byte, size)
+		n, err := io.ReadFull(reader, chunk)
+		chunk = chunk[:n]
+		if err == nil {
+			var rest []byte
+			rest, err = reader.ReadBytes('\n')
+			chunk = append(chunk, rest...)
+		}
+
+		if len(chunk) > 0 {
+			select {
+			case chunks <- parallelCopyChunk{number: number, offset: offset, data: chunk}:
+			case <-aborted:
+				return nil
+			}
+			offset += int64(len(chunk))
+		}
+
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			return nil
+		case err != nil:
+			return fmt.Errorf("Cannot read the input at offset %d: %w", offset, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package vertigo
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestParallelCopy(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect(topologyQuery).
+		Columns(vertigotest.Column{Name: "node_name"}, vertigotest.Column{Name: "export_address"}, vertigotest.Column{Name: "node_state"}, vertigotest.Column{Name: "subcluster_name"}).
+		Row("v_vmart_node0001", "127.0.0.1", "UP", "default_subcluster").
+		Row("v_vmart_node0002", "127.0.0.1", "UP", "default_subcluster").
+		Row("v_vmart_node0003", "127.0.0.1", "DOWN", "default_subcluster")
+	server.Expect("SELECT GET_NUM_REJECTED_ROWS()").Columns(vertigotest.Column{Name: "GET_NUM_REJECTED_ROWS", Type: DataTypeInteger}).Row(1)
+	server.Expect("COPY t FROM STDIN").CopyIn().Tag("COPY 2")
+
+	input := "1|a\n2|b\n3|c\n4|d\n5|e\n6|f"
+	load := ParallelCopy{Config: &ConnectionInfo{Address: server.Addr(), User: "dbadmin"}, SQL: "COPY t FROM STDIN", ChunkSize: 5}
+	result, err := load.Load(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Loaded != 6 || result.Rejected != 3 {
+		t.Fatalf("Expected 6 loaded and 3 rejected rows from 3 chunks, but found %+v", result)
+	}
+
+	var chunks []string
+	for _, data := range server.CopyData() {
+		chunks = append(chunks, string(data))
+	}
+	sort.Strings(chunks)
+	if expected := []string{"1|a\n2|b\n", "3|c\n4|d\n", "5|e\n6|f"}; strings.Join(chunks, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected the input to be split into chunks of whole lines, but found %q", chunks)
+	}
+}
+
+func TestParallelCopyError(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("COPY t FROM STDIN").CopyIn().Error("22V04", "COPY: Input record 1 has been rejected")
+
+	load := ParallelCopy{Config: &ConnectionInfo{Address: server.Addr(), User: "dbadmin"}, SQL: "COPY t FROM STDIN", Sessions: 2, ChunkSize: 1}
+	_, err = load.Load(strings.NewReader("1\n2\n3\n4\n"))
+
+	var chunkError *ChunkError
+	if !errors.As(err, &chunkError) || !strings.Contains(chunkError.Error(), "rejected") {
+		t.Fatalf("Expected a *ChunkError, but found %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package vertigo
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// Returns the text format representation of a parameter value that is bound to a
+// prepared statement. NULL is returned as nil. Values of types that implement Encoder
+// or driver.Valuer are encoded as the value they return.
+func encodeParameter(v interface{}) ([]byte, error) {
+	v, err := resolveEncoder(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(v), nil
+	case []byte:
+		if v == nil {
+			return nil, nil
+		}
+		return escapeBinary(v), nil
+	case bool:
+		return []byte(strconv.FormatBool(v)), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return []byte(fmt.Sprintf("%d", v)), nil
+	case float32:
+		return []byte(formatFloat(float64(v), 32)), nil
+	case float64:
+		return []byte(formatFloat(v, 64)), nil
+	case Decimal:
+		return []byte(v.String()), nil
+	case *big.Int:
+		return []byte(v.String()), nil
+	case time.Time:
+		return []byte(v.Format(timestampFormat)), nil
+	}
+
+	if uuid, ok := uuidString(v); ok {
+		return []byte(uuid), nil
+	}
+	return nil, fmt.Errorf("Cannot use value of type %T as a statement parameter", v)
+}
+
+// Returns the text representation of a float, using Vertica's representation of
+// the special values.
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, bitSize)
+	}
+}
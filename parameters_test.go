@@ -0,0 +1,55 @@
+package vertigo
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEncodeParameter(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected []byte
+	}{
+		{nil, nil},
+		{"it's", []byte("it's")},
+		{[]byte{'a', '\\', 0, 0xff}, []byte(`a\\\000\377`)},
+		{true, []byte("true")},
+		{int8(-5), []byte("-5")},
+		{uint64(math.MaxUint64), []byte("18446744073709551615")},
+		{1.5, []byte("1.5")},
+		{math.Inf(-1), []byte("-Infinity")},
+		{time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC), []byte("2020-01-02 03:04:05.000006+00:00")},
+		{UUID{0x12}, []byte("12000000-0000-0000-0000-000000000000")},
+	}
+
+	for _, test := range tests {
+		value, err := encodeParameter(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(value, test.expected) || (value == nil) != (test.expected == nil) {
+			t.Errorf("Expected %q for %#v, but found %q", test.expected, test.value, value)
+		}
+	}
+
+	if _, err := encodeParameter(struct{}{}); err == nil {
+		t.Error("Expected an error for an unsupported parameter type")
+	}
+}
+
+func TestEscapeBinary(t *testing.T) {
+	original := make([]byte, 256)
+	for i := range original {
+		original[i] = byte(i)
+	}
+
+	decoded, err := decodeBinary(&Field{}, escapeBinary(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, original) {
+		t.Fatalf("Expected escaped values to round-trip, but found %q", decoded)
+	}
+}
@@ -0,0 +1,129 @@
+package vertigo
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Pipeline runs a number of statements in a single round trip. All statements are
+// written to the server before any of their responses are read, followed by a single
+// Sync, which saves a round trip per statement over high-latency links. This is meant
+// for many small statements; their resultsets are buffered in memory.
+//
+//	var p vertigo.Pipeline
+//	p.Add("UPDATE counters SET n = n + ? WHERE id = ?", 1, 42)
+//	p.Add("SELECT n FROM counters WHERE id = ?", 42)
+//	resultsets, err := p.Run(connection)
+type Pipeline struct {
+	statements []pipelineStatement
+}
+
+type pipelineStatement struct {
+	sql  string
+	args []interface{}
+}
+
+// Adds a statement to the pipeline. Parameters in the SQL string are written as ?
+// placeholders, and bound to the arguments like those of a prepared statement.
+func (p *Pipeline) Add(sql string, args ...interface{}) {
+	p.statements = append(p.statements, pipelineStatement{sql: sql, args: args})
+}
+
+// Returns the number of statements in the pipeline.
+func (p *Pipeline) Len() int {
+	return len(p.statements)
+}
+
+// Runs the statements of the pipeline on the connection, and returns their resultsets
+// in order. Statements that don't return rows get a resultset without fields and rows.
+// The pipeline can be run again afterwards.
+//
+// When a statement fails, the server skips the remaining statements. The error is
+// returned together with the resultsets of the statements that completed before it.
+func (p *Pipeline) Run(c *Connection) (resultsets []*Resultset, err error) {
+	values := make([][][]byte, len(p.statements))
+	for i, statement := range p.statements {
+		values[i] = make([][]byte, len(statement.args))
+		for j, arg := range statement.args {
+			if values[i][j], err = encodeParameter(arg); err != nil {
+				return nil, fmt.Errorf("Statement %d of the pipeline, argument %d: %w", i, j, err)
+			}
+		}
+		if err := c.checkReadOnly(statement.sql); err != nil {
+			return nil, err
+		}
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			resultsets, err = nil, r.(error)
+			c.markBroken(err)
+		}
+	}()
+
+	if c.portal != nil {
+		return nil, ErrPortalOpen
+	}
+	if err := c.brokenError(); err != nil {
+		return nil, err
+	}
+	if c.socket == nil {
+		c.openConnection()
+	}
+
+	var buffer bytes.Buffer
+	for i, statement := range p.statements {
+		c.appendMessage(&buffer, ParseMessage{SQL: statement.sql})
+		c.appendMessage(&buffer, BindMessage{Values: values[i]})
+		c.appendMessage(&buffer, DescribeMessage{Kind: 'P'})
+		c.appendMessage(&buffer, ExecuteMessage{})
+	}
+	c.appendMessage(&buffer, SyncMessage{})
+
+	// The server may start responding before it has read the whole pipeline, so the
+	// responses are read while it is written, or both sides could wait for each other.
+	written := make(chan error, 1)
+	socket := c.socket
+	go func() {
+		_, err := socket.Write(buffer.Bytes())
+		written <- err
+	}()
+
+	resultset := &Resultset{}
+	for msg := c.receiveMessage(); !c.isReadyForQuery(msg); msg = c.receiveMessage() {
+		switch msg := msg.(type) {
+		case ParseCompleteMessage, BindCompleteMessage, NoDataMessage:
+			continue
+
+		case RowDescriptionMessage:
+			c.prepareFields(msg.Fields)
+			resultset.Fields = msg.Fields
+
+		case DataRowMessage:
+			resultset.Rows = append(resultset.Rows, Row{Values: msg.Values, fields: resultset.Fields})
+
+		case CommandCompleteMessage, EmptyQueryMessage:
+			if complete, ok := msg.(CommandCompleteMessage); ok {
+				resultset.Result = complete.Result
+			}
+			resultsets = append(resultsets, resultset)
+			resultset = &Resultset{}
+
+		case ErrorResponseMessage:
+			if err == nil {
+				err = msg.VerticaError()
+			}
+
+		default:
+			c.handleStatelessMessage(msg)
+		}
+	}
+
+	if writeError := <-written; writeError != nil {
+		panic(writeError)
+	}
+	return resultsets, err
+}
@@ -0,0 +1,58 @@
+package vertigo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestPipeline(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("UPDATE counters SET n = n + ? WHERE id = ?").Tag("UPDATE 1")
+	server.Expect("SELECT n FROM counters WHERE id = ?").Columns(vertigotest.Column{Name: "n", Type: DataTypeInteger}).Row(5)
+	server.Expect("SELECT missing").Error("42703", "Column \"missing\" does not exist")
+
+	connection, err := Connect(&ConnectionInfo{Address: server.Addr(), User: "dbadmin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	var p Pipeline
+	p.Add("UPDATE counters SET n = n + ? WHERE id = ?", 1, 42)
+	p.Add("SELECT n FROM counters WHERE id = ?", 42)
+	resultsets, err := p.Run(&connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resultsets) != 2 || resultsets[0].Result != "UPDATE 1" {
+		t.Fatalf("Expected two resultsets, but found %+v", resultsets)
+	}
+	var n int64
+	if err := resultsets[1].Rows[0].Scan(&n); err != nil || n != 5 || resultsets[1].Fields[0].Name != "n" {
+		t.Fatalf("Expected a row with n = 5, but found %v, %v", n, err)
+	}
+
+	p.Add("SELECT missing")
+	p.Add("UPDATE counters SET n = n + ? WHERE id = ?", 1, 42)
+	resultsets, err = p.Run(&connection)
+	if _, ok := err.(*VerticaError); !ok || len(resultsets) != 2 {
+		t.Fatalf("Expected the statements before the failing one to complete, but found %d resultsets, %v", len(resultsets), err)
+	}
+
+	expected := []string{
+		"UPDATE counters SET n = n + ? WHERE id = ?", "SELECT n FROM counters WHERE id = ?",
+		"UPDATE counters SET n = n + ? WHERE id = ?", "SELECT n FROM counters WHERE id = ?", "SELECT missing",
+	}
+	if statements := server.Statements(); !reflect.DeepEqual(statements, expected) {
+		t.Fatalf("Expected the statements after the error to be skipped, but found %q", statements)
+	}
+	if _, err := connection.Query("SELECT n FROM counters WHERE id = ?"); err != nil {
+		t.Fatalf("Expected the connection to be usable after the pipeline, but found %v", err)
+	}
+}
@@ -0,0 +1,509 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPoolMaxIdle             = 2
+	defaultPoolHealthCheckInterval = time.Minute
+)
+
+var ErrPoolClosed = errors.New("Pool is closed")
+
+// The reason the pool closed a connection, as passed to Pool.ConnectionClosed.
+type PoolCloseReason string
+
+const (
+	PoolCloseBroken      PoolCloseReason = "broken"       // The connection broke while it was in use.
+	PoolCloseTransaction PoolCloseReason = "transaction"  // The connection was returned in a transaction.
+	PoolCloseUnhealthy   PoolCloseReason = "unhealthy"    // The connection failed a health check while it was idle.
+	PoolCloseMaxLifetime PoolCloseReason = "max_lifetime" // The connection was open for longer than MaxLifetime.
+	PoolCloseIdleTimeout PoolCloseReason = "idle_timeout" // The connection was idle for longer than IdleTimeout.
+	PoolCloseMaxIdle     PoolCloseReason = "max_idle"     // The pool already had MaxIdle idle connections.
+	PoolCloseClosed      PoolCloseReason = "closed"       // The pool was closed.
+	PoolCloseForeign     PoolCloseReason = "foreign"      // The connection wasn't opened by the pool.
+	PoolCloseLeftOpen    PoolCloseReason = "left_open"    // The portal or statements left open couldn't be closed.
+)
+
+// A pool of connections that are opened with the same configuration, for use by
+// concurrent goroutines. Take a connection with Get, and give it back with Put
+// when done, so it can be reused. The settings must not be changed after the
+// first call to Get.
+//
+// The pool keeps its idle connections healthy. Every HealthCheckInterval, they are
+// pinged, and those that fail, or that exceed the MaxLifetime, are closed and replaced
+// in the background. Those that exceed the IdleTimeout are closed, and only replaced
+// as far as needed to keep MinIdleConns idle connections. Connections are checked
+// for their age when they are taken and given back as well. Connections that broke
+// while they were in use, or that are given back in a transaction, are closed.
+type Pool struct {
+	Config *ConnectionInfo // The configuration connections are opened with
+
+	// The name of the pool in the events reported to the StatsCollector of the Config,
+	// when it implements PoolStatsCollector.
+	Name string
+
+	MaxOpen int // The maximum number of open connections. Zero means no limit.
+	MaxIdle int // The maximum number of idle connections. Zero uses a default of two.
+
+	// The number of idle connections the pool keeps open, so queries don't pay for
+	// connecting and authenticating. They are opened by Warmup, and replenished by
+	// the health checks.
+	MinIdleConns int
+
+	// The maximum time a connection is used after it was opened, and the maximum
+	// time it is kept while it is idle. Zero means no limit.
+	MaxLifetime time.Duration
+	IdleTimeout time.Duration
+
+	// How often idle connections are checked. Zero uses a default of one minute, and
+	// a negative interval disables the background checks.
+	HealthCheckInterval time.Duration
+
+	// Called after the pool opened a connection, and after it closed one, so the
+	// churn of the pool can be observed. The functions are called synchronously,
+	// without holding the lock of the pool, and must not use the connection.
+	ConnectionOpened func(c *Connection)
+	ConnectionClosed func(c *Connection, reason PoolCloseReason)
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	open    int                        // The number of open connections, and of connections being opened
+	entries map[*Connection]*poolEntry // The open connections, by connection
+	idle    []*poolEntry               // The idle connections, the most recently used last
+	waiters []chan *poolEntry          // The Get calls waiting for a connection, in order
+	done    chan struct{}              // Closed when the pool is closed, to stop the health checks
+	checks  sync.WaitGroup
+	stats   PoolStats // The counters of the statistics
+}
+
+// A snapshot of the statistics of a pool, see Pool.Stats. It mirrors sql.DBStats.
+type PoolStats struct {
+	MaxOpenConnections int // The MaxOpen setting of the pool. Zero means no limit.
+
+	OpenConnections int // The number of open connections, including those being opened.
+	InUse           int // The number of connections in use, including those being health checked.
+	Idle            int // The number of idle connections.
+
+	WaitCount    int64         // The number of times Get waited for a connection.
+	WaitDuration time.Duration // The total time Get waited for connections.
+
+	MaxIdleClosed     int64 // The number of connections closed because of MaxIdle.
+	MaxIdleTimeClosed int64 // The number of connections closed because of IdleTimeout.
+	MaxLifetimeClosed int64 // The number of connections closed because of MaxLifetime.
+	BrokenClosed      int64 // The number of connections closed because they broke, failed a health check, or were given back in a transaction or with a portal that couldn't be closed.
+}
+
+type poolEntry struct {
+	c         *Connection
+	created   time.Time
+	idleSince time.Time
+}
+
+// Returns an idle connection, or opens a new one. When MaxOpen connections are
+// open, Get waits until one is given back, or until the context is done.
+func (p *Pool) Get(ctx context.Context) (c *Connection, err error) {
+	var waited time.Duration
+	defer func() {
+		if collector := p.statsCollector(); collector != nil && err == nil {
+			collector.ConnectionCheckedOut(p.Name, waited)
+		}
+	}()
+
+	for {
+		p.mu.Lock()
+		p.start()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		if n := len(p.idle); n > 0 {
+			e := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+
+			if reason := p.expired(e, time.Now()); reason != "" {
+				p.closeEntry(e, reason)
+				continue
+			}
+			return e.c, nil
+		}
+
+		if p.MaxOpen <= 0 || p.open < p.MaxOpen {
+			p.open++
+			p.mu.Unlock()
+			return p.openForGet()
+		}
+
+		wait := make(chan *poolEntry, 1)
+		p.waiters = append(p.waiters, wait)
+		p.stats.WaitCount++
+		p.mu.Unlock()
+
+		waitStart := time.Now()
+		select {
+		case e, ok := <-wait:
+			// Opening a connection in a free slot doesn't count as waiting.
+			waited += p.addWaitDuration(waitStart)
+			switch {
+			case !ok:
+				return nil, ErrPoolClosed
+			case e == nil:
+				return p.openForGet()
+			default:
+				return e.c, nil
+			}
+
+		case <-ctx.Done():
+			p.addWaitDuration(waitStart)
+			p.mu.Lock()
+			waiting := p.removeWaiter(wait)
+			p.mu.Unlock()
+
+			// A connection or a free slot may have been handed over in the meantime.
+			if !waiting {
+				if e, ok := <-wait; ok && e == nil {
+					p.releaseSlot()
+				} else if ok {
+					p.putEntry(e)
+				}
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Gives a connection taken with Get back to the pool. It must not be used afterwards.
+// Connections that broke, that are in a transaction, or that exceeded their lifetime,
+// are closed instead of being reused. A portal and statements prepared on the
+// connection that are still open are closed, except for the statements of the
+// statement cache, so the next borrower gets a clean connection.
+func (p *Pool) Put(c *Connection) {
+	p.mu.Lock()
+	e := p.entries[c]
+	p.mu.Unlock()
+
+	if e == nil {
+		c.Close()
+		if p.ConnectionClosed != nil {
+			p.ConnectionClosed(c, PoolCloseForeign)
+		}
+		return
+	}
+	if collector := p.statsCollector(); collector != nil {
+		collector.ConnectionCheckedIn(p.Name)
+	}
+
+	switch {
+	case !c.IsAlive():
+		p.closeEntry(e, PoolCloseBroken)
+	case c.TransactionStatus() != TransactionStatusIdle:
+		p.closeEntry(e, PoolCloseTransaction)
+	case p.MaxLifetime > 0 && time.Since(e.created) > p.MaxLifetime:
+		p.closeEntry(e, PoolCloseMaxLifetime)
+	case c.closeOutstanding(true) != nil:
+		p.closeEntry(e, PoolCloseLeftOpen)
+	default:
+		e.idleSince = time.Now()
+		p.putEntry(e)
+	}
+}
+
+// Closes the pool and its idle connections, and stops the health checks. Get calls
+// that are waiting fail with ErrPoolClosed. Connections that are in use are closed
+// when they are given back.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	for _, wait := range p.waiters {
+		close(wait)
+	}
+	p.waiters = nil
+	if p.started {
+		close(p.done)
+	}
+	p.mu.Unlock()
+
+	p.checks.Wait()
+	for _, e := range idle {
+		p.closeEntry(e, PoolCloseClosed)
+	}
+	return nil
+}
+
+// Opens MinIdleConns connections, or one connection if it is zero, and keeps them
+// idle. This is meant for startup, to fail fast when the configuration is wrong,
+// and to have connections ready for the first queries. The error of the first
+// connection that cannot be opened is returned.
+func (p *Pool) Warmup(ctx context.Context) error {
+	return p.fill(ctx, max(p.MinIdleConns, 1))
+}
+
+// Returns a snapshot of the statistics of the pool.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.MaxOpenConnections = p.MaxOpen
+	stats.OpenConnections = p.open
+	stats.Idle = len(p.idle)
+	stats.InUse = p.open - len(p.idle)
+	return stats
+}
+
+// Adds the time since start to the time Get waited for connections, and returns it.
+func (p *Pool) addWaitDuration(start time.Time) time.Duration {
+	d := time.Since(start)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.WaitDuration += d
+	return d
+}
+
+// Returns the StatsCollector of the Config if it collects the statistics of pools.
+func (p *Pool) statsCollector() PoolStatsCollector {
+	collector, _ := p.Config.StatsCollector.(PoolStatsCollector)
+	return collector
+}
+
+// Initializes the pool and starts the health checks on first use. The pool lock must be held.
+func (p *Pool) start() {
+	if p.started {
+		return
+	}
+	p.started = true
+	p.entries = make(map[*Connection]*poolEntry)
+	p.done = make(chan struct{})
+
+	interval := p.HealthCheckInterval
+	if interval == 0 {
+		interval = defaultPoolHealthCheckInterval
+	}
+	if interval > 0 && !p.closed {
+		p.checks.Add(1)
+		go p.runHealthChecks(interval)
+	}
+}
+
+func (p *Pool) runHealthChecks(interval time.Duration) {
+	defer p.checks.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.checkIdle()
+		}
+	}
+}
+
+// Pings the idle connections, closes those that fail or that expired, and opens
+// replacements for them.
+func (p *Pool) checkIdle() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	// Connections that were closed because they stayed idle too long aren't replaced,
+	// so an idle pool shrinks to MinIdleConns.
+	evicted := 0
+	for _, e := range idle {
+		reason := p.expired(e, time.Now())
+		if reason == "" && e.c.Ping() != nil {
+			reason = PoolCloseUnhealthy
+		}
+
+		if reason != "" {
+			p.closeEntry(e, reason)
+			if reason != PoolCloseIdleTimeout {
+				evicted++
+			}
+			continue
+		}
+		p.putEntry(e)
+	}
+
+	p.mu.Lock()
+	target := max(len(p.idle)+evicted, p.MinIdleConns)
+	p.mu.Unlock()
+
+	if err := p.fill(context.Background(), target); err != nil && err != ErrPoolClosed && p.Config.Logger != nil {
+		p.Config.Logger.Log(LogLevelWarn, "Cannot replace pooled connection", "address", p.Config.Address, "error", err)
+	}
+}
+
+// Opens connections until the pool has target idle connections, or MaxOpen open
+// connections. Returns the first error opening a connection.
+func (p *Pool) fill(ctx context.Context, target int) error {
+	target = min(target, p.maxIdle())
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		p.start()
+		switch {
+		case p.closed:
+			p.mu.Unlock()
+			return ErrPoolClosed
+		case len(p.idle) >= target, p.MaxOpen > 0 && p.open >= p.MaxOpen:
+			p.mu.Unlock()
+			return nil
+		}
+		p.open++
+		p.mu.Unlock()
+
+		// The connection wasn't checked out, so it isn't given back with Put.
+		e, err := p.openEntry()
+		if err != nil {
+			return err
+		}
+		e.idleSince = time.Now()
+		p.putEntry(e)
+	}
+}
+
+// Returns why the idle connection should be closed, if it should.
+func (p *Pool) expired(e *poolEntry, now time.Time) PoolCloseReason {
+	switch {
+	case p.MaxLifetime > 0 && now.Sub(e.created) > p.MaxLifetime:
+		return PoolCloseMaxLifetime
+	case p.IdleTimeout > 0 && now.Sub(e.idleSince) > p.IdleTimeout:
+		return PoolCloseIdleTimeout
+	default:
+		return ""
+	}
+}
+
+// Returns the maximum number of idle connections, which is at least MinIdleConns.
+func (p *Pool) maxIdle() int {
+	maxIdle := p.MaxIdle
+	if maxIdle <= 0 {
+		maxIdle = defaultPoolMaxIdle
+	}
+	return max(maxIdle, p.MinIdleConns)
+}
+
+// Opens a connection in a slot that was counted in open.
+func (p *Pool) openEntry() (*poolEntry, error) {
+	connection, err := Connect(p.Config)
+	if err != nil {
+		p.releaseSlot()
+		return nil, err
+	}
+	e := &poolEntry{c: &connection, created: time.Now()}
+	e.c.l.Lock()
+	e.c.startHeartbeat()
+	e.c.l.Unlock()
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		e.c.Close()
+		p.releaseSlot()
+		return nil, ErrPoolClosed
+	}
+	p.entries[e.c] = e
+	p.mu.Unlock()
+
+	if p.ConnectionOpened != nil {
+		p.ConnectionOpened(e.c)
+	}
+	return e, nil
+}
+
+// Opens a connection in a slot that was counted in open, for a Get call.
+func (p *Pool) openForGet() (*Connection, error) {
+	e, err := p.openEntry()
+	if err != nil {
+		return nil, err
+	}
+	return e.c, nil
+}
+
+// Hands the connection to a waiting Get call, or makes it idle.
+func (p *Pool) putEntry(e *poolEntry) {
+	p.mu.Lock()
+	switch {
+	case p.closed:
+		p.mu.Unlock()
+		p.closeEntry(e, PoolCloseClosed)
+	case len(p.waiters) > 0:
+		p.waiters[0] <- e
+		p.waiters = p.waiters[1:]
+		p.mu.Unlock()
+	case len(p.idle) >= p.maxIdle():
+		p.mu.Unlock()
+		p.closeEntry(e, PoolCloseMaxIdle)
+	default:
+		p.idle = append(p.idle, e)
+		p.mu.Unlock()
+	}
+}
+
+// Closes a connection of the pool, and frees its slot.
+func (p *Pool) closeEntry(e *poolEntry, reason PoolCloseReason) {
+	p.mu.Lock()
+	delete(p.entries, e.c)
+	switch reason {
+	case PoolCloseMaxIdle:
+		p.stats.MaxIdleClosed++
+	case PoolCloseIdleTimeout:
+		p.stats.MaxIdleTimeClosed++
+	case PoolCloseMaxLifetime:
+		p.stats.MaxLifetimeClosed++
+	case PoolCloseBroken, PoolCloseUnhealthy, PoolCloseTransaction, PoolCloseLeftOpen:
+		p.stats.BrokenClosed++
+	}
+	p.mu.Unlock()
+	p.releaseSlot()
+
+	e.c.Close()
+	if p.ConnectionClosed != nil {
+		p.ConnectionClosed(e.c, reason)
+	}
+}
+
+// Frees a slot counted in open. When a Get call is waiting, the slot is handed over
+// to it instead, so it can open a connection of its own.
+func (p *Pool) releaseSlot() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.waiters) > 0 && !p.closed {
+		p.waiters[0] <- nil
+		p.waiters = p.waiters[1:]
+		return
+	}
+	p.open--
+}
+
+// Removes a waiting Get call, and returns whether it was still waiting. The pool
+// lock must be held.
+func (p *Pool) removeWaiter(wait chan *poolEntry) bool {
+	for i, w := range p.waiters {
+		if w == wait {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,415 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+// Records the connections opened and closed by a pool.
+type poolChurn struct {
+	mu      sync.Mutex
+	opened  int
+	reasons []PoolCloseReason
+}
+
+func (c *poolChurn) hook(p *Pool) {
+	p.ConnectionOpened = func(*Connection) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.opened++
+	}
+	p.ConnectionClosed = func(_ *Connection, reason PoolCloseReason) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.reasons = append(c.reasons, reason)
+	}
+}
+
+func (c *poolChurn) counts() (int, []PoolCloseReason) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.opened, append([]PoolCloseReason(nil), c.reasons...)
+}
+
+func TestPoolReuse(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("BEGIN").Tag("BEGIN").TransactionStatus('T')
+
+	var churn poolChurn
+	pool := &Pool{Config: &ConnectionInfo{Address: server.Addr(), User: "dbadmin"}, MaxOpen: 1}
+	churn.hook(pool)
+	defer pool.Close()
+
+	c1, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected Get to wait for a connection until the context is done, but found %v", err)
+	}
+
+	got := make(chan *Connection)
+	go func() {
+		c, err := pool.Get(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		got <- c
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.Put(c1)
+	if c2 := <-got; c2 != c1 {
+		t.Fatalf("Expected the waiting Get to receive the connection that was given back")
+	}
+
+	if _, err := c1.Exec("BEGIN"); err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(c1)
+
+	c3, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c3 == c1 {
+		t.Fatalf("Expected a connection given back in a transaction to be closed")
+	}
+	pool.Put(c3)
+
+	if opened, reasons := churn.counts(); opened != 2 || len(reasons) != 1 || reasons[0] != PoolCloseTransaction {
+		t.Fatalf("Expected two connections to be opened and one to be closed, but found %d, %v", opened, reasons)
+	}
+
+	stats := pool.Stats()
+	if stats.MaxOpenConnections != 1 || stats.OpenConnections != 1 || stats.Idle != 1 || stats.InUse != 0 {
+		t.Fatalf("Expected a single idle connection, but found %+v", stats)
+	}
+	if stats.WaitCount != 2 || stats.WaitDuration < 20*time.Millisecond || stats.BrokenClosed != 1 {
+		t.Fatalf("Expected two waits and one broken connection, but found %+v", stats)
+	}
+
+	pool.Close()
+	if _, err := pool.Get(context.Background()); err != ErrPoolClosed {
+		t.Fatalf("Expected ErrPoolClosed, but found %v", err)
+	}
+	if _, reasons := churn.counts(); len(reasons) != 2 || reasons[1] != PoolCloseClosed {
+		t.Fatalf("Expected the idle connection to be closed with the pool, but found %v", reasons)
+	}
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	var churn poolChurn
+	pool := &Pool{
+		Config:              &ConnectionInfo{Address: server.Addr(), User: "dbadmin"},
+		MaxLifetime:         30 * time.Millisecond,
+		HealthCheckInterval: 10 * time.Millisecond,
+	}
+	churn.hook(pool)
+	defer pool.Close()
+
+	c, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(c)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		opened, reasons := churn.counts()
+		if opened >= 2 && len(reasons) >= 1 {
+			if reasons[0] != PoolCloseMaxLifetime {
+				t.Fatalf("Expected the expired connection to be evicted, but found %v", reasons)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the expired connection to be replaced, but found %d opened, %v closed", opened, reasons)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if stats := pool.Stats(); stats.MaxLifetimeClosed < 1 {
+		t.Fatalf("Expected the eviction to be counted, but found %+v", stats)
+	}
+
+	if replacement, err := pool.Get(context.Background()); err != nil || replacement == c || !replacement.IsAlive() {
+		t.Fatalf("Expected the replacement to be idle in the pool, but found %v", err)
+	} else {
+		pool.Put(replacement)
+	}
+}
+
+func TestPoolIdleTimeoutShrinks(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	pool := &Pool{
+		Config:              &ConnectionInfo{Address: server.Addr(), User: "dbadmin"},
+		MaxIdle:             3,
+		MinIdleConns:        1,
+		IdleTimeout:         30 * time.Millisecond,
+		HealthCheckInterval: 10 * time.Millisecond,
+	}
+	defer pool.Close()
+
+	var connections []*Connection
+	for i := 0; i < 3; i++ {
+		c, err := pool.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		connections = append(connections, c)
+	}
+	for _, c := range connections {
+		pool.Put(c)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().MaxIdleTimeClosed < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the idle connections to be closed, but found %+v", pool.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The pool stays at MinIdleConns over the next idle timeouts.
+	for i := 0; i < 10; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if stats := pool.Stats(); stats.OpenConnections > 1 {
+			t.Fatalf("Expected the pool to shrink to 1 connection, but found %+v", stats)
+		}
+	}
+}
+
+func TestPoolWarmup(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	pool := &Pool{Config: &ConnectionInfo{Address: server.Addr(), User: "dbadmin"}, MinIdleConns: 3}
+	defer pool.Close()
+
+	if err := pool.Warmup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := pool.Stats(); stats.Idle != 3 || stats.OpenConnections != 3 {
+		t.Fatalf("Expected three idle connections, but found %+v", stats)
+	}
+
+	server.RequirePassword("secret")
+	misconfigured := &Pool{Config: &ConnectionInfo{Address: server.Addr(), User: "dbadmin", Password: "wrong"}}
+	defer misconfigured.Close()
+	if err := misconfigured.Warmup(context.Background()); err == nil {
+		t.Fatalf("Expected warming up with a wrong password to fail")
+	}
+	if stats := misconfigured.Stats(); stats.OpenConnections != 0 {
+		t.Fatalf("Expected no connections to be open, but found %+v", stats)
+	}
+}
+
+func TestPoolClosesOutstanding(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT i FROM t").Columns(vertigotest.Column{Name: "i", Type: DataTypeInteger}).Row(1).Row(2)
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "a", Type: DataTypeInteger}).Row(1)
+
+	pool := &Pool{Config: &ConnectionInfo{Address: server.Addr(), User: "dbadmin"}, MaxOpen: 1}
+	defer pool.Close()
+
+	c1, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := c1.Prepare("SELECT i FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stmt.ExecutePortal(1); err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(c1)
+
+	c2, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Put(c2)
+	if c2 != c1 {
+		t.Fatalf("Expected the connection to be reused")
+	}
+	if prepared := server.PreparedStatements(); prepared != 0 {
+		t.Fatalf("Expected the statement to be closed, but found %d prepared statements", prepared)
+	}
+	if _, err := c2.Query("SELECT 1"); err != nil {
+		t.Fatalf("Expected the portal to be closed, but found %v", err)
+	}
+}
+
+// Records the pool events on top of the connection events.
+type poolCollector struct {
+	countingCollector
+	mu                  sync.Mutex
+	checkouts, checkins map[string]int
+	waited              time.Duration
+}
+
+func (c *poolCollector) ConnectionCheckedOut(pool string, wait time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkouts[pool]++
+	c.waited += wait
+}
+
+func (c *poolCollector) ConnectionCheckedIn(pool string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkins[pool]++
+}
+
+func TestPoolStatsCollector(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	collector := &poolCollector{checkouts: make(map[string]int), checkins: make(map[string]int)}
+	pool := &Pool{Config: &ConnectionInfo{Address: server.Addr(), User: "dbadmin", StatsCollector: collector}, Name: "reports", MaxOpen: 1}
+	defer pool.Close()
+
+	c1, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		pool.Put(c1)
+	}()
+	c2, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(c2)
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.checkouts["reports"] != 2 || collector.checkins["reports"] != 2 || len(collector.checkouts) != 1 {
+		t.Fatalf("Expected 2 checkouts and checkins of the pool, but found %v and %v", collector.checkouts, collector.checkins)
+	}
+	if collector.waited < 20*time.Millisecond {
+		t.Fatalf("Expected the second Get to wait, but found a wait of %s", collector.waited)
+	}
+}
+
+func TestPoolStatsCollectorRefill(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	var churn poolChurn
+	collector := &poolCollector{checkouts: make(map[string]int), checkins: make(map[string]int)}
+	pool := &Pool{
+		Config:              &ConnectionInfo{Address: server.Addr(), User: "dbadmin", StatsCollector: collector},
+		Name:                "reports",
+		MinIdleConns:        2,
+		MaxLifetime:         30 * time.Millisecond,
+		HealthCheckInterval: 10 * time.Millisecond,
+	}
+	churn.hook(pool)
+	defer pool.Close()
+
+	if err := pool.Warmup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the health checks to replace the expired connections.
+	deadline := time.Now().Add(time.Second)
+	for opened, _ := churn.counts(); opened < 4; opened, _ = churn.counts() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the expired connections to be replaced, but found %d opened", opened)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if len(collector.checkouts) != 0 || len(collector.checkins) != 0 {
+		t.Fatalf("Expected no checkouts or checkins, but found %v and %v", collector.checkouts, collector.checkins)
+	}
+}
+
+func TestPoolStatsCollectorWaitExcludesConnect(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	var slow atomic.Bool
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if slow.Load() {
+			time.Sleep(100 * time.Millisecond)
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}
+	collector := &poolCollector{checkouts: make(map[string]int), checkins: make(map[string]int)}
+	pool := &Pool{Config: &ConnectionInfo{Address: server.Addr(), User: "dbadmin", StatsCollector: collector, Dial: dial}, Name: "reports", MaxOpen: 1}
+	defer pool.Close()
+
+	c1, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow.Store(true)
+	go func() {
+		// A closed connection frees its slot, and the waiter opens a new one.
+		time.Sleep(20 * time.Millisecond)
+		c1.Close()
+		pool.Put(c1)
+	}()
+	c2, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(c2)
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.waited >= 100*time.Millisecond {
+		t.Fatalf("Expected the wait to exclude opening the connection, but found a wait of %s", collector.waited)
+	}
+	if wait := pool.Stats().WaitDuration; wait >= 100*time.Millisecond {
+		t.Fatalf("Expected the wait duration to exclude opening the connection, but found %s", wait)
+	}
+}
@@ -0,0 +1,80 @@
+package vertigo
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// The default interval between progress reports.
+const defaultProgressInterval = time.Second
+
+// The progress of a statement, as reported to the Progress function of a connection.
+type Progress struct {
+	SQL           string        // The statement, redacted like it is in logs
+	BytesSent     int64         // The number of bytes sent to the server for the statement, like the data of a COPY
+	BytesReceived int64         // The number of bytes received from the server for the statement
+	Rows          int64         // The number of rows received
+	Elapsed       time.Duration // The time since the statement started
+	Done          bool          // Whether the statement has completed; this is the last report for it
+}
+
+// Tracks the progress of the statement that is running, and reports it every
+// ProgressInterval. The counters are those of the connection statistics.
+type progressTracker struct {
+	c        *Connection
+	progress Progress
+	start    time.Time
+	reported time.Time
+	sent     int64 // The bytes sent by the connection before the statement started
+	received int64 // The bytes received by the connection before the statement started
+}
+
+// Starts tracking the progress of a statement. Returns nil if progress isn't reported.
+func (c *Connection) startProgress(sql string) *progressTracker {
+	if c.config.Progress == nil {
+		return nil
+	}
+
+	now := time.Now()
+	return &progressTracker{
+		c:        c,
+		progress: Progress{SQL: c.redact(sql)},
+		start:    now,
+		reported: now,
+		sent:     atomic.LoadInt64(&c.stats.bytesSent),
+		received: atomic.LoadInt64(&c.stats.bytesReceived),
+	}
+}
+
+// Reports the progress if the last report was at least ProgressInterval ago.
+func (p *progressTracker) update(rows int) {
+	if p == nil {
+		return
+	}
+
+	interval := p.c.config.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	if time.Since(p.reported) >= interval {
+		p.report(rows)
+	}
+}
+
+// Reports the final progress of the statement.
+func (p *progressTracker) finish(rows int) {
+	if p == nil {
+		return
+	}
+	p.progress.Done = true
+	p.report(rows)
+}
+
+func (p *progressTracker) report(rows int) {
+	p.reported = time.Now()
+	p.progress.Rows = int64(rows)
+	p.progress.Elapsed = p.reported.Sub(p.start)
+	p.progress.BytesSent = atomic.LoadInt64(&p.c.stats.bytesSent) - p.sent
+	p.progress.BytesReceived = atomic.LoadInt64(&p.c.stats.bytesReceived) - p.received
+	p.c.config.Progress(p.progress)
+}
@@ -0,0 +1,58 @@
+package vertigo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestProgress(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT GET_NUM_REJECTED_ROWS()").Columns(vertigotest.Column{Name: "GET_NUM_REJECTED_ROWS", Type: DataTypeInteger}).Row(0)
+	server.Expect("COPY t FROM STDIN").CopyIn()
+	server.Expect("SELECT a FROM t").Columns(vertigotest.Column{Name: "a"}).Row(1).Row(2).Row(3)
+
+	var reports []Progress
+	connection, err := Open(server.Addr(), WithUser("dbadmin"), WithProgress(time.Nanosecond, func(p Progress) { reports = append(reports, p) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	data := strings.Repeat("1\n", copyChunkSize)
+	if _, err := connection.CopyIn("COPY t FROM STDIN", strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) < 3 || reports[0].SQL != "COPY t FROM STDIN" || reports[0].Done {
+		t.Fatalf("Expected the COPY to be reported while it runs, but found %+v", reports)
+	}
+	var copied *Progress
+	for i := range reports {
+		if reports[i].SQL == "COPY t FROM STDIN" && reports[i].Done {
+			copied = &reports[i]
+		}
+	}
+	if copied == nil || copied.BytesSent < int64(len(data)) {
+		t.Fatalf("Expected a final report of the COPY with all data sent, but found %+v", copied)
+	}
+
+	reports = nil
+	if _, err := connection.Query("SELECT a FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Rows != 3 || last.BytesReceived == 0 || last.BytesSent == 0 || last.Elapsed <= 0 {
+		t.Fatalf("Expected a final report with all rows, but found %+v", last)
+	}
+	for _, report := range reports[:len(reports)-1] {
+		if report.Done {
+			t.Fatalf("Expected only the last report to be done, but found %+v", reports)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package vertigo
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Returns a proxy function for ConnectionInfo.Proxy that always returns proxy.
+func ProxyURL(proxy *url.URL) func(address string) (*url.URL, error) {
+	return func(string) (*url.URL, error) {
+		return proxy, nil
+	}
+}
+
+// A proxy function for ConnectionInfo.Proxy that uses the proxy configured with the
+// HTTPS_PROXY or ALL_PROXY environment variables, or their lower case versions, in
+// that order. Addresses whose host matches NO_PROXY are connected to directly. Proxy
+// URLs without a scheme are HTTP proxies.
+func ProxyFromEnvironment(address string) (*url.URL, error) {
+	proxy := getenv("HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy")
+	if proxy == "" || noProxy(address, getenv("NO_PROXY", "no_proxy")) {
+		return nil, nil
+	}
+
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid proxy URL: %w", err)
+	}
+	return u, nil
+}
+
+// Returns the value of the first of the environment variables that is set.
+func getenv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Returns whether address should be connected to directly according to the
+// comma-separated NO_PROXY patterns. A pattern matches a host name and its
+// subdomains, an IP address, or a CIDR range, optionally with a port.
+func noProxy(address, patterns string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "":
+			continue
+		case pattern == "*":
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(pattern); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(pattern); err == nil {
+			if p != port {
+				continue
+			}
+			pattern = h
+		}
+		if pattern = strings.TrimPrefix(pattern, "*"); strings.HasPrefix(pattern, ".") {
+			pattern = pattern[1:]
+		}
+		if host == pattern || strings.HasSuffix(host, "."+pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Opens a connection to address through the proxy, which is connected to with dial.
+// Supported are SOCKS5 proxies, and HTTP(S) proxies that support CONNECT.
+func dialProxy(ctx context.Context, dial DialFunc, proxy *url.URL, address string) (net.Conn, error) {
+	proxyAddress := proxy.Host
+	if proxy.Port() == "" {
+		defaultPort := map[string]string{"socks5": "1080", "socks5h": "1080", "http": "80", "https": "443"}[proxy.Scheme]
+		proxyAddress = net.JoinHostPort(proxy.Hostname(), defaultPort)
+	}
+
+	conn, err := dial(ctx, "tcp", proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	switch proxy.Scheme {
+	case "socks5", "socks5h":
+		err = socksConnect(ctx, conn, proxy, address)
+	case "https":
+		conn = tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+		fallthrough
+	case "http":
+		err = httpConnect(conn, proxy, address)
+	default:
+		err = fmt.Errorf("unsupported scheme %q", proxy.Scheme)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Cannot connect to %s through proxy %s: %w", address, proxyAddress, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// The replies of a SOCKS5 server to a CONNECT request, as defined in RFC 1928.
+var socksReplies = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// Asks the SOCKS5 server on conn to connect to address. Host names are resolved by the
+// proxy with the socks5h scheme, and by the client with socks5.
+func socksConnect(ctx context.Context, conn net.Conn, proxy *url.URL, address string) error {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", portString)
+	}
+
+	// Negotiate the authentication method: none, or username and password (RFC 1929).
+	methods := []byte{0x00}
+	if proxy.User != nil {
+		methods = []byte{0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 || reply[1] != methods[0] {
+		return errors.New("no acceptable SOCKS authentication method")
+	}
+	if methods[0] == 0x02 {
+		user := proxy.User.Username()
+		password, _ := proxy.User.Password()
+		if len(user) > 255 || len(password) > 255 {
+			return errors.New("SOCKS user name or password too long")
+		}
+		request := append([]byte{0x01, byte(len(user))}, user...)
+		request = append(append(request, byte(len(password))), password...)
+		if _, err := conn.Write(request); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return errors.New("SOCKS authentication failed")
+		}
+	}
+
+	request := []byte{0x05, 0x01, 0x00}
+	ip := net.ParseIP(host)
+	if ip == nil && proxy.Scheme == "socks5" {
+		addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return err
+		}
+		ip = addresses[0].IP
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q too long", host)
+		}
+		request = append(append(request, 0x03, byte(len(host))), host...)
+	case ip.To4() != nil:
+		request = append(append(request, 0x01), ip.To4()...)
+	default:
+		request = append(append(request, 0x04), ip.To16()...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	// The reply holds the address the proxy bound to, which isn't needed.
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0x00 {
+		if message, ok := socksReplies[header[1]]; ok {
+			return errors.New(message)
+		}
+		return fmt.Errorf("SOCKS reply %d", header[1])
+	}
+	var skip int
+	switch header[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		skip = int(length[0]) + 2
+	default:
+		return fmt.Errorf("unknown SOCKS address type %d", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip))
+	return err
+}
+
+// Asks the HTTP proxy on conn to open a tunnel to address with a CONNECT request.
+func httpConnect(conn net.Conn, proxy *url.URL, address string) error {
+	request := "CONNECT " + address + " HTTP/1.1\r\nHost: " + address + "\r\n"
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		request += "Proxy-Authorization: Basic " + credentials + "\r\n"
+	}
+	if _, err := conn.Write([]byte(request + "\r\n")); err != nil {
+		return err
+	}
+
+	// The response is read a byte at a time, so nothing after its header is consumed.
+	var response []byte
+	b := make([]byte, 1)
+	for !strings.HasSuffix(string(response), "\r\n\r\n") {
+		if len(response) > 8192 {
+			return errors.New("proxy response header too long")
+		}
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return err
+		}
+		response = append(response, b[0])
+	}
+
+	status := strings.SplitN(strings.SplitN(string(response), "\r\n", 2)[0], " ", 2)
+	if len(status) != 2 || !strings.HasPrefix(status[0], "HTTP/") {
+		return fmt.Errorf("malformed proxy response %q", status)
+	}
+	if !strings.HasPrefix(status[1], "200") {
+		return fmt.Errorf("proxy responded %s", status[1])
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package vertigo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+// Starts a proxy that hands every connection it accepts to handshake, which returns the
+// address to connect to, and then forwards the traffic.
+func startTestProxy(t *testing.T, handshake func(conn net.Conn) string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				target, err := net.Dial("tcp", handshake(conn))
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// The handshake of a SOCKS5 proxy that requires user "u" with password "p".
+func socksHandshake(conn net.Conn) string {
+	buf := make([]byte, 262)
+	io.ReadFull(conn, buf[:2])
+	io.ReadFull(conn, buf[:buf[1]])
+	conn.Write([]byte{0x05, 0x02})
+
+	io.ReadFull(conn, buf[:2])
+	user := make([]byte, buf[1])
+	io.ReadFull(conn, user)
+	io.ReadFull(conn, buf[:1])
+	password := make([]byte, buf[0])
+	io.ReadFull(conn, password)
+	if string(user) != "u" || string(password) != "p" {
+		conn.Write([]byte{0x01, 0x01})
+		return ""
+	}
+	conn.Write([]byte{0x01, 0x00})
+
+	io.ReadFull(conn, buf[:4])
+	io.ReadFull(conn, buf[:1])
+	length := int(buf[0])
+	io.ReadFull(conn, buf[:length+2])
+	host := string(buf[:length])
+	port := binary.BigEndian.Uint16(buf[length:])
+	conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// The handshake of an HTTP proxy that supports CONNECT.
+func httpHandshake(conn net.Conn) string {
+	reader := bufio.NewReader(conn)
+	line, _ := reader.ReadString('\n')
+	for header := line; header != "\r\n" && header != ""; {
+		header, _ = reader.ReadString('\n')
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[0] != "CONNECT" {
+		conn.Write([]byte("HTTP/1.1 405 Method Not Allowed\r\n\r\n"))
+		return ""
+	}
+	conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	return fields[1]
+}
+
+func TestProxy(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Addr())
+	address := net.JoinHostPort("localhost", port)
+
+	proxies := []string{
+		"socks5h://u:p@" + startTestProxy(t, socksHandshake),
+		"http://" + startTestProxy(t, httpHandshake),
+	}
+	for _, proxy := range proxies {
+		u, _ := url.Parse(proxy)
+		connection, err := Connect(&ConnectionInfo{Address: address, User: "dbadmin", Proxy: ProxyURL(u)})
+		if err != nil {
+			t.Fatalf("Expected to connect through %s, but found %v", proxy, err)
+		}
+		connection.Close()
+	}
+
+	u, _ := url.Parse("socks5h://u:wrong@" + startTestProxy(t, socksHandshake))
+	if _, err := Connect(&ConnectionInfo{Address: address, User: "dbadmin", Proxy: ProxyURL(u)}); err == nil || !strings.Contains(err.Error(), "SOCKS authentication failed") {
+		t.Fatalf("Expected the proxy to reject the credentials, but found %v", err)
+	}
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("ALL_PROXY", "socks5://proxy:1080")
+	t.Setenv("NO_PROXY", "localhost, .internal,10.0.0.0/8, db:5434")
+
+	tests := []struct {
+		address string
+		proxied bool
+	}{
+		{"vertica:5433", true},
+		{"localhost:5433", false},
+		{"db.internal:5433", false},
+		{"internal:5433", false},
+		{"10.1.2.3:5433", false},
+		{"11.1.2.3:5433", true},
+		{"db:5433", true},
+		{"db:5434", false},
+	}
+	for _, test := range tests {
+		u, err := ProxyFromEnvironment(test.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (u != nil) != test.proxied {
+			t.Fatalf("Expected %s to be proxied: %v, but found proxy %v", test.address, test.proxied, u)
+		}
+		if u != nil && u.String() != "socks5://proxy:1080" {
+			t.Fatalf("Expected the ALL_PROXY proxy, but found %v", u)
+		}
+	}
+
+	t.Setenv("HTTPS_PROXY", "proxy:3128")
+	if u, _ := ProxyFromEnvironment("vertica:5433"); u == nil || u.String() != "http://proxy:3128" {
+		t.Fatalf("Expected HTTPS_PROXY to take precedence, but found %v", u)
+	}
+}
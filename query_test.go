@@ -1,9 +1,15 @@
 package vertigo
 
 import (
+	"context"
+	"errors"
+	"io"
 	"log"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
 )
 
 func getConnection(t *testing.T) Connection {
@@ -108,3 +114,312 @@ func TestQueryWithSyntaxError(t *testing.T) {
 		t.Fatal("Expected an error response")
 	}
 }
+
+func TestQueryWithClientTimeout(t *testing.T) {
+	info := defaultConnectionInfo()
+	info.ClientTimeout = 100 * time.Millisecond
+
+	connection, err := Connect(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if _, err := connection.Query("SELECT SLEEP(5)"); !errors.Is(err, ErrClientTimeout) {
+		t.Fatalf("Expected a client timeout error, but found %#+v", err)
+	}
+
+	if _, err := connection.Query("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryWithCancelledContext(t *testing.T) {
+	connection := getConnection(t)
+	defer connection.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := connection.QueryContext(ctx, "SELECT SLEEP(5)"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the context error, but found %#+v", err)
+	}
+
+	if _, err := connection.Query("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryWithIdleValidation(t *testing.T) {
+	info := defaultConnectionInfo()
+	info.ValidateAfterIdle = time.Nanosecond
+
+	connection, err := Connect(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	time.Sleep(time.Millisecond)
+	if _, err := connection.Query("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryRow(t *testing.T) {
+	connection := getConnection(t)
+	defer connection.Close()
+
+	row, err := connection.QueryRow("SELECT ?, ?", "test", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		s string
+		i int
+	)
+	if err := row.Scan(&s, &i); err != nil {
+		t.Fatal(err)
+	}
+
+	if s != "test" || i != 42 {
+		t.Fatalf("Unexpected values %q and %d", s, i)
+	}
+
+	if _, err := connection.QueryRow("SELECT 1 LIMIT 0"); err != ErrNoRows {
+		t.Fatalf("Expected ErrNoRows, but found %#+v", err)
+	}
+}
+
+func TestQueryValue(t *testing.T) {
+	connection := getConnection(t)
+	defer connection.Close()
+
+	count, err := QueryValue[int64](&connection, "SELECT COUNT(*) FROM (SELECT 1 UNION ALL SELECT 2) t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected count to be 2, but found %d", count)
+	}
+}
+
+func TestExec(t *testing.T) {
+	connection := getConnection(t)
+	defer connection.Close()
+
+	if _, err := connection.Exec("CREATE LOCAL TEMPORARY TABLE exec_test (id INT) ON COMMIT PRESERVE ROWS"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := connection.Exec("INSERT INTO exec_test SELECT 1 UNION ALL SELECT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Command() != "INSERT" {
+		t.Fatalf("Expected an INSERT command tag, but found %q", result.CommandTag)
+	}
+}
+
+func TestExecutePortal(t *testing.T) {
+	connection := getConnection(t)
+	defer connection.Close()
+
+	stmt, err := connection.Prepare("SELECT * FROM (SELECT 1 AS i UNION ALL SELECT 2 UNION ALL SELECT 3) t WHERE i > ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	portal, err := stmt.ExecutePortal(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batches int
+	for {
+		rows, err := portal.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 1 {
+			t.Fatalf("Expected batches of 1 row, but found %d rows", len(rows))
+		}
+		batches++
+	}
+
+	if batches != 3 {
+		t.Fatalf("Expected 3 batches, but found %d", batches)
+	}
+}
+
+func TestQueryMulti(t *testing.T) {
+	connection := getConnection(t)
+	defer connection.Close()
+
+	resultsets, err := connection.QueryMulti("SELECT 1; SELECT 2, 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resultsets) != 2 || len(resultsets[0].Fields) != 1 || len(resultsets[1].Fields) != 2 {
+		t.Fatalf("Unexpected resultsets %+v", resultsets)
+	}
+}
+
+func TestPortalAll(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT i FROM t WHERE i > ?").Columns(vertigotest.Column{Name: "i", Type: DataTypeInteger}).Row(1).Row(2).Row(3)
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "one", Type: DataTypeInteger}).Row(1)
+
+	connection, err := Open(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	stmt, err := connection.Prepare("SELECT i FROM t WHERE i > ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	portal, err := stmt.ExecutePortal(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []int64
+	for row, err := range portal.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		var i int64
+		if err := row.Scan(&i); err != nil {
+			t.Fatal(err)
+		}
+		if values = append(values, i); i == 2 {
+			break
+		}
+	}
+	if len(values) != 2 || values[1] != 2 {
+		t.Fatalf("Expected the loop to stop after row 2, but found %v", values)
+	}
+
+	if _, err := connection.Query("SELECT 1"); err != nil {
+		t.Fatalf("Expected the portal to be closed after the loop, but found %v", err)
+	}
+}
+
+func TestStmtSurvivesReconnect(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT i FROM t WHERE i > ?").Columns(vertigotest.Column{Name: "i", Type: DataTypeInteger}).Row(1).Row(2)
+
+	connection, err := Open(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	stmt, err := connection.Prepare("SELECT i FROM t WHERE i > ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := connection.Reconnect(); err != nil {
+			t.Fatal(err)
+		}
+
+		portal, err := stmt.ExecutePortal(0, 0)
+		if err != nil {
+			t.Fatalf("Expected the statement to be prepared again, but found %v", err)
+		}
+		rows := 0
+		for _, err := range portal.All() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows++
+		}
+		if rows != 2 {
+			t.Fatalf("Expected 2 rows, but found %d", rows)
+		}
+	}
+
+	if err := stmt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stmt.ExecutePortal(0, 0); err != ErrStmtClosed {
+		t.Fatalf("Expected ErrStmtClosed, but found %v", err)
+	}
+}
+
+func TestCloseOutstanding(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT i FROM t").Columns(vertigotest.Column{Name: "i", Type: DataTypeInteger}).Row(1).Row(2)
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "a", Type: DataTypeInteger}).Row(1)
+
+	connection, err := Open(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	stmt, err := connection.Prepare("SELECT i FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err := connection.Prepare("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := closed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stmt.ExecutePortal(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The driver keeps the statements, but closes the portal.
+	if err := (&driverConn{c: connection}).ResetSession(context.Background()); err != nil {
+		t.Fatalf("Expected the portal to be closed, but found %v", err)
+	}
+	if prepared := server.PreparedStatements(); prepared != 1 {
+		t.Fatalf("Expected 1 prepared statement, but found %d", prepared)
+	}
+
+	if _, err := stmt.ExecutePortal(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := connection.closeOutstanding(true); err != nil {
+		t.Fatal(err)
+	}
+	if prepared := server.PreparedStatements(); prepared != 0 {
+		t.Fatalf("Expected the statements to be closed, but found %d", prepared)
+	}
+	if _, err := stmt.ExecutePortal(0); err != ErrStmtClosed {
+		t.Fatalf("Expected ErrStmtClosed, but found %v", err)
+	}
+	if _, err := connection.Query("SELECT 1"); err != nil {
+		t.Fatalf("Expected the connection to be usable, but found %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrReadOnly = errors.New("Statement is not allowed on a read-only connection")
+
+// The first keywords of statements that obviously modify data, the catalog or files
+// on the cluster. Statements like SELECT of a function with side effects aren't caught;
+// the server rejects those in a read-only session.
+var mutatingKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "COPY": true, "TRUNCATE": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "GRANT": true, "REVOKE": true, "COMMENT": true,
+	"EXPORT": true,
+}
+
+// Returns the first keyword of the first statement in sql that obviously modifies data,
+// or an empty string if there is none.
+func mutatingStatement(sql string, standardStrings bool) string {
+	for _, keyword := range statementKeywords(sql, standardStrings) {
+		if mutatingKeywords[keyword] {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// Returns the first keyword of every statement in sql, in upper case. Leading comments
+// and parentheses are skipped, and statements that don't start with a keyword are left out.
+func statementKeywords(sql string, standardStrings bool) []string {
+	var keywords []string
+	for _, words := range statementWords(sql, standardStrings, 1) {
+		keywords = append(keywords, words[0])
+	}
+	return keywords
+}
+
+// Returns up to n leading keywords of every statement in sql, in upper case, like
+// statementKeywords. The keywords after the first one may be separated by whitespace
+// and comments only.
+func statementWords(sql string, standardStrings bool, n int) [][]string {
+	var statements [][]string
+	start := true
+	more := false // Whether the next word belongs to the keywords of the statement
+	for i := 0; i < len(sql); {
+		if end := skipNonCode(sql, i, standardStrings); end > i {
+			if sql[i] != '-' && sql[i] != '/' {
+				start, more = false, false
+			}
+			i = end
+			continue
+		}
+
+		switch ch := sql[i]; {
+		case ch == ';':
+			start, more = true, false
+			i++
+
+		case start && ch == '(', (start || more) && (ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n'):
+			i++
+
+		case (start || more) && isNameChar(ch, true):
+			end := i + 1
+			for end < len(sql) && isNameChar(sql[end], false) {
+				end++
+			}
+			word := strings.ToUpper(sql[i:end])
+			if start {
+				statements = append(statements, []string{word})
+			} else {
+				last := len(statements) - 1
+				statements[last] = append(statements[last], word)
+			}
+			more = len(statements[len(statements)-1]) < n
+			start = false
+			i = end
+
+		default:
+			start, more = false, false
+			i++
+		}
+	}
+	return statements
+}
+
+// Returns an error matching ErrReadOnly if the connection is read-only and the statement
+// obviously modifies data, unless the check is left to the server.
+func (c *Connection) checkReadOnly(sql string) error {
+	if !c.config.ReadOnly || c.config.SkipReadOnlyCheck {
+		return nil
+	}
+	if keyword := mutatingStatement(sql, c.standardConformingStrings()); keyword != "" {
+		return fmt.Errorf("%w: %s", ErrReadOnly, keyword)
+	}
+	return nil
+}
+
+// Makes the session that was just opened read-only, if configured. This function will
+// panic if the session characteristics cannot be set.
+func (c *Connection) applyReadOnly() {
+	if !c.config.ReadOnly {
+		return
+	}
+
+	if err := c.execInternal("SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY", &discardHandler{}); err != nil {
+		panic(fmt.Errorf("Cannot make the session read-only: %w", err))
+	}
+}
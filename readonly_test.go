@@ -0,0 +1,78 @@
+package vertigo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestMutatingStatement(t *testing.T) {
+	tests := map[string]string{
+		"SELECT * FROM t":                                "",
+		"insert into t values (1)":                       "INSERT",
+		"  -- load\n/* x */ COPY t FROM STDIN":           "COPY",
+		"(SELECT 1) UNION (SELECT 2)":                    "",
+		"SELECT 1; DROP TABLE t":                         "DROP",
+		"SELECT 'a; DELETE FROM t'":                      "",
+		`SELECT "x;update"`:                              "",
+		"SELECT update_time FROM t":                      "",
+		"WITH a AS (SELECT 1) SELECT * FROM a":           "",
+		"CREATE TABLE t (a INT)":                         "CREATE",
+		"EXPORT TO PARQUET (directory='/d') AS SELECT 1": "EXPORT",
+	}
+	for sql, expected := range tests {
+		if keyword := mutatingStatement(sql, true); keyword != expected {
+			t.Fatalf("Expected %q for %q, but found %q", expected, sql, keyword)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY").Tag("SET")
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "a"}).Row(1)
+	server.Expect("DELETE FROM t").Error(ErrCodeReadOnlySQLTransaction, "Cannot issue this command in a read-only transaction")
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"), WithReadOnly(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if _, err := connection.Query("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connection.Exec("DELETE FROM t"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, but found %v", err)
+	}
+	if _, err := connection.Prepare("INSERT INTO t VALUES (?)"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, but found %v", err)
+	}
+	if !connection.IsAlive() {
+		t.Fatalf("Expected a rejected statement not to break the connection")
+	}
+
+	unchecked, err := Open(server.Addr(), WithUser("dbadmin"), WithReadOnly(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unchecked.Close()
+
+	if _, err := unchecked.Exec("DELETE FROM t"); err == nil || errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Expected the server to reject the statement, but found %v", err)
+	}
+
+	expected := []string{
+		"SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY", "SELECT 1",
+		"SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY", "DELETE FROM t",
+	}
+	if statements := server.Statements(); !reflect.DeepEqual(statements, expected) {
+		t.Fatalf("Expected the mutating statements not to be sent, but found %q", statements)
+	}
+}
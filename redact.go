@@ -0,0 +1,129 @@
+package vertigo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"regexp"
+)
+
+// Redactor scrubs application-specific sensitive data from SQL text before it is
+// logged. It is applied after the built-in redaction of passwords.
+type Redactor func(sql string) string
+
+const redacted = "'***'"
+
+// Matches the literals that hold passwords in statements like CREATE USER, ALTER USER
+// and CONNECT TO VERTICA, e.g. IDENTIFIED BY 'secret' REPLACE 'old' and PASSWORD 'secret'.
+var passwordLiteral = regexp.MustCompile(`(?i)(\b(?:IDENTIFIED\s+BY|REPLACE|PASSWORD)\s+)('(?:[^']|'')*'|"(?:[^"]|"")*")`)
+
+// Returns the SQL text with passwords and any data scrubbed by the Redactor of the
+// connection replaced, so it can be logged.
+func (c *Connection) redact(sql string) string {
+	sql = passwordLiteral.ReplaceAllString(sql, "${1}"+redacted)
+	if c.config.Redactor != nil {
+		sql = c.config.Redactor(sql)
+	}
+	return sql
+}
+
+// Returns a copy of an outgoing message that is safe to log.
+func (c *Connection) redactMessage(msg OutgoingMessage) OutgoingMessage {
+	switch msg := msg.(type) {
+	case PasswordMessage:
+		msg.Password = "***"
+		return msg
+	case QueryMessage:
+		msg.SQL = c.redact(msg.SQL)
+		return msg
+	case ParseMessage:
+		msg.SQL = c.redact(msg.SQL)
+		return msg
+	case BindMessage:
+		msg.Values = redactValues(msg.Values)
+		return msg
+	default:
+		return msg
+	}
+}
+
+// Returns parameter values with the values that aren't NULL replaced by ***.
+func redactValues(values [][]byte) [][]byte {
+	masked := make([][]byte, len(values))
+	for i, value := range values {
+		if value != nil {
+			masked[i] = []byte("***")
+		}
+	}
+	return masked
+}
+
+// Returns the body of an outgoing message that is safe to dump.
+func (c *Connection) redactMessageBody(messageType byte, body []byte) []byte {
+	switch messageType {
+	case 'p':
+		return []byte("***")
+	case 'Q':
+		return append([]byte(c.redact(string(body[:len(body)-1]))), 0)
+	case 'P':
+		// The name of the statement, its SQL, and the parameter types.
+		name := bytes.IndexByte(body, 0)
+		end := name + 1 + bytes.IndexByte(body[name+1:], 0)
+		if name < 0 || end <= name {
+			return []byte("***")
+		}
+		redacted := append([]byte(nil), body[:name+1]...)
+		redacted = append(redacted, c.redact(string(body[name+1:end]))...)
+		return append(redacted, body[end:]...)
+	case 'B':
+		if redacted, ok := redactBindBody(body); ok {
+			return redacted
+		}
+		return []byte("***")
+	default:
+		return body
+	}
+}
+
+// Returns the body of a Bind message with the parameter values masked like
+// redactValues does, or false if it can't be parsed.
+func redactBindBody(body []byte) ([]byte, bool) {
+	// The names of the portal and the statement, and the parameter format codes.
+	offset := 0
+	for i := 0; i < 2; i++ {
+		end := bytes.IndexByte(body[offset:], 0)
+		if end < 0 {
+			return nil, false
+		}
+		offset += end + 1
+	}
+	if len(body) < offset+2 {
+		return nil, false
+	}
+	offset += 2 + 2*int(binary.BigEndian.Uint16(body[offset:]))
+	if len(body) < offset+2 {
+		return nil, false
+	}
+	count := int(binary.BigEndian.Uint16(body[offset:]))
+	offset += 2
+
+	redacted := append([]byte(nil), body[:offset]...)
+	for i := 0; i < count; i++ {
+		if len(body) < offset+4 {
+			return nil, false
+		}
+		length := int32(binary.BigEndian.Uint32(body[offset:]))
+		offset += 4
+		if length < 0 {
+			redacted = binary.BigEndian.AppendUint32(redacted, uint32(length))
+			continue
+		}
+		if len(body) < offset+int(length) {
+			return nil, false
+		}
+		offset += int(length)
+		redacted = binary.BigEndian.AppendUint32(redacted, 3)
+		redacted = append(redacted, "***"...)
+	}
+	// The result format codes.
+	return append(redacted, body[offset:]...), true
+}
@@ -0,0 +1,80 @@
+package vertigo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	c := &Connection{config: &ConnectionInfo{}}
+
+	tests := map[string]string{
+		"CREATE USER u IDENTIFIED BY 'it''s secret'":        "CREATE USER u IDENTIFIED BY '***'",
+		"alter user u identified by 'new' replace 'old';":   "alter user u identified by '***' replace '***';",
+		"CONNECT TO VERTICA db USER u PASSWORD 'pw' ON 'h'": "CONNECT TO VERTICA db USER u PASSWORD '***' ON 'h'",
+		"SELECT password FROM users":                        "SELECT password FROM users",
+	}
+	for sql, expected := range tests {
+		if redacted := c.redact(sql); redacted != expected {
+			t.Errorf("Expected %q, but found %q", expected, redacted)
+		}
+	}
+
+	c.config.Redactor = func(sql string) string { return strings.Replace(sql, "4111111111111111", "****", -1) }
+	if redacted := c.redact("SELECT * FROM cards WHERE number = '4111111111111111'"); strings.Contains(redacted, "4111") {
+		t.Errorf("Expected the Redactor to be applied, but found %q", redacted)
+	}
+}
+
+func TestRedactPasswordMessage(t *testing.T) {
+	msg := PasswordMessage{AuthenticationMethod: AuthenticationCleartextPassword, Password: "secret"}
+	for _, format := range []string{"%v", "%+v", "%#+v", "%s"} {
+		if formatted := fmt.Sprintf(format, msg); strings.Contains(formatted, "secret") {
+			t.Errorf("Expected the password to be redacted with %s, but found %q", format, formatted)
+		}
+	}
+
+	var buffer bytes.Buffer
+	c := &Connection{config: &ConnectionInfo{WireDump: &buffer}}
+	c.writeMessageTo(io.Discard, msg)
+	if strings.Contains(buffer.String(), "secret") || strings.Contains(buffer.String(), "73 65 63") {
+		t.Errorf("Expected the password to be redacted from the dump, but found %q", buffer.String())
+	}
+
+	if logged := c.redactMessage(QueryMessage{SQL: "ALTER USER u IDENTIFIED BY 'secret'"}); strings.Contains(fmt.Sprintf("%#+v", logged), "secret") {
+		t.Errorf("Expected the password to be redacted from the query, but found %#+v", logged)
+	}
+}
+
+func TestRedactExtendedQueryMessages(t *testing.T) {
+	var buffer bytes.Buffer
+	c := &Connection{config: &ConnectionInfo{WireDump: &buffer}}
+	c.writeMessageTo(io.Discard, ParseMessage{Name: "s1", SQL: "ALTER USER u IDENTIFIED BY 'secret'"})
+	c.writeMessageTo(io.Discard, BindMessage{Statement: "s1", Values: [][]byte{[]byte("secret"), nil}})
+	if strings.Contains(buffer.String(), "secret") || strings.Contains(buffer.String(), "73 65 63") {
+		t.Errorf("Expected the password and the values to be redacted from the dump, but found %q", buffer.String())
+	}
+	if !strings.Contains(buffer.String(), "ALTER USER u ") || !strings.Contains(buffer.String(), "2a 2a 2a ff") {
+		t.Errorf("Expected the rest of the messages to be dumped, but found %q", buffer.String())
+	}
+
+	body := []byte("\x00s1\x00\x00\x00\x00\x02\x00\x00\x00\x06secret\xff\xff\xff\xff\x00\x00")
+	expected := []byte("\x00s1\x00\x00\x00\x00\x02\x00\x00\x00\x03***\xff\xff\xff\xff\x00\x00")
+	if redacted := c.redactMessageBody('B', body); !bytes.Equal(redacted, expected) {
+		t.Errorf("Expected %q, but found %q", expected, redacted)
+	}
+	if redacted := c.redactMessageBody('B', body[:12]); !bytes.Equal(redacted, []byte("***")) {
+		t.Errorf("Expected a truncated message to be redacted entirely, but found %q", redacted)
+	}
+
+	logged := c.redactMessage(BindMessage{Values: [][]byte{[]byte("secret"), nil}})
+	if formatted := fmt.Sprintf("%#+v", logged); strings.Contains(formatted, "115, 101, 99") {
+		t.Errorf("Expected the values to be redacted from the message, but found %s", formatted)
+	}
+	if values := logged.(BindMessage).Values; values[1] != nil {
+		t.Errorf("Expected NULL to be kept, but found %q", values[1])
+	}
+}
@@ -0,0 +1,91 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// HostResolver returns the addresses of the nodes of a cluster, including their ports,
+// for connections to try in order. It is asked every time a connection is opened, so it
+// can follow a dynamic set of nodes, like those registered in Consul or behind a
+// Kubernetes headless service.
+type HostResolver interface {
+	ResolveHosts(ctx context.Context) ([]string, error)
+}
+
+// An adapter to use a function as a HostResolver.
+type HostResolverFunc func(ctx context.Context) ([]string, error)
+
+func (f HostResolverFunc) ResolveHosts(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+// Resolves the nodes of a cluster from DNS SRV records, as described in RFC 2782. The
+// records of _Service._Proto.Name are looked up, e.g. _vertica._tcp.example.com; when
+// Service and Proto are empty, Name is looked up as it is. The nodes are returned in
+// the order of their priority, and randomized by their weight.
+type SRVResolver struct {
+	Service string
+	Proto   string
+	Name    string
+
+	// The resolver to use. Nil uses net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+func (r *SRVResolver) ResolveHosts(ctx context.Context) ([]string, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	_, records, err := resolver.LookupSRV(ctx, r.Service, r.Proto, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	return srvAddresses(records), nil
+}
+
+// Returns the addresses of the targets of SRV records, leaving out the "." target that
+// means the service isn't available.
+func srvAddresses(records []*net.SRV) []string {
+	addresses := make([]string, 0, len(records))
+	for _, record := range records {
+		if target := strings.TrimSuffix(record.Target, "."); target != "" {
+			addresses = append(addresses, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+		}
+	}
+	return addresses
+}
+
+// Returns the addresses configured for the cluster: those returned by the HostResolver,
+// if any, or else the Address. When resolving fails, the Address is used if it is set.
+func (c *Connection) configuredAddresses() ([]string, error) {
+	if c.config.HostResolver == nil {
+		return []string{c.config.Address}, nil
+	}
+
+	ctx := context.Background()
+	if c.config.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.ConnectTimeout)
+		defer cancel()
+	}
+
+	addresses, err := c.config.HostResolver.ResolveHosts(ctx)
+	if err == nil && len(addresses) == 0 {
+		err = errors.New("no hosts found")
+	}
+	if err != nil {
+		if c.config.Address == "" {
+			return nil, fmt.Errorf("Cannot resolve the hosts of the cluster: %w", err)
+		}
+		c.log(LogLevelWarn, "Cannot resolve the hosts of the cluster", "address", c.config.Address, "error", err)
+		return []string{c.config.Address}, nil
+	}
+	return addresses, nil
+}
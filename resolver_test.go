@@ -0,0 +1,58 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestHostResolver(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	resolved := []string{"127.0.0.1:1", server.Addr()}
+	resolver := HostResolverFunc(func(ctx context.Context) ([]string, error) {
+		return resolved, nil
+	})
+	connection, err := Connect(&ConnectionInfo{User: "dbadmin", HostResolver: resolver})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connection.address != server.Addr() {
+		t.Fatalf("Expected to connect to the first reachable host, but found %s", connection.address)
+	}
+	connection.Close()
+
+	failing := HostResolverFunc(func(ctx context.Context) ([]string, error) {
+		return nil, errors.New("lookup failed")
+	})
+	connection, err = Connect(&ConnectionInfo{Address: server.Addr(), User: "dbadmin", HostResolver: failing})
+	if err != nil {
+		t.Fatalf("Expected to fall back to the Address, but found %v", err)
+	}
+	connection.Close()
+
+	if _, err := Connect(&ConnectionInfo{User: "dbadmin", HostResolver: failing}); err == nil || !strings.Contains(err.Error(), "lookup failed") {
+		t.Fatalf("Expected the resolver error without an Address, but found %v", err)
+	}
+}
+
+func TestSRVAddresses(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "node1.vertica.example.com.", Port: 5433, Priority: 10},
+		{Target: "node2.vertica.example.com.", Port: 5434, Priority: 20},
+		{Target: ".", Port: 0},
+	}
+	expected := []string{"node1.vertica.example.com:5433", "node2.vertica.example.com:5434"}
+	if addresses := srvAddresses(records); !reflect.DeepEqual(addresses, expected) {
+		t.Fatalf("Expected %q, but found %q", expected, addresses)
+	}
+}
@@ -1,13 +1,66 @@
 package vertigo
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The rows of a query, and the metadata describing them.
+//
+// If the resultset was spilled to disk because it exceeded the resultset limits of
+// the connection, Rows only holds the rows received before the limits were reached.
+// Use EachRow to visit all rows, and Close to remove the temporary file.
 type Resultset struct {
 	Fields []Field
 	Rows   []Row
 	Result string
+
+	spill *spillFile // The rows that didn't fit in memory, if any
+}
+
+// Returns the parsed command tag the server sent when the statement completed.
+func (rs *Resultset) CommandTag() CommandTag {
+	return CommandTag(rs.Result)
+}
+
+// Returns the index of the column with the given name. Names are matched exactly
+// first, and case-insensitively if that fails. The second return value is false
+// if there is no such column.
+func (rs *Resultset) ColumnIndex(name string) (int, bool) {
+	return columnIndex(rs.Fields, name)
 }
 
 type Row struct {
 	Values [][]byte
+
+	fields []Field // The fields of the resultset the row belongs to, used to decode values
+}
+
+// Returns the raw value of the column with the given name. The second return
+// value is false if there is no such column. See Resultset.ColumnIndex for how
+// names are matched.
+func (r Row) Get(fields []Field, name string) ([]byte, bool) {
+	if i, ok := columnIndex(fields, name); ok && i < len(r.Values) {
+		return r.Values[i], true
+	}
+	return nil, false
+}
+
+func columnIndex(fields []Field, name string) (int, bool) {
+	for i, field := range fields {
+		if field.Name == name {
+			return i, true
+		}
+	}
+
+	for i, field := range fields {
+		if strings.EqualFold(field.Name, name) {
+			return i, true
+		}
+	}
+	return -1, false
 }
 
 type Field struct {
@@ -18,4 +71,174 @@ type Field struct {
 	DataTypeSize    uint16
 	TypeModifier    uint32
 	FormatCode      uint16
+
+	location *time.Location  // The session time zone to decode timestamps in
+	coercion *coercionPolicy // How lossy conversions are reported
+}
+
+// The result of a statement run with Exec.
+type Result struct {
+	CommandTag
+}
+
+// Receives the parts of a resultset as they are read from the server.
+type resultHandler interface {
+	handleFields(fields []Field)
+	handleRow(values [][]byte)
+	handleComplete(result string)
+}
+
+// Implemented by resultHandlers that are interested in the notices the server sends
+// while the statement runs.
+type noticeReceiver interface {
+	handleNotice(msg NoticeResponseMessage)
+}
+
+// Collects everything that is received into a Resultset.
+type resultsetHandler struct {
+	resultset *Resultset
+
+	maxRows  int    // The maximum number of rows to buffer in memory, or zero for no limit
+	maxSize  int64  // The maximum total size of the values to buffer in memory, or zero for no limit
+	spillDir string // Where to spill rows to when a limit is exceeded, or empty to fail instead
+	cancel   func() // Asks the server to cancel the query
+	size     int64
+	err      error
+}
+
+func (h *resultsetHandler) handleFields(fields []Field) {
+	// Only the resultset of the last statement is kept, so the resultset of an earlier
+	// statement is dropped, along with its spill file, and the limits start over.
+	h.resultset.Close()
+	h.resultset = &Resultset{Fields: fields}
+	h.size = 0
+}
+
+func (h *resultsetHandler) handleRow(values [][]byte) {
+	if h.err != nil {
+		return
+	}
+
+	if h.resultset.spill != nil {
+		if h.err = h.resultset.spill.write(values); h.err != nil {
+			h.abort()
+		}
+		return
+	}
+
+	for _, value := range values {
+		h.size += int64(len(value))
+	}
+
+	if (h.maxRows > 0 && len(h.resultset.Rows) >= h.maxRows) || (h.maxSize > 0 && h.size > h.maxSize) {
+		if h.spillDir == "" {
+			h.err = fmt.Errorf("%w: more than %d rows or %d bytes", ErrResultTooLarge, h.maxRows, h.maxSize)
+			h.abort()
+			return
+		}
+
+		if h.resultset.spill, h.err = createSpillFile(h.spillDir); h.err != nil {
+			h.abort()
+			return
+		}
+		h.handleRow(values)
+		return
+	}
+
+	h.resultset.Rows = append(h.resultset.Rows, Row{Values: values, fields: h.resultset.Fields})
+}
+
+func (h *resultsetHandler) handleComplete(result string) {
+	if h.resultset == nil {
+		h.resultset = &Resultset{}
+	}
+	h.resultset.Result = result
+
+	if h.resultset.spill != nil && h.err == nil {
+		h.err = h.resultset.spill.writer.Flush()
+	}
+}
+
+// Stops buffering the resultset after an error, and asks the server to cancel the query.
+func (h *resultsetHandler) abort() {
+	h.resultset.Rows = nil
+	h.resultset.Close()
+	if h.cancel != nil {
+		h.cancel()
+	}
+}
+
+// Collects the resultsets of multiple statements, using a new resultsetHandler for
+// every statement.
+type multiResultsetHandler struct {
+	newHandler func() *resultsetHandler
+	current    *resultsetHandler
+	resultsets []*Resultset
+}
+
+func (h *multiResultsetHandler) handleFields(fields []Field) {
+	if h.current != nil {
+		return // A previous statement failed, and the remaining rows are skipped.
+	}
+	h.current = h.newHandler()
+	h.current.handleFields(fields)
+}
+
+func (h *multiResultsetHandler) handleRow(values [][]byte) {
+	h.current.handleRow(values)
+}
+
+func (h *multiResultsetHandler) handleComplete(result string) {
+	if h.current == nil {
+		h.current = h.newHandler()
+	}
+	h.current.handleComplete(result)
+
+	if h.current.err == nil {
+		h.resultsets = append(h.resultsets, h.current.resultset)
+		h.current = nil
+	}
+}
+
+// Discards all rows, and only keeps the command tag.
+type discardHandler struct {
+	tag CommandTag
+}
+
+func (h *discardHandler) handleFields(fields []Field) {}
+
+func (h *discardHandler) handleRow(values [][]byte) {}
+
+func (h *discardHandler) handleComplete(result string) {
+	h.tag = CommandTag(result)
+}
+
+// The command tag of a CommandComplete message, e.g. "INSERT 0 5" or "CREATE TABLE".
+type CommandTag string
+
+// Returns the command verb of the tag, without any trailing counts, e.g. "INSERT".
+func (t CommandTag) Command() string {
+	words := strings.Fields(string(t))
+	for len(words) > 0 {
+		if _, err := strconv.ParseInt(words[len(words)-1], 10, 64); err != nil {
+			break
+		}
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// Returns the number of rows affected by the command, or 0 if the tag doesn't
+// include a row count.
+func (t CommandTag) RowsAffected() int64 {
+	words := strings.Fields(string(t))
+	if len(words) < 2 {
+		return 0
+	}
+
+	rows, err := strconv.ParseInt(words[len(words)-1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return rows
 }
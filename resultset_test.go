@@ -0,0 +1,76 @@
+package vertigo
+
+import (
+	"testing"
+)
+
+func TestCommandTag(t *testing.T) {
+	tests := []struct {
+		tag          CommandTag
+		command      string
+		rowsAffected int64
+	}{
+		{"INSERT 0 5", "INSERT", 5},
+		{"UPDATE 12", "UPDATE", 12},
+		{"SELECT", "SELECT", 0},
+		{"CREATE TABLE", "CREATE TABLE", 0},
+		{"", "", 0},
+	}
+
+	for _, test := range tests {
+		if command := test.tag.Command(); command != test.command {
+			t.Errorf("Expected command of %q to be %q, but found %q", test.tag, test.command, command)
+		}
+		if rows := test.tag.RowsAffected(); rows != test.rowsAffected {
+			t.Errorf("Expected %q to affect %d rows, but found %d", test.tag, test.rowsAffected, rows)
+		}
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	resultset := &Resultset{
+		Fields: []Field{{Name: "id"}, {Name: "Name"}, {Name: "name"}},
+		Rows:   []Row{{Values: [][]byte{[]byte("1"), []byte("foo"), []byte("bar")}}},
+	}
+
+	if i, ok := resultset.ColumnIndex("name"); !ok || i != 2 {
+		t.Errorf("Expected an exact match to take precedence, but found %d", i)
+	}
+
+	if i, ok := resultset.ColumnIndex("ID"); !ok || i != 0 {
+		t.Errorf("Expected a case-insensitive match, but found %d", i)
+	}
+
+	if _, ok := resultset.ColumnIndex("unknown"); ok {
+		t.Error("Expected no match for an unknown column")
+	}
+
+	if value, ok := resultset.Rows[0].Get(resultset.Fields, "Name"); !ok || string(value) != "foo" {
+		t.Errorf("Expected value foo, but found %q", value)
+	}
+}
+
+func TestMultiResultsetHandler(t *testing.T) {
+	handler := &multiResultsetHandler{newHandler: func() *resultsetHandler { return &resultsetHandler{} }}
+
+	handler.handleComplete("CREATE TABLE")
+	handler.handleFields([]Field{{Name: "a"}})
+	handler.handleRow([][]byte{[]byte("1")})
+	handler.handleComplete("SELECT 1")
+	handler.handleFields([]Field{{Name: "b"}, {Name: "c"}})
+	handler.handleComplete("SELECT 0")
+
+	if len(handler.resultsets) != 3 {
+		t.Fatalf("Expected 3 resultsets, but found %d", len(handler.resultsets))
+	}
+
+	if rs := handler.resultsets[0]; rs.Result != "CREATE TABLE" || len(rs.Fields) != 0 {
+		t.Errorf("Unexpected first resultset %+v", rs)
+	}
+	if rs := handler.resultsets[1]; rs.Fields[0].Name != "a" || len(rs.Rows) != 1 {
+		t.Errorf("Unexpected second resultset %+v", rs)
+	}
+	if rs := handler.resultsets[2]; len(rs.Fields) != 2 || len(rs.Rows) != 0 || rs.Result != "SELECT 0" {
+		t.Errorf("Unexpected third resultset %+v", rs)
+	}
+}
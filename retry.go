@@ -0,0 +1,125 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 10 * time.Second
+)
+
+// Configures how opening a connection, or a transaction run by RunInTransaction, is
+// retried. The delay before the nth retry is Backoff doubled n-1 times, up to
+// MaxBackoff, of which a random fraction of at most Jitter is taken off, so clients
+// that start together don't retry in lockstep.
+type RetryPolicy struct {
+	// The maximum number of attempts, including the first one. Values below two
+	// disable retries.
+	Attempts int
+
+	Backoff    time.Duration // The delay before the first retry. Defaults to 100ms.
+	MaxBackoff time.Duration // The maximum delay between attempts. Defaults to ten seconds.
+	Jitter     float64       // The fraction of the delay that is randomized, between 0 and 1.
+
+	// Returns whether to retry after the error. Defaults to IsConnectRetryable when
+	// connecting, and to IsRetryable for RunInTransaction.
+	Retryable func(error) bool
+}
+
+// Returns whether connecting may succeed when it is retried after the error. That is
+// the case when the server can't be reached, when a load balancer closes or resets
+// the connection before the server answered, and when the server isn't accepting
+// connections for the moment, because it is starting or shutting down, or because
+// it has too many of them. Authentication errors aren't retryable.
+func IsConnectRetryable(err error) bool {
+	var opError *net.OpError
+	if errors.As(err, &opError) && opError.Op == "dial" {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	switch sqlState(err) {
+	case ErrCodeCannotConnectNow, ErrCodeAdminShutdown, ErrCodeTooManyConnections:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsConnectRetryable(err)
+}
+
+// Returns the delay before the retry that follows the attempt, counting from one.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	backoff, maxBackoff := p.Backoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	delay := backoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	if p.Jitter > 0 {
+		delay -= time.Duration(float64(delay) * min(p.Jitter, 1) * rand.Float64())
+	}
+	return delay
+}
+
+// Opens the connection, and retries according to the ConnectRetry policy of the
+// configuration. This function will panic with the error of the last attempt if
+// the connection cannot be opened.
+func (c *Connection) openConnection() {
+	policy := c.config.ConnectRetry
+	for attempt := 1; ; attempt++ {
+		err := c.tryOpenSession()
+		if err == nil {
+			return
+		}
+		if attempt >= policy.Attempts || !policy.retryable(err) {
+			panic(err)
+		}
+
+		delay := policy.delay(attempt)
+		c.log(LogLevelWarn, "Cannot connect, retrying", "address", c.config.Address, "attempt", attempt, "delay", delay, "error", err)
+		c.resetConnection()
+		time.Sleep(delay)
+	}
+}
+
+// Makes a single attempt to open the connection, and returns its error.
+func (c *Connection) tryOpenSession() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			if err, ok = r.(error); !ok {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	c.openSession()
+	return nil
+}
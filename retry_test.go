@@ -0,0 +1,114 @@
+package vertigo
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, delay := range expected {
+		if found := policy.delay(i + 1); found != delay {
+			t.Fatalf("Expected a delay of %s after attempt %d, but found %s", delay, i+1, found)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if delay := policy.delay(1); delay < 50*time.Millisecond || delay > 100*time.Millisecond {
+			t.Fatalf("Expected a delay between 50ms and 100ms, but found %s", delay)
+		}
+	}
+}
+
+func TestIsConnectRetryable(t *testing.T) {
+	verticaError := func(code string) error {
+		return ErrorResponseMessage{Fields: map[byte]string{'S': "FATAL", 'C': code}}.VerticaError()
+	}
+
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{&net.OpError{Op: "dial", Err: errors.New("no such host")}, true},
+		{io.EOF, true},
+		{verticaError(ErrCodeCannotConnectNow), true},
+		{verticaError(ErrCodeTooManyConnections), true},
+		{verticaError(ErrCodeInvalidPassword), false},
+		{AuthenticationMethodNotSupported, false},
+	}
+	for _, test := range tests {
+		if IsConnectRetryable(test.err) != test.retryable {
+			t.Fatalf("Expected IsConnectRetryable to be %v for %v", test.retryable, test.err)
+		}
+	}
+}
+
+func TestConnectRetry(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// A load balancer that drops the first connection, and forwards the next ones.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for accepted := 0; ; accepted++ {
+			client, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if accepted == 0 {
+				client.Close()
+				continue
+			}
+			backend, err := net.Dial("tcp", server.Addr())
+			if err != nil {
+				client.Close()
+				continue
+			}
+			go func() { io.Copy(backend, client); backend.Close() }()
+			go func() { io.Copy(client, backend); client.Close() }()
+		}
+	}()
+
+	connection, err := Open(listener.Addr().String(), WithUser("dbadmin"), WithConnectRetry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("Expected connecting to succeed on the second attempt, but found %v", err)
+	}
+	connection.Close()
+
+	server.RequirePassword("secret")
+	var errs []error
+	_, err = Open(listener.Addr().String(), WithUser("dbadmin"), WithPassword("wrong"), WithConnectRetry(RetryPolicy{
+		Attempts:  3,
+		Backoff:   time.Millisecond,
+		Retryable: func(err error) bool { errs = append(errs, err); return IsConnectRetryable(err) },
+	}))
+	if err == nil || len(errs) != 1 {
+		t.Fatalf("Expected an authentication error not to be retried, but found %v, %v", errs, err)
+	}
+
+	address := listener.Addr().String()
+	listener.Close()
+	errs = nil
+	_, err = Open(address, WithUser("dbadmin"), WithConnectRetry(RetryPolicy{
+		Attempts:  3,
+		Backoff:   time.Millisecond,
+		Retryable: func(err error) bool { errs = append(errs, err); return true },
+	}))
+	if err == nil || len(errs) != 2 {
+		t.Fatalf("Expected three failed attempts, but found %v, %v", errs, err)
+	}
+}
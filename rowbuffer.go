@@ -0,0 +1,74 @@
+package vertigo
+
+const (
+	rowChunkSize    = 32 << 10 // The size of the chunks row bodies are allocated from
+	rowChunkMaxBody = 4 << 10  // Larger bodies are allocated on their own
+	valueChunkSize  = 1024     // The number of value slices allocated at once
+)
+
+// Allocates the memory that DataRow messages are read into. Rows are read by the
+// thousands, so rather than allocating the body and the value slice of every row, they
+// are carved from larger chunks. A row keeps its chunk in memory for as long as it is
+// referenced.
+//
+// When the rows are transient, because the handler is done with a row before the next
+// one is read, the same memory is reused for every row instead.
+type rowBuffer struct {
+	transient bool
+
+	body   []byte   // The unused part of the current chunk of bytes
+	values [][]byte // The unused part of the current chunk of value slices
+
+	scratchBody   []byte // The memory reused for transient rows
+	scratchValues [][]byte
+}
+
+// Returns a slice of size bytes to read the body of a DataRow message into.
+func (b *rowBuffer) allocBody(size int) []byte {
+	if b.transient {
+		if cap(b.scratchBody) < size {
+			b.scratchBody = make([]byte, size)
+		}
+		return b.scratchBody[:size]
+	}
+
+	if size > rowChunkMaxBody {
+		return make([]byte, size)
+	}
+	if len(b.body) < size {
+		b.body = make([]byte, rowChunkSize)
+	}
+	body := b.body[:size:size]
+	b.body = b.body[size:]
+	return body
+}
+
+// Returns a slice for the n values of a row.
+func (b *rowBuffer) allocValues(n int) [][]byte {
+	if b.transient {
+		if cap(b.scratchValues) < n {
+			b.scratchValues = make([][]byte, n)
+		}
+		return b.scratchValues[:n]
+	}
+
+	if n > valueChunkSize/4 {
+		return make([][]byte, n)
+	}
+	if len(b.values) < n {
+		b.values = make([][]byte, valueChunkSize)
+	}
+	values := b.values[:n:n]
+	b.values = b.values[n:]
+	return values
+}
+
+// Implemented by result handlers that are done with the values of a row when
+// handleRow returns, so their rows can be read into the same memory.
+type transientRowHandler interface {
+	transientRows()
+}
+
+func (h *discardHandler) transientRows() {}
+
+func (h *sinkHandler) transientRows() {}
@@ -0,0 +1,30 @@
+package vertigo
+
+import (
+	"testing"
+)
+
+func TestRowBuffer(t *testing.T) {
+	var b rowBuffer
+	first, err := parseDataRow(append(b.allocBody(11)[:0], "\x00\x01\x00\x00\x00\x05hello"...), b.allocValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := parseDataRow(append(b.allocBody(11)[:0], "\x00\x01\x00\x00\x00\x05world"...), b.allocValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Appending to a value mustn't overwrite the row after it in the chunk.
+	_ = append(first.Values[0], '!')
+	if string(first.Values[0]) != "hello" || string(second.Values[0]) != "world" {
+		t.Fatalf("Expected rows to keep their values, but found %q and %q", first.Values, second.Values)
+	}
+
+	b.transient = true
+	first, _ = parseDataRow(append(b.allocBody(11)[:0], "\x00\x01\x00\x00\x00\x05hello"...), b.allocValues)
+	second, _ = parseDataRow(append(b.allocBody(11)[:0], "\x00\x01\x00\x00\x00\x05world"...), b.allocValues)
+	if &first.Values[0][0] != &second.Values[0][0] {
+		t.Fatalf("Expected transient rows to reuse the same memory")
+	}
+}
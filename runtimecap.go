@@ -0,0 +1,61 @@
+package vertigo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type runtimeCapKey struct{}
+
+// Returns a context that makes the statements run with it, like with QueryContext, run
+// under a RUNTIMECAP of d: the server cancels them when they run longer. Unlike a
+// client-side timeout, this works even when the client stops reading, as the server
+// enforces it. The cap is set with SET SESSION RUNTIMECAP before each statement, in
+// whole seconds, and the cap the session had before, as SHOW RUNTIMECAP reports it, is
+// restored afterwards.
+func WithRuntimeCap(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, runtimeCapKey{}, d)
+}
+
+// Returns the statement that sets the runtime cap of the statements run with ctx, if any.
+func runtimeCapStatement(ctx context.Context) (string, bool) {
+	d, ok := ctx.Value(runtimeCapKey{}).(time.Duration)
+	if !ok {
+		return "", false
+	}
+	seconds := int64((d + time.Second - 1) / time.Second)
+	return fmt.Sprintf("SET SESSION RUNTIMECAP '%d seconds'", max(seconds, 1)), true
+}
+
+// Returns the statement that restores the runtime cap of the session to the setting
+// SHOW RUNTIMECAP reported, which is UNLIMITED when there is no cap.
+func restoreRuntimeCapStatement(setting string) string {
+	if setting == "" || strings.EqualFold(setting, "unlimited") {
+		return "SET SESSION RUNTIMECAP NONE"
+	}
+	return "SET SESSION RUNTIMECAP " + quoteString(setting)
+}
+
+// Returns the runtime cap of the session, as SHOW RUNTIMECAP reports it.
+func (c *Connection) sessionRuntimeCap() (string, error) {
+	handler := &settingHandler{}
+	if err := c.execInternal("SHOW RUNTIMECAP", handler); err != nil {
+		return "", err
+	}
+	return handler.setting, nil
+}
+
+// Keeps the setting of the row of a SHOW statement, which has the name of the
+// parameter and its setting.
+type settingHandler struct {
+	discardHandler
+	setting string
+}
+
+func (h *settingHandler) handleRow(values [][]byte) {
+	if len(values) > 0 {
+		h.setting = string(values[len(values)-1])
+	}
+}
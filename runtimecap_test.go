@@ -0,0 +1,53 @@
+package vertigo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestWithRuntimeCap(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SET SESSION RUNTIMECAP '2 seconds'").Tag("SET")
+	setting := []vertigotest.Column{{Name: "name", Type: DataTypeVarchar}, {Name: "setting", Type: DataTypeVarchar}}
+	server.Expect("SHOW RUNTIMECAP").Columns(setting...).Row("runtimecap", "00:05").Once()
+	server.Expect("SHOW RUNTIMECAP").Columns(setting...).Row("runtimecap", "UNLIMITED")
+	server.Expect("SET SESSION RUNTIMECAP '00:05'").Tag("SET")
+	server.Expect("SET SESSION RUNTIMECAP NONE").Tag("SET")
+	server.Expect("SELECT COUNT(*) FROM events").Columns(vertigotest.Column{Name: "count", Type: DataTypeInteger}).Row(7)
+	server.Expect("SELECT missing").Error("42703", "Column \"missing\" does not exist")
+
+	connection, err := Connect(&ConnectionInfo{Address: server.Addr(), User: "dbadmin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	ctx := WithRuntimeCap(context.Background(), 1500*time.Millisecond)
+	if rs, err := connection.QueryContext(ctx, "SELECT COUNT(*) FROM events"); err != nil || len(rs.Rows) != 1 {
+		t.Fatalf("Expected a single row, but found %v, %v", rs, err)
+	}
+	if _, err := connection.QueryContext(ctx, "SELECT missing"); err == nil {
+		t.Fatalf("Expected the statement to fail")
+	}
+	if _, err := connection.Query("SELECT COUNT(*) FROM events"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The session starts with a cap of five minutes, which is restored, and has none later.
+	expected := []string{
+		"SHOW RUNTIMECAP", "SET SESSION RUNTIMECAP '2 seconds'", "SELECT COUNT(*) FROM events", "SET SESSION RUNTIMECAP '00:05'",
+		"SHOW RUNTIMECAP", "SET SESSION RUNTIMECAP '2 seconds'", "SELECT missing", "SET SESSION RUNTIMECAP NONE",
+		"SELECT COUNT(*) FROM events",
+	}
+	if statements := server.Statements(); !reflect.DeepEqual(statements, expected) {
+		t.Fatalf("Expected the runtime cap to be set around the statements and restored, but found %q", statements)
+	}
+}
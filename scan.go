@@ -0,0 +1,427 @@
+package vertigo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnexpectedNull = errors.New("Unexpected NULL value")
+
+// Nullable holds a value of type T that can be NULL. It can be used as a scan
+// destination for columns that contain NULL values.
+type Nullable[T any] struct {
+	Value T
+	Valid bool // Valid is false if the value is NULL.
+}
+
+// Implements sql.Scanner.
+func (n *Nullable[T]) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		return n.scanText(nil, nil)
+	case []byte:
+		return n.scanText(nil, src)
+	case string:
+		return n.scanText(nil, []byte(src))
+	}
+
+	value, ok := src.(T)
+	if !ok {
+		return fmt.Errorf("Cannot convert %T into %T", src, n.Value)
+	}
+	n.Value, n.Valid = value, true
+	return nil
+}
+
+func (n *Nullable[T]) scanText(field *Field, src []byte) error {
+	*n = Nullable[T]{}
+	if src == nil {
+		return nil
+	}
+
+	if err := convertValue(field, src, &n.Value); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// Implemented by scan destinations that know how to convert a value in text format.
+type textScanner interface {
+	scanText(field *Field, src []byte) error
+}
+
+// Copies the values of the row into the values pointed at by dest, converting
+// them from their text representation. The number of values in dest must be
+// the same as the number of values in the row.
+//
+// NULL values can be scanned into pointers, which are set to nil, into Nullable
+// values, and into any sql.Scanner like sql.NullString. Scanning NULL into any
+// other destination fails with ErrUnexpectedNull.
+func (r Row) Scan(dest ...interface{}) error {
+	if len(dest) != len(r.Values) {
+		return fmt.Errorf("Expected %d destination arguments in Scan, got %d", len(r.Values), len(dest))
+	}
+
+	for i, value := range r.Values {
+		if err := convertValue(r.field(i), value, dest[i]); err != nil {
+			return fmt.Errorf("Cannot scan column %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Returns the field of the i-th value of the row, or nil if the row doesn't
+// know its fields.
+func (r Row) field(i int) *Field {
+	if len(r.fields) != len(r.Values) {
+		return nil
+	}
+	return &r.fields[i]
+}
+
+// Converts a value in text format into the value pointed at by dest. If the
+// field of the value is known, it is used to decode the value based on its
+// data type. Otherwise, the conversion is based on the type of dest only.
+func convertValue(field *Field, src []byte, dest interface{}) error {
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.IsNil() {
+		return fmt.Errorf("Destination should be a non-nil pointer, got %T", dest)
+	}
+
+	if textScanner, ok := dest.(textScanner); ok {
+		return textScanner.scanText(field, src)
+	}
+
+	// Values of types with a registered codec are used if they fit the destination.
+	if field != nil && src != nil {
+		if codec := lookupCodec(field.DataTypeOID); codec != nil {
+			decoded, err := codec.Decode(*field, src)
+			if err != nil {
+				return err
+			}
+			if decodedValue := reflect.ValueOf(decoded); decodedValue.IsValid() && decodedValue.Type().AssignableTo(destValue.Elem().Type()) {
+				destValue.Elem().Set(decodedValue)
+				return nil
+			}
+		}
+	}
+
+	switch dest := dest.(type) {
+
+	case sql.Scanner:
+		if src == nil {
+			return dest.Scan(nil)
+		}
+		if field == nil {
+			return dest.Scan(append([]byte(nil), src...))
+		}
+
+		value, err := decodeValue(*field, src)
+		if err != nil {
+			return err
+		}
+
+		// Only pass the value types database/sql drivers use to scanners.
+		switch value.(type) {
+		case bool, int64, float64, string, []byte, time.Time:
+			return dest.Scan(value)
+		default:
+			return dest.Scan(string(src))
+		}
+
+	case *big.Rat:
+		if src != nil {
+			if _, ok := dest.SetString(string(src)); !ok {
+				return fmt.Errorf("Cannot convert %q into *big.Rat", src)
+			}
+			return nil
+		}
+
+	case *big.Int:
+		if src != nil {
+			if _, ok := dest.SetString(string(src), 10); !ok {
+				return fmt.Errorf("Cannot convert %q into *big.Int", src)
+			}
+			return nil
+		}
+
+	case *time.Duration:
+		if src != nil {
+			var interval Interval
+			if err := interval.scanText(field, src); err != nil {
+				return err
+			}
+
+			d, err := interval.Duration()
+			if err != nil {
+				return err
+			}
+			*dest = d
+			return nil
+		}
+
+	case *time.Time:
+		if src != nil {
+			var (
+				location *time.Location
+				coercion *coercionPolicy
+			)
+			if field != nil {
+				location, coercion = field.location, field.coercion
+			}
+
+			t, err := parseTime(string(src), location, coercion)
+			if err != nil {
+				return err
+			}
+			*dest = t
+			return nil
+		}
+	}
+
+	value := destValue.Elem()
+	if src == nil {
+		switch value.Kind() {
+		case reflect.Interface, reflect.Slice, reflect.Map, reflect.Ptr:
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		default:
+			return fmt.Errorf("%w, cannot convert it into %s; use a pointer, Nullable or sql.Null type instead", ErrUnexpectedNull, value.Type())
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		target := reflect.New(value.Type().Elem())
+		if err := convertValue(field, src, target.Interface()); err != nil {
+			return err
+		}
+		value.Set(target)
+
+	case reflect.String:
+		value.SetString(string(src))
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(string(src))
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(string(src), 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(string(src), 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(string(src), value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		if field != nil && field.coercion != nil {
+			if err := field.coercion.checkFloat(string(src), f, value.Type().Bits(), value.Type().String()); err != nil {
+				return err
+			}
+		}
+		value.SetFloat(f)
+
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.Uint8 {
+			if field != nil && field.DataTypeOID != DataTypeArray {
+				if _, ok := collectionElementType(field.DataTypeOID); !ok {
+					return fmt.Errorf("Cannot convert value of type %d into %s", field.DataTypeOID, value.Type())
+				}
+			}
+			return convertCollection(field, src, value)
+		}
+		if field != nil && isBinaryType(field.DataTypeOID) {
+			b, err := decodeBinary(field, src)
+			if err != nil {
+				return err
+			}
+			value.SetBytes(b)
+		} else {
+			value.SetBytes(append([]byte(nil), src...))
+		}
+
+	case reflect.Struct:
+		if field != nil && field.DataTypeOID != DataTypeRow {
+			return fmt.Errorf("Cannot convert value of type %d into %s", field.DataTypeOID, value.Type())
+		}
+		return convertRowValue(src, value)
+
+	case reflect.Map:
+		if field != nil && !isComplexType(field.DataTypeOID) {
+			return fmt.Errorf("Cannot convert value of type %d into %s", field.DataTypeOID, value.Type())
+		}
+
+		decoded, err := decodeComplexValue(src)
+		if err != nil {
+			return err
+		}
+		decodedValue := reflect.ValueOf(decoded)
+		if !decodedValue.Type().AssignableTo(value.Type()) {
+			return fmt.Errorf("Cannot convert %s into %s", decodedValue.Type(), value.Type())
+		}
+		value.Set(decodedValue)
+
+	case reflect.Interface:
+		if value.NumMethod() != 0 {
+			return fmt.Errorf("Cannot convert value into %s", value.Type())
+		}
+
+		if field == nil {
+			value.Set(reflect.ValueOf(string(src)))
+		} else if decoded, err := decodeValue(*field, src); err != nil {
+			return err
+		} else {
+			value.Set(reflect.ValueOf(decoded))
+		}
+
+	default:
+		return fmt.Errorf("Cannot convert value into %s", value.Type())
+	}
+	return nil
+}
+
+// Copies the values of the row into the fields of the struct pointed at by dest.
+//
+// Columns are mapped onto struct fields using the `db:"name"` tag of the field. Fields
+// without a tag are matched on their name, ignoring case. Fields tagged with `db:"-"`
+// are never used. It is an error if a column can't be mapped onto a field.
+func (r Row) ScanStruct(fields []Field, dest interface{}) error {
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.IsNil() || destValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Destination should be a non-nil pointer to a struct, got %T", dest)
+	}
+
+	indexes, err := structFieldIndexes(destValue.Elem().Type(), fields)
+	if err != nil {
+		return err
+	}
+	return r.scanStruct(fields, indexes, destValue.Elem())
+}
+
+// Copies all rows of the resultset into the slice of structs pointed at by dest.
+// The elements of the slice can be either structs or pointers to structs. See
+// Row.ScanStruct for how columns are mapped onto struct fields.
+func (rs *Resultset) ScanStruct(dest interface{}) error {
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.IsNil() || destValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("Destination should be a non-nil pointer to a slice, got %T", dest)
+	}
+
+	slice := destValue.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("Destination should be a slice of structs, got %T", dest)
+	}
+
+	indexes, err := structFieldIndexes(structType, rs.Fields)
+	if err != nil {
+		return err
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, rs.RowCount())
+	err = rs.EachRow(func(row Row) error {
+		elem := reflect.New(structType)
+		if err := row.scanStruct(rs.Fields, indexes, elem.Elem()); err != nil {
+			return err
+		}
+
+		if elemType.Kind() == reflect.Ptr {
+			result = reflect.Append(result, elem)
+		} else {
+			result = reflect.Append(result, elem.Elem())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slice.Set(result)
+	return nil
+}
+
+func (r Row) scanStruct(fields []Field, indexes [][]int, dest reflect.Value) error {
+	if len(indexes) != len(r.Values) {
+		return fmt.Errorf("Expected %d values in row, got %d", len(indexes), len(r.Values))
+	}
+
+	for i, value := range r.Values {
+		if err := convertValue(&fields[i], value, dest.FieldByIndex(indexes[i]).Addr().Interface()); err != nil {
+			return fmt.Errorf("Cannot scan column %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Returns, for every field of the resultset, the index of the struct field it
+// should be scanned into.
+func structFieldIndexes(structType reflect.Type, fields []Field) ([][]int, error) {
+	tagged := make(map[string][]int)
+	untagged := make(map[string][]int)
+	collectStructFields(structType, nil, tagged, untagged)
+
+	indexes := make([][]int, len(fields))
+	for i, field := range fields {
+		if index, ok := tagged[field.Name]; ok {
+			indexes[i] = index
+		} else if index, ok := untagged[strings.ToLower(field.Name)]; ok {
+			indexes[i] = index
+		} else {
+			return nil, fmt.Errorf("No field in %s for column %q", structType, field.Name)
+		}
+	}
+	return indexes, nil
+}
+
+// Collects the exported fields of a struct type, including the fields of embedded
+// structs, by their db tag or by their lowercased name if they don't have a tag.
+func collectStructFields(structType reflect.Type, parent []int, tagged, untagged map[string][]int) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		index := append(append([]int(nil), parent...), i)
+
+		tag := field.Tag.Get("db")
+		switch {
+		case tag == "-":
+			continue
+
+		case tag == "" && field.Anonymous && field.Type.Kind() == reflect.Struct:
+			collectStructFields(field.Type, index, tagged, untagged)
+
+		case field.PkgPath != "":
+			continue
+
+		case tag != "":
+			tagged[tag] = index
+
+		default:
+			if _, exists := untagged[strings.ToLower(field.Name)]; !exists {
+				untagged[strings.ToLower(field.Name)] = index
+			}
+		}
+	}
+}
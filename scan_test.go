@@ -0,0 +1,134 @@
+package vertigo
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestRowScan(t *testing.T) {
+	row := Row{Values: [][]byte{[]byte("test"), []byte("42"), []byte("1.5"), []byte("t"), nil}}
+
+	var (
+		s string
+		i int64
+		f float64
+		b bool
+		n interface{}
+	)
+
+	if err := row.Scan(&s, &i, &f, &b, &n); err != nil {
+		t.Fatal(err)
+	}
+
+	if s != "test" || i != 42 || f != 1.5 || !b || n != nil {
+		t.Fatalf("Unexpected scan result: %q %d %f %t %#+v", s, i, f, b, n)
+	}
+}
+
+func TestRowScanErrors(t *testing.T) {
+	row := Row{Values: [][]byte{[]byte("300"), nil}}
+
+	var (
+		i int8
+		s string
+	)
+
+	if err := row.Scan(&i); err == nil {
+		t.Error("Expected an error when passing the wrong number of arguments")
+	}
+
+	if err := row.Scan(&i, new(interface{})); err == nil {
+		t.Error("Expected an error when the value overflows the destination")
+	}
+
+	if err := row.Scan(new(int), &s); err == nil {
+		t.Error("Expected an error when scanning NULL into a string")
+	}
+}
+
+type scanStructBase struct {
+	ID int64 `db:"id"`
+}
+
+type scanStructTest struct {
+	scanStructBase
+	Name    string
+	Score   float64 `db:"total_score"`
+	Ignored string  `db:"-"`
+	private string
+}
+
+func TestResultsetScanStruct(t *testing.T) {
+	resultset := &Resultset{
+		Fields: []Field{{Name: "id"}, {Name: "NAME"}, {Name: "total_score"}},
+		Rows: []Row{
+			{Values: [][]byte{[]byte("1"), []byte("foo"), []byte("1.5")}},
+			{Values: [][]byte{[]byte("2"), []byte("bar"), []byte("2.5")}},
+		},
+	}
+
+	var values []scanStructTest
+	if err := resultset.ScanStruct(&values); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 2 || values[1].ID != 2 || values[1].Name != "bar" || values[1].Score != 2.5 {
+		t.Fatalf("Unexpected scan result: %#+v", values)
+	}
+
+	var pointers []*scanStructTest
+	if err := resultset.ScanStruct(&pointers); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pointers) != 2 || pointers[0].Name != "foo" {
+		t.Fatalf("Unexpected scan result: %#+v", pointers)
+	}
+}
+
+func TestRowScanStructUnknownColumn(t *testing.T) {
+	fields := []Field{{Name: "unknown"}}
+	row := Row{Values: [][]byte{[]byte("1")}}
+
+	var value scanStructTest
+	if err := row.ScanStruct(fields, &value); err == nil {
+		t.Fatal("Expected an error for a column without a struct field")
+	}
+}
+
+func TestRowScanNulls(t *testing.T) {
+	row := Row{Values: [][]byte{nil, []byte("42"), nil, []byte("foo"), nil, []byte("1.5")}}
+
+	var (
+		p1 *int
+		p2 *int
+		ns sql.NullString
+		s  sql.NullString
+		n1 Nullable[float64]
+		n2 Nullable[float64]
+	)
+
+	if err := row.Scan(&p1, &p2, &ns, &s, &n1, &n2); err != nil {
+		t.Fatal(err)
+	}
+
+	if p1 != nil || p2 == nil || *p2 != 42 {
+		t.Errorf("Unexpected pointer values %#+v and %#+v", p1, p2)
+	}
+
+	if ns.Valid || !s.Valid || s.String != "foo" {
+		t.Errorf("Unexpected sql.NullString values %#+v and %#+v", ns, s)
+	}
+
+	if n1.Valid || !n2.Valid || n2.Value != 1.5 {
+		t.Errorf("Unexpected Nullable values %#+v and %#+v", n1, n2)
+	}
+}
+
+func TestRowScanUnexpectedNull(t *testing.T) {
+	var i int
+	if err := (Row{Values: [][]byte{nil}}).Scan(&i); !errors.Is(err, ErrUnexpectedNull) {
+		t.Fatalf("Expected ErrUnexpectedNull, but found %#+v", err)
+	}
+}
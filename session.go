@@ -0,0 +1,112 @@
+package vertigo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	sessionParameterName     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	sessionParameterKeywords = regexp.MustCompile(`^[A-Za-z0-9_, ]+$`)
+)
+
+// Returns the statement that sets a session parameter. Parameters with a dedicated SET
+// statement use it; all other names are set as configuration parameters with ALTER SESSION.
+func sessionParamStatement(name, value string) (string, error) {
+	switch strings.ToLower(name) {
+	case "search_path":
+		schemas := strings.Split(value, ",")
+		for i, schema := range schemas {
+			schemas[i] = QuoteIdentifier(strings.TrimSpace(schema))
+		}
+		return "SET SEARCH_PATH TO " + strings.Join(schemas, ", "), nil
+	case "timezone", "time_zone":
+		return "SET TIME ZONE TO " + quoteString(value), nil
+	case "locale":
+		return "SET LOCALE TO " + quoteString(value), nil
+	case "resource_pool":
+		return "SET SESSION RESOURCE_POOL = " + QuoteIdentifier(value), nil
+	case "datestyle":
+		return keywordStatement("SET DATESTYLE TO ", name, value)
+	case "intervalstyle":
+		return keywordStatement("SET INTERVALSTYLE TO ", name, value)
+	case "autocommit":
+		return keywordStatement("SET SESSION AUTOCOMMIT TO ", name, value)
+	case "role":
+		return "SET ROLE " + QuoteIdentifier(value), nil
+	}
+
+	if !sessionParameterName.MatchString(name) {
+		return "", fmt.Errorf("Invalid session parameter name %q", name)
+	}
+	return "ALTER SESSION SET " + name + " = " + quoteString(value), nil
+}
+
+// Returns the statement that sets a session parameter to keywords, which are checked
+// instead of quoted.
+func keywordStatement(prefix, name, value string) (string, error) {
+	if !sessionParameterKeywords.MatchString(value) {
+		return "", fmt.Errorf("Invalid value %q for session parameter %s", value, name)
+	}
+	return prefix + value, nil
+}
+
+// Applies the SessionParams of the configuration to the session that was just opened.
+// The parameters are set in the order of their names. This function will panic if a
+// parameter cannot be set.
+func (c *Connection) applySessionParams() {
+	names := make([]string, 0, len(c.config.SessionParams))
+	for name := range c.config.SessionParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sql, err := sessionParamStatement(name, c.config.SessionParams[name])
+		if err == nil {
+			err = c.execInternal(sql, &discardHandler{})
+		}
+		if err != nil {
+			panic(fmt.Errorf("Cannot set session parameter %s: %w", name, err))
+		}
+	}
+}
+
+// Runs a statement the driver needs for its own bookkeeping, and passes its resultset
+// to the handler. Unlike run, it isn't logged, audited or counted. The connection lock
+// must be held. Server errors are returned; this function will panic on connection errors.
+func (c *Connection) execInternal(sql string, handler resultHandler) (err error) {
+	c.sendMessage(QueryMessage{SQL: sql})
+	for msg := c.receiveMessage(); !c.isReadyForQuery(msg); msg = c.receiveMessage() {
+		switch msg := msg.(type) {
+		case ErrorResponseMessage:
+			err = msg.VerticaError()
+		case EmptyQueryMessage:
+		case RowDescriptionMessage:
+			c.prepareFields(msg.Fields)
+			handler.handleFields(msg.Fields)
+		case DataRowMessage:
+			handler.handleRow(msg.Values)
+		case CommandCompleteMessage:
+			handler.handleComplete(msg.Result)
+		default:
+			c.handleStatelessMessage(msg)
+		}
+	}
+	return err
+}
+
+// Moves the session that was just opened into the configured ResourcePool. The server
+// rejects pools that don't exist or that the user has no access to, which makes the
+// connection attempt fail. This function will panic if the pool cannot be used.
+func (c *Connection) applyResourcePool() {
+	if c.config.ResourcePool == "" {
+		return
+	}
+
+	if err := c.execInternal("SET SESSION RESOURCE_POOL = "+QuoteIdentifier(c.config.ResourcePool), &discardHandler{}); err != nil {
+		panic(fmt.Errorf("Cannot use resource pool %s: %w", c.config.ResourcePool, err))
+	}
+}
@@ -0,0 +1,123 @@
+package vertigo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestSessionParamStatement(t *testing.T) {
+	tests := map[[2]string]string{
+		{"search_path", "app, public"}:         `SET SEARCH_PATH TO "app", "public"`,
+		{"TimeZone", "Europe/Amsterdam"}:       "SET TIME ZONE TO 'Europe/Amsterdam'",
+		{"locale", "en_US@collation=binary"}:   "SET LOCALE TO 'en_US@collation=binary'",
+		{"resource_pool", "etl"}:               `SET SESSION RESOURCE_POOL = "etl"`,
+		{"MaxParsedQuerySizeMB", "512"}:        "ALTER SESSION SET MaxParsedQuerySizeMB = '512'",
+		{"search_path", "public;DROP TABLE t"}: `SET SEARCH_PATH TO "public;DROP TABLE t"`,
+		{"datestyle", "ISO, MDY"}:              "SET DATESTYLE TO ISO, MDY",
+		{"role", "analyst; DROP TABLE t"}:      `SET ROLE "analyst; DROP TABLE t"`,
+	}
+	for param, expected := range tests {
+		if sql, err := sessionParamStatement(param[0], param[1]); err != nil || sql != expected {
+			t.Fatalf("Expected %q for %s, but found %q, %v", expected, param[0], sql, err)
+		}
+	}
+
+	if _, err := sessionParamStatement("x = 1; DROP TABLE t; --", "1"); err == nil {
+		t.Fatalf("Expected an invalid parameter name to be rejected")
+	}
+	for _, name := range []string{"datestyle", "intervalstyle", "autocommit"} {
+		if _, err := sessionParamStatement(name, "on; DROP TABLE t"); err == nil {
+			t.Fatalf("Expected an invalid value for %s to be rejected", name)
+		}
+	}
+}
+
+func TestSessionParams(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect(`SET SEARCH_PATH TO "app", "public"`).Tag("SET")
+	server.Expect("SET TIME ZONE TO 'Europe/Amsterdam'").Tag("SET").Parameter("timezone", "Europe/Amsterdam")
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"),
+		WithSessionParam("timezone", "Europe/Amsterdam"),
+		WithSessionParam("search_path", "app, public"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if timezone, _ := connection.ServerParameter("timezone"); timezone != "Europe/Amsterdam" {
+		t.Fatalf("Expected time zone Europe/Amsterdam, but found %q", timezone)
+	}
+
+	if err := connection.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`SET SEARCH_PATH TO "app", "public"`, "SET TIME ZONE TO 'Europe/Amsterdam'",
+		`SET SEARCH_PATH TO "app", "public"`, "SET TIME ZONE TO 'Europe/Amsterdam'",
+	}
+	if statements := server.Statements(); !reflect.DeepEqual(statements, expected) {
+		t.Fatalf("Expected the session parameters to be set on every connect, but found %q", statements)
+	}
+
+	if _, err := Open(server.Addr(), WithUser("dbadmin"), WithSessionParam("locale", "xx")); err == nil {
+		t.Fatalf("Expected connecting to fail when a session parameter cannot be set")
+	}
+}
+
+func TestResourcePool(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect(`SET SESSION RESOURCE_POOL = "dashboards"`).Tag("SET")
+	server.Expect(`SET SESSION RESOURCE_POOL = "missing"`).Error(ErrCodeUndefinedObject, `Resource pool "missing" does not exist`)
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"), WithResourcePool("dashboards"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	connection.Close()
+
+	_, err = Open(server.Addr(), WithUser("dbadmin"), WithResourcePool("missing"))
+	if err == nil || !strings.Contains(err.Error(), "Cannot use resource pool missing") {
+		t.Fatalf("Expected connecting with a missing resource pool to fail, but found %v", err)
+	}
+}
+
+func TestWorkload(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"), WithWorkload("reporting"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if err := connection.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+
+	startups := server.Startups()
+	if len(startups) != 2 {
+		t.Fatalf("Expected two sessions to be opened, but found %v", startups)
+	}
+	for _, startup := range startups {
+		if startup["workload"] != "reporting" || startup["user"] != "dbadmin" {
+			t.Fatalf("Expected the workload to be sent on every connect, but found %v", startup)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package vertigo
+
+import (
+	"context"
+)
+
+// RowSink receives the resultset of a query while it is read from the server, so it
+// can be exported, e.g. to CSV, Arrow or Parquet, without buffering it in a Resultset.
+// See QueryInto.
+//
+// The values passed to OnRow are in text format, with nil for NULL, and are only valid
+// until OnRow returns; use NewRow to scan them. When a method returns an error, the
+// query is cancelled and no more methods are called.
+type RowSink interface {
+	// Called before the rows of a statement with the fields describing them.
+	OnFields(fields []Field) error
+
+	// Called for every row of the statement.
+	OnRow(values [][]byte) error
+
+	// Called when a statement has completed, with the command tag the server sent.
+	// Statements that don't return rows only get this call.
+	OnComplete(tag CommandTag) error
+}
+
+// Returns a row with the values, which are decoded based on the fields like those of
+// the rows of a Resultset. This is meant for RowSinks.
+func NewRow(fields []Field, values [][]byte) Row {
+	return Row{Values: values, fields: fields}
+}
+
+// Runs a SQL query and passes its resultset to the sink while it is received. When
+// the SQL string contains multiple statements, the sink gets the resultsets of all of
+// them in order. The error of the sink is returned, if any; otherwise errors are
+// handled the same way as they are by Query.
+func (c *Connection) QueryInto(sink RowSink, sql string, args ...interface{}) error {
+	return c.QueryIntoContext(context.Background(), sink, sql, args...)
+}
+
+// Runs a SQL query like QueryInto, until the context is done. Cancellation is handled
+// the same way as it is by QueryContext.
+func (c *Connection) QueryIntoContext(ctx context.Context, sink RowSink, sql string, args ...interface{}) error {
+	handler := &sinkHandler{sink: sink, cancel: c.cancelRunningQuery}
+	err := c.runContext(ctx, sql, args, handler)
+	if handler.err != nil {
+		return handler.err
+	}
+	return err
+}
+
+// Passes everything that is received to a RowSink.
+type sinkHandler struct {
+	sink   RowSink
+	cancel func() // Asks the server to cancel the query
+	err    error
+}
+
+func (h *sinkHandler) handleFields(fields []Field) {
+	if h.err == nil {
+		h.check(h.sink.OnFields(fields))
+	}
+}
+
+func (h *sinkHandler) handleRow(values [][]byte) {
+	if h.err == nil {
+		h.check(h.sink.OnRow(values))
+	}
+}
+
+func (h *sinkHandler) handleComplete(result string) {
+	if h.err == nil {
+		h.check(h.sink.OnComplete(CommandTag(result)))
+	}
+}
+
+// Stops passing the resultset to the sink after it failed, and asks the server to
+// cancel the query.
+func (h *sinkHandler) check(err error) {
+	if err != nil {
+		h.err = err
+		h.cancel()
+	}
+}
@@ -0,0 +1,86 @@
+package vertigo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+// Collects what it receives, and fails after failAfter rows if that is set.
+type testSink struct {
+	fields    []Field
+	ids       []int64
+	tags      []CommandTag
+	failAfter int
+}
+
+func (s *testSink) OnFields(fields []Field) error {
+	s.fields = fields
+	return nil
+}
+
+func (s *testSink) OnRow(values [][]byte) error {
+	if s.failAfter > 0 && len(s.ids) == s.failAfter {
+		return errors.New("Disk full")
+	}
+
+	var id int64
+	if err := NewRow(s.fields, values).Scan(&id); err != nil {
+		return err
+	}
+	s.ids = append(s.ids, id)
+	return nil
+}
+
+func (s *testSink) OnComplete(tag CommandTag) error {
+	s.tags = append(s.tags, tag)
+	return nil
+}
+
+func TestQueryInto(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT id FROM t").Columns(vertigotest.Column{Name: "id", Type: DataTypeInteger}).Row(1).Row(2).Row(3)
+
+	connection, err := Open(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	sink := &testSink{}
+	if err := connection.QueryInto(sink, "SELECT id FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.ids) != 3 || sink.ids[2] != 3 {
+		t.Fatalf("Expected 3 rows, but found %v", sink.ids)
+	}
+	if len(sink.tags) != 1 || sink.tags[0].RowsAffected() != 3 {
+		t.Fatalf("Expected the command tag SELECT 3, but found %v", sink.tags)
+	}
+}
+
+func TestSinkHandlerError(t *testing.T) {
+	cancelled := false
+	sink := &testSink{failAfter: 1}
+	handler := &sinkHandler{sink: sink, cancel: func() { cancelled = true }}
+	handler.handleFields([]Field{{Name: "id", DataTypeOID: DataTypeInteger}})
+	handler.handleRow([][]byte{[]byte("1")})
+	handler.handleRow([][]byte{[]byte("2")})
+	handler.handleRow([][]byte{[]byte("3")})
+	handler.handleComplete("SELECT 3")
+
+	if handler.err == nil || handler.err.Error() != "Disk full" {
+		t.Fatalf("Expected the error of the sink, but found %v", handler.err)
+	}
+	if !cancelled {
+		t.Error("Expected the query to be cancelled")
+	}
+	if len(sink.ids) != 1 || len(sink.tags) != 0 {
+		t.Fatalf("Expected the sink to get nothing after it failed, but found %v and %v", sink.ids, sink.tags)
+	}
+}
@@ -0,0 +1,179 @@
+package vertigo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"iter"
+	"os"
+)
+
+var ErrResultTooLarge = errors.New("Resultset exceeds the configured limits")
+
+// Holds the rows of a resultset that were spilled to a temporary file. Rows are
+// stored in the same format as the body of a DataRow message.
+type spillFile struct {
+	file   *os.File
+	writer *bufio.Writer
+	rows   int
+}
+
+func createSpillFile(dir string) (*spillFile, error) {
+	file, err := os.CreateTemp(dir, "vertigo-spill-*")
+	if err != nil {
+		return nil, err
+	}
+	return &spillFile{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+func (s *spillFile) write(values [][]byte) error {
+	binary.Write(s.writer, binary.BigEndian, uint16(len(values)))
+	for _, value := range values {
+		if value == nil {
+			binary.Write(s.writer, binary.BigEndian, uint32(0xffffffff))
+			continue
+		}
+		binary.Write(s.writer, binary.BigEndian, uint32(len(value)))
+		s.writer.Write(value)
+	}
+
+	s.rows++
+
+	// The bufio.Writer keeps the first write error, so writing nothing reports it.
+	_, err := s.writer.Write(nil)
+	return err
+}
+
+// Reads all rows back from the file, and passes them to fn.
+func (s *spillFile) each(fields []Field, fn func(Row) error) error {
+	reader, err := s.reader(fields)
+	if err != nil {
+		return err
+	}
+
+	for {
+		row, err := reader.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// Reads the rows of a spill file back one at a time.
+type spillReader struct {
+	reader    *bufio.Reader
+	fields    []Field
+	remaining int
+}
+
+// Returns a reader for the rows of the file, starting at the first row.
+func (s *spillFile) reader(fields []Field) (*spillReader, error) {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &spillReader{reader: bufio.NewReader(s.file), fields: fields, remaining: s.rows}, nil
+}
+
+// Returns the next row, or io.EOF after the last row.
+func (r *spillReader) next() (Row, error) {
+	if r.remaining == 0 {
+		return Row{}, io.EOF
+	}
+	r.remaining--
+
+	// The file holds exactly the rows that were written, so it never ends early.
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r.reader, header[:2]); err == io.EOF {
+		return Row{}, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return Row{}, err
+	}
+
+	values := make([][]byte, unpackUint16(header))
+	for j := range values {
+		if _, err := io.ReadFull(r.reader, header); err != nil {
+			return Row{}, err
+		}
+
+		size := unpackUint32(header)
+		if size == 0xffffffff {
+			continue
+		}
+
+		values[j] = make([]byte, size)
+		if _, err := io.ReadFull(r.reader, values[j]); err != nil {
+			return Row{}, err
+		}
+	}
+	return Row{Values: values, fields: r.fields}, nil
+}
+
+func (s *spillFile) close() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// Returns whether some of the rows of the resultset were spilled to disk.
+func (rs *Resultset) Spilled() bool {
+	return rs.spill != nil
+}
+
+// Returns the number of rows in the resultset, including spilled rows.
+func (rs *Resultset) RowCount() int {
+	if rs.spill != nil {
+		return len(rs.Rows) + rs.spill.rows
+	}
+	return len(rs.Rows)
+}
+
+// Calls fn for every row of the resultset, including the rows that were spilled
+// to disk. It stops at the first error returned by fn.
+func (rs *Resultset) EachRow(fn func(Row) error) error {
+	for _, row := range rs.Rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	if rs.spill != nil {
+		return rs.spill.each(rs.Fields, fn)
+	}
+	return nil
+}
+
+// Returns an iterator over all rows of the resultset, including the rows that were
+// spilled to disk. An error reading the spilled rows ends the iteration.
+func (rs *Resultset) All() iter.Seq2[Row, error] {
+	return func(yield func(Row, error) bool) {
+		err := rs.EachRow(func(row Row) error {
+			if !yield(row, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && err != errStopIteration {
+			yield(Row{}, err)
+		}
+	}
+}
+
+// Returned by the function passed to EachRow when the loop over All is left early.
+var errStopIteration = errors.New("iteration stopped")
+
+// Removes the temporary file holding the spilled rows of the resultset, if any.
+// The spilled rows are no longer available afterwards. Closing a nil resultset is a no-op.
+func (rs *Resultset) Close() error {
+	if rs == nil || rs.spill == nil {
+		return nil
+	}
+
+	err := rs.spill.close()
+	rs.spill = nil
+	return err
+}
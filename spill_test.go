@@ -0,0 +1,130 @@
+package vertigo
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestResultsetHandlerMaxRows(t *testing.T) {
+	cancelled := false
+	handler := &resultsetHandler{maxRows: 2, cancel: func() { cancelled = true }}
+	handler.handleFields([]Field{{Name: "i"}})
+	for _, value := range []string{"1", "2", "3", "4"} {
+		handler.handleRow([][]byte{[]byte(value)})
+	}
+	handler.handleComplete("SELECT 4")
+
+	if !errors.Is(handler.err, ErrResultTooLarge) {
+		t.Fatalf("Expected ErrResultTooLarge, but found %v", handler.err)
+	}
+	if !cancelled {
+		t.Error("Expected the query to be cancelled")
+	}
+	if len(handler.resultset.Rows) != 0 {
+		t.Errorf("Expected the buffered rows to be released, but found %d rows", len(handler.resultset.Rows))
+	}
+}
+
+func TestResultsetHandlerMaxResultSize(t *testing.T) {
+	handler := &resultsetHandler{maxSize: 5}
+	handler.handleFields([]Field{{Name: "s"}})
+	handler.handleRow([][]byte{[]byte("abc")})
+	if handler.err != nil {
+		t.Fatal(handler.err)
+	}
+
+	handler.handleRow([][]byte{[]byte("def")})
+	if !errors.Is(handler.err, ErrResultTooLarge) {
+		t.Fatalf("Expected ErrResultTooLarge, but found %v", handler.err)
+	}
+}
+
+func TestResultsetHandlerSpill(t *testing.T) {
+	dir := t.TempDir()
+	handler := &resultsetHandler{maxRows: 1, spillDir: dir}
+	handler.handleFields([]Field{{Name: "i", DataTypeOID: DataTypeInteger}, {Name: "s"}})
+	handler.handleRow([][]byte{[]byte("1"), []byte("a")})
+	handler.handleRow([][]byte{[]byte("2"), nil})
+	handler.handleRow([][]byte{[]byte("3"), []byte("")})
+	handler.handleComplete("SELECT 3")
+	if handler.err != nil {
+		t.Fatal(handler.err)
+	}
+
+	rs := handler.resultset
+	if !rs.Spilled() || len(rs.Rows) != 1 || rs.RowCount() != 3 {
+		t.Fatalf("Expected 1 buffered and 2 spilled rows, but found %d and %d", len(rs.Rows), rs.RowCount()-len(rs.Rows))
+	}
+
+	var rows []struct {
+		I int64
+		S *string
+	}
+	if err := rs.ScanStruct(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1].I != 2 || rows[1].S != nil || rows[2].S == nil || *rows[2].S != "" {
+		t.Fatalf("Unexpected rows %+v", rows)
+	}
+
+	maps, err := rs.Maps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]interface{}{"i": int64(3), "s": ""}; !reflect.DeepEqual(maps[2], expected) {
+		t.Fatalf("Expected %v, but found %v", expected, maps[2])
+	}
+
+	var ids []int64
+	for row, err := range rs.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		var id int64
+		if err := row.Scan(&id, new(*string)); err != nil {
+			t.Fatal(err)
+		}
+		if ids = append(ids, id); id == 2 {
+			break
+		}
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2}) {
+		t.Fatalf("Expected the loop to stop after row 2, but found %v", ids)
+	}
+
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("Expected the spill file to be removed, but found %d files", len(entries))
+	}
+}
+
+func TestResultsetHandlerSpillMultipleStatements(t *testing.T) {
+	dir := t.TempDir()
+	handler := &resultsetHandler{maxRows: 1, maxSize: 4, spillDir: dir}
+	handler.handleFields([]Field{{Name: "s"}})
+	handler.handleRow([][]byte{[]byte("ab")})
+	handler.handleRow([][]byte{[]byte("cd")})
+	handler.handleComplete("SELECT 2")
+
+	handler.handleFields([]Field{{Name: "s"}})
+	handler.handleRow([][]byte{[]byte("abc")})
+	handler.handleComplete("SELECT 1")
+	if handler.err != nil {
+		t.Fatal(handler.err)
+	}
+
+	rs := handler.resultset
+	if rs.Spilled() || len(rs.Rows) != 1 {
+		t.Fatalf("Expected the limits to start over for the last statement, but found %d rows, spilled: %v", len(rs.Rows), rs.Spilled())
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("Expected the spill file of the first statement to be removed, but found %d files", len(entries))
+	}
+}
@@ -0,0 +1,166 @@
+package vertigo
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StatsCollector receives the events of a connection that statistics are derived
+// from, for example to export them to a monitoring system. The methods are called
+// synchronously, so they should be fast, and safe for concurrent use if the
+// collector is shared by connections.
+type StatsCollector interface {
+	// Called after every statement. The error is nil if the statement succeeded.
+	QueryExecuted(duration time.Duration, rows int, err error)
+
+	// Called for every message sent to and received from the server.
+	BytesSent(n int)
+	BytesReceived(n int)
+
+	// Called after every attempt to open a connection. The error is nil if it succeeded.
+	ConnectAttempted(err error)
+}
+
+// PoolStatsCollector can be implemented by a StatsCollector to also receive the events
+// of the pools whose Config it is set in, by the Name of the pool.
+type PoolStatsCollector interface {
+	// Called when Pool.Get returned a connection, with the time it waited for one to
+	// be given back, which is zero if it didn't have to wait.
+	ConnectionCheckedOut(pool string, wait time.Duration)
+
+	// Called when a connection was given back with Pool.Put.
+	ConnectionCheckedIn(pool string)
+}
+
+// A snapshot of the statistics of a connection, see Connection.Stats.
+type Stats struct {
+	Queries          int64            // The number of statements run.
+	Rows             int64            // The number of rows received.
+	BytesSent        int64            // The number of bytes sent to the server.
+	BytesReceived    int64            // The number of bytes received from the server.
+	MessagesSent     map[string]int64 // The number of messages sent to the server, by message type.
+	MessagesReceived map[string]int64 // The number of messages received from the server, by message type.
+	Errors           int64            // The number of statements that failed.
+	ErrorsBySQLSTATE map[string]int64 // The number of statements that failed with a server error, by SQLSTATE.
+	ConnectAttempts  int64            // The number of attempts to open the connection.
+	ConnectFailures  int64            // The number of attempts to open the connection that failed.
+}
+
+// The counters of a connection. They are updated atomically, as they can be read
+// while a statement is running.
+type connectionStats struct {
+	queries         int64
+	rows            int64
+	bytesSent       int64
+	bytesReceived   int64
+	errors          int64
+	connectAttempts int64
+	connectFailures int64
+
+	// Indexed by the type byte of the messages
+	messagesSent     [256]int64
+	messagesReceived [256]int64
+
+	l                sync.Mutex
+	errorsBySQLSTATE map[string]int64
+}
+
+// Returns a snapshot of the statistics of the connection. It is safe to call this
+// while the connection is in use by another goroutine.
+func (c *Connection) Stats() Stats {
+	s := &c.stats
+	stats := Stats{
+		Queries:          atomic.LoadInt64(&s.queries),
+		Rows:             atomic.LoadInt64(&s.rows),
+		BytesSent:        atomic.LoadInt64(&s.bytesSent),
+		BytesReceived:    atomic.LoadInt64(&s.bytesReceived),
+		Errors:           atomic.LoadInt64(&s.errors),
+		ConnectAttempts:  atomic.LoadInt64(&s.connectAttempts),
+		ConnectFailures:  atomic.LoadInt64(&s.connectFailures),
+		ErrorsBySQLSTATE: make(map[string]int64),
+		MessagesSent:     countMessages(&s.messagesSent),
+		MessagesReceived: countMessages(&s.messagesReceived),
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+	for state, count := range s.errorsBySQLSTATE {
+		stats.ErrorsBySQLSTATE[state] = count
+	}
+	return stats
+}
+
+func (c *Connection) recordQuery(duration time.Duration, rows int, err error) {
+	atomic.AddInt64(&c.stats.queries, 1)
+	atomic.AddInt64(&c.stats.rows, int64(rows))
+
+	if err != nil {
+		atomic.AddInt64(&c.stats.errors, 1)
+		if state := sqlState(err); state != "" {
+			c.stats.l.Lock()
+			if c.stats.errorsBySQLSTATE == nil {
+				c.stats.errorsBySQLSTATE = make(map[string]int64)
+			}
+			c.stats.errorsBySQLSTATE[state]++
+			c.stats.l.Unlock()
+		}
+	}
+
+	if c.config.StatsCollector != nil {
+		c.config.StatsCollector.QueryExecuted(duration, rows, err)
+	}
+}
+
+// Returns the non-zero counters of messages by the name of their type.
+func countMessages(counters *[256]int64) map[string]int64 {
+	counts := make(map[string]int64)
+	for messageType := range counters {
+		if count := atomic.LoadInt64(&counters[messageType]); count > 0 {
+			counts[messageTypeName(byte(messageType))] = count
+		}
+	}
+	return counts
+}
+
+func (c *Connection) recordMessageSent(messageType byte, n int) {
+	atomic.AddInt64(&c.stats.messagesSent[messageType], 1)
+	atomic.AddInt64(&c.stats.bytesSent, int64(n))
+	if c.config.StatsCollector != nil {
+		c.config.StatsCollector.BytesSent(n)
+	}
+}
+
+func (c *Connection) recordMessageReceived(messageType byte, n int) {
+	atomic.AddInt64(&c.stats.messagesReceived[messageType], 1)
+	atomic.AddInt64(&c.stats.bytesReceived, int64(n))
+	if c.config.StatsCollector != nil {
+		c.config.StatsCollector.BytesReceived(n)
+	}
+}
+
+func (c *Connection) recordConnectAttempt(err error) {
+	atomic.AddInt64(&c.stats.connectAttempts, 1)
+	if err != nil {
+		atomic.AddInt64(&c.stats.connectFailures, 1)
+	}
+	if c.config.StatsCollector != nil {
+		c.config.StatsCollector.ConnectAttempted(err)
+	}
+}
+
+// Returns the SQLSTATE of an error returned by the server, or an empty string
+// for other errors.
+func sqlState(err error) string {
+	var verticaError *VerticaError
+	if errors.As(err, &verticaError) {
+		return verticaError.Code
+	}
+
+	var response ErrorResponse
+	if errors.As(err, &response) {
+		return response.Code()
+	}
+	return ""
+}
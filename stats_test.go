@@ -0,0 +1,56 @@
+package vertigo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type countingCollector struct {
+	queries, errors, sent, received, connects int
+}
+
+func (c *countingCollector) QueryExecuted(duration time.Duration, rows int, err error) {
+	c.queries++
+	if err != nil {
+		c.errors++
+	}
+}
+
+func (c *countingCollector) BytesSent(n int)            { c.sent += n }
+func (c *countingCollector) BytesReceived(n int)        { c.received += n }
+func (c *countingCollector) ConnectAttempted(err error) { c.connects++ }
+
+func TestConnectionStats(t *testing.T) {
+	collector := &countingCollector{}
+	c := &Connection{config: &ConnectionInfo{StatsCollector: collector}}
+
+	c.recordQuery(time.Millisecond, 10, nil)
+	c.recordQuery(time.Millisecond, 0, ErrorResponseMessage{Fields: map[byte]string{'C': "42601"}})
+	c.recordQuery(time.Millisecond, 2, errors.New("connection reset"))
+	c.recordMessageSent('Q', 15)
+	c.recordMessageSent('S', 5)
+	c.recordMessageReceived('D', 20)
+	c.recordMessageReceived('D', 10)
+	c.recordConnectAttempt(nil)
+	c.recordConnectAttempt(errors.New("connection refused"))
+
+	stats := c.Stats()
+	if stats.Queries != 3 || stats.Rows != 12 || stats.Errors != 2 || stats.ErrorsBySQLSTATE["42601"] != 1 || len(stats.ErrorsBySQLSTATE) != 1 {
+		t.Errorf("Unexpected query stats %+v", stats)
+	}
+	if stats.BytesSent != 20 || stats.BytesReceived != 30 || stats.ConnectAttempts != 2 || stats.ConnectFailures != 1 {
+		t.Errorf("Unexpected connection stats %+v", stats)
+	}
+
+	if len(stats.MessagesSent) != 2 || stats.MessagesSent["Q"] != 1 || stats.MessagesSent["S"] != 1 {
+		t.Errorf("Expected a Query and a Sync message to be sent, but found %v", stats.MessagesSent)
+	}
+	if len(stats.MessagesReceived) != 1 || stats.MessagesReceived["D"] != 2 {
+		t.Errorf("Expected two DataRow messages to be received, but found %v", stats.MessagesReceived)
+	}
+
+	if collector.queries != 3 || collector.errors != 2 || collector.sent != 20 || collector.received != 30 || collector.connects != 2 {
+		t.Errorf("Unexpected collected events %+v", collector)
+	}
+}
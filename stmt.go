@@ -0,0 +1,486 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+)
+
+var (
+	ErrPortalOpen = errors.New("Connection is busy with an open portal")
+	ErrStmtClosed = errors.New("Statement is closed")
+)
+
+// A prepared statement, created with Connection.Prepare.
+type Stmt struct {
+	SQL            string   // The SQL of the statement.
+	Fields         []Field  // The fields of the rows returned by the statement, if any.
+	ParameterTypes []uint32 // The data type OIDs of the parameters of the statement.
+
+	c       *Connection
+	name    string
+	session int  // The session of the connection the statement was last prepared in
+	cached  bool // Whether the statement is owned by the statement cache of the connection
+	closed  bool
+}
+
+// A portal is a prepared statement bound to parameter values, whose rows are
+// fetched from the server in batches. While a portal is open, it is the only
+// thing that can use the connection.
+type Portal struct {
+	stmt      *Stmt
+	fetchSize int
+	suspended bool // Whether the server suspended the portal after a full batch
+	done      bool
+	tag       CommandTag
+
+	// Only used with AdaptiveFetchSize.
+	adaptive     bool
+	fetchStart   time.Time // When the current batch was requested
+	fetchedRows  int64
+	fetchedBytes int64 // The size of the values of the rows fetched so far
+}
+
+// Prepares a statement on the server. Parameters in the SQL string are written
+// as ? placeholders, and are bound when the statement is executed.
+//
+// When the StatementCacheSize of the connection is set, statements are cached by
+// their SQL, and preparing the same SQL again returns the cached statement. Closing
+// a cached statement has no effect; it is closed when it is evicted from the cache
+// to make room for another one, after which it can no longer be executed.
+//
+// Statements survive the connection being reopened, e.g. by Reconnect after a node
+// failed over: they are prepared again in the new session when they are executed.
+func (c *Connection) Prepare(sql string) (stmt *Stmt, err error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			stmt, err = nil, r.(error)
+			c.markBroken(err)
+		}
+	}()
+
+	if c.portal != nil {
+		return nil, ErrPortalOpen
+	}
+	if err := c.brokenError(); err != nil {
+		return nil, err
+	}
+	if err := c.checkReadOnly(sql); err != nil {
+		return nil, err
+	}
+	if c.socket == nil {
+		c.openConnection()
+	}
+
+	if c.config.StatementCacheSize > 0 {
+		if c.stmtCache == nil {
+			c.stmtCache = newStatementCache(c.config.StatementCacheSize)
+		}
+		if stmt := c.stmtCache.get(sql); stmt != nil {
+			return stmt, nil
+		}
+	}
+
+	c.statements++
+	stmt = &Stmt{SQL: sql, c: c, name: fmt.Sprintf("vertigo_%d", c.statements), session: c.session}
+	if stmt.ParameterTypes, stmt.Fields, err = c.parseStatement(stmt.name, sql); err != nil {
+		return nil, err
+	}
+
+	if c.stmtCache != nil {
+		c.cacheStatement(stmt)
+	}
+	c.trackStatement(stmt)
+	return stmt, nil
+}
+
+// Adds a statement to the statement cache of the session, and closes the statement
+// that was evicted to make room for it. The connection lock must be held.
+func (c *Connection) cacheStatement(s *Stmt) {
+	if c.stmtCache == nil {
+		c.stmtCache = newStatementCache(c.config.StatementCacheSize)
+	}
+	if evicted := c.stmtCache.add(s); evicted != nil {
+		if err := c.closeStatement(evicted); err != nil {
+			c.log(LogLevelWarn, "Cannot close evicted statement", "query", c.redact(evicted.SQL), "error", err)
+		}
+	}
+}
+
+// Remembers a statement that was prepared in the session, unless the statement cache
+// owns it, so it can be closed when the connection is reused. The cache bounds the
+// number of statements it keeps prepared itself.
+func (c *Connection) trackStatement(s *Stmt) {
+	if s.cached {
+		return
+	}
+	if c.prepared == nil {
+		c.prepared = make(map[*Stmt]struct{})
+	}
+	c.prepared[s] = struct{}{}
+}
+
+// Closes the open portal and, if statements is set, the statements prepared in the
+// session that are still open, which the previous user of a pooled connection may
+// have left behind. They would otherwise accumulate on the server for as long as the
+// session lasts. The statements can't be executed afterwards.
+func (c *Connection) closeOutstanding(statements bool) (err error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+			c.markBroken(err)
+		}
+	}()
+
+	if c.socket == nil {
+		return nil
+	}
+	if c.portal != nil {
+		c.sendMessage(CloseMessage{Kind: 'P'})
+		c.portal.finish()
+	}
+	if !statements || len(c.prepared) == 0 {
+		return nil
+	}
+
+	for stmt := range c.prepared {
+		stmt.closed = true
+		c.sendMessage(CloseMessage{Kind: 'S', Name: stmt.name})
+	}
+	c.log(LogLevelDebug, "Closed outstanding statements", "count", len(c.prepared))
+	c.prepared = nil
+	c.sendMessage(SyncMessage{})
+	return c.syncPortal()
+}
+
+// Parses the statement on the server under the name, and returns the data types of its
+// parameters and the fields of its rows. The connection lock must be held.
+func (c *Connection) parseStatement(name, sql string) (parameterTypes []uint32, fields []Field, err error) {
+	c.sendMessage(ParseMessage{Name: name, SQL: sql})
+	c.sendMessage(DescribeMessage{Kind: 'S', Name: name})
+	c.sendMessage(SyncMessage{})
+	for msg := c.receiveMessage(); !c.isReadyForQuery(msg); msg = c.receiveMessage() {
+		switch msg := msg.(type) {
+		case ParseCompleteMessage, NoDataMessage:
+			continue
+
+		case ParameterDescriptionMessage:
+			parameterTypes = msg.DataTypeOIDs
+
+		case RowDescriptionMessage:
+			c.prepareFields(msg.Fields)
+			fields = msg.Fields
+
+		case ErrorResponseMessage:
+			err = msg.VerticaError()
+
+		default:
+			c.handleStatelessMessage(msg)
+		}
+	}
+	return parameterTypes, fields, err
+}
+
+// Prepares a statement again in the current session, after the session it was prepared
+// in was replaced. It fails when the parameters of the statement changed, e.g. because
+// a table it uses was altered in the meantime. The connection lock must be held.
+func (c *Connection) reprepareStatement(s *Stmt) error {
+	c.log(LogLevelInfo, "Preparing statement again", "query", c.redact(s.SQL), "address", c.address)
+	parameterTypes, fields, err := c.parseStatement(s.name, s.SQL)
+	if err != nil {
+		return fmt.Errorf("Cannot prepare statement again: %w", err)
+	}
+	if len(parameterTypes) != len(s.ParameterTypes) {
+		return fmt.Errorf("Cannot prepare statement again: it has %d parameters instead of %d", len(parameterTypes), len(s.ParameterTypes))
+	}
+
+	s.ParameterTypes, s.Fields, s.session = parameterTypes, fields, c.session
+	if s.cached {
+		// The cache of the previous session is gone, so the statement is cached again,
+		// unless its SQL was prepared again in the meantime. It's closed like any other
+		// statement then.
+		s.cached = false
+		if c.config.StatementCacheSize > 0 && (c.stmtCache == nil || c.stmtCache.get(s.SQL) == nil) {
+			c.cacheStatement(s)
+		}
+	}
+	c.trackStatement(s)
+	return nil
+}
+
+// Closes the prepared statement on the server. Statements that are owned by the
+// statement cache of the connection stay open.
+func (s *Stmt) Close() (err error) {
+	c := s.c
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+			c.markBroken(err)
+		}
+	}()
+
+	if s.cached || s.closed {
+		return nil
+	}
+	if c.portal != nil {
+		return ErrPortalOpen
+	}
+	if c.socket == nil || s.session != c.session {
+		// The session the statement was prepared in is gone, and the statement with it.
+		s.closed = true
+		return nil
+	}
+	return c.closeStatement(s)
+}
+
+// Closes the prepared statement on the server. The connection lock must be held.
+func (c *Connection) closeStatement(s *Stmt) error {
+	s.closed = true
+	delete(c.prepared, s)
+	c.sendMessage(CloseMessage{Kind: 'S', Name: s.name})
+	c.sendMessage(SyncMessage{})
+	return c.syncPortal()
+}
+
+// Binds the parameter values to the statement, and starts executing it. The
+// rows are fetched in batches of at most fetchSize rows using Portal.Next, so the
+// server never sends rows faster than they are consumed. A fetchSize of zero
+// fetches all rows at once.
+//
+// With a fetchSize of AdaptiveFetchSize, the first batch has 100 rows, and the size of
+// the next batches is tuned to fetch about ConnectionInfo.FetchBytes per batch, based on
+// the width of the rows fetched so far. Batches are kept small enough for the server to
+// send them within a second, based on how long the previous batch took.
+//
+// The portal has to be read until Next returns io.EOF, or be closed, before the
+// connection can be used for anything else.
+func (s *Stmt) ExecutePortal(fetchSize int, args ...interface{}) (portal *Portal, err error) {
+	if s.closed {
+		return nil, ErrStmtClosed
+	}
+	if len(args) != len(s.ParameterTypes) {
+		return nil, fmt.Errorf("Statement has %d parameters, but got %d arguments", len(s.ParameterTypes), len(args))
+	}
+
+	values := make([][]byte, len(args))
+	for i, arg := range args {
+		if values[i], err = encodeParameter(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	c := s.c
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			portal, err = nil, r.(error)
+			c.markBroken(err)
+		}
+	}()
+
+	if c.portal != nil {
+		return nil, ErrPortalOpen
+	}
+	if err := c.brokenError(); err != nil {
+		return nil, err
+	}
+	if c.socket == nil {
+		c.openConnection()
+	}
+	if s.session != c.session {
+		if err := c.reprepareStatement(s); err != nil {
+			return nil, err
+		}
+	}
+
+	adaptive := fetchSize == AdaptiveFetchSize
+	if adaptive {
+		fetchSize = initialAdaptiveFetchSize
+	}
+	start := time.Now()
+	c.sendMessage(BindMessage{Statement: s.name, Values: values})
+	c.sendMessage(ExecuteMessage{MaxRows: uint32(fetchSize)})
+	c.sendMessage(FlushMessage{})
+
+	for {
+		switch msg := c.receiveMessage().(type) {
+		case BindCompleteMessage:
+			portal = &Portal{stmt: s, fetchSize: fetchSize, adaptive: adaptive, fetchStart: start}
+			c.portal = portal
+			return portal, nil
+
+		case ErrorResponseMessage:
+			c.sendMessage(SyncMessage{})
+			c.syncPortal()
+			return nil, msg.VerticaError()
+
+		default:
+			c.handleStatelessMessage(msg)
+		}
+	}
+}
+
+// Returns the fields of the rows of the portal.
+func (p *Portal) Fields() []Field {
+	return p.stmt.Fields
+}
+
+// Returns the command tag of the statement, once all rows have been fetched.
+func (p *Portal) CommandTag() CommandTag {
+	return p.tag
+}
+
+// Fetches the next batch of rows from the server. After the last batch, io.EOF
+// is returned and the connection can be used again.
+func (p *Portal) Next() (rows []Row, err error) {
+	c := p.stmt.c
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			p.done = true
+			rows, err = nil, r.(error)
+			c.markBroken(err)
+		}
+	}()
+
+	if p.done {
+		return nil, io.EOF
+	}
+
+	if p.suspended {
+		p.fetchStart = time.Now()
+		c.sendMessage(ExecuteMessage{MaxRows: uint32(p.fetchSize)})
+		c.sendMessage(FlushMessage{})
+		p.suspended = false
+	}
+
+	for {
+		switch msg := c.receiveMessage().(type) {
+		case DataRowMessage:
+			rows = append(rows, Row{Values: msg.Values, fields: p.stmt.Fields})
+
+		case PortalSuspendedMessage:
+			p.suspended = true
+			if p.adaptive {
+				p.adaptFetchSize(rows, time.Since(p.fetchStart))
+			}
+			return rows, nil
+
+		case CommandCompleteMessage, EmptyQueryMessage:
+			if complete, ok := msg.(CommandCompleteMessage); ok {
+				p.tag = CommandTag(complete.Result)
+			}
+			p.finish()
+			if len(rows) == 0 {
+				return nil, io.EOF
+			}
+			return rows, nil
+
+		case ErrorResponseMessage:
+			p.finish()
+			return nil, msg.VerticaError()
+
+		default:
+			c.handleStatelessMessage(msg)
+		}
+	}
+}
+
+// Returns an iterator over the remaining rows of the portal, which fetches them in
+// batches like Next:
+//
+//	for row, err := range portal.All() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// An error ends the iteration. The portal is closed when the loop ends, also when it
+// is left early, so the connection can be used again.
+func (p *Portal) All() iter.Seq2[Row, error] {
+	return func(yield func(Row, error) bool) {
+		defer p.Close()
+		for {
+			rows, err := p.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(Row{}, err)
+				return
+			}
+			for _, row := range rows {
+				if !yield(row, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Closes the portal, discarding any rows that haven't been fetched.
+func (p *Portal) Close() (err error) {
+	c := p.stmt.c
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+			c.markBroken(err)
+		}
+	}()
+
+	if p.done {
+		return nil
+	}
+
+	c.sendMessage(CloseMessage{Kind: 'P'})
+	p.finish()
+	return nil
+}
+
+// Ends the extended query, and releases the connection.
+func (p *Portal) finish() {
+	c := p.stmt.c
+	p.done = true
+	c.portal = nil
+	c.sendMessage(SyncMessage{})
+	c.syncPortal()
+}
+
+// Reads messages until the server is ready for a query after a Sync. The first
+// error the server reports is returned.
+func (c *Connection) syncPortal() (err error) {
+	for msg := c.receiveMessage(); !c.isReadyForQuery(msg); msg = c.receiveMessage() {
+		switch msg := msg.(type) {
+		case ErrorResponseMessage:
+			if err == nil {
+				err = msg.VerticaError()
+			}
+
+		case CloseCompleteMessage, DataRowMessage, PortalSuspendedMessage, CommandCompleteMessage:
+			continue
+
+		default:
+			c.handleStatelessMessage(msg)
+		}
+	}
+	return err
+}
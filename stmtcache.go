@@ -0,0 +1,39 @@
+package vertigo
+
+import "container/list"
+
+// The prepared statements of a session keyed by their SQL, that evicts the least
+// recently used statement when it is full.
+type statementCache struct {
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List // The cached statements, the most recently used first
+}
+
+func newStatementCache(size int) *statementCache {
+	return &statementCache{size: size, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Returns the cached statement for the SQL, or nil if it isn't cached.
+func (sc *statementCache) get(sql string) *Stmt {
+	element, ok := sc.entries[sql]
+	if !ok {
+		return nil
+	}
+	sc.lru.MoveToFront(element)
+	return element.Value.(*Stmt)
+}
+
+// Adds a statement to the cache, and returns the statement that was evicted to make
+// room for it, if any.
+func (sc *statementCache) add(stmt *Stmt) *Stmt {
+	stmt.cached = true
+	sc.entries[stmt.SQL] = sc.lru.PushFront(stmt)
+	if sc.lru.Len() <= sc.size {
+		return nil
+	}
+
+	evicted := sc.lru.Remove(sc.lru.Back()).(*Stmt)
+	delete(sc.entries, evicted.SQL)
+	return evicted
+}
@@ -0,0 +1,91 @@
+package vertigo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestStatementCache(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"), WithStatementCache(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	first, err := connection.Prepare("SELECT ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if stmt, err := connection.Prepare("SELECT ?"); err != nil || stmt != first {
+		t.Fatalf("Expected the cached statement to be reused, but found %v, %v", stmt, err)
+	}
+
+	if _, err := connection.Prepare("SELECT ?, ?"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.ExecutePortal(0, 1); !errors.Is(err, ErrStmtClosed) {
+		t.Fatalf("Expected the evicted statement to be closed, but found %v", err)
+	}
+
+	if stmt, err := connection.Prepare("SELECT ?"); err != nil || stmt == first {
+		t.Fatalf("Expected the evicted statement to be prepared again, but found %v, %v", stmt, err)
+	}
+}
+
+func TestStatementCacheReconnect(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT i FROM t").Columns(vertigotest.Column{Name: "i", Type: DataTypeInteger}).Row(1)
+
+	connection, err := Open(server.Addr(), WithUser("dbadmin"), WithStatementCache(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	stmt, err := connection.Prepare("SELECT i FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := connection.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	portal, err := stmt.ExecutePortal(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range portal.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The statement prepared again belongs to the cache of the new session.
+	if cached, err := connection.Prepare("SELECT i FROM t"); err != nil || cached != stmt {
+		t.Fatalf("Expected the statement to be cached again, but found %v, %v", cached, err)
+	}
+	if _, err := connection.Prepare("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stmt.ExecutePortal(0); !errors.Is(err, ErrStmtClosed) {
+		t.Fatalf("Expected the evicted statement to be closed, but found %v", err)
+	}
+	if prepared := server.PreparedStatements(); prepared != 1 {
+		t.Fatalf("Expected 1 prepared statement, but found %d", prepared)
+	}
+}
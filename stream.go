@@ -0,0 +1,176 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StreamedRow reads the values of a row directly from the connection while the row
+// is received, without materializing the row in memory. This is meant for rows with
+// huge values, like LONG VARCHAR and LONG VARBINARY columns of tens of megabytes.
+//
+// The values have to be read in order, and are only available until the function
+// the row was passed to returns.
+type StreamedRow struct {
+	body      *io.LimitedReader // The unread part of the DataRow message
+	remaining int               // The number of values that haven't been read yet
+	value     *io.LimitedReader // The unread part of the current value
+}
+
+// Returns a reader for the next value of the row, or a nil reader if the value is
+// NULL. Any unread part of the previous value is skipped. After the last value,
+// io.EOF is returned.
+func (r *StreamedRow) NextValue() (io.Reader, error) {
+	if r.value != nil {
+		if _, err := io.Copy(io.Discard, r.value); err != nil {
+			return nil, err
+		}
+		r.value = nil
+	}
+
+	if r.remaining == 0 {
+		return nil, io.EOF
+	}
+
+	var size uint32
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r.body, header); err != nil {
+		return nil, err
+	}
+	if err := decodeUint32(header, &size); err != nil {
+		return nil, err
+	}
+	r.remaining--
+
+	if size == 0xffffffff {
+		return nil, nil
+	}
+	if int64(size) > r.body.N {
+		return nil, errors.New("StreamedRow: truncated message")
+	}
+
+	r.value = &io.LimitedReader{R: r.body, N: int64(size)}
+	return r.value, nil
+}
+
+// Reads the next value of the row completely, and returns it in text format. NULL
+// values are returned as nil. After the last value, io.EOF is returned.
+func (r *StreamedRow) ReadValue() ([]byte, error) {
+	value, err := r.NextValue()
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, r.value.N)
+	if _, err := io.ReadFull(value, buffer); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// Runs a SQL query in streaming mode, and calls handle for every row while it is
+// received from the server. The values of the row are read directly from the
+// connection, see StreamedRow.
+//
+// When handle returns an error, the remaining rows are skipped, and the error is
+// returned once the query has completed. Errors are otherwise handled the same way
+// as they are by Query.
+func (c *Connection) QueryStream(handle func(fields []Field, row *StreamedRow) error, sql string, args ...interface{}) error {
+	return c.QueryStreamContext(context.Background(), handle, sql, args...)
+}
+
+// Runs a SQL query in streaming mode like QueryStream, until the context is done.
+// Then the server is asked to cancel the query, handle is no longer called, and the
+// rows that are still on their way are discarded until the server reports the query
+// has ended, so the connection can be reused. The error of the context is returned.
+func (c *Connection) QueryStreamContext(ctx context.Context, handle func(fields []Field, row *StreamedRow) error, sql string, args ...interface{}) error {
+	handler := &streamHandler{ctx: ctx, handle: handle}
+	if err := c.runContext(ctx, sql, args, handler); err != nil {
+		return err
+	}
+	return handler.err
+}
+
+// Implemented by result handlers that read DataRow messages directly from the connection.
+type rowStreamer interface {
+	streamRow(body *io.LimitedReader) error
+}
+
+// Takes the place of a DataRowMessage that was passed to a rowStreamer.
+type streamedDataRowMessage struct {
+	Size int
+}
+
+type streamHandler struct {
+	ctx    context.Context
+	handle func(fields []Field, row *StreamedRow) error
+	fields []Field
+	err    error
+}
+
+func (sh *streamHandler) handleFields(fields []Field) {
+	sh.fields = fields
+}
+
+func (sh *streamHandler) handleRow(values [][]byte) {}
+
+func (sh *streamHandler) handleComplete(result string) {}
+
+func (sh *streamHandler) streamRow(body *io.LimitedReader) error {
+	if sh.err != nil || sh.ctx != nil && sh.ctx.Err() != nil {
+		return nil
+	}
+
+	var count uint16
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(body, header); err != nil {
+		return err
+	}
+	if err := decodeUint16(header, &count); err != nil {
+		return err
+	}
+
+	sh.err = sh.handle(sh.fields, &StreamedRow{body: body, remaining: int(count)})
+	return nil
+}
+
+// Receives a message from the server. DataRow messages are passed to the streamer,
+// if it isn't nil, instead of being read into memory.
+func (c *Connection) receiveStreamedMessage(streamer rowStreamer) IncomingMessage {
+	if streamer == nil {
+		return c.receiveMessage()
+	}
+
+	messageType, bodySize, err := receiveMessageHeader(c.bufioReader, c.header[:])
+	if err != nil {
+		panic(err)
+	}
+
+	if messageType != 'D' {
+		body := c.receiveMessageBody(messageType, bodySize)
+		msg, err := parseMessage(messageType, body)
+		if err != nil {
+			panic(err)
+		}
+		c.observeReceivedMessage(messageType, bodySize, msg)
+		return msg
+	}
+
+	if c.config.WireDump != nil {
+		fmt.Fprintf(c.config.WireDump, "<= %s length=%d (body streamed, not dumped)\n", messageTypeName(messageType), bodySize+4)
+	}
+
+	body := &io.LimitedReader{R: c.bufioReader, N: int64(bodySize)}
+	if err := streamer.streamRow(body); err != nil {
+		panic(fmt.Errorf("Cannot read streamed row: %w", err))
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		panic(err)
+	}
+
+	msg := streamedDataRowMessage{Size: bodySize}
+	c.observeReceivedMessage(messageType, bodySize, msg)
+	return msg
+}
@@ -0,0 +1,107 @@
+package vertigo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestStreamedRow(t *testing.T) {
+	body := []byte{0, 0, 0, 5, 'h', 'e', 'l', 'l', 'o', 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 2, '4', '2'}
+	row := &StreamedRow{body: &io.LimitedReader{R: bytes.NewReader(body), N: int64(len(body))}, remaining: 3}
+
+	value, err := row.NextValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only read part of the value; the rest should be skipped.
+	prefix := make([]byte, 2)
+	if _, err := io.ReadFull(value, prefix); err != nil || string(prefix) != "he" {
+		t.Fatalf("Unexpected prefix %q (%v)", prefix, err)
+	}
+
+	if value, err := row.NextValue(); err != nil || value != nil {
+		t.Fatalf("Expected a nil reader for NULL, but found %v (%v)", value, err)
+	}
+
+	if value, err := row.ReadValue(); err != nil || string(value) != "42" {
+		t.Fatalf("Expected 42, but found %q (%v)", value, err)
+	}
+
+	if _, err := row.NextValue(); err != io.EOF {
+		t.Fatalf("Expected io.EOF after the last value, but found %v", err)
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	var values []string
+	handler := &streamHandler{handle: func(fields []Field, row *StreamedRow) error {
+		value, err := row.ReadValue()
+		if err != nil {
+			return err
+		}
+		values = append(values, string(value))
+		if len(values) == 2 {
+			return errors.New("enough")
+		}
+		return nil
+	}}
+
+	for _, value := range []string{"a", "b", "c"} {
+		body := append([]byte{0, 1, 0, 0, 0, 1}, value...)
+		if err := handler.streamRow(&io.LimitedReader{R: bytes.NewReader(body), N: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(values) != 2 || handler.err == nil || handler.err.Error() != "enough" {
+		t.Fatalf("Expected rows after the error to be skipped, but found %v (%v)", values, handler.err)
+	}
+}
+
+func TestQueryStreamContext(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT a FROM big").Columns(vertigotest.Column{Name: "a"}).Row(1).Row(2).Delay(200 * time.Millisecond)
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "a"}).Row(1)
+
+	connection, err := Connect(&ConnectionInfo{Address: server.Addr(), User: "dbadmin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	rows := 0
+	err = connection.QueryStreamContext(ctx, func(fields []Field, row *StreamedRow) error {
+		rows++
+		return nil
+	}, "SELECT a FROM big")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the context error, but found %v", err)
+	}
+	if rows != 0 {
+		t.Fatalf("Expected the rows received after the cancellation to be discarded, but found %d", rows)
+	}
+	if server.CancelRequests() != 1 {
+		t.Fatalf("Expected the query to be cancelled, but found %d cancel requests", server.CancelRequests())
+	}
+
+	if rs, err := connection.Query("SELECT 1"); err != nil || len(rs.Rows) != 1 {
+		t.Fatalf("Expected the connection to be reusable, but found %v, %v", rs, err)
+	}
+	if !connection.IsAlive() {
+		t.Fatalf("Expected the connection to be drained instead of closed")
+	}
+}
@@ -0,0 +1,152 @@
+package vertigo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	maxTime = time.Date(294277, 1, 1, 0, 0, 0, 0, time.UTC)  // Just after the last timestamp Vertica supports
+	minTime = time.Date(-4713, 11, 24, 0, 0, 0, 0, time.UTC) // The first timestamp Vertica supports
+)
+
+// Parses a DATE, TIME, TIMETZ, TIMESTAMP or TIMESTAMPTZ value in text format.
+//
+// Values without a time zone are returned in UTC, keeping their wall clock time.
+// Timestamps with a time zone are converted into loc, or keep the offset they were
+// sent with if loc is nil. Values of type TIME and TIMETZ are returned on January 1st
+// of year 0.
+func parseTime(src string, loc *time.Location, coercion *coercionPolicy) (time.Time, error) {
+	switch src {
+	case "infinity":
+		return maxTime, coercion.report(&CoercionWarning{Value: src, Type: "time.Time", Reason: "infinite timestamp replaced by the maximum timestamp"})
+	case "-infinity":
+		return minTime, coercion.report(&CoercionWarning{Value: src, Type: "time.Time", Reason: "infinite timestamp replaced by the minimum timestamp"})
+	}
+
+	s := src
+	bc := strings.HasSuffix(s, " BC")
+	if bc {
+		s = s[:len(s)-3]
+	}
+
+	zone, hasZone := time.UTC, false
+	if colon := strings.IndexByte(s, ':'); colon >= 0 {
+		if sign := strings.LastIndexAny(s, "+-"); sign > colon {
+			offset, err := parseTimeZoneOffset(s[sign:])
+			if err != nil {
+				return time.Time{}, fmt.Errorf("Invalid time zone offset in %q", src)
+			}
+			zone, hasZone, s = time.FixedZone("", offset), true, s[:sign]
+		}
+	}
+
+	var (
+		datePart, clockPart string
+		year, month, day    = 0, 1, 1
+		hour, min, sec, ns  int
+		err                 error
+	)
+
+	switch space := strings.IndexByte(s, ' '); {
+	case space >= 0:
+		datePart, clockPart = s[:space], s[space+1:]
+	case strings.IndexByte(s, ':') >= 0:
+		clockPart = s
+	default:
+		datePart = s
+	}
+
+	if datePart != "" {
+		if year, month, day, err = parseDate(datePart); err != nil {
+			return time.Time{}, fmt.Errorf("Invalid date in %q", src)
+		}
+		if bc {
+			year = 1 - year
+		}
+	}
+
+	if clockPart != "" {
+		if hour, min, sec, ns, err = parseClock(clockPart); err != nil {
+			return time.Time{}, fmt.Errorf("Invalid time in %q", src)
+		}
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, min, sec, ns, zone)
+	if hasZone && datePart != "" && loc != nil {
+		t = t.In(loc)
+	}
+	return t, nil
+}
+
+// Parses a date in the format YYYY-MM-DD. The year can have more than 4 digits.
+func parseDate(s string) (year, month, day int, err error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("Invalid date %q", s)
+	}
+
+	if year, err = strconv.Atoi(parts[0]); err != nil {
+		return
+	}
+	if month, err = strconv.Atoi(parts[1]); err != nil {
+		return
+	}
+	day, err = strconv.Atoi(parts[2])
+	return
+}
+
+// Parses a time of day in the format HH:MM:SS, with an optional fraction of
+// up to nanosecond precision.
+func parseClock(s string) (hour, min, sec, ns int, err error) {
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		fraction := s[dot+1:]
+		if len(fraction) > 9 {
+			fraction = fraction[:9]
+		}
+		if ns, err = strconv.Atoi(fraction + strings.Repeat("0", 9-len(fraction))); err != nil {
+			return
+		}
+		s = s[:dot]
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, 0, 0, 0, fmt.Errorf("Invalid time %q", s)
+	}
+
+	if hour, err = strconv.Atoi(parts[0]); err != nil {
+		return
+	}
+	if min, err = strconv.Atoi(parts[1]); err != nil {
+		return
+	}
+	sec, err = strconv.Atoi(parts[2])
+	return
+}
+
+// Parses a time zone offset like +02, -05:30 or +01:02:03, and returns it in
+// seconds east of UTC.
+func parseTimeZoneOffset(s string) (int, error) {
+	sign := 1
+	if s[0] == '-' {
+		sign = -1
+	}
+
+	parts := strings.Split(s[1:], ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("Invalid time zone offset %q", s)
+	}
+
+	offset := 0
+	for i, unit := range []int{3600, 60, 1}[:len(parts)] {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0, err
+		}
+		offset += n * unit
+	}
+	return sign * offset, nil
+}
@@ -0,0 +1,62 @@
+package vertigo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		src      string
+		location *time.Location
+		expected time.Time
+	}{
+		{"2015-01-02", nil, time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2015-01-02 13:14:15", amsterdam, time.Date(2015, 1, 2, 13, 14, 15, 0, time.UTC)},
+		{"2015-01-02 13:14:15.123456", nil, time.Date(2015, 1, 2, 13, 14, 15, 123456000, time.UTC)},
+		{"2015-01-02 13:14:15+00", amsterdam, time.Date(2015, 1, 2, 14, 14, 15, 0, amsterdam)},
+		{"2015-01-02 13:14:15.5-05:30", nil, time.Date(2015, 1, 2, 13, 14, 15, 500000000, time.FixedZone("", -19800))},
+		{"13:14:15", nil, time.Date(0, 1, 1, 13, 14, 15, 0, time.UTC)},
+		{"13:14:15+02", amsterdam, time.Date(0, 1, 1, 13, 14, 15, 0, time.FixedZone("", 7200))},
+		{"0044-03-15 BC", nil, time.Date(-43, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"294276-12-31", nil, time.Date(294276, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		parsed, err := parseTime(test.src, test.location, nil)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %s", test.src, err)
+		} else if !parsed.Equal(test.expected) || parsed.Location().String() != test.expected.Location().String() {
+			t.Errorf("Expected %q to be parsed as %s, but found %s", test.src, test.expected, parsed)
+		}
+	}
+}
+
+func TestParseInfiniteTime(t *testing.T) {
+	if parsed, err := parseTime("infinity", nil, nil); err != nil || !parsed.Equal(maxTime) {
+		t.Errorf("Expected infinity to be replaced by the maximum timestamp, but found %s", parsed)
+	}
+
+	if _, err := parseTime("-infinity", nil, &coercionPolicy{strict: true}); err == nil {
+		t.Error("Expected an error for an infinite timestamp in strict mode")
+	}
+}
+
+func TestRowScanTime(t *testing.T) {
+	fields := []Field{{Name: "ts", DataTypeOID: DataTypeTimestampTZ, location: time.UTC}}
+	row := Row{Values: [][]byte{[]byte("2015-01-02 13:14:15+02")}, fields: fields}
+
+	var ts time.Time
+	if err := row.Scan(&ts); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := time.Date(2015, 1, 2, 11, 14, 15, 0, time.UTC); ts != expected {
+		t.Fatalf("Expected %s, but found %s", expected, ts)
+	}
+}
@@ -0,0 +1,260 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrSubclusterUnavailable = errors.New("Cannot connect to subcluster")
+
+const (
+	defaultTopologyRefreshInterval = time.Minute
+
+	topologyQuery = "SELECT node_name, export_address, node_state, subcluster_name FROM v_catalog.nodes ORDER BY node_name"
+)
+
+// A node of the cluster, as discovered by a Topology.
+type Node struct {
+	Name       string
+	Address    string // The address to connect to, including the port
+	State      string // The state of the node, e.g. "UP" or "DOWN"
+	Subcluster string // The subcluster of the node in Eon mode
+}
+
+// Discovers the nodes of a cluster, so connections can fail over to other nodes and be
+// spread over the cluster. Set the same Topology as the ConnectionInfo.Topology of all
+// connections to a cluster; it is safe for concurrent use.
+//
+// The nodes are discovered from v_catalog.nodes by the first connection that is opened,
+// and again when a connection is opened after RefreshInterval has passed. Connections
+// try the nodes that are UP when the node they would connect to is unreachable.
+type Topology struct {
+	// How long the discovered nodes are used before they are discovered again.
+	// Zero uses a default of one minute.
+	RefreshInterval time.Duration
+
+	// When set, new connections are spread round-robin over the nodes that are UP,
+	// instead of going to the ConnectionInfo.Address first.
+	Distribute bool
+
+	// The port to connect to on the discovered nodes. Zero uses the port of the
+	// ConnectionInfo.Address.
+	Port int
+
+	mu        sync.Mutex
+	nodes     []Node
+	refreshed time.Time
+	next      int // The index of the UP node the next distributed connection goes to
+}
+
+// Returns the nodes that were discovered, in the order of their names.
+func (t *Topology) Nodes() []Node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Node(nil), t.nodes...)
+}
+
+// Returns whether the nodes should be discovered again.
+func (t *Topology) stale() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	interval := t.RefreshInterval
+	if interval == 0 {
+		interval = defaultTopologyRefreshInterval
+	}
+	return time.Since(t.refreshed) > interval
+}
+
+// Replaces the discovered nodes. The resultset is that of topologyQuery.
+func (t *Topology) update(rs *Resultset, port string) {
+	nodes := make([]Node, 0, len(rs.Rows))
+	for _, row := range rs.Rows {
+		if len(row.Values) < 4 {
+			continue
+		}
+
+		if t.Port != 0 {
+			port = strconv.Itoa(t.Port)
+		}
+		nodes = append(nodes, Node{
+			Name:       string(row.Values[0]),
+			Address:    net.JoinHostPort(string(row.Values[1]), port),
+			State:      string(row.Values[2]),
+			Subcluster: string(row.Values[3]),
+		})
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nodes = nodes
+	t.refreshed = time.Now()
+}
+
+// Marks the nodes as discovered without changing them, so a failing discovery isn't
+// retried by every connection that is opened.
+func (t *Topology) touch() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refreshed = time.Now()
+}
+
+// Returns the addresses a connection should try, in order. The configured addresses
+// come first, unless connections are distributed; then they are the last resort.
+//
+// When a subcluster is given and its nodes are known, only its UP nodes are returned.
+// Until the nodes are discovered, the configured addresses are used.
+func (t *Topology) addresses(configured []string, subcluster string) ([]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var up []string
+	known := false
+	for _, node := range t.nodes {
+		if subcluster != "" && node.Subcluster != subcluster {
+			continue
+		}
+		known = true
+		if node.State == "UP" {
+			up = append(up, node.Address)
+		}
+	}
+
+	if subcluster != "" && len(t.nodes) > 0 {
+		switch {
+		case !known:
+			return nil, fmt.Errorf("%w: there is no subcluster %s", ErrSubclusterUnavailable, subcluster)
+		case len(up) == 0:
+			return nil, fmt.Errorf("%w: no nodes of subcluster %s are UP", ErrSubclusterUnavailable, subcluster)
+		}
+	}
+
+	if len(up) > 0 && (t.Distribute || subcluster != "") {
+		start := 0
+		if t.Distribute {
+			start = t.next % len(up)
+			t.next++
+		}
+		addresses := append(append([]string(nil), up[start:]...), up[:start]...)
+		if subcluster != "" {
+			return addresses, nil
+		}
+		for _, address := range configured {
+			addresses = appendMissing(addresses, address)
+		}
+		return addresses, nil
+	}
+
+	addresses := append([]string(nil), configured...)
+	for _, address := range up {
+		addresses = appendMissing(addresses, address)
+	}
+	return addresses, nil
+}
+
+// Returns whether the address is that of a node of the subcluster. The second return
+// value is false if no nodes were discovered.
+func (t *Topology) inSubcluster(address, subcluster string) (bool, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, node := range t.nodes {
+		if node.Address == address {
+			return node.Subcluster == subcluster, true
+		}
+	}
+	return false, len(t.nodes) > 0
+}
+
+// Appends the address to the list, unless it is already in it.
+func appendMissing(addresses []string, address string) []string {
+	for _, a := range addresses {
+		if a == address {
+			return addresses
+		}
+	}
+	return append(addresses, address)
+}
+
+// Discovers the nodes of the cluster if the topology is stale. Discovery is best effort:
+// a failure is logged, and the nodes that were discovered before are kept.
+func (c *Connection) refreshTopology() {
+	t := c.topology()
+	if t == nil || !t.stale() {
+		return
+	}
+
+	_, port, err := net.SplitHostPort(c.address)
+	if err == nil {
+		handler := &resultsetHandler{}
+		if err = c.execInternal(topologyQuery, handler); err == nil && handler.resultset != nil {
+			t.update(handler.resultset, port)
+			return
+		}
+	}
+
+	t.touch()
+	c.log(LogLevelWarn, "Cannot discover cluster topology", "address", c.address, "error", err)
+}
+
+// Returns the topology of the cluster the connection uses, if any. When a Subcluster
+// is configured without a Topology, the connection discovers the nodes on its own.
+func (c *Connection) topology() *Topology {
+	if c.config.Topology != nil {
+		return c.config.Topology
+	}
+	if c.config.Subcluster != "" && c.privateTopology == nil {
+		c.privateTopology = &Topology{}
+	}
+	return c.privateTopology
+}
+
+// Opens the TCP socket to the first reachable address. Without a Topology, those are
+// just the configured addresses.
+func (c *Connection) dial() (net.Conn, error) {
+	addresses, err := c.configuredAddresses()
+	if err != nil {
+		return nil, err
+	}
+	if t := c.topology(); t != nil {
+		if addresses, err = t.addresses(addresses, c.config.Subcluster); err != nil {
+			return nil, err
+		}
+	}
+
+	var dialError error
+	for _, address := range addresses {
+		socket, err := dialTimeout(c.dialFunc(), address, c.config.ConnectTimeout)
+		if err == nil {
+			c.address = address
+			return socket, nil
+		}
+		if dialError == nil {
+			dialError = err
+		}
+		if len(addresses) > 1 {
+			c.log(LogLevelWarn, "Cannot connect to node", "address", address, "error", err)
+		}
+	}
+	return nil, dialError
+}
+
+// Checks that the connection was opened to a node of the configured Subcluster. Returns
+// false if it should be reopened, because it was opened to the configured address before
+// the nodes of the subcluster were known. This function will panic if the subcluster
+// can't be verified.
+func (c *Connection) checkSubcluster() bool {
+	if c.config.Subcluster == "" {
+		return true
+	}
+
+	in, discovered := c.topology().inSubcluster(c.address, c.config.Subcluster)
+	if !discovered {
+		panic(fmt.Errorf("%w: cannot discover the nodes of subcluster %s", ErrSubclusterUnavailable, c.config.Subcluster))
+	}
+	return in
+}
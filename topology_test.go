@@ -0,0 +1,145 @@
+package vertigo
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestTopologyAddresses(t *testing.T) {
+	topology := &Topology{nodes: []Node{
+		{Name: "node1", Address: "10.0.0.1:5433", State: "UP"},
+		{Name: "node2", Address: "10.0.0.2:5433", State: "DOWN"},
+		{Name: "node3", Address: "10.0.0.3:5433", State: "UP"},
+	}}
+
+	if addresses, _ := topology.addresses([]string{"10.0.0.3:5433"}, ""); !reflect.DeepEqual(addresses, []string{"10.0.0.3:5433", "10.0.0.1:5433"}) {
+		t.Fatalf("Expected the configured address first, then the UP nodes, but found %q", addresses)
+	}
+
+	topology.Distribute = true
+	expected := [][]string{
+		{"10.0.0.1:5433", "10.0.0.3:5433", "lb:5433"},
+		{"10.0.0.3:5433", "10.0.0.1:5433", "lb:5433"},
+		{"10.0.0.1:5433", "10.0.0.3:5433", "lb:5433"},
+	}
+	for _, e := range expected {
+		if addresses, _ := topology.addresses([]string{"lb:5433"}, ""); !reflect.DeepEqual(addresses, e) {
+			t.Fatalf("Expected connections to be distributed over the UP nodes, but found %q", addresses)
+		}
+	}
+}
+
+func TestTopologyDiscovery(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect(topologyQuery).
+		Columns(vertigotest.Column{Name: "node_name"}, vertigotest.Column{Name: "export_address"}, vertigotest.Column{Name: "node_state"}, vertigotest.Column{Name: "subcluster_name"}).
+		Row("v_vmart_node0001", "10.0.0.1", "UP", "default_subcluster").
+		Row("v_vmart_node0002", "10.0.0.2", "DOWN", "default_subcluster")
+
+	topology := &Topology{}
+	connection, err := Open(server.Addr(), WithUser("dbadmin"), func(config *ConnectionInfo) { config.Topology = topology })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	_, port, _ := net.SplitHostPort(server.Addr())
+	expected := []Node{
+		{Name: "v_vmart_node0001", Address: "10.0.0.1:" + port, State: "UP", Subcluster: "default_subcluster"},
+		{Name: "v_vmart_node0002", Address: "10.0.0.2:" + port, State: "DOWN", Subcluster: "default_subcluster"},
+	}
+	if nodes := topology.Nodes(); !reflect.DeepEqual(nodes, expected) {
+		t.Fatalf("Expected the discovered nodes, but found %+v", nodes)
+	}
+}
+
+func TestTopologyFailover(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := listener.Addr().String()
+	listener.Close()
+
+	topology := &Topology{
+		nodes:     []Node{{Name: "node1", Address: server.Addr(), State: "UP"}},
+		refreshed: time.Now(),
+	}
+	connection, err := Open(unreachable, WithUser("dbadmin"), func(config *ConnectionInfo) { config.Topology = topology })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if connection.Address() != server.Addr() {
+		t.Fatalf("Expected the connection to fail over to %s, but found %s", server.Addr(), connection.Address())
+	}
+}
+
+func TestSubclusterAddresses(t *testing.T) {
+	topology := &Topology{nodes: []Node{
+		{Name: "node1", Address: "10.0.0.1:5433", State: "UP", Subcluster: "etl"},
+		{Name: "node2", Address: "10.0.0.2:5433", State: "UP", Subcluster: "dashboards"},
+		{Name: "node3", Address: "10.0.0.3:5433", State: "DOWN", Subcluster: "analytics"},
+	}}
+
+	if addresses, err := topology.addresses([]string{"10.0.0.1:5433"}, "dashboards"); err != nil || !reflect.DeepEqual(addresses, []string{"10.0.0.2:5433"}) {
+		t.Fatalf("Expected only the nodes of the subcluster, but found %q, %v", addresses, err)
+	}
+	if _, err := topology.addresses([]string{"10.0.0.1:5433"}, "analytics"); !errors.Is(err, ErrSubclusterUnavailable) {
+		t.Fatalf("Expected ErrSubclusterUnavailable for a subcluster without UP nodes, but found %v", err)
+	}
+	if _, err := topology.addresses([]string{"10.0.0.1:5433"}, "missing"); !errors.Is(err, ErrSubclusterUnavailable) {
+		t.Fatalf("Expected ErrSubclusterUnavailable for an unknown subcluster, but found %v", err)
+	}
+}
+
+func TestSubcluster(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	nodes := func(subcluster string) {
+		server.Expect(topologyQuery).Once().
+			Columns(vertigotest.Column{Name: "node_name"}, vertigotest.Column{Name: "export_address"}, vertigotest.Column{Name: "node_state"}, vertigotest.Column{Name: "subcluster_name"}).
+			Row("v_vmart_node0001", "127.0.0.1", "UP", subcluster)
+	}
+	nodes("dashboards")
+
+	// The first connection goes to the configured address, which doesn't match the
+	// address of the node, so it is reopened to the node.
+	host, port, _ := net.SplitHostPort(server.Addr())
+	topology := &Topology{}
+	connection, err := Open(net.JoinHostPort("localhost", port), WithUser("dbadmin"), WithSubcluster("dashboards"), func(config *ConnectionInfo) { config.Topology = topology })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if connection.Address() != net.JoinHostPort(host, port) {
+		t.Fatalf("Expected the connection to be reopened to the node of the subcluster, but found %s", connection.Address())
+	}
+
+	topology.RefreshInterval = time.Nanosecond
+	nodes("etl")
+	if err := connection.Reconnect(); !errors.Is(err, ErrSubclusterUnavailable) {
+		t.Fatalf("Expected ErrSubclusterUnavailable when the node moved to another subcluster, but found %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Returned by RunInTransaction when the connection is already in a transaction.
+var ErrInTransaction = errors.New("Connection is already in a transaction")
+
+// Runs fn in a transaction, and commits it when fn returns nil. The statements of the
+// transaction are run by fn on tx, which is the connection itself.
+//
+// When fn or the commit fails, the transaction is rolled back, and it is retried as a
+// whole according to the policy, which defaults to retrying transient errors like
+// serialization failures and lost connections, see IsRetryable. fn must therefore be
+// safe to run more than once. A connection that was lost is reopened with Reconnect
+// before the transaction is retried, but a commit that fails because the connection
+// was lost is not retried, as it may have succeeded. Waiting between attempts ends when the
+// context is done, but the context is only passed to the statements of fn if fn uses
+// it.
+//
+// The error of the last attempt is returned. A panic in fn rolls the transaction back
+// before it is propagated.
+func (c *Connection) RunInTransaction(ctx context.Context, fn func(tx *Connection) error, policy RetryPolicy) error {
+	if status := c.TransactionStatus(); status == TransactionStatusInTransaction || status == TransactionStatusFailed {
+		return ErrInTransaction
+	}
+
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	for attempt := 1; ; attempt++ {
+		committing, err := c.runTransaction(ctx, fn)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.Attempts || !retryable(err) || (committing && IsConnectionError(err)) {
+			return err
+		}
+
+		delay := policy.delay(attempt)
+		c.log(LogLevelWarn, "Transaction failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		// A connection that was lost is broken, and would fail every retry at BEGIN.
+		if !c.IsAlive() {
+			if err := c.Reconnect(); err != nil {
+				c.log(LogLevelWarn, "Cannot reconnect to retry transaction", "attempt", attempt, "error", err)
+			}
+		}
+	}
+}
+
+// Makes a single attempt to run a transaction, and rolls it back when it fails. It
+// returns whether the error, if any, is the error of the commit.
+func (c *Connection) runTransaction(ctx context.Context, fn func(tx *Connection) error) (committing bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.rollback()
+			panic(r)
+		}
+		if err != nil {
+			c.rollback()
+		}
+	}()
+
+	if _, err = c.ExecContext(ctx, "BEGIN"); err != nil {
+		return false, err
+	}
+	if err = fn(c); err != nil {
+		return false, err
+	}
+	// The commit isn't cancelled with the context, as it would be unclear whether the
+	// transaction was committed.
+	if _, err = c.Exec("COMMIT"); err != nil {
+		return true, fmt.Errorf("Cannot commit transaction: %w", err)
+	}
+	return false, nil
+}
+
+// Rolls back the transaction of the connection, if it is still in one. Errors are
+// only logged, as the error that caused the rollback is more relevant, and a
+// connection that broke doesn't have a transaction to roll back anymore.
+func (c *Connection) rollback() {
+	if status := c.TransactionStatus(); status != TransactionStatusInTransaction && status != TransactionStatusFailed {
+		return
+	}
+	if _, err := c.Exec("ROLLBACK"); err != nil {
+		c.log(LogLevelWarn, "Cannot roll back transaction", "error", err)
+	}
+}
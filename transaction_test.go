@@ -0,0 +1,114 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestRunInTransaction(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("BEGIN").TransactionStatus('T')
+	server.Expect("COMMIT").TransactionStatus('I')
+	server.Expect("ROLLBACK").TransactionStatus('I')
+	server.Expect("UPDATE t SET a = 1").Error(ErrCodeSerializationFailure, "Serialization failure").TransactionStatus('E').Once()
+	server.Expect("UPDATE t SET a = 1").Tag("UPDATE 1").TransactionStatus('T')
+	server.Expect("UPDATE t SET a = 2").Error(ErrCodeSyntaxError, "Syntax error").TransactionStatus('E')
+
+	connection, err := Open(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	policy := RetryPolicy{Attempts: 3, Backoff: 1}
+	attempts := 0
+	err = connection.RunInTransaction(context.Background(), func(tx *Connection) error {
+		attempts++
+		_, err := tx.Exec("UPDATE t SET a = 1")
+		return err
+	}, policy)
+	if err != nil || attempts != 2 {
+		t.Fatalf("Expected the transaction to succeed on the second attempt, but found %v after %d attempts", err, attempts)
+	}
+
+	err = connection.RunInTransaction(context.Background(), func(tx *Connection) error {
+		_, err := tx.Exec("UPDATE t SET a = 2")
+		return err
+	}, policy)
+	if sqlState(err) != ErrCodeSyntaxError {
+		t.Fatalf("Expected the syntax error without retrying, but found %v", err)
+	}
+
+	failure := errors.New("failure")
+	if err := connection.RunInTransaction(context.Background(), func(tx *Connection) error { return failure }, policy); err != failure {
+		t.Fatalf("Expected the error of the function, but found %v", err)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "panic" {
+				t.Fatalf("Expected the panic to be propagated, but found %v", r)
+			}
+		}()
+		connection.RunInTransaction(context.Background(), func(tx *Connection) error { panic("panic") }, policy)
+	}()
+
+	expected := []string{
+		"BEGIN", "UPDATE t SET a = 1", "ROLLBACK", "BEGIN", "UPDATE t SET a = 1", "COMMIT",
+		"BEGIN", "UPDATE t SET a = 2", "ROLLBACK",
+		"BEGIN", "ROLLBACK",
+		"BEGIN", "ROLLBACK",
+	}
+	if statements := server.Statements(); strings.Join(statements, ", ") != strings.Join(expected, ", ") {
+		t.Fatalf("Expected statements %q, but found %q", expected, statements)
+	}
+	if status := connection.TransactionStatus(); status != TransactionStatusIdle {
+		t.Fatalf("Expected the transaction to be over, but found %v", status)
+	}
+
+	if _, err := connection.Exec("BEGIN"); err != nil {
+		t.Fatal(err)
+	}
+	if err := connection.RunInTransaction(context.Background(), func(tx *Connection) error { return nil }, policy); err != ErrInTransaction {
+		t.Fatalf("Expected ErrInTransaction, but found %v", err)
+	}
+}
+
+func TestRunInTransactionReconnects(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("BEGIN").TransactionStatus('T')
+	server.Expect("COMMIT").TransactionStatus('I')
+	server.Expect("UPDATE t SET a = 1").Disconnect().Once()
+	server.Expect("UPDATE t SET a = 1").Tag("UPDATE 1")
+
+	connection, err := Open(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	attempts := 0
+	err = connection.RunInTransaction(context.Background(), func(tx *Connection) error {
+		attempts++
+		_, err := tx.Exec("UPDATE t SET a = 1")
+		return err
+	}, RetryPolicy{Attempts: 3, Backoff: 1})
+	if err != nil || attempts != 2 {
+		t.Fatalf("Expected the transaction to succeed on a new connection, but found %v after %d attempts", err, attempts)
+	}
+	if startups := server.Startups(); len(startups) != 2 {
+		t.Fatalf("Expected the connection to be reopened once, but found %d sessions", len(startups))
+	}
+}
@@ -0,0 +1,154 @@
+package vertigo
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Data type OIDs of Vertica's built-in types, as found in Field.DataTypeOID.
+const (
+	DataTypeBoolean       = 5
+	DataTypeInteger       = 6
+	DataTypeFloat         = 7
+	DataTypeChar          = 8
+	DataTypeVarchar       = 9
+	DataTypeDate          = 10
+	DataTypeTime          = 11
+	DataTypeTimestamp     = 12
+	DataTypeTimestampTZ   = 13
+	DataTypeInterval      = 14
+	DataTypeTimeTZ        = 15
+	DataTypeNumeric       = 16
+	DataTypeVarbinary     = 17
+	DataTypeUUID          = 20
+	DataTypeIntervalYM    = 114
+	DataTypeLongVarchar   = 115
+	DataTypeLongVarbinary = 116
+	DataTypeBinary        = 117
+)
+
+// The names of the built-in data types, by OID.
+var dataTypeNames = map[uint32]string{
+	DataTypeBoolean:       "BOOLEAN",
+	DataTypeInteger:       "INTEGER",
+	DataTypeFloat:         "FLOAT",
+	DataTypeChar:          "CHAR",
+	DataTypeVarchar:       "VARCHAR",
+	DataTypeDate:          "DATE",
+	DataTypeTime:          "TIME",
+	DataTypeTimestamp:     "TIMESTAMP",
+	DataTypeTimestampTZ:   "TIMESTAMPTZ",
+	DataTypeInterval:      "INTERVAL",
+	DataTypeTimeTZ:        "TIMETZ",
+	DataTypeNumeric:       "NUMERIC",
+	DataTypeVarbinary:     "VARBINARY",
+	DataTypeUUID:          "UUID",
+	DataTypeIntervalYM:    "INTERVAL YEAR TO MONTH",
+	DataTypeLongVarchar:   "LONG VARCHAR",
+	DataTypeLongVarbinary: "LONG VARBINARY",
+	DataTypeBinary:        "BINARY",
+	DataTypeRow:           "ROW",
+	DataTypeArray:         "ARRAY",
+	DataTypeMap:           "MAP",
+}
+
+// Returns the name of the data type, like "VARCHAR" or "ARRAY[INTEGER]", or an
+// empty string if the type isn't known.
+func dataTypeName(oid uint32) string {
+	if name, ok := dataTypeNames[oid]; ok {
+		return name
+	}
+
+	element, ok := collectionElementType(oid)
+	if !ok || dataTypeNames[element] == "" {
+		return ""
+	}
+	if oid > setTypeOffset {
+		return "SET[" + dataTypeNames[element] + "]"
+	}
+	return "ARRAY[" + dataTypeNames[element] + "]"
+}
+
+// Decodes a value in text format into the Go type matching the data type of the field.
+// NULL values are decoded as nil. Types without a more specific representation are
+// decoded as strings, ARRAY and SET values as []interface{}, and ROW values as
+// map[string]interface{}. Codecs registered with RegisterType take precedence.
+func decodeValue(field Field, src []byte) (interface{}, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	if codec := lookupCodec(field.DataTypeOID); codec != nil {
+		return codec.Decode(field, src)
+	}
+
+	switch field.DataTypeOID {
+	case DataTypeBoolean:
+		return strconv.ParseBool(string(src))
+
+	case DataTypeInteger:
+		return strconv.ParseInt(string(src), 10, 64)
+
+	case DataTypeFloat:
+		return strconv.ParseFloat(string(src), 64)
+
+	case DataTypeDate, DataTypeTime, DataTypeTimeTZ, DataTypeTimestamp, DataTypeTimestampTZ:
+		return parseTime(string(src), field.location, field.coercion)
+
+	case DataTypeNumeric:
+		return ParseDecimal(string(src))
+
+	case DataTypeInterval, DataTypeIntervalYM:
+		return parseInterval(field.DataTypeOID, field.TypeModifier, string(src))
+
+	case DataTypeUUID:
+		return ParseUUID(string(src))
+
+	case DataTypeVarbinary, DataTypeLongVarbinary, DataTypeBinary:
+		return decodeBinary(&field, src)
+
+	case DataTypeRow, DataTypeArray, DataTypeMap:
+		return decodeComplexValue(src)
+
+	default:
+		if _, ok := collectionElementType(field.DataTypeOID); ok {
+			return decodeCollection(field, src)
+		}
+		return string(src), nil
+	}
+}
+
+// Returns the values of the row keyed by column name, decoded into the Go type
+// matching the data type of each column.
+func (r Row) Map(fields []Field) (map[string]interface{}, error) {
+	if len(fields) != len(r.Values) {
+		return nil, fmt.Errorf("Expected %d values in row, got %d", len(fields), len(r.Values))
+	}
+
+	values := make(map[string]interface{}, len(fields))
+	for i, field := range fields {
+		value, err := decodeValue(field, r.Values[i])
+		if err != nil {
+			return nil, fmt.Errorf("Cannot decode column %q: %s", field.Name, err)
+		}
+		values[field.Name] = value
+	}
+	return values, nil
+}
+
+// Returns all rows of the resultset as maps. See Row.Map.
+func (rs *Resultset) Maps() ([]map[string]interface{}, error) {
+	maps := make([]map[string]interface{}, 0, rs.RowCount())
+	err := rs.EachRow(func(row Row) error {
+		values, err := row.Map(rs.Fields)
+		if err != nil {
+			return err
+		}
+		maps = append(maps, values)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return maps, nil
+}
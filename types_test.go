@@ -0,0 +1,38 @@
+package vertigo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowMap(t *testing.T) {
+	fields := []Field{
+		{Name: "b", DataTypeOID: DataTypeBoolean},
+		{Name: "i", DataTypeOID: DataTypeInteger},
+		{Name: "f", DataTypeOID: DataTypeFloat},
+		{Name: "s", DataTypeOID: DataTypeVarchar},
+		{Name: "n", DataTypeOID: DataTypeInteger},
+	}
+	row := Row{Values: [][]byte{[]byte("t"), []byte("42"), []byte("1.5"), []byte("foo"), nil}}
+
+	values, err := row.Map(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"b": true, "i": int64(42), "f": 1.5, "s": "foo", "n": nil}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Expected %#+v, but found %#+v", expected, values)
+	}
+}
+
+func TestResultsetMapsInvalidValue(t *testing.T) {
+	resultset := &Resultset{
+		Fields: []Field{{Name: "i", DataTypeOID: DataTypeInteger}},
+		Rows:   []Row{{Values: [][]byte{[]byte("foo")}}},
+	}
+
+	if _, err := resultset.Maps(); err == nil {
+		t.Fatal("Expected an error for an invalid integer value")
+	}
+}
@@ -0,0 +1,75 @@
+package vertigo
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// UUID is the decoded value of a UUID column.
+//
+// Any other [16]byte based UUID type, like the one of github.com/google/uuid, can
+// be used as a query argument as well, and can be scanned into if it implements
+// sql.Scanner.
+type UUID [16]byte
+
+// Parses a UUID in its canonical form, e.g. "6bbf0744-74b4-46b9-bb05-53905d4538e7".
+// The hyphens are optional.
+func ParseUUID(s string) (UUID, error) {
+	var uuid UUID
+
+	digits := strings.Replace(s, "-", "", -1)
+	if len(digits) != 32 {
+		return uuid, fmt.Errorf("Invalid UUID %q", s)
+	}
+
+	if _, err := hex.Decode(uuid[:], []byte(digits)); err != nil {
+		return uuid, fmt.Errorf("Invalid UUID %q", s)
+	}
+	return uuid, nil
+}
+
+// Returns the UUID in its canonical form.
+func (u UUID) String() string {
+	return formatUUID(u)
+}
+
+// Implements encoding.TextMarshaler, so UUIDs are encoded as strings in JSON.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// Implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(text []byte) error {
+	uuid, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = uuid
+	return nil
+}
+
+func (u *UUID) scanText(field *Field, src []byte) error {
+	if src == nil {
+		return fmt.Errorf("%w, cannot convert it into vertigo.UUID; use a pointer or Nullable instead", ErrUnexpectedNull)
+	}
+	return u.UnmarshalText(src)
+}
+
+func formatUUID(u [16]byte) string {
+	digits := hex.EncodeToString(u[:])
+	return digits[0:8] + "-" + digits[8:12] + "-" + digits[12:16] + "-" + digits[16:20] + "-" + digits[20:]
+}
+
+// Checks whether v is a [16]byte based UUID type, and returns its canonical form if so.
+func uuidString(v interface{}) (string, bool) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Array || value.Len() != 16 || value.Type().Elem().Kind() != reflect.Uint8 {
+		return "", false
+	}
+
+	var u [16]byte
+	reflect.Copy(reflect.ValueOf(&u).Elem(), value)
+	return formatUUID(u), true
+}
@@ -0,0 +1,54 @@
+package vertigo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type otherUUID [16]byte
+
+func TestUUID(t *testing.T) {
+	const canonical = "6bbf0744-74b4-46b9-bb05-53905d4538e7"
+
+	uuid, err := ParseUUID(canonical)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uuid.String() != canonical {
+		t.Errorf("Expected %s, but found %s", canonical, uuid)
+	}
+
+	if data, err := json.Marshal(uuid); err != nil || string(data) != `"`+canonical+`"` {
+		t.Errorf("Unexpected JSON %s (%v)", data, err)
+	}
+
+	if _, err := ParseUUID("6bbf0744-74b4-46b9-bb05"); err == nil {
+		t.Error("Expected an error for a truncated UUID")
+	}
+}
+
+func TestRowScanUUID(t *testing.T) {
+	fields := []Field{{DataTypeOID: DataTypeUUID}, {DataTypeOID: DataTypeUUID}}
+	row := Row{Values: [][]byte{[]byte("6bbf0744-74b4-46b9-bb05-53905d4538e7"), nil}, fields: fields}
+
+	var (
+		uuid UUID
+		null Nullable[UUID]
+	)
+	if err := row.Scan(&uuid, &null); err != nil {
+		t.Fatal(err)
+	}
+
+	if uuid[0] != 0x6b || uuid[15] != 0xe7 || null.Valid {
+		t.Fatalf("Unexpected values %s and %#+v", uuid, null)
+	}
+}
+
+func TestQuoteUUID(t *testing.T) {
+	uuid := otherUUID{0x6b, 0xbf, 0x07, 0x44, 0x74, 0xb4, 0x46, 0xb9, 0xbb, 0x05, 0x53, 0x90, 0x5d, 0x45, 0x38, 0xe7}
+
+	if literal, err := QuoteLiteral(uuid); err != nil || literal != "'6bbf0744-74b4-46b9-bb05-53905d4538e7'" {
+		t.Fatalf("Unexpected literal %s (%v)", literal, err)
+	}
+}
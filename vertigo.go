@@ -1,9 +1,13 @@
 package vertigo
 
 import (
+	"fmt"
 	"log"
 )
 
+// When set, all protocol messages of all connections are dumped to this logger.
+//
+// Deprecated: Use ConnectionInfo.Logger, which receives structured events with levels.
 var TrafficLogger *log.Logger
 
 const (
@@ -18,8 +22,30 @@ const (
 	AuthenticationSSPI              = 9
 )
 
+// The transaction status of a connection, as reported by the server whenever it is
+// ready for a query.
+type TransactionStatus byte
+
 const (
-	TransactionStatusIdle          = 'I'
-	TransactionStatusInTransaction = 'T'
-	TransactionStatusError         = 'E'
+	TransactionStatusUnknown       TransactionStatus = 0   // The connection isn't open
+	TransactionStatusIdle          TransactionStatus = 'I' // Not in a transaction
+	TransactionStatusInTransaction TransactionStatus = 'T' // In a transaction
+	TransactionStatusFailed        TransactionStatus = 'E' // In a failed transaction, which has to be rolled back
+
+	// Deprecated: Use TransactionStatusFailed.
+	TransactionStatusError = TransactionStatusFailed
 )
+
+func (s TransactionStatus) String() string {
+	switch s {
+	case TransactionStatusUnknown:
+		return "unknown"
+	case TransactionStatusIdle:
+		return "idle"
+	case TransactionStatusInTransaction:
+		return "in transaction"
+	case TransactionStatusFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("TransactionStatus(%q)", byte(s))
+}
@@ -0,0 +1,395 @@
+//go:build arrow
+
+// Package vertigoarrow converts vertigo resultsets into Apache Arrow records, to hand
+// them to dataframes, DuckDB or Parquet writers. It is only built with the arrow build
+// tag, so the vertigo package itself doesn't depend on the Arrow library.
+package vertigoarrow
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/decimal128"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/lomik/vertigo"
+)
+
+// Returns the Arrow schema of the fields of a resultset. All columns are nullable, as
+// the server doesn't tell whether they can be NULL. The types are mapped like this:
+//
+//	BOOLEAN                              bool
+//	INTEGER                              int64
+//	FLOAT                                float64
+//	BINARY, VARBINARY, LONG VARBINARY    binary
+//	DATE                                 date32
+//	TIME, TIMETZ                         time64[us], TIMETZ in UTC
+//	TIMESTAMP                            timestamp[us]
+//	TIMESTAMPTZ                          timestamp[us, tz=UTC]
+//	NUMERIC                              decimal128 with the precision and scale of the column
+//	INTERVAL DAY TO SECOND               duration[us]
+//	INTERVAL YEAR TO MONTH               month_interval
+//	UUID                                 fixed_size_binary[16]
+//
+// Columns of other types, like CHAR, VARCHAR and complex types, and NUMERIC columns
+// with more than 38 digits, are converted into utf8 with the text the server sent.
+func Schema(fields []vertigo.Field) *arrow.Schema {
+	arrowFields := make([]arrow.Field, len(fields))
+	for i, field := range fields {
+		arrowFields[i] = arrow.Field{Name: field.Name, Type: dataType(field), Nullable: true}
+	}
+	return arrow.NewSchema(arrowFields, nil)
+}
+
+// Returns the Arrow type of the values of a field.
+func dataType(field vertigo.Field) arrow.DataType {
+	switch field.DataTypeOID {
+	case vertigo.DataTypeBoolean:
+		return arrow.FixedWidthTypes.Boolean
+	case vertigo.DataTypeInteger:
+		return arrow.PrimitiveTypes.Int64
+	case vertigo.DataTypeFloat:
+		return arrow.PrimitiveTypes.Float64
+	case vertigo.DataTypeBinary, vertigo.DataTypeVarbinary, vertigo.DataTypeLongVarbinary:
+		return arrow.BinaryTypes.Binary
+	case vertigo.DataTypeDate:
+		return arrow.FixedWidthTypes.Date32
+	case vertigo.DataTypeTime, vertigo.DataTypeTimeTZ:
+		return arrow.FixedWidthTypes.Time64us
+	case vertigo.DataTypeTimestamp:
+		return &arrow.TimestampType{Unit: arrow.Microsecond}
+	case vertigo.DataTypeTimestampTZ:
+		return arrow.FixedWidthTypes.Timestamp_us
+	case vertigo.DataTypeNumeric:
+		if precision, scale, ok := numericPrecisionScale(field); ok && precision <= 38 {
+			return &arrow.Decimal128Type{Precision: precision, Scale: scale}
+		}
+	case vertigo.DataTypeInterval:
+		return arrow.FixedWidthTypes.Duration_us
+	case vertigo.DataTypeIntervalYM:
+		return arrow.FixedWidthTypes.MonthInterval
+	case vertigo.DataTypeUUID:
+		return &arrow.FixedSizeBinaryType{ByteWidth: 16}
+	}
+	return arrow.BinaryTypes.String
+}
+
+// Returns the precision and scale of a NUMERIC field from its type modifier.
+func numericPrecisionScale(field vertigo.Field) (int32, int32, bool) {
+	if field.TypeModifier == 0xffffffff || field.TypeModifier < 4 {
+		return 0, 0, false
+	}
+	modifier := field.TypeModifier - 4
+	return int32(modifier >> 16), int32(modifier & 0xffff), true
+}
+
+// Builder builds Arrow records from the rows of a resultset. Rows are appended until
+// NewRecord is called, which returns them as a record and starts the next one, so large
+// results can be converted in batches.
+type Builder struct {
+	fields  []vertigo.Field
+	builder *array.RecordBuilder
+	columns []column
+	dest    []interface{}
+}
+
+// The conversion of a column: the row is scanned into dest, after which appendValue
+// appends the scanned value to the builder of the column.
+type column struct {
+	dest        interface{}
+	appendValue func(builder array.Builder) error
+}
+
+// Returns a builder for rows with the fields, which allocates the records from mem.
+// Nil uses memory.DefaultAllocator.
+func NewBuilder(mem memory.Allocator, fields []vertigo.Field) *Builder {
+	if mem == nil {
+		mem = memory.DefaultAllocator
+	}
+
+	b := &Builder{
+		fields:  fields,
+		builder: array.NewRecordBuilder(mem, Schema(fields)),
+		columns: make([]column, len(fields)),
+		dest:    make([]interface{}, len(fields)),
+	}
+	for i, field := range fields {
+		b.columns[i] = newColumn(field, dataType(field))
+		b.dest[i] = b.columns[i].dest
+	}
+	return b
+}
+
+func newColumn(field vertigo.Field, dataType arrow.DataType) column {
+	switch dataType := dataType.(type) {
+	case *arrow.BooleanType:
+		var v *bool
+		return column{&v, func(builder array.Builder) error {
+			builder.(*array.BooleanBuilder).Append(*v)
+			return nil
+		}}
+
+	case *arrow.Int64Type:
+		var v *int64
+		return column{&v, func(builder array.Builder) error {
+			builder.(*array.Int64Builder).Append(*v)
+			return nil
+		}}
+
+	case *arrow.Float64Type:
+		var v *float64
+		return column{&v, func(builder array.Builder) error {
+			builder.(*array.Float64Builder).Append(*v)
+			return nil
+		}}
+
+	case *arrow.BinaryType:
+		var v []byte
+		return column{&v, func(builder array.Builder) error {
+			builder.(*array.BinaryBuilder).Append(v)
+			return nil
+		}}
+
+	case *arrow.Date32Type:
+		var v *time.Time
+		return column{&v, func(builder array.Builder) error {
+			builder.(*array.Date32Builder).Append(arrow.Date32FromTime(*v))
+			return nil
+		}}
+
+	case *arrow.Time64Type:
+		var v *time.Time
+		return column{&v, func(builder array.Builder) error {
+			t := *v
+			if field.DataTypeOID == vertigo.DataTypeTimeTZ {
+				t = t.UTC()
+			}
+			micros := (int64(t.Hour())*3600+int64(t.Minute())*60+int64(t.Second()))*1e6 + int64(t.Nanosecond()/1e3)
+			builder.(*array.Time64Builder).Append(arrow.Time64(micros))
+			return nil
+		}}
+
+	case *arrow.TimestampType:
+		var v *time.Time
+		return column{&v, func(builder array.Builder) error {
+			builder.(*array.TimestampBuilder).Append(arrow.Timestamp(v.UnixMicro()))
+			return nil
+		}}
+
+	case *arrow.Decimal128Type:
+		var v *vertigo.Decimal
+		return column{&v, func(builder array.Builder) error {
+			unscaled, err := rescale(*v, int(dataType.Scale))
+			if err != nil {
+				return fmt.Errorf("Cannot convert %s into %s: %w", v, dataType, err)
+			}
+			builder.(*array.Decimal128Builder).Append(decimal128.FromBigInt(unscaled))
+			return nil
+		}}
+
+	case *arrow.DurationType:
+		var v *vertigo.Interval
+		return column{&v, func(builder array.Builder) error {
+			builder.(*array.DurationBuilder).Append(arrow.Duration(v.Days*24*60*60*1e6 + v.Microseconds))
+			return nil
+		}}
+
+	case *arrow.MonthIntervalType:
+		var v *vertigo.Interval
+		return column{&v, func(builder array.Builder) error {
+			builder.(*array.MonthIntervalBuilder).Append(arrow.MonthInterval(v.Months))
+			return nil
+		}}
+
+	case *arrow.FixedSizeBinaryType:
+		var v *vertigo.UUID
+		return column{&v, func(builder array.Builder) error {
+			builder.(*array.FixedSizeBinaryBuilder).Append(v[:])
+			return nil
+		}}
+
+	default:
+		var v *string
+		return column{&v, func(builder array.Builder) error {
+			builder.(*array.StringBuilder).Append(*v)
+			return nil
+		}}
+	}
+}
+
+// Returns the unscaled value of d at the scale, which fails if d has more digits after
+// the decimal point than the scale allows.
+func rescale(d vertigo.Decimal, scale int) (*big.Int, error) {
+	unscaled := d.Unscaled()
+	switch {
+	case d.Scale() < scale:
+		exponent := big.NewInt(int64(scale - d.Scale()))
+		unscaled.Mul(unscaled, exponent.Exp(big.NewInt(10), exponent, nil))
+	case d.Scale() > scale:
+		exponent := big.NewInt(int64(d.Scale() - scale))
+		var remainder big.Int
+		unscaled.QuoRem(unscaled, exponent.Exp(big.NewInt(10), exponent, nil), &remainder)
+		if remainder.Sign() != 0 {
+			return nil, fmt.Errorf("scale %d is too small", scale)
+		}
+	}
+	return unscaled, nil
+}
+
+// Returns the Arrow schema of the records the builder builds.
+func (b *Builder) Schema() *arrow.Schema {
+	return b.builder.Schema()
+}
+
+// Appends a row of the resultset to the current record.
+func (b *Builder) Append(row vertigo.Row) error {
+	if err := row.Scan(b.dest...); err != nil {
+		return err
+	}
+
+	for i, value := range row.Values {
+		builder := b.builder.Field(i)
+		if value == nil {
+			builder.AppendNull()
+			continue
+		}
+		if err := b.columns[i].appendValue(builder); err != nil {
+			return fmt.Errorf("Cannot convert column %q: %w", b.fields[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// Returns the number of rows in the current record.
+func (b *Builder) Len() int {
+	if len(b.fields) == 0 {
+		return 0
+	}
+	return b.builder.Field(0).Len()
+}
+
+// Returns the rows appended since the last call as a record, which must be released
+// by the caller.
+func (b *Builder) NewRecord() arrow.Record {
+	return b.builder.NewRecord()
+}
+
+// Releases the memory of the rows appended since the last record.
+func (b *Builder) Release() {
+	b.builder.Release()
+}
+
+// Converts all rows of the resultset into a single record, which must be released by
+// the caller. Nil mem uses memory.DefaultAllocator.
+func Record(mem memory.Allocator, rs *vertigo.Resultset) (arrow.Record, error) {
+	var record arrow.Record
+	err := Records(mem, rs, 0, func(r arrow.Record) error {
+		record = r
+		record.Retain()
+		return nil
+	})
+	return record, err
+}
+
+// Converts the rows of the resultset into records of at most batchSize rows, and calls
+// fn for each of them; zero puts all rows into a single record. The records are released
+// after fn returns, so fn must retain those it keeps. A resultset without rows is
+// converted into a single empty record.
+func Records(mem memory.Allocator, rs *vertigo.Resultset, batchSize int, fn func(arrow.Record) error) error {
+	b := NewBuilder(mem, rs.Fields)
+	defer b.Release()
+
+	flush := func() error {
+		record := b.NewRecord()
+		defer record.Release()
+		return fn(record)
+	}
+
+	sent := false
+	err := rs.EachRow(func(row vertigo.Row) error {
+		if err := b.Append(row); err != nil {
+			return err
+		}
+		if batchSize > 0 && b.Len() >= batchSize {
+			sent = true
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if b.Len() > 0 || !sent {
+		return flush()
+	}
+	return nil
+}
+
+// Sink is a vertigo.RowSink that converts the rows of a query into records while they
+// are received, so results that don't fit in memory can be converted:
+//
+//	sink := vertigoarrow.NewSink(nil, 65536, func(record arrow.Record) error {
+//		return writer.Write(record)
+//	})
+//	err := connection.QueryInto(sink, "SELECT * FROM events")
+type Sink struct {
+	mem       memory.Allocator
+	batchSize int
+	fn        func(arrow.Record) error
+	builder   *Builder
+	fields    []vertigo.Field
+}
+
+// Returns a sink that calls fn with records of at most batchSize rows, allocated from
+// mem, like Records does, except that statements without rows produce no records.
+func NewSink(mem memory.Allocator, batchSize int, fn func(arrow.Record) error) *Sink {
+	return &Sink{mem: mem, batchSize: batchSize, fn: fn}
+}
+
+func (s *Sink) OnFields(fields []vertigo.Field) error {
+	s.release()
+	s.fields = fields
+	s.builder = NewBuilder(s.mem, fields)
+	return nil
+}
+
+func (s *Sink) OnRow(values [][]byte) error {
+	if err := s.builder.Append(vertigo.NewRow(s.fields, values)); err != nil {
+		s.release()
+		return err
+	}
+	if s.batchSize > 0 && s.builder.Len() >= s.batchSize {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *Sink) OnComplete(tag vertigo.CommandTag) error {
+	if s.builder == nil {
+		return nil
+	}
+	defer s.release()
+	if s.builder.Len() > 0 {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *Sink) flush() error {
+	record := s.builder.NewRecord()
+	defer record.Release()
+	if err := s.fn(record); err != nil {
+		s.release()
+		return err
+	}
+	return nil
+}
+
+func (s *Sink) release() {
+	if s.builder != nil {
+		s.builder.Release()
+		s.builder = nil
+	}
+}
@@ -0,0 +1,87 @@
+//go:build arrow
+
+package vertigoarrow
+
+import (
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/lomik/vertigo"
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+var eventColumns = []vertigotest.Column{
+	{Name: "id", Type: vertigo.DataTypeInteger},
+	{Name: "name", Type: vertigo.DataTypeVarchar},
+	{Name: "price", Type: vertigo.DataTypeNumeric, TypeModifier: (10<<16 | 2) + 4},
+}
+
+func startServer(t *testing.T) *vertigo.Connection {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	server.Expect("SELECT * FROM events").Columns(eventColumns...).Row(1, "first", "12.5").Row(2, nil, nil)
+
+	connection, err := vertigo.Open(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { connection.Close() })
+	return connection
+}
+
+func TestRecord(t *testing.T) {
+	connection := startServer(t)
+	rs, err := connection.Query("SELECT * FROM events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	record, err := Record(mem, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer record.Release()
+
+	schema := record.Schema()
+	if record.NumRows() != 2 || schema.Field(0).Type.ID() != arrow.INT64 || schema.Field(1).Type.ID() != arrow.STRING {
+		t.Fatalf("Expected 2 rows of int64 and utf8 columns, but found %d rows of %s", record.NumRows(), schema)
+	}
+	if decimal, ok := schema.Field(2).Type.(*arrow.Decimal128Type); !ok || decimal.Precision != 10 || decimal.Scale != 2 {
+		t.Fatalf("Expected a decimal128(10, 2) column, but found %s", schema.Field(2).Type)
+	}
+
+	ids := record.Column(0).(*array.Int64)
+	names := record.Column(1).(*array.String)
+	prices := record.Column(2).(*array.Decimal128)
+	if ids.Value(0) != 1 || ids.Value(1) != 2 || names.Value(0) != "first" || !names.IsNull(1) {
+		t.Fatalf("Unexpected values %s and %s", ids, names)
+	}
+	if prices.Value(0).LowBits() != 1250 || !prices.IsNull(1) {
+		t.Fatalf("Expected 12.50 and NULL, but found %s", prices)
+	}
+}
+
+func TestSink(t *testing.T) {
+	connection := startServer(t)
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	var batches []int64
+	sink := NewSink(mem, 1, func(record arrow.Record) error {
+		batches = append(batches, record.NumRows())
+		return nil
+	})
+	if err := connection.QueryInto(sink, "SELECT * FROM events"); err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 || batches[0] != 1 || batches[1] != 1 {
+		t.Fatalf("Expected 2 records of 1 row, but found %v", batches)
+	}
+}
@@ -0,0 +1,77 @@
+// Package vertigocollect collects the rows of vertigo queries into typed slices while
+// they are received, without buffering a Resultset and without reflection:
+//
+//	type user struct {
+//		ID   int64
+//		Name string
+//	}
+//
+//	users, err := vertigocollect.Collect(connection, func(row vertigo.Row) (user, error) {
+//		var u user
+//		err := row.Scan(&u.ID, &u.Name)
+//		return u, err
+//	}, "SELECT id, name FROM users")
+//
+//	ids, err := vertigocollect.CollectScalar[int64](connection, "SELECT id FROM users")
+package vertigocollect
+
+import (
+	"context"
+
+	"github.com/lomik/vertigo"
+)
+
+// Runs a SQL query, and returns the values scan returns for its rows, in order. When
+// scan fails, the query is cancelled and the error is returned. Rows passed to scan
+// are only valid until it returns, so it must copy what it keeps; the values Row.Scan
+// returns are copies already.
+func Collect[T any](c *vertigo.Connection, scan func(row vertigo.Row) (T, error), sql string, args ...interface{}) ([]T, error) {
+	return CollectContext(context.Background(), c, scan, sql, args...)
+}
+
+// Runs a SQL query like Collect, until the context is done. Cancellation is handled
+// the same way as it is by Connection.QueryContext.
+func CollectContext[T any](ctx context.Context, c *vertigo.Connection, scan func(row vertigo.Row) (T, error), sql string, args ...interface{}) ([]T, error) {
+	sink := &collectSink[T]{scan: scan}
+	if err := c.QueryIntoContext(ctx, sink, sql, args...); err != nil {
+		return nil, err
+	}
+	return sink.values, nil
+}
+
+// Runs a SQL query that returns a single column, like "SELECT id FROM t", and returns
+// the values of the column converted into T.
+func CollectScalar[T any](c *vertigo.Connection, sql string, args ...interface{}) ([]T, error) {
+	return Collect(c, Scalar[T], sql, args...)
+}
+
+// A scan function for Collect that converts the only value of a row into T.
+func Scalar[T any](row vertigo.Row) (value T, err error) {
+	err = row.Scan(&value)
+	return value, err
+}
+
+// A vertigo.RowSink that scans every row it receives.
+type collectSink[T any] struct {
+	scan   func(row vertigo.Row) (T, error)
+	fields []vertigo.Field
+	values []T
+}
+
+func (s *collectSink[T]) OnFields(fields []vertigo.Field) error {
+	s.fields = fields
+	return nil
+}
+
+func (s *collectSink[T]) OnRow(values [][]byte) error {
+	value, err := s.scan(vertigo.NewRow(s.fields, values))
+	if err != nil {
+		return err
+	}
+	s.values = append(s.values, value)
+	return nil
+}
+
+func (s *collectSink[T]) OnComplete(tag vertigo.CommandTag) error {
+	return nil
+}
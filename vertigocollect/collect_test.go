@@ -0,0 +1,62 @@
+package vertigocollect
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lomik/vertigo"
+	"github.com/lomik/vertigo/vertigotest"
+)
+
+func TestCollect(t *testing.T) {
+	s, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	columns := []vertigotest.Column{{Name: "id", Type: vertigo.DataTypeInteger}, {Name: "name"}}
+	s.Expect("SELECT id, name FROM users").Columns(columns...).Row(1, "alice").Row(2, "bob")
+	s.Expect("SELECT id FROM users").Columns(columns[0]).Row(1).Row(2)
+	s.Expect("SELECT id, name FROM users WHERE name IS NULL").Columns(columns...).Row(3, nil)
+	s.Expect("SELECT 1").Columns(columns[0]).Row(1)
+
+	connection, err := vertigo.Open(s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	type user struct {
+		ID   int64
+		Name string
+	}
+	scanUser := func(row vertigo.Row) (user, error) {
+		var u user
+		err := row.Scan(&u.ID, &u.Name)
+		return u, err
+	}
+
+	users, err := Collect(connection, scanUser, "SELECT id, name FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[1] != (user{2, "bob"}) {
+		t.Fatalf("Expected alice and bob, but found %v", users)
+	}
+
+	ids, err := CollectScalar[int64](connection, "SELECT id FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("Expected ids 1 and 2, but found %v", ids)
+	}
+
+	if _, err := Collect(connection, scanUser, "SELECT id, name FROM users WHERE name IS NULL"); !errors.Is(err, vertigo.ErrUnexpectedNull) {
+		t.Fatalf("Expected ErrUnexpectedNull, but found %v", err)
+	}
+	if _, err := connection.Query("SELECT 1"); err != nil {
+		t.Fatalf("Expected the connection to be usable after a failed scan, but found %v", err)
+	}
+}
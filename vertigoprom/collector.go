@@ -0,0 +1,147 @@
+//go:build prometheus
+
+// Package vertigoprom exports the statistics of vertigo connections as Prometheus
+// metrics. It is only built with the prometheus build tag, so the vertigo package
+// itself doesn't depend on the Prometheus client library.
+package vertigoprom
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lomik/vertigo"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a vertigo.StatsCollector that is also a prometheus.Collector. Set it
+// as the StatsCollector of the connections to monitor, and register it once. It also
+// implements vertigo.PoolStatsCollector, so the pools whose Config it is set in are
+// monitored by their Name.
+type Collector struct {
+	queries       prometheus.Counter
+	queryDuration prometheus.Histogram
+	rows          prometheus.Counter
+	bytesSent     prometheus.Counter
+	bytesReceived prometheus.Counter
+	errors        *prometheus.CounterVec
+	connects      *prometheus.CounterVec
+	checkouts     *prometheus.CounterVec
+	checkins      *prometheus.CounterVec
+	poolWait      *prometheus.HistogramVec
+}
+
+// Returns a new collector, with all metric names prefixed by the namespace.
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		queries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "vertica", Name: "queries_total",
+			Help: "Number of statements run.",
+		}),
+		queryDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "vertica", Name: "query_duration_seconds",
+			Help:    "Duration of statements.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+		}),
+		rows: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "vertica", Name: "rows_read_total",
+			Help: "Number of rows received.",
+		}),
+		bytesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "vertica", Name: "sent_bytes_total",
+			Help: "Number of bytes sent to the server.",
+		}),
+		bytesReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "vertica", Name: "received_bytes_total",
+			Help: "Number of bytes received from the server.",
+		}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "vertica", Name: "query_errors_total",
+			Help: "Number of failed statements, by SQLSTATE. Client-side errors have an empty SQLSTATE.",
+		}, []string{"sqlstate"}),
+		connects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "vertica", Name: "connect_attempts_total",
+			Help: "Number of attempts to open a connection, by result.",
+		}, []string{"result"}),
+		checkouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "vertica", Name: "pool_checkouts_total",
+			Help: "Number of connections taken from a pool, by pool.",
+		}, []string{"pool"}),
+		checkins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "vertica", Name: "pool_checkins_total",
+			Help: "Number of connections given back to a pool, by pool.",
+		}, []string{"pool"}),
+		poolWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "vertica", Name: "pool_wait_seconds",
+			Help:    "Time waited for a connection to be given back to a pool, by pool.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"pool"}),
+	}
+}
+
+func (c *Collector) QueryExecuted(duration time.Duration, rows int, err error) {
+	c.queries.Inc()
+	c.queryDuration.Observe(duration.Seconds())
+	c.rows.Add(float64(rows))
+
+	if err != nil {
+		var verticaError *vertigo.VerticaError
+		state := ""
+		if errors.As(err, &verticaError) {
+			state = verticaError.Code
+		}
+		c.errors.WithLabelValues(state).Inc()
+	}
+}
+
+func (c *Collector) BytesSent(n int) {
+	c.bytesSent.Add(float64(n))
+}
+
+func (c *Collector) BytesReceived(n int) {
+	c.bytesReceived.Add(float64(n))
+}
+
+func (c *Collector) ConnectAttempted(err error) {
+	if err != nil {
+		c.connects.WithLabelValues("failure").Inc()
+	} else {
+		c.connects.WithLabelValues("success").Inc()
+	}
+}
+
+func (c *Collector) ConnectionCheckedOut(pool string, wait time.Duration) {
+	c.checkouts.WithLabelValues(pool).Inc()
+	c.poolWait.WithLabelValues(pool).Observe(wait.Seconds())
+}
+
+func (c *Collector) ConnectionCheckedIn(pool string) {
+	c.checkins.WithLabelValues(pool).Inc()
+}
+
+// Implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.queries.Describe(ch)
+	c.queryDuration.Describe(ch)
+	c.rows.Describe(ch)
+	c.bytesSent.Describe(ch)
+	c.bytesReceived.Describe(ch)
+	c.errors.Describe(ch)
+	c.connects.Describe(ch)
+	c.checkouts.Describe(ch)
+	c.checkins.Describe(ch)
+	c.poolWait.Describe(ch)
+}
+
+// Implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.queries.Collect(ch)
+	c.queryDuration.Collect(ch)
+	c.rows.Collect(ch)
+	c.bytesSent.Collect(ch)
+	c.bytesReceived.Collect(ch)
+	c.errors.Collect(ch)
+	c.connects.Collect(ch)
+	c.checkouts.Collect(ch)
+	c.checkins.Collect(ch)
+	c.poolWait.Collect(ch)
+}
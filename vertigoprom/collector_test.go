@@ -0,0 +1,66 @@
+//go:build prometheus
+
+package vertigoprom
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lomik/vertigo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var (
+	_ vertigo.StatsCollector     = (*Collector)(nil)
+	_ vertigo.PoolStatsCollector = (*Collector)(nil)
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("app")
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	c.QueryExecuted(time.Millisecond, 10, nil)
+	c.QueryExecuted(time.Millisecond, 0, &vertigo.VerticaError{Code: vertigo.ErrCodeSyntaxError})
+	c.QueryExecuted(time.Millisecond, 0, errors.New("connection reset"))
+	c.BytesSent(15)
+	c.BytesReceived(30)
+	c.ConnectAttempted(nil)
+	c.ConnectAttempted(errors.New("connection refused"))
+	c.ConnectionCheckedOut("reports", 20*time.Millisecond)
+	c.ConnectionCheckedOut("reports", 0)
+	c.ConnectionCheckedIn("reports")
+
+	if n := testutil.ToFloat64(c.queries); n != 3 {
+		t.Errorf("Expected 3 queries, but found %v", n)
+	}
+	if n := testutil.ToFloat64(c.rows); n != 10 {
+		t.Errorf("Expected 10 rows, but found %v", n)
+	}
+	if sent, received := testutil.ToFloat64(c.bytesSent), testutil.ToFloat64(c.bytesReceived); sent != 15 || received != 30 {
+		t.Errorf("Expected 15 bytes sent and 30 received, but found %v and %v", sent, received)
+	}
+	if n := testutil.ToFloat64(c.errors.WithLabelValues(vertigo.ErrCodeSyntaxError)); n != 1 {
+		t.Errorf("Expected 1 syntax error, but found %v", n)
+	}
+	if n := testutil.ToFloat64(c.errors.WithLabelValues("")); n != 1 {
+		t.Errorf("Expected 1 client-side error, but found %v", n)
+	}
+	if n := testutil.ToFloat64(c.connects.WithLabelValues("failure")); n != 1 {
+		t.Errorf("Expected 1 failed connect attempt, but found %v", n)
+	}
+	if out, in := testutil.ToFloat64(c.checkouts.WithLabelValues("reports")), testutil.ToFloat64(c.checkins.WithLabelValues("reports")); out != 2 || in != 1 {
+		t.Errorf("Expected 2 checkouts and 1 checkin, but found %v and %v", out, in)
+	}
+
+	if _, err := registry.Gather(); err != nil {
+		t.Fatalf("Expected the metrics to be consistent, but found %v", err)
+	}
+	if n := testutil.CollectAndCount(c, "app_vertica_pool_wait_seconds"); n != 1 {
+		t.Errorf("Expected a wait time histogram for the pool, but found %d", n)
+	}
+}
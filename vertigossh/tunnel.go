@@ -0,0 +1,133 @@
+//go:build ssh
+
+// Package vertigossh connects to Vertica clusters that are only reachable through an
+// SSH bastion host. It is only built with the ssh build tag, so the vertigo package
+// itself doesn't depend on golang.org/x/crypto.
+//
+//	tunnel := vertigossh.NewTunnel("bastion:22", sshConfig)
+//	defer tunnel.Close()
+//	connection, err := vertigo.Connect(&vertigo.ConnectionInfo{
+//		Address: "vertica.internal:5433",
+//		Dial:    tunnel.DialContext,
+//	})
+package vertigossh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrTunnelClosed = errors.New("SSH tunnel is closed")
+
+// Tunnel opens connections through an SSH bastion host. The SSH connection is opened
+// when the first connection is dialed, shared by all connections, and reopened when it
+// breaks. Its DialContext method is a vertigo.DialFunc.
+type Tunnel struct {
+	address string
+	config  *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+	closed bool
+}
+
+// Returns a tunnel through the SSH server at address, which should include a port
+// number. The config holds the user, the authentication methods and the host key
+// callback to use.
+func NewTunnel(address string, config *ssh.ClientConfig) *Tunnel {
+	return &Tunnel{address: address, config: config}
+}
+
+// Opens a connection to address from the bastion host.
+func (t *Tunnel) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	client, err := t.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := client.Dial(network, address)
+		done <- result{conn, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, fmt.Errorf("Cannot connect to %s through SSH server %s: %w", address, t.address, r.err)
+		}
+		return r.conn, nil
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// Closes the SSH connection, and with it all connections that were opened through it.
+func (t *Tunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	if t.client == nil {
+		return nil
+	}
+	err := t.client.Close()
+	t.client = nil
+	return err
+}
+
+// Returns the SSH connection to the bastion host, opening it if needed.
+func (t *Tunnel) sshClient(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil, ErrTunnelClosed
+	}
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot connect to SSH server %s: %w", t.address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, t.address, t.config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Cannot connect to SSH server %s: %w", t.address, err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	client := ssh.NewClient(sshConn, channels, requests)
+	t.client = client
+	go func() {
+		client.Wait()
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.client == client {
+			t.client = nil
+		}
+	}()
+	return client, nil
+}
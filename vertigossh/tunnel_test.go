@@ -0,0 +1,132 @@
+//go:build ssh
+
+package vertigossh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/lomik/vertigo"
+	"github.com/lomik/vertigo/vertigotest"
+	"golang.org/x/crypto/ssh"
+)
+
+// Starts an SSH server that forwards direct-tcpip channels, like a bastion host, and
+// returns its address and the config to connect to it with.
+func startBastion(t *testing.T) (string, *ssh.ClientConfig) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveBastion(conn, config)
+		}
+	}()
+	return listener.Addr().String(), &ssh.ClientConfig{User: "tester", HostKeyCallback: ssh.FixedHostKey(signer.PublicKey())}
+}
+
+func serveBastion(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "only direct-tcpip is supported")
+			continue
+		}
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		go func() {
+			io.Copy(channel, upstream)
+			channel.Close()
+		}()
+		go func() {
+			io.Copy(upstream, channel)
+			upstream.Close()
+		}()
+	}
+}
+
+func TestTunnel(t *testing.T) {
+	server, err := vertigotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Expect("SELECT 1").Columns(vertigotest.Column{Name: "a", Type: vertigo.DataTypeInteger}).Row(1)
+
+	address, config := startBastion(t)
+	tunnel := NewTunnel(address, config)
+	defer tunnel.Close()
+
+	connection, err := vertigo.Open(server.Addr(), vertigo.WithDial(tunnel.DialContext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	var one int
+	row, err := connection.QueryRow("SELECT 1")
+	if err == nil {
+		err = row.Scan(&one)
+	}
+	if err != nil || one != 1 {
+		t.Fatalf("Expected 1 through the tunnel, but found %d, %v", one, err)
+	}
+
+	if _, err := tunnel.DialContext(context.Background(), "tcp", "127.0.0.1:1"); err == nil {
+		t.Fatalf("Expected an error for an address the bastion host can't reach")
+	}
+
+	tunnel.Close()
+	if _, err := tunnel.DialContext(context.Background(), "tcp", server.Addr()); !errors.Is(err, ErrTunnelClosed) {
+		t.Fatalf("Expected ErrTunnelClosed, but found %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package vertigotest
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A protocol message captured in a trace.
+//
+// Traces are text, with one frame per line: the number of the connection, F for
+// frames sent by the client (the frontend) or B for frames sent by the server (the
+// backend), the message type, and the hex encoded body. Untyped frames, like the
+// startup message, have type "-". Blank lines and lines starting with # are
+// ignored, so traces can be annotated:
+//
+//	# SELECT 1
+//	1 F Q 53454c4543542031 00
+//	1 B T 0001...
+type Frame struct {
+	Conn       int
+	FromClient bool
+	Type       byte
+	Body       []byte
+}
+
+func (f Frame) String() string {
+	direction, messageType := "B", "-"
+	if f.FromClient {
+		direction = "F"
+	}
+	if f.Type != 0 {
+		messageType = string(f.Type)
+	}
+
+	line := fmt.Sprintf("%d %s %s", f.Conn, direction, messageType)
+	if len(f.Body) > 0 {
+		line += " " + hex.EncodeToString(f.Body)
+	}
+	return line
+}
+
+// Reads all frames of a trace.
+func ReadTrace(r io.Reader) ([]Frame, error) {
+	var frames []Frame
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<30)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		frame, err := parseFrame(text)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse line %d of trace: %s", line, err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, scanner.Err()
+}
+
+func parseFrame(line string) (Frame, error) {
+	var frame Frame
+
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return frame, fmt.Errorf("expected at least 3 fields, got %d", len(fields))
+	}
+
+	conn, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return frame, err
+	}
+	frame.Conn = conn
+
+	switch fields[1] {
+	case "F":
+		frame.FromClient = true
+	case "B":
+	default:
+		return frame, fmt.Errorf("unknown direction %q", fields[1])
+	}
+
+	switch {
+	case fields[2] == "-":
+	case len(fields[2]) == 1:
+		frame.Type = fields[2][0]
+	default:
+		return frame, fmt.Errorf("invalid message type %q", fields[2])
+	}
+
+	frame.Body, err = hex.DecodeString(strings.Join(fields[3:], ""))
+	return frame, err
+}
+
+// A proxy that records the traffic between vertigo and a real server as a trace,
+// to be served back by a Replayer in tests. Point the ConnectionInfo.Address at
+// the recorder instead of the server.
+//
+// Only connections without SSL can be recorded; the traffic of SSL connections is
+// passed on, but not recorded. Password messages are recorded without the password.
+type Recorder struct {
+	listener net.Listener
+	upstream string
+
+	mu    sync.Mutex
+	trace io.Writer
+	conns int
+	err   error
+	wg    sync.WaitGroup
+}
+
+// Starts a recorder on a random port on the loopback interface, that forwards
+// connections to the upstream address and writes their traffic to trace.
+func NewRecorder(upstream string, trace io.Writer) (*Recorder, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{listener: listener, upstream: upstream, trace: trace}
+	r.wg.Add(1)
+	go r.accept()
+	return r, nil
+}
+
+// Returns the address to connect to, for ConnectionInfo.Address.
+func (r *Recorder) Addr() string {
+	return r.listener.Addr().String()
+}
+
+// Stops accepting connections, and waits until the recorded connections are closed.
+// Returns the first error writing the trace, if any.
+func (r *Recorder) Close() error {
+	r.listener.Close()
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Annotates the trace with a comment, e.g. to describe what happens next.
+func (r *Recorder) Comment(text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, line := range strings.Split(text, "\n") {
+		r.writeLine("# " + line)
+	}
+}
+
+func (r *Recorder) accept() {
+	defer r.wg.Done()
+	for {
+		client, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		r.mu.Lock()
+		r.conns++
+		conn := r.conns
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer client.Close()
+
+			server, err := net.Dial("tcp", r.upstream)
+			if err != nil {
+				return
+			}
+			defer server.Close()
+			r.proxy(conn, client, server)
+		}()
+	}
+}
+
+// Forwards the traffic of a connection, and records it.
+func (r *Recorder) proxy(conn int, client, server net.Conn) {
+	// The startup phase is strictly request and response, and has untyped frames.
+	for {
+		_, body, err := r.forward(conn, client, server, true, false)
+		if err != nil {
+			return
+		}
+		if len(body) < 4 {
+			break
+		}
+
+		switch readUint32(body) {
+		case cancelRequestCode:
+			io.Copy(client, server)
+			return
+
+		case sslMagicNumber:
+			response := make([]byte, 1)
+			if _, err := io.ReadFull(server, response); err != nil {
+				return
+			}
+			r.record(Frame{Conn: conn, Body: response})
+			if _, err := client.Write(response); err != nil {
+				return
+			}
+			if response[0] == 'S' {
+				go io.Copy(server, client)
+				io.Copy(client, server)
+				return
+			}
+			continue
+		}
+		break
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := r.forward(conn, client, server, true, true); err != nil {
+				server.Close()
+				return
+			}
+		}
+	}()
+
+	for {
+		if _, _, err := r.forward(conn, server, client, false, true); err != nil {
+			client.Close()
+			break
+		}
+	}
+	<-done
+}
+
+// Reads a frame from src, records it, and writes it to dst.
+func (r *Recorder) forward(conn int, src, dst net.Conn, fromClient, typed bool) (byte, []byte, error) {
+	messageType, body, err := readFrame(src, typed)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	frame := Frame{Conn: conn, FromClient: fromClient, Type: messageType, Body: body}
+	if fromClient && messageType == 'p' {
+		frame.Body = nil
+	}
+	r.record(frame)
+
+	if typed {
+		_, err = dst.Write(append([]byte{messageType}, uint32Bytes(uint32(len(body)+4))...))
+	} else {
+		_, err = dst.Write(uint32Bytes(uint32(len(body) + 4)))
+	}
+	if err == nil {
+		_, err = dst.Write(body)
+	}
+	return messageType, body, err
+}
+
+func (r *Recorder) record(frame Frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeLine(frame.String())
+}
+
+// Writes a line to the trace. The lock must be held.
+func (r *Recorder) writeLine(line string) {
+	if r.err == nil {
+		_, r.err = io.WriteString(r.trace, line+"\n")
+	}
+}
@@ -0,0 +1,173 @@
+package vertigotest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// A server that plays back a trace recorded by a Recorder. The nth connection it
+// accepts gets the server frames of the nth connection in the trace, and every
+// frame the client sends is checked against the trace. Password messages are only
+// checked for their type, as the Recorder doesn't record passwords.
+//
+// When the client deviates from the trace, the replayer sends it a protocol
+// violation error and closes the connection. Err reports the first deviation.
+type Replayer struct {
+	listener net.Listener
+	conns    map[int][]Frame
+
+	mu       sync.Mutex
+	accepted int
+	clients  map[net.Conn]struct{}
+	closed   bool
+	err      error
+	wg       sync.WaitGroup
+}
+
+// Reads the trace, and starts serving it on a random port on the loopback interface.
+func NewReplayer(trace io.Reader) (*Replayer, error) {
+	frames, err := ReadTrace(trace)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Replayer{
+		listener: listener,
+		conns:    make(map[int][]Frame),
+		clients:  make(map[net.Conn]struct{}),
+	}
+	for _, frame := range frames {
+		r.conns[frame.Conn] = append(r.conns[frame.Conn], frame)
+	}
+
+	r.wg.Add(1)
+	go r.accept()
+	return r, nil
+}
+
+// Returns the address to connect to, for ConnectionInfo.Address.
+func (r *Replayer) Addr() string {
+	return r.listener.Addr().String()
+}
+
+// Returns the first difference between the traffic of the clients and the trace, if any.
+func (r *Replayer) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Stops the replayer, and closes all client connections. Client connections
+// that are closed before they reached the end of the trace aren't reported by Err.
+func (r *Replayer) Close() error {
+	err := r.listener.Close()
+
+	r.mu.Lock()
+	r.closed = true
+	for client := range r.clients {
+		client.Close()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return err
+}
+
+func (r *Replayer) accept() {
+	defer r.wg.Done()
+	for {
+		client, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		r.mu.Lock()
+		r.accepted++
+		frames := r.conns[r.accepted]
+		r.clients[client] = struct{}{}
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer func() {
+				r.mu.Lock()
+				delete(r.clients, client)
+				r.mu.Unlock()
+				client.Close()
+			}()
+
+			if err := replay(client, frames); err != nil {
+				r.fail(err)
+				c := &session{conn: client}
+				c.writeError("08P01", "vertigotest: "+err.Error())
+			}
+		}()
+	}
+}
+
+func (r *Replayer) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil && !r.closed {
+		r.err = err
+	}
+}
+
+// Plays back the frames of a connection, and then waits for the client to disconnect.
+func replay(client net.Conn, frames []Frame) error {
+	for i, frame := range frames {
+		if !frame.FromClient {
+			var err error
+			switch {
+			case frame.Type == 0:
+				_, err = client.Write(frame.Body)
+			default:
+				_, err = client.Write(append(append([]byte{frame.Type}, uint32Bytes(uint32(len(frame.Body)+4))...), frame.Body...))
+			}
+			if err != nil {
+				return fmt.Errorf("connection %d, frame %d: %s", frame.Conn, i+1, err)
+			}
+			continue
+		}
+
+		messageType, body, err := readFrame(client, frame.Type != 0)
+		switch {
+		case err == io.EOF:
+			return fmt.Errorf("connection %d, frame %d: client disconnected, expected message %s", frame.Conn, i+1, frameType(frame.Type))
+		case err != nil:
+			return fmt.Errorf("connection %d, frame %d: %s", frame.Conn, i+1, err)
+		case messageType != frame.Type:
+			return fmt.Errorf("connection %d, frame %d: expected message %s, got %s", frame.Conn, i+1, frameType(frame.Type), frameType(messageType))
+		case messageType != 'p' && !bytes.Equal(body, frame.Body):
+			return fmt.Errorf("connection %d, frame %d: expected message %s with body %q, got %q", frame.Conn, i+1, frameType(frame.Type), frame.Body, body)
+		}
+	}
+
+	if messageType, _, err := readFrame(client, true); err == nil && messageType != 'X' {
+		return fmt.Errorf("connection %d: unexpected message %s after the end of the trace", connNumber(frames), frameType(messageType))
+	}
+	return nil
+}
+
+func frameType(messageType byte) string {
+	if messageType == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%q", messageType)
+}
+
+func connNumber(frames []Frame) int {
+	if len(frames) == 0 {
+		return 0
+	}
+	return frames[0].Conn
+}
@@ -0,0 +1,111 @@
+package vertigotest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lomik/vertigo"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.RequirePassword("secret")
+	s.Expect("SELECT 1").Columns(Column{Name: "one", Type: vertigo.DataTypeInteger}).Row(1)
+	s.Expect("SELECT 2").Error(vertigo.ErrCodeDivisionByZero, "Division by zero")
+
+	var trace bytes.Buffer
+	recorder, err := NewRecorder(s.Addr(), &trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := func(address string) {
+		connection, err := vertigo.Connect(&vertigo.ConnectionInfo{Address: address, User: "dbadmin", Password: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer connection.Close()
+
+		if rs, err := connection.Query("SELECT 1"); err != nil || len(rs.Rows) != 1 || string(rs.Rows[0].Values[0]) != "1" {
+			t.Fatalf("Expected a single row, but found %v, %v", rs, err)
+		}
+		if _, err := connection.Query("SELECT 2"); err == nil || !strings.Contains(err.Error(), "Division by zero") {
+			t.Fatalf("Expected a division by zero error, but found %v", err)
+		}
+	}
+
+	recorder.Comment("Two queries")
+	queries(recorder.Addr())
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(trace.String(), "736563726574") {
+		t.Fatalf("Expected the password not to be recorded, but found trace:\n%s", trace.String())
+	}
+
+	replayer, err := NewReplayer(bytes.NewReader(trace.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayer.Close()
+
+	queries(replayer.Addr())
+	replayer.Close()
+	if err := replayer.Err(); err != nil {
+		t.Fatalf("Expected the replay to match the trace, but found %v", err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	trace := strings.Join([]string{
+		"# Startup, with an AuthenticationOK and ReadyForQuery",
+		"1 F - 00030000 7573657200646261646d696e0000",
+		"1 B R 00000000",
+		"1 B Z 49",
+		"1 F Q 53454c454354203100",
+		"1 B C 53454c4543542031 00",
+		"1 B Z 49",
+	}, "\n")
+
+	replayer, err := NewReplayer(strings.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayer.Close()
+
+	connection, err := vertigo.Connect(&vertigo.ConnectionInfo{Address: replayer.Addr(), User: "dbadmin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	if _, err := connection.Query("SELECT 2"); err == nil {
+		t.Fatalf("Expected the replayer to reject a different query")
+	}
+	if err := replayer.Err(); err == nil || !strings.Contains(err.Error(), "SELECT 1") {
+		t.Fatalf("Expected the difference to be reported, but found %v", err)
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	if _, err := ReadTrace(strings.NewReader("1 X Q 00")); err == nil {
+		t.Fatalf("Expected an invalid direction to fail")
+	}
+
+	frames, err := ReadTrace(strings.NewReader("\n# comment\n2 F Q 5345 4c\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 1 || frames[0].Conn != 2 || !frames[0].FromClient || frames[0].Type != 'Q' || string(frames[0].Body) != "SEL" {
+		t.Fatalf("Expected a single Query frame, but found %v", frames)
+	}
+	if frames[0].String() != "2 F Q 53454c" {
+		t.Fatalf("Expected the frame to format as it was read, but found %q", frames[0].String())
+	}
+}
@@ -0,0 +1,811 @@
+// Package vertigotest implements a scriptable, in-process Vertica server, so code
+// that uses vertigo can be unit tested without a live cluster.
+//
+// The server accepts any user, and answers every statement with the response that
+// was scripted for it:
+//
+//	server, err := vertigotest.NewServer()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer server.Close()
+//
+//	server.Expect("SELECT id, name FROM users").
+//		Columns(vertigotest.Column{Name: "id", Type: vertigo.DataTypeInteger}, vertigotest.Column{Name: "name"}).
+//		Row(1, "alice").
+//		Row(2, nil)
+//
+//	connection, err := vertigo.Connect(&vertigo.ConnectionInfo{Address: server.Addr(), User: "dbadmin"})
+//
+// Statements without a scripted response fail with a syntax error.
+package vertigotest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	protocolVersion   = uint32(3 << 16)
+	sslMagicNumber    = uint32(80877103)
+	cancelRequestCode = uint32(80877102)
+)
+
+// A column of a scripted resultset. Type is the data type OID, like the
+// vertigo.DataType* constants, and defaults to VARCHAR.
+type Column struct {
+	Name         string
+	Type         uint32
+	TypeModifier uint32 // Like the declared length of a VARCHAR plus 4
+}
+
+// The scripted response to the statements matching an expectation.
+type Response struct {
+	match      func(sql string) bool
+	columns    []Column
+	rows       [][]interface{}
+	tag        string
+	err        *responseError
+	delay      time.Duration
+	once       bool
+	params     [][2]string
+	notices    [][2]string
+	copyIn     bool
+	status     byte
+	disconnect bool
+}
+
+type responseError struct {
+	code    string
+	message string
+}
+
+// Sets the columns of the resultset the statement returns.
+func (r *Response) Columns(columns ...Column) *Response {
+	r.columns = columns
+	return r
+}
+
+// Adds a row to the resultset. Nil values are NULL, []byte values are sent as they
+// are, and all other values are sent formatted with fmt.Sprint.
+func (r *Response) Row(values ...interface{}) *Response {
+	r.rows = append(r.rows, values)
+	return r
+}
+
+// Sets the command tag the statement completes with. It defaults to "SELECT n"
+// for statements with columns, and to the first word of the statement otherwise.
+func (r *Response) Tag(tag string) *Response {
+	r.tag = tag
+	return r
+}
+
+// Makes the statement fail with the SQLSTATE code and message, after sending
+// any rows that were scripted.
+func (r *Response) Error(code, message string) *Response {
+	r.err = &responseError{code: code, message: message}
+	return r
+}
+
+// Makes the server report a new value for a parameter before the statement
+// completes, like it does for SET TIME ZONE.
+func (r *Response) Parameter(name, value string) *Response {
+	r.params = append(r.params, [2]string{name, value})
+	return r
+}
+
+// Makes the server send a notice with the message and hint before the statement
+// completes, like it does for PROFILE.
+func (r *Response) Notice(message, hint string) *Response {
+	r.notices = append(r.notices, [2]string{message, hint})
+	return r
+}
+
+// Makes the statement a COPY FROM STDIN, which receives data from the client
+// before it completes. The data is available from Server.CopyData.
+func (r *Response) CopyIn() *Response {
+	r.copyIn = true
+	return r
+}
+
+// Sets the transaction status the server reports after the statement, like 'T'
+// after BEGIN or 'E' after an error in a transaction. It stays in effect for the
+// session until another statement sets it; the initial status is 'I'.
+func (r *Response) TransactionStatus(status byte) *Response {
+	r.status = status
+	return r
+}
+
+// Makes the server close the connection instead of responding, as if the node went
+// down while the statement ran.
+func (r *Response) Disconnect() *Response {
+	r.disconnect = true
+	return r
+}
+
+// Delays the response, to test timeouts and cancellation.
+func (r *Response) Delay(d time.Duration) *Response {
+	r.delay = d
+	return r
+}
+
+// Only uses the response for the first matching statement. Later matching
+// statements get the next expectation that matches them.
+func (r *Response) Once() *Response {
+	r.once = true
+	return r
+}
+
+// An in-process server that speaks enough of the Vertica protocol for vertigo.
+type Server struct {
+	listener net.Listener
+
+	mu         sync.Mutex
+	responses  []*Response
+	statements []string
+	copies     [][]byte
+	parameters map[string]string
+	password   string
+	conns      map[net.Conn]struct{}
+	sessions   int
+	startups   []map[string]string
+	cancels    int
+	prepared   int
+	wg         sync.WaitGroup
+}
+
+// Starts a server listening on a random port on the loopback interface.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		parameters: map[string]string{
+			"server_version": "v7.1.1",
+			"timezone":       "UTC",
+		},
+		conns: make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// Returns the address to connect to, for ConnectionInfo.Address.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Stops the server, and closes all client connections.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// Scripts the response to statements that are exactly sql, ignoring surrounding
+// whitespace and a trailing semicolon. Expectations are matched in the order they
+// were added.
+func (s *Server) Expect(sql string) *Response {
+	sql = normalizeStatement(sql)
+	return s.addResponse(func(statement string) bool { return statement == sql })
+}
+
+// Scripts the response to statements matching the regular expression.
+func (s *Server) ExpectMatch(pattern string) *Response {
+	re := regexp.MustCompile(pattern)
+	return s.addResponse(re.MatchString)
+}
+
+func (s *Server) addResponse(match func(sql string) bool) *Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &Response{match: match}
+	s.responses = append(s.responses, r)
+	return r
+}
+
+// Sets a parameter the server reports to clients when they connect, like
+// "timezone" or "server_version".
+func (s *Server) SetParameter(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.parameters[name] = value
+}
+
+// Makes the server ask clients for a cleartext password, and reject them unless
+// they send this one. An empty password accepts all clients without asking.
+func (s *Server) RequirePassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// Returns all statements the server received, in order.
+func (s *Server) Statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statements...)
+}
+
+// Returns the data the server received for each COPY FROM STDIN statement, in order.
+func (s *Server) CopyData() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.copies...)
+}
+
+// Returns the parameters of the startup messages the server received, like "user"
+// and "database", in the order the sessions were opened.
+func (s *Server) Startups() []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]string(nil), s.startups...)
+}
+
+// Returns the number of cancel requests the server received.
+func (s *Server) CancelRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cancels
+}
+
+// Returns the number of named statements that are prepared in the open sessions, and
+// weren't closed yet.
+func (s *Server) PreparedStatements() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prepared
+}
+
+// Counts a named statement that was prepared or closed.
+func (s *Server) countPrepared(name string, n int) {
+	if name == "" {
+		return
+	}
+	s.mu.Lock()
+	s.prepared += n
+	s.mu.Unlock()
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.sessions++
+		pid := uint32(s.sessions)
+		s.mu.Unlock()
+
+		c := &session{
+			conn:       conn,
+			pid:        pid,
+			status:     'I',
+			statements: make(map[string]string),
+			portals:    make(map[string]string),
+			suspended:  make(map[string]*suspendedPortal),
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				for name := range c.statements {
+					if name != "" {
+						s.prepared--
+					}
+				}
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			s.serve(c)
+		}()
+	}
+}
+
+// Returns the response for the statement, or nil if nothing matches it.
+func (s *Server) respondTo(sql string) *Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statements = append(s.statements, sql)
+	for i, r := range s.responses {
+		if r.match(sql) {
+			if r.once {
+				s.responses = append(s.responses[:i:i], s.responses[i+1:]...)
+			}
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *Server) serve(c *session) {
+	if !s.startup(c) {
+		return
+	}
+
+	for {
+		messageType, body, err := readFrame(c.conn, true)
+		if err != nil {
+			return
+		}
+
+		if c.failed && messageType != 'S' && messageType != 'X' {
+			continue
+		}
+
+		switch messageType {
+		case 'Q':
+			sql := normalizeStatement(readString(body))
+			if sql == "" {
+				c.write('I', nil)
+			} else {
+				s.execute(c, sql, true)
+			}
+			c.write('Z', []byte{c.status})
+
+		case 'P':
+			// Parse: the name of the statement, and its SQL.
+			names := readStrings(body, 2)
+			if _, ok := c.statements[names[0]]; !ok {
+				s.countPrepared(names[0], 1)
+			}
+			c.statements[names[0]] = normalizeStatement(names[1])
+			c.write('1', nil)
+
+		case 'D':
+			if len(body) == 0 {
+				return
+			}
+			if body[0] == 'S' {
+				sql := c.statements[readString(body[1:])]
+				c.write('t', parameterDescription(countPlaceholders(sql)))
+			}
+			c.describe(s.peek(c.statement(body)))
+
+		case 'B':
+			// Bind: the portal, then the statement.
+			names := readStrings(body, 2)
+			c.portals[names[0]] = c.statements[names[1]]
+			c.write('2', nil)
+
+		case 'E':
+			// Execute: the portal, and the maximum number of rows to return.
+			portal := readString(body)
+			c.failed = !s.executePortal(c, portal, readUint32(body[len(portal)+1:]))
+
+		case 'C':
+			name := readString(body[1:])
+			if body[0] == 'P' {
+				delete(c.suspended, name)
+			} else if _, ok := c.statements[name]; ok {
+				delete(c.statements, name)
+				s.countPrepared(name, -1)
+			}
+			c.write('3', nil)
+
+		case 'S':
+			c.failed = false
+			clear(c.suspended)
+			c.write('Z', []byte{c.status})
+
+		case 'H':
+			// Flush: everything is written immediately.
+
+		case 'X':
+			return
+
+		default:
+			c.writeError("08P01", fmt.Sprintf("vertigotest: unsupported message type %q", messageType))
+		}
+	}
+}
+
+// Executes a portal, returning at most maxRows rows, or all rows if it is zero. When
+// rows are left, the portal is suspended, and the next Execute continues with them.
+func (s *Server) executePortal(c *session, portal string, maxRows uint32) bool {
+	p, ok := c.suspended[portal]
+	if !ok {
+		sql := c.portals[portal]
+		r := s.respondTo(sql)
+		if r == nil || maxRows == 0 || int(maxRows) >= len(r.rows) || r.err != nil || r.copyIn {
+			return s.respond(c, r, sql, false)
+		}
+		p = &suspendedPortal{rows: r.rows, tag: r.commandTag(sql)}
+	}
+
+	n := len(p.rows)
+	if maxRows > 0 && int(maxRows) < n {
+		n = int(maxRows)
+	}
+	for _, row := range p.rows[:n] {
+		c.write('D', dataRow(row))
+	}
+	p.rows = p.rows[n:]
+	if len(p.rows) > 0 {
+		c.suspended[portal] = p
+		c.write('s', nil)
+		return true
+	}
+	delete(c.suspended, portal)
+	c.write('C', append([]byte(p.tag), 0))
+	return true
+}
+
+// Runs the statement, and writes its response. Row descriptions are only sent for
+// simple queries; with the extended protocol they are sent on Describe. Returns false
+// if the statement failed.
+func (s *Server) execute(c *session, sql string, simple bool) bool {
+	return s.respond(c, s.respondTo(sql), sql, simple)
+}
+
+// Writes the response to a statement, see execute.
+func (s *Server) respond(c *session, r *Response, sql string, simple bool) bool {
+	if r == nil {
+		c.writeError("42601", fmt.Sprintf("vertigotest: unexpected statement: %s", sql))
+		return false
+	}
+
+	if r.delay > 0 {
+		time.Sleep(r.delay)
+	}
+	if r.disconnect {
+		c.conn.Close()
+		return false
+	}
+	if r.status != 0 {
+		c.status = r.status
+	}
+
+	if r.copyIn && !s.receiveCopyData(c) {
+		return false
+	}
+
+	for _, notice := range r.notices {
+		c.writeNotice(notice[0], notice[1])
+	}
+	if simple && r.columns != nil {
+		c.write('T', rowDescription(r.columns))
+	}
+	for _, row := range r.rows {
+		c.write('D', dataRow(row))
+	}
+
+	if r.err != nil {
+		c.writeError(r.err.code, r.err.message)
+		return false
+	}
+	for _, param := range r.params {
+		c.writeParameter(param[0], param[1])
+	}
+	c.write('C', append([]byte(r.commandTag(sql)), 0))
+	return true
+}
+
+// Receives the data of a COPY FROM STDIN statement until the client is done.
+// Returns false if the client aborted the COPY, after sending it the error.
+func (s *Server) receiveCopyData(c *session) bool {
+	c.write('G', []byte{0, 0, 0})
+
+	var data []byte
+	for {
+		messageType, body, err := readFrame(c.conn, true)
+		if err != nil {
+			return false
+		}
+
+		switch messageType {
+		case 'd':
+			data = append(data, body...)
+
+		case 'c':
+			s.mu.Lock()
+			s.copies = append(s.copies, data)
+			s.mu.Unlock()
+			return true
+
+		case 'f':
+			c.writeError("57014", "COPY from STDIN failed: "+readString(body))
+			return false
+		}
+	}
+}
+
+// Returns the response for the statement without recording it, for Describe.
+func (s *Server) peek(sql string) *Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.responses {
+		if r.match(sql) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Does the startup handshake. Returns false if the connection should be closed.
+func (s *Server) startup(c *session) bool {
+	_, body, err := readFrame(c.conn, false)
+	if err != nil || len(body) < 4 {
+		return false
+	}
+
+	switch code := readUint32(body); code {
+	case sslMagicNumber:
+		if _, err := c.conn.Write([]byte{'N'}); err != nil {
+			return false
+		}
+		return s.startup(c)
+
+	case cancelRequestCode:
+		s.mu.Lock()
+		s.cancels++
+		s.mu.Unlock()
+		return false
+
+	case protocolVersion:
+	default:
+		c.writeError("08P01", fmt.Sprintf("vertigotest: unsupported protocol version %d", code))
+		return false
+	}
+
+	startup := make(map[string]string)
+	for rest := body[4:]; len(rest) > 1; {
+		pair := readStrings(rest, 2)
+		startup[pair[0]] = pair[1]
+		rest = rest[min(len(rest), len(pair[0])+len(pair[1])+2):]
+	}
+
+	s.mu.Lock()
+	s.startups = append(s.startups, startup)
+	password := s.password
+	parameters := make(map[string]string, len(s.parameters))
+	for name, value := range s.parameters {
+		parameters[name] = value
+	}
+	s.mu.Unlock()
+
+	if password != "" {
+		c.write('R', uint32Bytes(3))
+		messageType, body, err := readFrame(c.conn, true)
+		if err != nil || messageType != 'p' {
+			return false
+		}
+		if readString(body) != password {
+			c.writeError("28000", "vertigotest: password authentication failed")
+			return false
+		}
+	}
+
+	c.write('R', uint32Bytes(0))
+	for name, value := range parameters {
+		c.writeParameter(name, value)
+	}
+	c.write('K', append(uint32Bytes(c.pid), uint32Bytes(c.pid*7919)...))
+	c.write('Z', []byte{'I'})
+	return c.err == nil
+}
+
+// Returns the command tag for a statement that completed with the response.
+func (r *Response) commandTag(sql string) string {
+	if r.tag != "" {
+		return r.tag
+	}
+	if r.columns != nil {
+		return fmt.Sprintf("SELECT %d", len(r.rows))
+	}
+	if words := strings.Fields(sql); len(words) > 0 {
+		return strings.ToUpper(words[0])
+	}
+	return ""
+}
+
+// The state of a client connection.
+type session struct {
+	conn       net.Conn
+	pid        uint32 // The PID reported to the client, numbered from 1
+	status     byte   // The transaction status reported in ReadyForQuery
+	err        error
+	failed     bool              // Whether an extended query failed, so messages are skipped until Sync
+	statements map[string]string // The SQL of the prepared statements, by name
+	portals    map[string]string // The SQL of the bound portals, by name
+	suspended  map[string]*suspendedPortal
+}
+
+// The rest of the response of a portal that was suspended after a row limit.
+type suspendedPortal struct {
+	rows [][]interface{}
+	tag  string
+}
+
+// Returns the SQL of the statement or portal a Describe message refers to.
+func (c *session) statement(body []byte) string {
+	if body[0] == 'S' {
+		return c.statements[readString(body[1:])]
+	}
+	return c.portals[readString(body[1:])]
+}
+
+// Describes the resultset of a response, for a Describe message.
+func (c *session) describe(r *Response) {
+	if r == nil || r.columns == nil {
+		c.write('n', nil)
+		return
+	}
+	c.write('T', rowDescription(r.columns))
+}
+
+// Reads a message frame. Untyped frames, like the startup message, only have a
+// length before their body, and are returned with type 0.
+func readFrame(r io.Reader, typed bool) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if !typed {
+		header = header[1:]
+	}
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	var messageType byte
+	if typed {
+		messageType, header = header[0], header[1:]
+	}
+	size := readUint32(header)
+	if size < 4 || size > 1<<30 {
+		return 0, nil, fmt.Errorf("invalid length %d for message %q", size, messageType)
+	}
+
+	body := make([]byte, size-4)
+	_, err := io.ReadFull(r, body)
+	return messageType, body, err
+}
+
+// Writes a message. After a write fails, further writes are skipped.
+func (c *session) write(messageType byte, body []byte) {
+	if c.err != nil {
+		return
+	}
+	frame := append([]byte{messageType}, uint32Bytes(uint32(len(body)+4))...)
+	_, c.err = c.conn.Write(append(frame, body...))
+}
+
+func (c *session) writeParameter(name, value string) {
+	c.write('S', append(append([]byte(name), 0), append([]byte(value), 0)...))
+}
+
+func (c *session) writeError(code, message string) {
+	var body []byte
+	body = append(append(append(body, 'S'), "ERROR"...), 0)
+	body = append(append(append(body, 'C'), code...), 0)
+	body = append(append(append(body, 'M'), message...), 0)
+	c.write('E', append(body, 0))
+}
+
+func (c *session) writeNotice(message, hint string) {
+	var body []byte
+	body = append(append(append(body, 'S'), "NOTICE"...), 0)
+	body = append(append(append(body, 'C'), "00000"...), 0)
+	body = append(append(append(body, 'M'), message...), 0)
+	body = append(append(append(body, 'H'), hint...), 0)
+	c.write('N', append(body, 0))
+}
+
+func rowDescription(columns []Column) []byte {
+	body := uint16Bytes(uint16(len(columns)))
+	for _, column := range columns {
+		dataType := column.Type
+		if dataType == 0 {
+			dataType = 9 // VARCHAR
+		}
+
+		body = append(append(body, column.Name...), 0)
+		body = append(body, uint32Bytes(0)...)                   // Table OID
+		body = append(body, uint16Bytes(0)...)                   // Attribute number
+		body = append(body, uint32Bytes(dataType)...)            // Data type OID
+		body = append(body, uint16Bytes(0xffff)...)              // Data type size
+		body = append(body, uint32Bytes(column.TypeModifier)...) // Type modifier
+		body = append(body, uint16Bytes(0)...)                   // Format code
+	}
+	return body
+}
+
+func dataRow(values []interface{}) []byte {
+	body := uint16Bytes(uint16(len(values)))
+	for _, value := range values {
+		var text []byte
+		switch value := value.(type) {
+		case nil:
+			body = append(body, uint32Bytes(0xffffffff)...)
+			continue
+		case []byte:
+			text = value
+		default:
+			text = []byte(fmt.Sprint(value))
+		}
+		body = append(body, uint32Bytes(uint32(len(text)))...)
+		body = append(body, text...)
+	}
+	return body
+}
+
+func parameterDescription(count int) []byte {
+	body := uint16Bytes(uint16(count))
+	for i := 0; i < count; i++ {
+		body = append(body, uint32Bytes(9)...)
+	}
+	return body
+}
+
+// Counts the ? placeholders in sql that are outside of string literals.
+func countPlaceholders(sql string) int {
+	count, quoted := 0, false
+	for i := 0; i < len(sql); i++ {
+		switch {
+		case sql[i] == '\'':
+			quoted = !quoted
+		case sql[i] == '?' && !quoted:
+			count++
+		}
+	}
+	return count
+}
+
+func normalizeStatement(sql string) string {
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(sql), ";"))
+}
+
+func readString(body []byte) string {
+	return readStrings(body, 1)[0]
+}
+
+// Reads n null-terminated strings from the start of body. Missing strings are empty.
+func readStrings(body []byte, n int) []string {
+	result := make([]string, n)
+	for i := range result {
+		end := bytes.IndexByte(body, 0)
+		if end < 0 {
+			result[i], body = string(body), nil
+			continue
+		}
+		result[i], body = string(body[:end]), body[end+1:]
+	}
+	return result
+}
+
+func readUint32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func uint32Bytes(v uint32) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+func uint16Bytes(v uint16) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
@@ -0,0 +1,139 @@
+package vertigotest
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/lomik/vertigo"
+)
+
+func connect(t *testing.T, s *Server) *vertigo.Connection {
+	connection, err := vertigo.Connect(&vertigo.ConnectionInfo{Address: s.Addr(), User: "dbadmin"})
+	if err != nil {
+		t.Fatalf("Expected to connect, but found %v", err)
+	}
+	return &connection
+}
+
+func TestQuery(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Expect("SELECT id, name FROM users").
+		Columns(Column{Name: "id", Type: vertigo.DataTypeInteger}, Column{Name: "name"}).
+		Row(1, "alice").
+		Row(2, nil)
+
+	connection := connect(t, s)
+	defer connection.Close()
+
+	rs, err := connection.Query("SELECT id, name FROM users;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Result != "SELECT 2" {
+		t.Fatalf("Expected command tag SELECT 2, but found %q", rs.Result)
+	}
+
+	maps, err := rs.Maps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != 2 || maps[0]["id"] != int64(1) || maps[0]["name"] != "alice" || maps[1]["name"] != nil {
+		t.Fatalf("Expected the scripted rows, but found %v", maps)
+	}
+
+	if statements := s.Statements(); len(statements) != 1 || statements[0] != "SELECT id, name FROM users" {
+		t.Fatalf("Expected the statement to be recorded, but found %q", statements)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Expect("INSERT INTO users VALUES (1)").Error(vertigo.ErrCodeUniqueViolation, "Duplicate key").Once()
+	s.Expect("INSERT INTO users VALUES (1)")
+
+	connection := connect(t, s)
+	defer connection.Close()
+
+	var verticaError *vertigo.VerticaError
+	if _, err := connection.Exec("INSERT INTO users VALUES (1)"); !errors.As(err, &verticaError) || verticaError.Code != vertigo.ErrCodeUniqueViolation {
+		t.Fatalf("Expected a unique violation, but found %v", err)
+	}
+
+	result, err := connection.Exec("INSERT INTO users VALUES (1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Command() != "INSERT" {
+		t.Fatalf("Expected command INSERT, but found %q", result.Command())
+	}
+
+	if _, err := connection.Exec("DROP TABLE users"); !errors.As(err, &verticaError) || verticaError.Code != vertigo.ErrCodeSyntaxError {
+		t.Fatalf("Expected a syntax error for an unexpected statement, but found %v", err)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.RequirePassword("secret")
+
+	if _, err := vertigo.Connect(&vertigo.ConnectionInfo{Address: s.Addr(), User: "dbadmin", Password: "wrong"}); err == nil {
+		t.Fatalf("Expected a wrong password to be rejected")
+	}
+
+	connection, err := vertigo.Connect(&vertigo.ConnectionInfo{Address: s.Addr(), User: "dbadmin", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	connection.Close()
+}
+
+func TestPreparedStatement(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Expect("SELECT name FROM users WHERE id = ?").Columns(Column{Name: "name"}).Row("alice")
+
+	connection := connect(t, s)
+	defer connection.Close()
+
+	stmt, err := connection.Prepare("SELECT name FROM users WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmt.ParameterTypes) != 1 || len(stmt.Fields) != 1 {
+		t.Fatalf("Expected 1 parameter and 1 field, but found %v and %v", stmt.ParameterTypes, stmt.Fields)
+	}
+
+	portal, err := stmt.ExecutePortal(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := portal.Next()
+	if err != nil || len(rows) != 1 || string(rows[0].Values[0]) != "alice" {
+		t.Fatalf("Expected the scripted row, but found %v, %v", rows, err)
+	}
+	if _, err := portal.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF, but found %v", err)
+	}
+	if err := stmt.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,69 @@
+package vertigo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Returned when a binary flex table map, like the __raw__ column, is scanned into a VMap.
+var ErrBinaryVMap = errors.New("Cannot decode a binary VMap")
+
+// VMap holds the keys and values of a flex table map, as returned by the
+// MAPTOSTRING function. Values are strings, or VMaps for nested maps.
+//
+// Only the text form of maps is decoded. The __raw__ column of a flex table holds the
+// map in Vertica's internal binary format, which isn't documented and may change
+// between server versions, so scanning it fails with an error matching ErrBinaryVMap.
+// Select MAPTOSTRING(__raw__) instead, for example with the MapToString helper, or
+// look up single keys on the server with MapLookup.
+type VMap map[string]interface{}
+
+func (m *VMap) scanText(field *Field, src []byte) error {
+	if src == nil {
+		*m = nil
+		return nil
+	}
+	if field != nil && isBinaryType(field.DataTypeOID) {
+		return fmt.Errorf("%w in column %s; select MAPTOSTRING(%s) instead", ErrBinaryVMap, field.Name, field.Name)
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(src, &values); err != nil {
+		return fmt.Errorf("Invalid VMap %q", src)
+	}
+	*m = toVMap(values)
+	return nil
+}
+
+func toVMap(values map[string]interface{}) VMap {
+	for key, value := range values {
+		if nested, ok := value.(map[string]interface{}); ok {
+			values[key] = toVMap(nested)
+		}
+	}
+	return VMap(values)
+}
+
+// Returns the string value of a key, and whether the key exists and holds a string.
+func (m VMap) String(key string) (string, bool) {
+	s, ok := m[key].(string)
+	return s, ok
+}
+
+// Returns the nested map of a key, and whether the key exists and holds a map.
+func (m VMap) Map(key string) (VMap, bool) {
+	nested, ok := m[key].(VMap)
+	return nested, ok
+}
+
+// Returns a MAPTOSTRING expression for a flex table map column, which converts
+// the map into text that can be scanned into a VMap.
+func MapToString(column string) string {
+	return "MAPTOSTRING(" + QuoteIdentifier(column) + ")"
+}
+
+// Returns a MAPLOOKUP expression that looks up a key in a flex table map column.
+func MapLookup(column string, key string) string {
+	return "MAPLOOKUP(" + QuoteIdentifier(column) + ", " + quoteString(key) + ")"
+}
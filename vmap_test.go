@@ -0,0 +1,45 @@
+package vertigo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRowScanVMap(t *testing.T) {
+	row := Row{
+		Values: [][]byte{[]byte("{\n\t\"name\": \"Sierra\",\n\t\"address\": {\n\t\t\"city\": \"Boston\"\n\t}\n}"), nil},
+		fields: []Field{{DataTypeOID: DataTypeLongVarchar}, {DataTypeOID: DataTypeLongVarchar}},
+	}
+
+	var m, null VMap
+	if err := row.Scan(&m, &null); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := VMap{"name": "Sierra", "address": VMap{"city": "Boston"}}
+	if !reflect.DeepEqual(m, expected) || null != nil {
+		t.Fatalf("Expected %v and nil, but found %v and %v", expected, m, null)
+	}
+
+	if name, ok := m.String("name"); !ok || name != "Sierra" {
+		t.Errorf("Unexpected name %q", name)
+	}
+	if address, ok := m.Map("address"); !ok || address["city"] != "Boston" {
+		t.Errorf("Unexpected address %v", address)
+	}
+
+	raw := Row{Values: [][]byte{[]byte(`\001`)}, fields: []Field{{Name: "__raw__", DataTypeOID: DataTypeLongVarbinary}}}
+	if err := raw.Scan(&m); !errors.Is(err, ErrBinaryVMap) {
+		t.Errorf("Expected ErrBinaryVMap, but found %v", err)
+	}
+}
+
+func TestMapToString(t *testing.T) {
+	if expr := MapToString("__raw__"); expr != `MAPTOSTRING("__raw__")` {
+		t.Errorf("Unexpected expression %s", expr)
+	}
+	if expr := MapLookup("__raw__", "it's"); expr != `MAPLOOKUP("__raw__", 'it''s')` {
+		t.Errorf("Unexpected expression %s", expr)
+	}
+}
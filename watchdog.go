@@ -0,0 +1,115 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	cancelDialTimeout = 5 * time.Second // How long to wait for the connection used to send a CancelRequest
+	cancelGracePeriod = 5 * time.Second // How long the server gets to honor a CancelRequest before the socket is abandoned
+)
+
+var ErrClientTimeout = errors.New("Client-side statement timeout exceeded")
+
+// Error returned by Query when the client-side watchdog fired before the statement
+// completed. It matches ErrClientTimeout when used with errors.Is.
+type ClientTimeoutError struct {
+	Timeout      time.Duration // The configured client-side timeout.
+	RowsReceived int           // The number of data rows received before the statement was aborted.
+}
+
+func (e *ClientTimeoutError) Error() string {
+	return fmt.Sprintf("%s after %s (%d rows received)", ErrClientTimeout.Error(), e.Timeout, e.RowsReceived)
+}
+
+func (e *ClientTimeoutError) Unwrap() error {
+	return ErrClientTimeout
+}
+
+// A watchdog fires once after the configured timeout, or when a context is done. It
+// asks the server to cancel the running statement, and arms a read deadline on the
+// socket so the statement will be aborted even if the server does not respond to the
+// cancel request. Until then, the response is read as usual, so the connection stays
+// in sync and can be reused.
+type watchdog struct {
+	l       sync.Mutex
+	release func() bool // Stops the timer or context that fires the watchdog
+	socket  net.Conn
+	fired   bool
+	stopped bool
+}
+
+// Starts a watchdog for the statement that is about to be sent on this connection.
+func (c *Connection) startWatchdog(timeout time.Duration) *watchdog {
+	w, fire := c.newWatchdog()
+	w.release = time.AfterFunc(timeout, fire).Stop
+	return w
+}
+
+// Starts a watchdog that fires when the context is done, for the statement that is
+// about to be sent on this connection.
+func (c *Connection) watchContext(ctx context.Context) *watchdog {
+	w, fire := c.newWatchdog()
+	w.release = context.AfterFunc(ctx, fire)
+	return w
+}
+
+// Returns a watchdog for the statement that is about to be sent, and the function
+// that fires it.
+func (c *Connection) newWatchdog() (*watchdog, func()) {
+	w := &watchdog{socket: c.socket}
+	dial, address, pid, key := c.dialFunc(), c.address, c.backendPid, c.backendKey
+
+	return w, func() {
+		w.l.Lock()
+		defer w.l.Unlock()
+
+		if w.stopped {
+			return
+		}
+
+		w.fired = true
+		sendCancelRequest(dial, address, pid, key)
+		w.socket.SetReadDeadline(time.Now().Add(cancelGracePeriod))
+	}
+}
+
+// Stops the watchdog, and returns whether it fired. After this function returns
+// the watchdog is guaranteed to no longer touch the connection.
+func (w *watchdog) stop() bool {
+	w.release()
+
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	w.stopped = true
+	if w.fired {
+		w.socket.SetReadDeadline(time.Time{})
+	}
+	return w.fired
+}
+
+// Asks the server to cancel the statement that is running on this connection.
+// Failures are ignored, the statement will then just run to completion.
+func (c *Connection) cancelRunningQuery() {
+	sendCancelRequest(c.dialFunc(), c.address, c.backendPid, c.backendKey)
+}
+
+// Sends a CancelRequest for the backend identified by pid and key. The request
+// is sent over a new, unencrypted connection, as required by the protocol. It is
+// opened with dial, unless that is nil.
+func sendCancelRequest(dial DialFunc, address string, pid, key uint32) error {
+	socket, err := dialTimeout(dial, address, cancelDialTimeout)
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+
+	_, _, err = sendMessage(socket, CancelRequestMessage{Pid: pid, Key: key})
+	return err
+}
@@ -0,0 +1,15 @@
+package vertigo
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// Writes a raw frame dump of a message to the WireDump writer of the connection,
+// if it has one. The length is the one in the frame, which includes itself.
+func (c *Connection) dumpMessage(direction string, messageType byte, body []byte) {
+	if c.config.WireDump == nil {
+		return
+	}
+	fmt.Fprintf(c.config.WireDump, "%s %s length=%d\n%s", direction, messageTypeName(messageType), len(body)+4, hex.Dump(body))
+}
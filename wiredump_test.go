@@ -0,0 +1,24 @@
+package vertigo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDumpMessage(t *testing.T) {
+	var buffer bytes.Buffer
+	c := &Connection{config: &ConnectionInfo{WireDump: &buffer}}
+	c.dumpMessage("=>", 'Q', []byte("SELECT 1\x00"))
+
+	expected := "=> Q length=13\n00000000  53 45 4c 45 43 54 20 31  00                       |SELECT 1.|\n"
+	if buffer.String() != expected {
+		t.Fatalf("Expected %q, but found %q", expected, buffer.String())
+	}
+
+	buffer.Reset()
+	c.dumpMessage("<=", 'I', nil)
+	if !strings.HasPrefix(buffer.String(), "<= I length=4\n") {
+		t.Fatalf("Unexpected dump of an empty message %q", buffer.String())
+	}
+}